@@ -0,0 +1,39 @@
+package av1
+
+type OBUType int
+
+const (
+	OBUSequenceHeader       = OBUType(1)
+	OBUTemporalDelimiter    = OBUType(2)
+	OBUFrameHeader          = OBUType(3)
+	OBUTileGroup            = OBUType(4)
+	OBUMetadata             = OBUType(5)
+	OBUFrame                = OBUType(6)
+	OBURedundantFrameHeader = OBUType(7)
+	OBUTileList             = OBUType(8)
+	OBUPadding              = OBUType(15)
+)
+
+type FrameType int
+
+const (
+	FrameTypeKey       = FrameType(0)
+	FrameTypeInter     = FrameType(1)
+	FrameTypeIntraOnly = FrameType(2)
+	FrameTypeSwitch    = FrameType(3)
+)
+
+const (
+	// ColorPrimariesBT709 6.4.2 Color config semantics
+	ColorPrimariesBT709           = 1
+	ColorPrimariesUnspecified     = 2
+	TransferCharacteristicsSRGB   = 13
+	TransferCharacteristicsUnspec = 2
+	MatrixCoefficientsIdentity    = 0
+	MatrixCoefficientsUnspecified = 2
+	ChromaSamplePositionUnknown   = 0
+	MaxOperatingPoints            = 32
+	SelectScreenContentTools      = 2
+	SelectIntegerMv               = 2
+	maxLeb128Bytes                = 8
+)
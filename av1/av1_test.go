@@ -0,0 +1,118 @@
+package av1
+
+import (
+	"bytes"
+	"github.com/lkmio/avformat/bufio"
+	"github.com/lkmio/avformat/utils"
+	"testing"
+)
+
+// 1920x1080, main profile, level 4.0(8), 8bit 4:2:0
+func newSequenceHeaderPayload() []byte {
+	writer := &bufio.BitsWriter{Data: make([]byte, 16)}
+	writer.Write(3, 0)  // seq_profile
+	writer.Write(1, 0)  // still_picture
+	writer.Write(1, 0)  // reduced_still_picture_header
+	writer.Write(1, 0)  // timing_info_present_flag
+	writer.Write(1, 1)  // initial_display_delay_present_flag
+	writer.Write(5, 0)  // operating_points_cnt_minus_1
+	writer.Write(12, 0) // operating_point_idc
+	writer.Write(5, 8)  // seq_level_idx
+	writer.Write(1, 1)  // seq_tier
+	writer.Write(1, 1)  // initial_display_delay_present_for_this_op
+	writer.Write(4, 9)  // initial_display_delay_minus_1
+	writer.Write(4, 10) // frame_width_bits_minus_1
+	writer.Write(4, 10) // frame_height_bits_minus_1
+	writer.Write(11, 1919)
+	writer.Write(11, 1079)
+	writer.Write(1, 0) // frame_id_numbers_present_flag
+	writer.Write(3, 0) // use_128x128_superblock, enable_filter_intra, enable_intra_edge_filter
+	writer.Write(4, 0)
+	writer.Write(1, 1) // enable_order_hint
+	writer.Write(2, 0)
+	writer.Write(1, 1) // seq_choose_screen_content_tools
+	writer.Write(1, 1) // seq_choose_integer_mv
+	writer.Write(3, 6) // order_hint_bits_minus_1
+	writer.Write(3, 0) // enable_superres, enable_cdef, enable_restoration
+	writer.Write(1, 0) // high_bitdepth
+	writer.Write(1, 0) // mono_chrome
+	writer.Write(1, 0) // color_description_present_flag
+	writer.Write(1, 0) // color_range
+	writer.Write(2, 1) // chroma_sample_position
+	writer.Write(1, 0) // separate_uv_delta_q
+	writer.Write(1, 0) // film_grain_params_present
+	writer.Write(1, 1) // trailing_one_bit
+	return writer.Data[:(writer.Offset+7)/8]
+}
+
+func newOBU(obuType OBUType, payload []byte) []byte {
+	header := OBUHeader{Type: obuType, HasSizeField: true}
+	obu := make([]byte, header.Size()+Leb128Size(uint64(len(payload)))+len(payload))
+	writeOBU(obu, header, payload)
+	return obu
+}
+
+func TestLeb128(t *testing.T) {
+	buffer := make([]byte, maxLeb128Bytes)
+	for _, value := range []uint64{0, 1, 127, 128, 300, 16383, 16384, 1<<32 - 1} {
+		n := PutLeb128(buffer, value)
+		utils.Assert(n == Leb128Size(value))
+
+		v, size, err := ReadLeb128(buffer[:n])
+		if err != nil {
+			panic(err)
+		}
+
+		utils.Assert(v == value && size == n)
+	}
+}
+
+func TestAV1(t *testing.T) {
+	sequenceHeaderOBU := newOBU(OBUSequenceHeader, newSequenceHeaderPayload())
+	// show_existing_frame=0, frame_type=KEY_FRAME, show_frame=1
+	keyFrame := append(append(newOBU(OBUTemporalDelimiter, nil), sequenceHeaderOBU...), newOBU(OBUFrame, []byte{0x10, 0xAA, 0xBB})...)
+	// frame_type=INTER_FRAME
+	interFrame := append(newOBU(OBUTemporalDelimiter, nil), newOBU(OBUFrame, []byte{0x30, 0xCC})...)
+
+	utils.Assert(IsKeyFrame(keyFrame))
+	utils.Assert(!IsKeyFrame(interFrame))
+
+	extraData, err := ExtractSequenceHeader(keyFrame)
+	if err != nil {
+		panic(err)
+	}
+
+	utils.Assert(bytes.Equal(extraData, sequenceHeaderOBU))
+
+	annexB, err := LowOverheadToAnnexB(keyFrame)
+	if err != nil {
+		panic(err)
+	}
+
+	lowOverhead, err := AnnexBToLowOverhead(annexB)
+	if err != nil {
+		panic(err)
+	}
+
+	utils.Assert(bytes.Equal(lowOverhead, keyFrame))
+
+	record, header, err := NewAV1CodecConfigurationRecord(extraData)
+	if err != nil {
+		panic(err)
+	}
+
+	utils.Assert(header.Width() == 1920 && header.Height() == 1080)
+	utils.Assert(header.BitDepth == 8 && header.SubsamplingX == 1 && header.SubsamplingY == 1)
+	utils.Assert(header.SeqLevelIdx() == 8 && header.SeqTier() == 1 && header.OrderHintBits == 7)
+
+	data := record.Marshal()
+	utils.Assert(bytes.Equal(data[:4], []byte{0x81, 0x08, 0x8D, 0x19}))
+
+	other := AV1CodecConfigurationRecord{}
+	if err = other.Unmarshal(data); err != nil {
+		panic(err)
+	}
+
+	utils.Assert(bytes.Equal(other.Marshal(), data))
+	utils.Assert(other.InitialPresentationDelayMinusOne == 9 && bytes.Equal(other.ConfigOBUs, extraData))
+}
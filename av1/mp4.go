@@ -0,0 +1,147 @@
+package av1
+
+import (
+	"fmt"
+)
+
+/*
+aligned (8) class AV1CodecConfigurationRecord {
+	unsigned int (1) marker = 1;
+	unsigned int (7) version = 1;
+	unsigned int (3) seq_profile;
+	unsigned int (5) seq_level_idx_0;
+	unsigned int (1) seq_tier_0;
+	unsigned int (1) high_bitdepth;
+	unsigned int (1) twelve_bit;
+	unsigned int (1) monochrome;
+	unsigned int (1) chroma_subsampling_x;
+	unsigned int (1) chroma_subsampling_y;
+	unsigned int (2) chroma_sample_position;
+	unsigned int (3) reserved = 0;
+
+	unsigned int (1) initial_presentation_delay_present;
+	if (initial_presentation_delay_present) {
+		unsigned int (4) initial_presentation_delay_minus_one;
+	} else {
+		unsigned int (4) reserved = 0;
+	}
+
+	unsigned int (8) configOBUs[];
+}
+*/
+
+type AV1CodecConfigurationRecord struct {
+	Version                          byte
+	SeqProfile                       byte
+	SeqLevelIdx0                     byte
+	SeqTier0                         byte
+	HighBitDepth                     byte
+	TwelveBit                        byte
+	MonoChrome                       byte
+	ChromaSubsamplingX               byte
+	ChromaSubsamplingY               byte
+	ChromaSamplePosition             byte
+	InitialPresentationDelayPresent  byte
+	InitialPresentationDelayMinusOne byte
+
+	ConfigOBUs []byte // Low Overhead Bitstream Format, 携带size字段
+}
+
+func (a *AV1CodecConfigurationRecord) Marshal() []byte {
+	data := make([]byte, 4+len(a.ConfigOBUs))
+	version := a.Version
+	if version == 0 {
+		version = 1
+	}
+
+	data[0] = 0x80 | version&0x7F
+	data[1] = a.SeqProfile<<5 | a.SeqLevelIdx0&0x1F
+	data[2] = a.SeqTier0<<7 | a.HighBitDepth<<6 | a.TwelveBit<<5 | a.MonoChrome<<4 | a.ChromaSubsamplingX<<3 | a.ChromaSubsamplingY<<2 | a.ChromaSamplePosition&0x3
+	if a.InitialPresentationDelayPresent == 1 {
+		data[3] = 0x10 | a.InitialPresentationDelayMinusOne&0xF
+	}
+
+	copy(data[4:], a.ConfigOBUs)
+	return data
+}
+
+func (a *AV1CodecConfigurationRecord) Unmarshal(data []byte) error {
+	if len(data) < 4 {
+		return fmt.Errorf("invalid data length %d", len(data))
+	} else if data[0]&0x80 == 0 {
+		return fmt.Errorf("invalid av1C marker")
+	}
+
+	a.Version = data[0] & 0x7F
+	a.SeqProfile = data[1] >> 5
+	a.SeqLevelIdx0 = data[1] & 0x1F
+	a.SeqTier0 = data[2] >> 7
+	a.HighBitDepth = data[2] >> 6 & 0x1
+	a.TwelveBit = data[2] >> 5 & 0x1
+	a.MonoChrome = data[2] >> 4 & 0x1
+	a.ChromaSubsamplingX = data[2] >> 3 & 0x1
+	a.ChromaSubsamplingY = data[2] >> 2 & 0x1
+	a.ChromaSamplePosition = data[2] & 0x3
+	a.InitialPresentationDelayPresent = data[3] >> 4 & 0x1
+	if a.InitialPresentationDelayPresent == 1 {
+		a.InitialPresentationDelayMinusOne = data[3] & 0xF
+	}
+
+	a.ConfigOBUs = data[4:]
+	return nil
+}
+
+// SequenceHeader 解析ConfigOBUs中的sequence header
+func (a *AV1CodecConfigurationRecord) SequenceHeader() (SequenceHeader, error) {
+	var payload []byte
+	err := SplitOBUs(a.ConfigOBUs, func(header OBUHeader, obu, data []byte) bool {
+		if header.Type == OBUSequenceHeader {
+			payload = data
+			return false
+		}
+
+		return true
+	})
+
+	if err != nil {
+		return SequenceHeader{}, err
+	} else if payload == nil {
+		return SequenceHeader{}, fmt.Errorf("not find sequence header for AV1")
+	}
+
+	return ParseSequenceHeader(payload)
+}
+
+func boolToByte(v bool) byte {
+	if v {
+		return 1
+	}
+
+	return 0
+}
+
+// NewAV1CodecConfigurationRecord 根据sequence header obu(携带size字段)创建av1C
+func NewAV1CodecConfigurationRecord(sequenceHeaderOBU []byte) (*AV1CodecConfigurationRecord, *SequenceHeader, error) {
+	record := &AV1CodecConfigurationRecord{ConfigOBUs: sequenceHeaderOBU}
+	s, err := record.SequenceHeader()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	record.Version = 1
+	record.SeqProfile = byte(s.SeqProfile)
+	record.SeqLevelIdx0 = byte(s.SeqLevelIdx())
+	record.SeqTier0 = byte(s.SeqTier())
+	record.HighBitDepth = boolToByte(s.HighBitDepth)
+	record.TwelveBit = boolToByte(s.TwelveBit)
+	record.MonoChrome = boolToByte(s.MonoChrome)
+	record.ChromaSubsamplingX = byte(s.SubsamplingX)
+	record.ChromaSubsamplingY = byte(s.SubsamplingY)
+	record.ChromaSamplePosition = byte(s.ChromaSamplePosition)
+	if point := s.OperatingPoints[0]; point.InitialDisplayDelayPresent {
+		record.InitialPresentationDelayPresent = 1
+		record.InitialPresentationDelayMinusOne = byte(point.InitialDisplayDelayMinusOne)
+	}
+
+	return record, &s, nil
+}
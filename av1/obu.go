@@ -0,0 +1,319 @@
+package av1
+
+import (
+	"fmt"
+)
+
+/*
+obu_header() {
+	obu_forbidden_bit	f(1)
+	obu_type	f(4)
+	obu_extension_flag	f(1)
+	obu_has_size_field	f(1)
+	obu_reserved_1bit	f(1)
+	if ( obu_extension_flag == 1 ) {
+		temporal_id	f(3)
+		spatial_id	f(2)
+		extension_header_reserved_3bits	f(3)
+	}
+}
+*/
+
+type OBUHeader struct {
+	Type          OBUType
+	ExtensionFlag bool
+	HasSizeField  bool
+	TemporalID    int
+	SpatialID     int
+}
+
+// Size 返回obu header长度
+func (h OBUHeader) Size() int {
+	if h.ExtensionFlag {
+		return 2
+	}
+
+	return 1
+}
+
+// Marshal 写入obu header, 返回写入长度
+func (h OBUHeader) Marshal(dst []byte) int {
+	dst[0] = byte(h.Type&0xF) << 3
+	if h.HasSizeField {
+		dst[0] |= 0x2
+	}
+
+	if !h.ExtensionFlag {
+		return 1
+	}
+
+	dst[0] |= 0x4
+	dst[1] = byte(h.TemporalID&0x7)<<5 | byte(h.SpatialID&0x3)<<3
+	return 2
+}
+
+func ParseOBUHeader(data []byte) (OBUHeader, error) {
+	header := OBUHeader{}
+	if len(data) < 1 {
+		return header, fmt.Errorf("need more data")
+	} else if data[0]&0x80 != 0 {
+		return header, fmt.Errorf("obu forbidden bit is set")
+	}
+
+	header.Type = OBUType(data[0] >> 3 & 0xF)
+	header.ExtensionFlag = data[0]&0x4 != 0
+	header.HasSizeField = data[0]&0x2 != 0
+	if header.ExtensionFlag {
+		if len(data) < 2 {
+			return header, fmt.Errorf("need more data")
+		}
+
+		header.TemporalID = int(data[1] >> 5)
+		header.SpatialID = int(data[1] >> 3 & 0x3)
+	}
+
+	return header, nil
+}
+
+// ReadLeb128 读取leb128编码的值, 返回值和读取的字节数
+func ReadLeb128(data []byte) (uint64, int, error) {
+	var value uint64
+	for i := 0; i < maxLeb128Bytes; i++ {
+		if i >= len(data) {
+			return 0, 0, fmt.Errorf("need more data")
+		}
+
+		value |= uint64(data[i]&0x7F) << (i * 7)
+		if data[i]&0x80 == 0 {
+			return value, i + 1, nil
+		}
+	}
+
+	return 0, 0, fmt.Errorf("invalid leb128")
+}
+
+func Leb128Size(value uint64) int {
+	size := 1
+	for value >>= 7; value != 0; value >>= 7 {
+		size++
+	}
+
+	return size
+}
+
+// PutLeb128 写入leb128编码的值, 返回写入长度
+func PutLeb128(dst []byte, value uint64) int {
+	var n int
+	for {
+		b := byte(value & 0x7F)
+		value >>= 7
+		if value != 0 {
+			b |= 0x80
+		}
+
+		dst[n] = b
+		n++
+		if value == 0 {
+			return n
+		}
+	}
+}
+
+// SplitOBUs 遍历Low Overhead Bitstream Format的OBU, obu包含header, payload不包含header和size字段
+func SplitOBUs(data []byte, cb func(header OBUHeader, obu, payload []byte) bool) error {
+	for len(data) > 0 {
+		header, err := ParseOBUHeader(data)
+		if err != nil {
+			return err
+		}
+
+		offset := header.Size()
+		size := len(data) - offset
+		if header.HasSizeField {
+			value, n, err := ReadLeb128(data[offset:])
+			if err != nil {
+				return err
+			}
+
+			offset += n
+			size = int(value)
+		}
+
+		if size < 0 || len(data)-offset < size {
+			return fmt.Errorf("invalid obu size %d", size)
+		}
+
+		if !cb(header, data[:offset+size], data[offset:offset+size]) {
+			return nil
+		}
+
+		data = data[offset+size:]
+	}
+
+	return nil
+}
+
+// splitAnnexBUnits 遍历AnnexB中以leb128长度开头的单元
+func splitAnnexBUnits(data []byte, cb func(unit []byte) error) error {
+	for len(data) > 0 {
+		size, n, err := ReadLeb128(data)
+		if err != nil {
+			return err
+		} else if uint64(len(data)-n) < size {
+			return fmt.Errorf("invalid annexb unit size %d", size)
+		}
+
+		if err = cb(data[n : n+int(size)]); err != nil {
+			return err
+		}
+
+		data = data[n+int(size):]
+	}
+
+	return nil
+}
+
+// writeOBU 使用新的header写入obu payload, 返回写入长度
+func writeOBU(dst []byte, header OBUHeader, payload []byte) int {
+	n := header.Marshal(dst)
+	if header.HasSizeField {
+		n += PutLeb128(dst[n:], uint64(len(payload)))
+	}
+
+	n += copy(dst[n:], payload)
+	return n
+}
+
+// LowOverheadToAnnexB 将一个temporal unit从Low Overhead Bitstream Format转为AnnexB格式(temporal_unit/frame_unit/obu_length)
+func LowOverheadToAnnexB(data []byte) ([]byte, error) {
+	var frameUnits [][]byte
+	var frameUnit []byte
+	var frameSeen bool
+
+	err := SplitOBUs(data, func(header OBUHeader, obu, payload []byte) bool {
+		// 每个frame unit只包含一帧, 遇到下一帧的frame header时开始新的frame unit
+		if header.Type == OBUFrame || header.Type == OBUFrameHeader {
+			if frameSeen {
+				frameUnits = append(frameUnits, frameUnit)
+				frameUnit = nil
+			}
+
+			frameSeen = true
+		}
+
+		header.HasSizeField = false
+		obuSize := header.Size() + len(payload)
+		buffer := make([]byte, Leb128Size(uint64(obuSize))+obuSize)
+		n := PutLeb128(buffer, uint64(obuSize))
+		writeOBU(buffer[n:], header, payload)
+		frameUnit = append(frameUnit, buffer...)
+		return true
+	})
+
+	if err != nil {
+		return nil, err
+	} else if frameUnit != nil {
+		frameUnits = append(frameUnits, frameUnit)
+	}
+
+	var temporalUnitSize int
+	for _, unit := range frameUnits {
+		temporalUnitSize += Leb128Size(uint64(len(unit))) + len(unit)
+	}
+
+	dst := make([]byte, Leb128Size(uint64(temporalUnitSize))+temporalUnitSize)
+	n := PutLeb128(dst, uint64(temporalUnitSize))
+	for _, unit := range frameUnits {
+		n += PutLeb128(dst[n:], uint64(len(unit)))
+		n += copy(dst[n:], unit)
+	}
+
+	return dst, nil
+}
+
+// AnnexBToLowOverhead 将AnnexB格式的temporal unit转为Low Overhead Bitstream Format, 每个OBU都携带size字段
+func AnnexBToLowOverhead(data []byte) ([]byte, error) {
+	dst := make([]byte, 0, len(data)+64)
+	err := splitAnnexBUnits(data, func(temporalUnit []byte) error {
+		return splitAnnexBUnits(temporalUnit, func(frameUnit []byte) error {
+			return splitAnnexBUnits(frameUnit, func(obu []byte) error {
+				header, err := ParseOBUHeader(obu)
+				if err != nil {
+					return err
+				}
+
+				payload := obu[header.Size():]
+				// AnnexB中的OBU也可以携带size字段
+				if header.HasSizeField {
+					size, n, err := ReadLeb128(payload)
+					if err != nil {
+						return err
+					} else if uint64(len(payload)-n) < size {
+						return fmt.Errorf("invalid obu size %d", size)
+					}
+
+					payload = payload[n : n+int(size)]
+				}
+
+				header.HasSizeField = true
+				buffer := make([]byte, header.Size()+Leb128Size(uint64(len(payload)))+len(payload))
+				writeOBU(buffer, header, payload)
+				dst = append(dst, buffer...)
+				return nil
+			})
+		})
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return dst, nil
+}
+
+// ExtractSequenceHeader 从temporal unit中提取sequence header obu(携带size字段)
+func ExtractSequenceHeader(data []byte) ([]byte, error) {
+	var sequenceHeader []byte
+	err := SplitOBUs(data, func(header OBUHeader, obu, payload []byte) bool {
+		if header.Type != OBUSequenceHeader {
+			return true
+		}
+
+		header.HasSizeField = true
+		sequenceHeader = make([]byte, header.Size()+Leb128Size(uint64(len(payload)))+len(payload))
+		writeOBU(sequenceHeader, header, payload)
+		return false
+	})
+
+	if err != nil {
+		return nil, err
+	} else if sequenceHeader == nil {
+		return nil, fmt.Errorf("not find sequence header for AV1")
+	}
+
+	return sequenceHeader, nil
+}
+
+// IsKeyFrame 解析temporal unit中第一个frame header, 判断是否是关键帧
+func IsKeyFrame(data []byte) bool {
+	var key bool
+	var sequenceHeader *SequenceHeader
+
+	_ = SplitOBUs(data, func(header OBUHeader, obu, payload []byte) bool {
+		switch header.Type {
+		case OBUSequenceHeader:
+			if s, err := ParseSequenceHeader(payload); err == nil {
+				sequenceHeader = &s
+			}
+			return true
+		case OBUFrame, OBUFrameHeader:
+			frameHeader, err := ParseFrameHeader(payload, sequenceHeader)
+			key = err == nil && !frameHeader.ShowExistingFrame && frameHeader.FrameType == FrameTypeKey
+			return false
+		default:
+			return true
+		}
+	})
+
+	return key
+}
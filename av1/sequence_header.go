@@ -0,0 +1,287 @@
+package av1
+
+import (
+	"fmt"
+	"github.com/lkmio/avformat/bufio"
+)
+
+type OperatingPoint struct {
+	IDC                         int
+	SeqLevelIdx                 int
+	SeqTier                     int
+	DecoderModelPresent         bool
+	InitialDisplayDelayPresent  bool
+	InitialDisplayDelayMinusOne int
+}
+
+// SequenceHeader 5.5 Sequence header OBU syntax
+type SequenceHeader struct {
+	SeqProfile                 int
+	StillPicture               bool
+	ReducedStillPictureHeader  bool
+	TimingInfoPresent          bool
+	DecoderModelInfoPresent    bool
+	InitialDisplayDelayPresent bool
+	OperatingPoints            []OperatingPoint
+
+	FrameWidthBits             int
+	FrameHeightBits            int
+	MaxFrameWidth              int
+	MaxFrameHeight             int
+	FrameIDNumbersPresent      bool
+	Use128x128Superblock       bool
+	EnableOrderHint            bool
+	OrderHintBits              int
+	SeqForceScreenContentTools int
+	EnableSuperres             bool
+	EnableCdef                 bool
+	EnableRestoration          bool
+
+	// color_config
+	HighBitDepth            bool
+	TwelveBit               bool
+	BitDepth                int
+	MonoChrome              bool
+	ColorDescriptionPresent bool
+	ColorPrimaries          int
+	TransferCharacteristics int
+	MatrixCoefficients      int
+	ColorRange              int
+	SubsamplingX            int
+	SubsamplingY            int
+	ChromaSamplePosition    int
+	SeparateUVDeltaQ        bool
+
+	FilmGrainParamsPresent bool
+}
+
+// SeqLevelIdx 返回operating point 0的level
+func (s *SequenceHeader) SeqLevelIdx() int {
+	return s.OperatingPoints[0].SeqLevelIdx
+}
+
+func (s *SequenceHeader) SeqTier() int {
+	return s.OperatingPoints[0].SeqTier
+}
+
+func (s *SequenceHeader) Width() int {
+	return s.MaxFrameWidth
+}
+
+func (s *SequenceHeader) Height() int {
+	return s.MaxFrameHeight
+}
+
+type FrameHeader struct {
+	ShowExistingFrame bool
+	FrameType         FrameType
+	ShowFrame         bool
+}
+
+func readFlag(reader *bufio.BitsReader) bool {
+	return reader.Read(1) == 1
+}
+
+// readUvlc 4.10.3 uvlc()
+func readUvlc(reader *bufio.BitsReader) uint64 {
+	var leadingZeros int
+	for reader.Offset < len(reader.Data)*8 && !readFlag(reader) {
+		leadingZeros++
+	}
+
+	if leadingZeros >= 32 {
+		return 1<<32 - 1
+	}
+
+	return reader.Read(leadingZeros) + (1 << leadingZeros) - 1
+}
+
+// ParseSequenceHeader 解析sequence header obu的payload(不包含obu header和size)
+func ParseSequenceHeader(payload []byte) (SequenceHeader, error) {
+	s := SequenceHeader{}
+	reader := &bufio.BitsReader{Data: payload}
+
+	s.SeqProfile = int(reader.Read(3))
+	if s.SeqProfile > 2 {
+		return s, fmt.Errorf("invalid seq_profile %d", s.SeqProfile)
+	}
+
+	s.StillPicture = readFlag(reader)
+	s.ReducedStillPictureHeader = readFlag(reader)
+	if s.ReducedStillPictureHeader {
+		s.OperatingPoints = []OperatingPoint{{SeqLevelIdx: int(reader.Read(5))}}
+	} else {
+		var bufferDelayLength int
+		if s.TimingInfoPresent = readFlag(reader); s.TimingInfoPresent {
+			// timing_info(): num_units_in_display_tick, time_scale
+			reader.Seek(64)
+			if equalPictureInterval := readFlag(reader); equalPictureInterval {
+				readUvlc(reader)
+			}
+
+			if s.DecoderModelInfoPresent = readFlag(reader); s.DecoderModelInfoPresent {
+				// decoder_model_info()
+				bufferDelayLength = int(reader.Read(5)) + 1
+				reader.Seek(32 + 5 + 5)
+			}
+		}
+
+		s.InitialDisplayDelayPresent = readFlag(reader)
+		count := int(reader.Read(5)) + 1
+		s.OperatingPoints = make([]OperatingPoint, count)
+		for i := 0; i < count; i++ {
+			point := &s.OperatingPoints[i]
+			point.IDC = int(reader.Read(12))
+			point.SeqLevelIdx = int(reader.Read(5))
+			if point.SeqLevelIdx > 7 {
+				point.SeqTier = int(reader.Read(1))
+			}
+
+			if s.DecoderModelInfoPresent {
+				if point.DecoderModelPresent = readFlag(reader); point.DecoderModelPresent {
+					// operating_parameters_info(): decoder_buffer_delay, encoder_buffer_delay, low_delay_mode_flag
+					reader.Seek(bufferDelayLength*2 + 1)
+				}
+			}
+
+			if s.InitialDisplayDelayPresent {
+				if point.InitialDisplayDelayPresent = readFlag(reader); point.InitialDisplayDelayPresent {
+					point.InitialDisplayDelayMinusOne = int(reader.Read(4))
+				}
+			}
+		}
+	}
+
+	s.FrameWidthBits = int(reader.Read(4)) + 1
+	s.FrameHeightBits = int(reader.Read(4)) + 1
+	s.MaxFrameWidth = int(reader.Read(s.FrameWidthBits)) + 1
+	s.MaxFrameHeight = int(reader.Read(s.FrameHeightBits)) + 1
+	if !s.ReducedStillPictureHeader {
+		s.FrameIDNumbersPresent = readFlag(reader)
+	}
+
+	if s.FrameIDNumbersPresent {
+		// delta_frame_id_length_minus_2, additional_frame_id_length_minus_1
+		reader.Seek(4 + 3)
+	}
+
+	s.Use128x128Superblock = readFlag(reader)
+	// enable_filter_intra, enable_intra_edge_filter
+	reader.Seek(2)
+
+	s.SeqForceScreenContentTools = SelectScreenContentTools
+	if !s.ReducedStillPictureHeader {
+		// enable_interintra_compound, enable_masked_compound, enable_warped_motion, enable_dual_filter
+		reader.Seek(4)
+		if s.EnableOrderHint = readFlag(reader); s.EnableOrderHint {
+			// enable_jnt_comp, enable_ref_frame_mvs
+			reader.Seek(2)
+		}
+
+		if seqChooseScreenContentTools := readFlag(reader); !seqChooseScreenContentTools {
+			s.SeqForceScreenContentTools = int(reader.Read(1))
+		}
+
+		if s.SeqForceScreenContentTools > 0 {
+			if seqChooseIntegerMv := readFlag(reader); !seqChooseIntegerMv {
+				reader.Seek(1)
+			}
+		}
+
+		if s.EnableOrderHint {
+			s.OrderHintBits = int(reader.Read(3)) + 1
+		}
+	}
+
+	s.EnableSuperres = readFlag(reader)
+	s.EnableCdef = readFlag(reader)
+	s.EnableRestoration = readFlag(reader)
+	parseColorConfig(reader, &s)
+	s.FilmGrainParamsPresent = readFlag(reader)
+
+	if reader.Offset > len(payload)*8 {
+		return s, fmt.Errorf("need more data")
+	}
+
+	return s, nil
+}
+
+// parseColorConfig 5.5.2 Color config syntax
+func parseColorConfig(reader *bufio.BitsReader, s *SequenceHeader) {
+	s.HighBitDepth = readFlag(reader)
+	s.BitDepth = 8
+	if s.SeqProfile == 2 && s.HighBitDepth {
+		s.TwelveBit = readFlag(reader)
+		if s.TwelveBit {
+			s.BitDepth = 12
+		} else {
+			s.BitDepth = 10
+		}
+	} else if s.HighBitDepth {
+		s.BitDepth = 10
+	}
+
+	if s.SeqProfile != 1 {
+		s.MonoChrome = readFlag(reader)
+	}
+
+	s.ColorPrimaries = ColorPrimariesUnspecified
+	s.TransferCharacteristics = TransferCharacteristicsUnspec
+	s.MatrixCoefficients = MatrixCoefficientsUnspecified
+	if s.ColorDescriptionPresent = readFlag(reader); s.ColorDescriptionPresent {
+		s.ColorPrimaries = int(reader.Read(8))
+		s.TransferCharacteristics = int(reader.Read(8))
+		s.MatrixCoefficients = int(reader.Read(8))
+	}
+
+	s.ChromaSamplePosition = ChromaSamplePositionUnknown
+	if s.MonoChrome {
+		s.ColorRange = int(reader.Read(1))
+		s.SubsamplingX, s.SubsamplingY = 1, 1
+		return
+	} else if s.ColorPrimaries == ColorPrimariesBT709 && s.TransferCharacteristics == TransferCharacteristicsSRGB && s.MatrixCoefficients == MatrixCoefficientsIdentity {
+		s.ColorRange = 1
+	} else {
+		s.ColorRange = int(reader.Read(1))
+		if s.SeqProfile == 0 {
+			s.SubsamplingX, s.SubsamplingY = 1, 1
+		} else if s.SeqProfile == 2 {
+			if s.BitDepth == 12 {
+				s.SubsamplingX = int(reader.Read(1))
+				if s.SubsamplingX == 1 {
+					s.SubsamplingY = int(reader.Read(1))
+				}
+			} else {
+				s.SubsamplingX = 1
+			}
+		}
+
+		if s.SubsamplingX == 1 && s.SubsamplingY == 1 {
+			s.ChromaSamplePosition = int(reader.Read(2))
+		}
+	}
+
+	s.SeparateUVDeltaQ = readFlag(reader)
+}
+
+// ParseFrameHeader 解析frame header obu的开始部分, 只读取到show_frame. sequenceHeader为空时按非reduced_still_picture_header处理.
+func ParseFrameHeader(payload []byte, sequenceHeader *SequenceHeader) (FrameHeader, error) {
+	header := FrameHeader{}
+	if sequenceHeader != nil && sequenceHeader.ReducedStillPictureHeader {
+		header.FrameType = FrameTypeKey
+		header.ShowFrame = true
+		return header, nil
+	} else if len(payload) < 1 {
+		return header, fmt.Errorf("need more data")
+	}
+
+	reader := &bufio.BitsReader{Data: payload}
+	if header.ShowExistingFrame = readFlag(reader); header.ShowExistingFrame {
+		header.ShowFrame = true
+		return header, nil
+	}
+
+	header.FrameType = FrameType(reader.Read(2))
+	header.ShowFrame = readFlag(reader)
+	return header, nil
+}
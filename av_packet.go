@@ -1,6 +1,7 @@
 package avformat
 
 import (
+	"github.com/lkmio/avformat/avc"
 	"github.com/lkmio/avformat/utils"
 	"sync"
 )
@@ -51,7 +52,13 @@ type AVPacket struct {
 	PacketType    PacketType // 视频打包模式
 	dataAVCC      []byte
 	dataAnnexB    []byte
+	dataADTS      []byte // raw AAC添加ADTSHeader后的数据
 	OnBufferAlloc func(size int) []byte
+
+	SEI       []avc.SEIMessage // 调用ParsePacketSEI后有效
+	seiParsed bool
+
+	Discontinuity bool // 时间戳发生跳变, 已重新映射到连续的时间轴
 }
 
 func (pkt *AVPacket) ConvertDts(dstTimebase int) int64 {
@@ -119,12 +126,16 @@ func FreePacket(packet *AVPacket) {
 	packet.Data = nil
 	packet.dataAVCC = nil
 	packet.dataAnnexB = nil
+	packet.dataADTS = nil
 	packet.OnBufferAlloc = nil
+	packet.SEI = nil
+	packet.seiParsed = false
 	packet.BufferIndex = 0
 	packet.Dts = 0
 	packet.Pts = 0
 	packet.Duration = 0
 	packet.Key = false
+	packet.Discontinuity = false
 	packet.CreatedTime = 0
 	packet.Index = 0
 	packet.Timebase = 0
@@ -25,6 +25,21 @@ for (i=0; i< numOfPictureParameterSets; i++) {
 unsigned int(16) pictureParameterSetLength;
 bit(8*pictureParameterSetLength) pictureParameterSetNALUnit;
 }
+if( profile_idc == 100 || profile_idc == 110 ||
+profile_idc == 122 || profile_idc == 144 )
+{
+bit(6) reserved = ‘111111’b;
+unsigned int(2) chroma_format;
+bit(5) reserved = ‘11111’b;
+unsigned int(3) bit_depth_luma_minus8;
+bit(5) reserved = ‘11111’b;
+unsigned int(3) bit_depth_chroma_minus8;
+unsigned int(8) numOfSequenceParameterSetExt;
+for (i=0; i< numOfSequenceParameterSetExt; i++) {
+unsigned int(16) sequenceParameterSetExtLength;
+bit(8*sequenceParameterSetExtLength) sequenceParameterSetExtNALUnit;
+}
+}
 }
 */
 
@@ -35,10 +50,58 @@ type AVCDecoderConfigurationRecord struct {
 	AVCLevelIndication   byte
 	LengthSizeMinusOne   byte
 
-	SPSList [][]byte // AnnexB格式
-	PPSList [][]byte
+	// High/High 10/High 4:2:2/High 4:4:4的扩展字段
+	ChromaFormat         byte
+	BitDepthLumaMinus8   byte
+	BitDepthChromaMinus8 byte
+
+	SPSList    [][]byte // AnnexB格式
+	PPSList    [][]byte
+	SPSExtList [][]byte
+
+	omitExtension bool // Unmarshal的数据缺少扩展字段, 部分muxer生成的High profile avcC没有扩展字段
+}
+
+// hasExtension profile_idc为100/110/122/144时包含扩展字段
+func hasExtension(profileIdc byte) bool {
+	return profileIdc == 100 || profileIdc == 110 || profileIdc == 122 || profileIdc == 144
+}
+
+func appendNALUnits(dst []byte, list [][]byte) []byte {
+	for _, nalu := range list {
+		noStartCodeNALU := RemoveStartCode(nalu)
+		dst = binary.BigEndian.AppendUint16(dst, uint16(len(noStartCodeNALU)))
+		dst = append(dst, noStartCodeNALU...)
+	}
+
+	return dst
 }
 
+func readNALUnits(reader bufio.BytesReader, count int) ([][]byte, error) {
+	var list [][]byte
+	for i := 0; i < count; i++ {
+		length, err := reader.ReadUint16()
+		if err != nil {
+			return nil, err
+		}
+
+		bytes, err := reader.ReadBytes(int(length))
+		if err != nil {
+			return nil, err
+		}
+
+		// 添加start code
+		nalu := make([]byte, len(bytes)+4)
+		binary.BigEndian.PutUint32(nalu, 0x1)
+		copy(nalu[4:], bytes)
+		list = append(list, nalu)
+	}
+
+	return list, nil
+}
+
+// Marshal 生成avcC, profile和level取自第一个SPS. High profile的扩展字段从SPS解析得到, SPSExtList不为空时一并写入.
+// 没有经过Unmarshal的record, 长度字段使用4字节.
 func (a *AVCDecoderConfigurationRecord) Marshal(spsList, ppsList [][]byte) ([]byte, error) {
 	if len(spsList) == 0 {
 		return nil, fmt.Errorf("sps cannot be null")
@@ -47,50 +110,51 @@ func (a *AVCDecoderConfigurationRecord) Marshal(spsList, ppsList [][]byte) ([]by
 		return nil, fmt.Errorf("pps cannot be null")
 	}
 
-	bytes := make([]byte, 1024)
-	writer := bufio.NewBytesWriter(bytes)
-	if err := writer.Seek(5); err != nil {
-		return nil, err
+	noStartCodeSps := RemoveStartCode(spsList[0])
+	if len(noStartCodeSps) < 4 {
+		return nil, fmt.Errorf("invalid sps length %d", len(noStartCodeSps))
 	}
 
-	if err := writer.WriteUint8(byte(len(spsList) & 0x1F)); err != nil {
-		return nil, err
+	if a.ConfigurationVersion == 0 {
+		a.ConfigurationVersion = 1
+		a.LengthSizeMinusOne = 3
 	}
 
-	var noStartCodeSps []byte
-	for _, sps := range spsList {
-		noStartCodeSps = RemoveStartCode(sps)
-		if err := writer.WriteUint16(uint16(len(noStartCodeSps))); err != nil {
-			return nil, err
+	a.AVCProfileIndication = noStartCodeSps[1]
+	a.ProfileCompatibility = noStartCodeSps[2]
+	a.AVCLevelIndication = noStartCodeSps[3]
+	extension := hasExtension(a.AVCProfileIndication) && !a.omitExtension
+	if extension {
+		sps, err := ParseSPS(spsList[0])
+		if err != nil {
+			return nil, fmt.Errorf("h264parser: parse SPS failed(%s)", err)
 		}
 
-		if err := writer.Write(noStartCodeSps); err != nil {
-			return nil, err
-		}
+		a.ChromaFormat = byte(sps.ChromaFormatIdc)
+		a.BitDepthLumaMinus8 = byte(sps.BitDepthLumaMinus8)
+		a.BitDepthChromaMinus8 = byte(sps.BitDepthChromaMinus8)
 	}
 
-	if err := writer.WriteUint8(byte(len(ppsList))); err != nil {
-		return nil, err
+	size := 7 + 4
+	for _, list := range [][][]byte{spsList, ppsList, a.SPSExtList} {
+		for _, nalu := range list {
+			size += 2 + len(RemoveStartCode(nalu))
+		}
 	}
 
-	for _, pps := range ppsList {
-		noStartCodePps := RemoveStartCode(pps)
-		if err := writer.WriteUint16(uint16(len(noStartCodePps))); err != nil {
-			return nil, err
-		}
+	data := make([]byte, 0, size)
+	data = append(data, a.ConfigurationVersion, a.AVCProfileIndication, a.ProfileCompatibility, a.AVCLevelIndication, 0xFC|a.LengthSizeMinusOne&0x3)
+	data = append(data, 0xE0|byte(len(spsList)&0x1F))
+	data = appendNALUnits(data, spsList)
+	data = append(data, byte(len(ppsList)))
+	data = appendNALUnits(data, ppsList)
 
-		if err := writer.Write(noStartCodePps); err != nil {
-			return nil, err
-		}
+	if extension {
+		data = append(data, 0xFC|a.ChromaFormat&0x3, 0xF8|a.BitDepthLumaMinus8&0x7, 0xF8|a.BitDepthChromaMinus8&0x7, byte(len(a.SPSExtList)))
+		data = appendNALUnits(data, a.SPSExtList)
 	}
 
-	bytes[0] = 1
-	bytes[1] = noStartCodeSps[1]
-	bytes[2] = noStartCodeSps[2]
-	bytes[3] = noStartCodeSps[3]
-	bytes[4] = 0xff
-	bytes[5] = 0xE0 | byte(len(spsList))
-	return bytes[:writer.Offset()], nil
+	return data, nil
 }
 
 func (a *AVCDecoderConfigurationRecord) Unmarshal(data []byte) error {
@@ -115,23 +179,8 @@ func (a *AVCDecoderConfigurationRecord) Unmarshal(data []byte) error {
 		return fmt.Errorf("no sps found")
 	}
 
-	for i := 0; i < int(spsCount); i++ {
-		length, err := reader.ReadUint16()
-		if err != nil {
-			return err
-		}
-
-		bytes, err := reader.ReadBytes(int(length))
-		if err != nil {
-			return err
-		}
-
-		// 添加start code
-		sps := make([]byte, len(bytes)+4)
-		binary.BigEndian.PutUint32(sps, 0x1)
-		copy(sps[4:], bytes)
-
-		a.SPSList = append(a.SPSList, sps)
+	if a.SPSList, err = readNALUnits(reader, int(spsCount)); err != nil {
+		return err
 	}
 
 	ppsCount, err = reader.ReadUint8()
@@ -142,23 +191,8 @@ func (a *AVCDecoderConfigurationRecord) Unmarshal(data []byte) error {
 		return fmt.Errorf("no pps found")
 	}
 
-	for i := 0; i < int(ppsCount); i++ {
-		length, err := reader.ReadUint16()
-		if err != nil {
-			return err
-		}
-
-		bytes, err := reader.ReadBytes(int(length))
-		if err != nil {
-			return err
-		}
-
-		// 添加start code
-		pps := make([]byte, len(bytes)+4)
-		binary.BigEndian.PutUint32(pps, 0x1)
-		copy(pps[4:], bytes)
-
-		a.PPSList = append(a.PPSList, pps)
+	if a.PPSList, err = readNALUnits(reader, int(ppsCount)); err != nil {
+		return err
 	}
 
 	a.ConfigurationVersion = data[0]
@@ -166,7 +200,21 @@ func (a *AVCDecoderConfigurationRecord) Unmarshal(data []byte) error {
 	a.ProfileCompatibility = data[2]
 	a.AVCLevelIndication = data[3]
 	a.LengthSizeMinusOne = data[4] & 0x3
-	return nil
+	a.ChromaFormat, a.BitDepthLumaMinus8, a.BitDepthChromaMinus8 = 1, 0, 0
+	a.SPSExtList = nil
+
+	// 扩展字段可能缺失
+	a.omitExtension = hasExtension(a.AVCProfileIndication) && reader.ReadableBytes() < 4
+	if !hasExtension(a.AVCProfileIndication) || a.omitExtension {
+		return nil
+	}
+
+	extension, _ := reader.ReadBytes(4)
+	a.ChromaFormat = extension[0] & 0x3
+	a.BitDepthLumaMinus8 = extension[1] & 0x7
+	a.BitDepthChromaMinus8 = extension[2] & 0x7
+	a.SPSExtList, err = readNALUnits(reader, int(extension[3]))
+	return err
 }
 
 // ExtraDataToAnnexB AVCDecoderConfigurationRecord转AnnexB
@@ -176,20 +224,12 @@ func ExtraDataToAnnexB(data []byte) ([]byte, error) {
 		return nil, err
 	}
 
-	bytes := make([]byte, 1024)
-	writer := bufio.NewBytesWriter(bytes)
-
-	for _, sps := range record.SPSList {
-		if err := writer.Write(sps); err != nil {
-			return nil, err
-		}
-	}
-
-	for _, pps := range record.PPSList {
-		if err := writer.Write(pps); err != nil {
-			return nil, err
+	var bytes []byte
+	for _, list := range [][][]byte{record.SPSList, record.SPSExtList, record.PPSList} {
+		for _, nalu := range list {
+			bytes = append(bytes, nalu...)
 		}
 	}
 
-	return bytes[:writer.Offset()], nil
+	return bytes, nil
 }
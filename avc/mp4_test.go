@@ -1,8 +1,10 @@
 package avc
 
 import (
+	"bytes"
 	"encoding/hex"
 	"fmt"
+	"github.com/lkmio/avformat/utils"
 	"testing"
 )
 
@@ -33,3 +35,63 @@ func TestAVCDecoderConfigurationRecord(t *testing.T) {
 
 	println(fmt.Sprintf("width:%d height:%d", sps.Width, sps.Height))
 }
+
+func TestMarshalAVCDecoderConfigurationRecord(t *testing.T) {
+	// baseline, 没有扩展字段
+	data := "0142c01effe100186742c01eda01e0089f961000000300100000030320f162ea01000568ce0f2c80"
+	extraData, err := hex.DecodeString(data)
+	if err != nil {
+		panic(err)
+	}
+
+	record := AVCDecoderConfigurationRecord{}
+	if err = record.Unmarshal(extraData); err != nil {
+		panic(err)
+	}
+
+	marshal, err := record.Marshal(record.SPSList, record.PPSList)
+	if err != nil {
+		panic(err)
+	}
+
+	utils.Assert(bytes.Equal(marshal, extraData))
+
+	// high profile, 包含扩展字段和超过1024字节的SPS扩展
+	spsExt := append([]byte{0x6D}, make([]byte, 2048)...)
+	record = AVCDecoderConfigurationRecord{SPSExtList: [][]byte{spsExt}}
+	marshal, err = record.Marshal([][]byte{newTestVUISPS(true)}, [][]byte{newTestPPS(), newTestPPS()})
+	if err != nil {
+		panic(err)
+	}
+
+	utils.Assert(marshal[0] == 1 && marshal[1] == 100 && marshal[3] == 40 && marshal[4] == 0xFF && marshal[5] == 0xE1)
+
+	unmarshal := AVCDecoderConfigurationRecord{}
+	if err = unmarshal.Unmarshal(marshal); err != nil {
+		panic(err)
+	}
+
+	utils.Assert(unmarshal.ChromaFormat == 1 && unmarshal.BitDepthLumaMinus8 == 0 && len(unmarshal.PPSList) == 2 && len(unmarshal.SPSExtList) == 1)
+	utils.Assert(bytes.Equal(RemoveStartCode(unmarshal.SPSExtList[0]), spsExt))
+
+	remarshal, err := unmarshal.Marshal(unmarshal.SPSList, unmarshal.PPSList)
+	if err != nil {
+		panic(err)
+	}
+
+	utils.Assert(bytes.Equal(remarshal, marshal))
+
+	// 缺少扩展字段的high profile avcC, 保持原样
+	truncated := marshal[:len(marshal)-(4+2+len(spsExt))]
+	unmarshal = AVCDecoderConfigurationRecord{}
+	if err = unmarshal.Unmarshal(truncated); err != nil {
+		panic(err)
+	}
+
+	remarshal, err = unmarshal.Marshal(unmarshal.SPSList, unmarshal.PPSList)
+	if err != nil {
+		panic(err)
+	}
+
+	utils.Assert(unmarshal.SPSExtList == nil && bytes.Equal(remarshal, truncated))
+}
@@ -0,0 +1,155 @@
+package avc
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/lkmio/avformat/bufio"
+)
+
+// moreRBSPData offset之后是否还有rbsp_trailing_bits以外的数据
+func moreRBSPData(data []byte, offset int) bool {
+	for i := len(data) - 1; i >= 0; i-- {
+		if data[i] == 0 {
+			continue
+		}
+
+		// 最后一个值为1的bit是rbsp_stop_one_bit
+		var trailing int
+		for data[i]>>trailing&1 == 0 {
+			trailing++
+		}
+
+		return offset < (i+1)*8-trailing-1
+	}
+
+	return false
+}
+
+// PPS pic_parameter_set_rbsp, 7.3.2.2
+type PPS struct {
+	Id                                    uint
+	SPSId                                 uint
+	EntropyCodingModeFlag                 bool
+	BottomFieldPicOrderInFramePresentFlag bool
+	NumSliceGroups                        int
+	NumRefIdxL0DefaultActive              int
+	NumRefIdxL1DefaultActive              int
+	WeightedPredFlag                      bool
+	WeightedBipredIdc                     int
+	PicInitQp                             int
+	PicInitQs                             int
+	ChromaQpIndexOffset                   int
+	DeblockingFilterControlPresentFlag    bool
+	ConstrainedIntraPredFlag              bool
+	RedundantPicCntPresentFlag            bool
+
+	// High profile的扩展字段
+	Transform8x8ModeFlag        bool
+	PicScalingMatrixPresentFlag bool
+	SecondChromaQpIndexOffset   int
+}
+
+// IsCABAC 是否使用CABAC熵编码, 否则为CAVLC
+func (p *PPS) IsCABAC() bool {
+	return p.EntropyCodingModeFlag
+}
+
+func ParsePPS(data []byte) (*PPS, error) {
+	data = EBSP2RBSP(RemoveStartCode(data))
+	if len(data) < 2 || data[0]&0x1F != H264NalPPS {
+		return nil, fmt.Errorf("invalid pps nal unit")
+	}
+
+	r := &bufio.GolombBitReader{R: bytes.NewReader(data[1:])}
+	pps := &PPS{}
+	pps.Id = uint(r.UE())
+	pps.SPSId = uint(r.UE())
+	if pps.Id > 255 || pps.SPSId > 31 {
+		return nil, fmt.Errorf("invalid pps id %d or sps id %d", pps.Id, pps.SPSId)
+	}
+
+	pps.EntropyCodingModeFlag = r.Flag()
+	pps.BottomFieldPicOrderInFramePresentFlag = r.Flag()
+	pps.NumSliceGroups = r.UE() + 1
+	if pps.NumSliceGroups > 8 {
+		return nil, fmt.Errorf("invalid num_slice_groups %d", pps.NumSliceGroups)
+	} else if pps.NumSliceGroups > 1 {
+		switch sliceGroupMapType := r.UE(); sliceGroupMapType {
+		case 0:
+			// run_length_minus1
+			for i := 0; i < pps.NumSliceGroups; i++ {
+				r.UE()
+			}
+		case 2:
+			// top_left, bottom_right
+			for i := 0; i < pps.NumSliceGroups-1; i++ {
+				r.UE()
+				r.UE()
+			}
+		case 3, 4, 5:
+			// slice_group_change_direction_flag, slice_group_change_rate_minus1
+			r.Skip(1)
+			r.UE()
+		case 6:
+			picSizeInMapUnits := r.UE() + 1
+			bits := 0
+			for 1<<bits < pps.NumSliceGroups {
+				bits++
+			}
+
+			// slice_group_id
+			r.Skip(picSizeInMapUnits * bits)
+		}
+	}
+
+	pps.NumRefIdxL0DefaultActive = r.UE() + 1
+	pps.NumRefIdxL1DefaultActive = r.UE() + 1
+	pps.WeightedPredFlag = r.Flag()
+	pps.WeightedBipredIdc = r.Bits(2)
+	pps.PicInitQp = 26 + r.SE()
+	pps.PicInitQs = 26 + r.SE()
+	pps.ChromaQpIndexOffset = r.SE()
+	pps.DeblockingFilterControlPresentFlag = r.Flag()
+	pps.ConstrainedIntraPredFlag = r.Flag()
+	pps.RedundantPicCntPresentFlag = r.Flag()
+	pps.SecondChromaQpIndexOffset = pps.ChromaQpIndexOffset
+	if moreRBSPData(data[1:], r.Offset()) {
+		pps.Transform8x8ModeFlag = r.Flag()
+		pps.PicScalingMatrixPresentFlag = r.Flag()
+		if pps.PicScalingMatrixPresentFlag {
+			// 不知道SPS的chroma_format_idc, 按非4:4:4处理
+			count := 6
+			if pps.Transform8x8ModeFlag {
+				count += 2
+			}
+
+			for i := 0; i < count; i++ {
+				// pic_scaling_list_present_flag
+				if !r.Flag() {
+					continue
+				}
+
+				size := 16
+				if i >= 6 {
+					size = 64
+				}
+
+				lastScale, nextScale := 8, 8
+				for j := 0; j < size && nextScale != 0; j++ {
+					nextScale = (lastScale + r.SE() + 256) % 256
+					if nextScale != 0 {
+						lastScale = nextScale
+					}
+				}
+			}
+		}
+
+		pps.SecondChromaQpIndexOffset = r.SE()
+	}
+
+	if r.Err() != nil {
+		return nil, fmt.Errorf("invalid pps")
+	}
+
+	return pps, nil
+}
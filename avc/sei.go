@@ -0,0 +1,442 @@
+package avc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"github.com/lkmio/avformat/bufio"
+)
+
+// SEI payloadType, H.264 Annex D和H.265 Annex D中定义相同
+const (
+	SEITypeBufferingPeriod              = 0
+	SEITypePicTiming                    = 1
+	SEITypeUserDataRegisteredITUTT35    = 4
+	SEITypeUserDataUnregistered         = 5
+	SEITypeRecoveryPoint                = 6
+	SEITypeMasteringDisplayColourVolume = 137
+	SEITypeContentLightLevelInfo        = 144
+)
+
+// SEIMessage sei_message(), Payload已去除防竞争字节
+type SEIMessage struct {
+	PayloadType int
+	Payload     []byte
+}
+
+type UserDataUnregistered struct {
+	UUID [16]byte
+	Data []byte
+}
+
+type UserDataRegisteredITUTT35 struct {
+	CountryCode          byte
+	CountryCodeExtension byte // CountryCode为0xFF时有效
+	Data                 []byte
+}
+
+type RecoveryPoint struct {
+	RecoveryFrameCnt      int
+	ExactMatchFlag        bool
+	BrokenLinkFlag        bool
+	ChangingSliceGroupIdc int
+}
+
+// MasteringDisplayColourVolume 色度坐标单位0.00002, 亮度单位0.0001cd/m2
+type MasteringDisplayColourVolume struct {
+	DisplayPrimariesX            [3]uint16
+	DisplayPrimariesY            [3]uint16
+	WhitePointX                  uint16
+	WhitePointY                  uint16
+	MaxDisplayMasteringLuminance uint32
+	MinDisplayMasteringLuminance uint32
+}
+
+type ContentLightLevelInfo struct {
+	MaxContentLightLevel    uint16
+	MaxPicAverageLightLevel uint16
+}
+
+// PicTimingParams 解析pic_timing需要的SPS VUI参数
+type PicTimingParams struct {
+	CpbDpbDelaysPresentFlag bool // nal_hrd_parameters_present_flag || vcl_hrd_parameters_present_flag
+	CpbRemovalDelayLength   int  // cpb_removal_delay_length_minus1 + 1
+	DpbOutputDelayLength    int  // dpb_output_delay_length_minus1 + 1
+	PicStructPresentFlag    bool
+	TimeOffsetLength        int
+}
+
+type ClockTimestamp struct {
+	CtType             int
+	NuitFieldBasedFlag bool
+	CountingType       int
+	DiscontinuityFlag  bool
+	CntDroppedFlag     bool
+	NFrames            int
+	Seconds            int
+	Minutes            int
+	Hours              int
+	TimeOffset         int
+}
+
+type PicTiming struct {
+	CpbRemovalDelay int
+	DpbOutputDelay  int
+	PicStruct       int
+	ClockTimestamps []*ClockTimestamp // clock_timestamp_flag为0的项为nil
+}
+
+// numClockTS Table D-1
+var numClockTS = [9]int{1, 1, 1, 2, 2, 3, 3, 2, 3}
+
+// EBSP2RBSP 去除防竞争字节, 没有防竞争字节时返回原切片
+func EBSP2RBSP(data []byte) []byte {
+	if bytes.Index(data, []byte{0x0, 0x0, 0x3}) < 0 {
+		return data
+	}
+
+	rbsp := make([]byte, 0, len(data))
+	var zeros int
+	for _, b := range data {
+		if zeros >= 2 && b == 0x3 {
+			zeros = 0
+			continue
+		}
+
+		if b == 0 {
+			zeros++
+		} else {
+			zeros = 0
+		}
+
+		rbsp = append(rbsp, b)
+	}
+
+	return rbsp
+}
+
+// RBSP2EBSP 添加防竞争字节, 不需要添加时返回原切片
+func RBSP2EBSP(rbsp []byte) []byte {
+	var ebsp []byte
+	var zeros int
+	for i, b := range rbsp {
+		if zeros >= 2 && b <= 0x3 {
+			if ebsp == nil {
+				ebsp = make([]byte, i, len(rbsp)+len(rbsp)/2)
+				copy(ebsp, rbsp[:i])
+			}
+
+			ebsp = append(ebsp, 0x3)
+			zeros = 0
+		}
+
+		if b == 0 {
+			zeros++
+		} else {
+			zeros = 0
+		}
+
+		if ebsp != nil {
+			ebsp = append(ebsp, b)
+		}
+	}
+
+	if ebsp == nil {
+		return rbsp
+	}
+
+	return ebsp
+}
+
+// isTrailingBits 剩余的数据是否只有rbsp_trailing_bits
+func isTrailingBits(data []byte) bool {
+	if data[0] != 0x80 {
+		return false
+	}
+
+	for _, b := range data[1:] {
+		if b != 0 {
+			return false
+		}
+	}
+
+	return true
+}
+
+// ParseSEIMessages 解析去除NALU头和防竞争字节后的sei_rbsp()
+func ParseSEIMessages(rbsp []byte) ([]SEIMessage, error) {
+	var messages []SEIMessage
+	for len(rbsp) > 0 && !isTrailingBits(rbsp) {
+		var values [2]int
+		for i := range values {
+			for {
+				if len(rbsp) == 0 {
+					return messages, fmt.Errorf("invalid sei message")
+				}
+
+				b := rbsp[0]
+				rbsp = rbsp[1:]
+				values[i] += int(b)
+				if b != 0xFF {
+					break
+				}
+			}
+		}
+
+		if values[1] > len(rbsp) {
+			return messages, fmt.Errorf("invalid sei payload size %d", values[1])
+		}
+
+		messages = append(messages, SEIMessage{PayloadType: values[0], Payload: rbsp[:values[1]]})
+		rbsp = rbsp[values[1]:]
+	}
+
+	return messages, nil
+}
+
+// MarshalSEIMessages 生成sei_rbsp(), 包含rbsp_trailing_bits, 不添加防竞争字节
+func MarshalSEIMessages(messages []SEIMessage) []byte {
+	var rbsp []byte
+	for _, message := range messages {
+		for _, value := range [2]int{message.PayloadType, len(message.Payload)} {
+			for ; value >= 0xFF; value -= 0xFF {
+				rbsp = append(rbsp, 0xFF)
+			}
+
+			rbsp = append(rbsp, byte(value))
+		}
+
+		rbsp = append(rbsp, message.Payload...)
+	}
+
+	return append(rbsp, 0x80)
+}
+
+// NewSEINalU 生成不包含起始码的SEI NALU
+func NewSEINalU(messages ...SEIMessage) []byte {
+	return append([]byte{H264NalSEI}, RBSP2EBSP(MarshalSEIMessages(messages))...)
+}
+
+// ParseSEI 解析SEI NALU, 可以包含起始码
+func ParseSEI(nalu []byte) ([]SEIMessage, error) {
+	nalu = RemoveStartCode(nalu)
+	if len(nalu) < 2 || nalu[0]&0x1F != H264NalSEI {
+		return nil, fmt.Errorf("invalid sei nal unit")
+	}
+
+	return ParseSEIMessages(EBSP2RBSP(nalu[1:]))
+}
+
+// SplitAVCC 拆分长度前缀的NALU, 回调的NALU不包含长度
+func SplitAVCC(data []byte, lengthSize int, cb func(nalu []byte)) error {
+	if lengthSize < 1 || lengthSize > 4 {
+		return fmt.Errorf("invalid nalu length size %d", lengthSize)
+	}
+
+	for len(data) > 0 {
+		if len(data) < lengthSize {
+			return fmt.Errorf("invalid data")
+		}
+
+		var size int
+		for i := 0; i < lengthSize; i++ {
+			size = size<<8 | int(data[i])
+		}
+
+		data = data[lengthSize:]
+		if size > len(data) {
+			return fmt.Errorf("invalid nalu size %d", size)
+		}
+
+		cb(data[:size])
+		data = data[size:]
+	}
+
+	return nil
+}
+
+func parseSEINalUnits(nalUnits [][]byte) ([]SEIMessage, error) {
+	var messages []SEIMessage
+	for _, nalu := range nalUnits {
+		result, err := ParseSEI(nalu)
+		messages = append(messages, result...)
+		if err != nil {
+			return messages, err
+		}
+	}
+
+	return messages, nil
+}
+
+// ExtractSEI 解析annexb帧中所有SEI NALU
+func ExtractSEI(annexB []byte) ([]SEIMessage, error) {
+	var nalUnits [][]byte
+	SplitNalU(annexB, func(nalu []byte) {
+		if nalu = RemoveStartCode(nalu); len(nalu) > 0 && nalu[0]&0x1F == H264NalSEI {
+			nalUnits = append(nalUnits, nalu)
+		}
+	})
+
+	return parseSEINalUnits(nalUnits)
+}
+
+// ExtractSEIFromAVCC 解析avcc帧中所有SEI NALU
+func ExtractSEIFromAVCC(avcc []byte, lengthSize int) ([]SEIMessage, error) {
+	var nalUnits [][]byte
+	err := SplitAVCC(avcc, lengthSize, func(nalu []byte) {
+		if len(nalu) > 0 && nalu[0]&0x1F == H264NalSEI {
+			nalUnits = append(nalUnits, nalu)
+		}
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return parseSEINalUnits(nalUnits)
+}
+
+func ParseUserDataUnregistered(payload []byte) (*UserDataUnregistered, error) {
+	if len(payload) < 16 {
+		return nil, fmt.Errorf("invalid user data unregistered size %d", len(payload))
+	}
+
+	data := &UserDataUnregistered{Data: payload[16:]}
+	copy(data.UUID[:], payload)
+	return data, nil
+}
+
+// NewUserDataUnregistered 创建user_data_unregistered消息, 用于携带自定义数据
+func NewUserDataUnregistered(uuid [16]byte, data []byte) SEIMessage {
+	payload := make([]byte, 16+len(data))
+	copy(payload, uuid[:])
+	copy(payload[16:], data)
+	return SEIMessage{PayloadType: SEITypeUserDataUnregistered, Payload: payload}
+}
+
+func ParseUserDataRegisteredITUTT35(payload []byte) (*UserDataRegisteredITUTT35, error) {
+	if len(payload) < 1 || (payload[0] == 0xFF && len(payload) < 2) {
+		return nil, fmt.Errorf("invalid user data registered size %d", len(payload))
+	}
+
+	data := &UserDataRegisteredITUTT35{CountryCode: payload[0], Data: payload[1:]}
+	if data.CountryCode == 0xFF {
+		data.CountryCodeExtension = payload[1]
+		data.Data = payload[2:]
+	}
+
+	return data, nil
+}
+
+func ParseRecoveryPoint(payload []byte) (*RecoveryPoint, error) {
+	r := &bufio.GolombBitReader{R: bytes.NewReader(payload)}
+	cnt, err := r.ReadExponentialGolombCode()
+	if err != nil {
+		return nil, err
+	}
+
+	flags, err := r.ReadBits(4)
+	if err != nil {
+		return nil, err
+	}
+
+	return &RecoveryPoint{
+		RecoveryFrameCnt:      int(cnt),
+		ExactMatchFlag:        flags>>3&1 == 1,
+		BrokenLinkFlag:        flags>>2&1 == 1,
+		ChangingSliceGroupIdc: int(flags & 0x3),
+	}, nil
+}
+
+func ParseMasteringDisplayColourVolume(payload []byte) (*MasteringDisplayColourVolume, error) {
+	if len(payload) < 24 {
+		return nil, fmt.Errorf("invalid mastering display colour volume size %d", len(payload))
+	}
+
+	mdcv := &MasteringDisplayColourVolume{}
+	for i := 0; i < 3; i++ {
+		mdcv.DisplayPrimariesX[i] = binary.BigEndian.Uint16(payload[i*4:])
+		mdcv.DisplayPrimariesY[i] = binary.BigEndian.Uint16(payload[i*4+2:])
+	}
+
+	mdcv.WhitePointX = binary.BigEndian.Uint16(payload[12:])
+	mdcv.WhitePointY = binary.BigEndian.Uint16(payload[14:])
+	mdcv.MaxDisplayMasteringLuminance = binary.BigEndian.Uint32(payload[16:])
+	mdcv.MinDisplayMasteringLuminance = binary.BigEndian.Uint32(payload[20:])
+	return mdcv, nil
+}
+
+func ParseContentLightLevelInfo(payload []byte) (*ContentLightLevelInfo, error) {
+	if len(payload) < 4 {
+		return nil, fmt.Errorf("invalid content light level info size %d", len(payload))
+	}
+
+	return &ContentLightLevelInfo{
+		MaxContentLightLevel:    binary.BigEndian.Uint16(payload),
+		MaxPicAverageLightLevel: binary.BigEndian.Uint16(payload[2:]),
+	}, nil
+}
+
+// ParsePicTiming pic_timing依赖SPS VUI中的HRD参数, 由调用方传入
+func ParsePicTiming(payload []byte, params *PicTimingParams) (*PicTiming, error) {
+	r := bufio.BitsReader{Data: payload}
+	timing := &PicTiming{}
+	if params.CpbDpbDelaysPresentFlag {
+		timing.CpbRemovalDelay = int(r.Read(params.CpbRemovalDelayLength))
+		timing.DpbOutputDelay = int(r.Read(params.DpbOutputDelayLength))
+	}
+
+	if params.PicStructPresentFlag {
+		timing.PicStruct = int(r.Read(4))
+		if timing.PicStruct >= len(numClockTS) {
+			return nil, fmt.Errorf("invalid pic_struct %d", timing.PicStruct)
+		}
+
+		timing.ClockTimestamps = make([]*ClockTimestamp, numClockTS[timing.PicStruct])
+		for i := range timing.ClockTimestamps {
+			if r.Read(1) == 0 {
+				continue
+			}
+
+			ts := &ClockTimestamp{}
+			ts.CtType = int(r.Read(2))
+			ts.NuitFieldBasedFlag = r.Read(1) == 1
+			ts.CountingType = int(r.Read(5))
+			fullTimestampFlag := r.Read(1) == 1
+			ts.DiscontinuityFlag = r.Read(1) == 1
+			ts.CntDroppedFlag = r.Read(1) == 1
+			ts.NFrames = int(r.Read(8))
+			if fullTimestampFlag {
+				ts.Seconds = int(r.Read(6))
+				ts.Minutes = int(r.Read(6))
+				ts.Hours = int(r.Read(5))
+			} else if r.Read(1) == 1 {
+				ts.Seconds = int(r.Read(6))
+				if r.Read(1) == 1 {
+					ts.Minutes = int(r.Read(6))
+					if r.Read(1) == 1 {
+						ts.Hours = int(r.Read(5))
+					}
+				}
+			}
+
+			if params.TimeOffsetLength > 0 {
+				// time_offset i(v)
+				offset := int(r.Read(params.TimeOffsetLength))
+				if offset>>(params.TimeOffsetLength-1) == 1 {
+					offset -= 1 << params.TimeOffsetLength
+				}
+
+				ts.TimeOffset = offset
+			}
+
+			timing.ClockTimestamps[i] = ts
+		}
+	}
+
+	if r.Offset > len(payload)*8 {
+		return nil, fmt.Errorf("invalid pic timing")
+	}
+
+	return timing, nil
+}
@@ -0,0 +1,174 @@
+package avc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"github.com/lkmio/avformat/bufio"
+	"github.com/lkmio/avformat/utils"
+	"testing"
+)
+
+func newSEINalU() []byte {
+	nalu := []byte{H264NalSEI}
+	// user_data_unregistered, 数据中包含防竞争字节
+	nalu = append(nalu, SEITypeUserDataUnregistered, 19)
+	nalu = append(nalu, bytes.Repeat([]byte{0x11}, 16)...)
+	nalu = append(nalu, 0x00, 0x00, 0x03, 0x01)
+	// recovery_point, recovery_frame_cnt=0, exact_match_flag=1
+	nalu = append(nalu, SEITypeRecoveryPoint, 1, 0xC4)
+	mdcv := make([]byte, 24)
+	for i := 0; i < 6; i++ {
+		binary.BigEndian.PutUint16(mdcv[i*2:], uint16(i+1))
+	}
+	binary.BigEndian.PutUint16(mdcv[12:], 15635)
+	binary.BigEndian.PutUint16(mdcv[14:], 16450)
+	binary.BigEndian.PutUint32(mdcv[16:], 10000000)
+	binary.BigEndian.PutUint32(mdcv[20:], 50)
+	nalu = append(nalu, SEITypeMasteringDisplayColourVolume, 24)
+	nalu = append(nalu, mdcv...)
+	nalu = append(nalu, SEITypeContentLightLevelInfo, 4, 0x03, 0xE8, 0x01, 0x90)
+	// payloadType=300, 使用0xFF扩展
+	nalu = append(nalu, 0xFF, 45, 1, 0x01)
+	return append(nalu, 0x80)
+}
+
+func TestSEI(t *testing.T) {
+	messages, err := ParseSEI(append([]byte{0, 0, 0, 1}, newSEINalU()...))
+	if err != nil {
+		panic(err)
+	}
+
+	utils.Assert(len(messages) == 5 && messages[4].PayloadType == 300)
+	utils.Assert(messages[0].PayloadType == SEITypeUserDataUnregistered && len(messages[0].Payload) == 19)
+
+	unregistered, err := ParseUserDataUnregistered(messages[0].Payload)
+	if err != nil {
+		panic(err)
+	}
+
+	utils.Assert(unregistered.UUID[15] == 0x11 && bytes.Equal(unregistered.Data, []byte{0, 0, 1}))
+
+	recoveryPoint, err := ParseRecoveryPoint(messages[1].Payload)
+	if err != nil {
+		panic(err)
+	}
+
+	utils.Assert(recoveryPoint.RecoveryFrameCnt == 0 && recoveryPoint.ExactMatchFlag && !recoveryPoint.BrokenLinkFlag)
+
+	utils.Assert(messages[2].PayloadType == SEITypeMasteringDisplayColourVolume)
+	mdcv, err := ParseMasteringDisplayColourVolume(messages[2].Payload)
+	if err != nil {
+		panic(err)
+	}
+
+	utils.Assert(mdcv.DisplayPrimariesX[1] == 3 && mdcv.DisplayPrimariesY[2] == 6 && mdcv.WhitePointX == 15635)
+	utils.Assert(mdcv.MaxDisplayMasteringLuminance == 10000000 && mdcv.MinDisplayMasteringLuminance == 50)
+
+	cll, err := ParseContentLightLevelInfo(messages[3].Payload)
+	if err != nil {
+		panic(err)
+	}
+
+	utils.Assert(cll.MaxContentLightLevel == 1000 && cll.MaxPicAverageLightLevel == 400)
+
+	// 截断的payload
+	_, err = ParseSEI([]byte{H264NalSEI, SEITypeUserDataUnregistered, 19, 0x11})
+	utils.Assert(err != nil)
+}
+
+func TestExtractSEI(t *testing.T) {
+	sei := newSEINalU()
+	slice := []byte{0x65, 0x88, 0x84, 0x00}
+
+	annexB := append([]byte{0, 0, 0, 1}, sei...)
+	annexB = append(annexB, 0, 0, 0, 1)
+	annexB = append(annexB, slice...)
+	messages, err := ExtractSEI(annexB)
+	if err != nil {
+		panic(err)
+	}
+
+	utils.Assert(len(messages) == 5)
+
+	avcc := make([]byte, 8+len(sei)+len(slice))
+	binary.BigEndian.PutUint32(avcc, uint32(len(sei)))
+	copy(avcc[4:], sei)
+	binary.BigEndian.PutUint32(avcc[4+len(sei):], uint32(len(slice)))
+	copy(avcc[8+len(sei):], slice)
+	messages, err = ExtractSEIFromAVCC(avcc, 4)
+	if err != nil {
+		panic(err)
+	}
+
+	utils.Assert(len(messages) == 5 && messages[3].PayloadType == SEITypeContentLightLevelInfo)
+
+	_, err = ExtractSEIFromAVCC(avcc[:len(avcc)-1], 4)
+	utils.Assert(err != nil)
+
+	// 长度字段只能是1~4字节
+	for _, lengthSize := range []int{-1, 0, 5} {
+		_, err = ExtractSEIFromAVCC(avcc, lengthSize)
+		utils.Assert(err != nil)
+	}
+}
+
+func TestPicTiming(t *testing.T) {
+	payload := make([]byte, 16)
+	writer := bufio.BitsWriter{Data: payload}
+	writer.Write(10, 2)  // cpb_removal_delay
+	writer.Write(10, 4)  // dpb_output_delay
+	writer.Write(4, 3)   // pic_struct, 2个clock timestamp
+	writer.Write(1, 1)   // clock_timestamp_flag
+	writer.Write(2, 0)   // ct_type
+	writer.Write(1, 0)   // nuit_field_based_flag
+	writer.Write(5, 0)   // counting_type
+	writer.Write(1, 1)   // full_timestamp_flag
+	writer.Write(2, 0)   // discontinuity_flag, cnt_dropped_flag
+	writer.Write(8, 5)   // n_frames
+	writer.Write(6, 10)  // seconds_value
+	writer.Write(6, 20)  // minutes_value
+	writer.Write(5, 3)   // hours_value
+	writer.Write(4, 0xE) // time_offset=-2
+	writer.Write(1, 0)   // 第二个clock_timestamp_flag
+
+	timing, err := ParsePicTiming(payload[:(writer.Offset+7)/8], &PicTimingParams{
+		CpbDpbDelaysPresentFlag: true,
+		CpbRemovalDelayLength:   10,
+		DpbOutputDelayLength:    10,
+		PicStructPresentFlag:    true,
+		TimeOffsetLength:        4,
+	})
+
+	if err != nil {
+		panic(err)
+	}
+
+	utils.Assert(timing.CpbRemovalDelay == 2 && timing.DpbOutputDelay == 4 && timing.PicStruct == 3)
+	utils.Assert(len(timing.ClockTimestamps) == 2 && timing.ClockTimestamps[1] == nil)
+
+	ts := timing.ClockTimestamps[0]
+	utils.Assert(ts.NFrames == 5 && ts.Seconds == 10 && ts.Minutes == 20 && ts.Hours == 3 && ts.TimeOffset == -2)
+}
+
+func TestNewSEINalU(t *testing.T) {
+	uuid := [16]byte{0xDC, 0x45, 0xE9, 0xBD}
+	// 数据中包含需要防竞争的字节
+	data := []byte{0x00, 0x00, 0x00, 0x00, 0x01, 0x02}
+	payload := bytes.Repeat([]byte{0x55}, 300)
+	nalu := NewSEINalU(NewUserDataUnregistered(uuid, data), SEIMessage{PayloadType: 300, Payload: payload})
+	utils.Assert(!bytes.Contains(nalu, []byte{0, 0, 0}) && !bytes.Contains(nalu, []byte{0, 0, 1}))
+
+	messages, err := ParseSEI(nalu)
+	if err != nil {
+		panic(err)
+	}
+
+	utils.Assert(len(messages) == 2 && messages[1].PayloadType == 300 && bytes.Equal(messages[1].Payload, payload))
+
+	unregistered, err := ParseUserDataUnregistered(messages[0].Payload)
+	if err != nil {
+		panic(err)
+	}
+
+	utils.Assert(unregistered.UUID == uuid && bytes.Equal(unregistered.Data, data))
+}
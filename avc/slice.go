@@ -0,0 +1,258 @@
+package avc
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/lkmio/avformat/bufio"
+)
+
+// slice_type % 5
+const (
+	SliceTypeP  = 0
+	SliceTypeB  = 1
+	SliceTypeI  = 2
+	SliceTypeSP = 3
+	SliceTypeSI = 4
+)
+
+// SliceHeader 只解析到redundant_pic_cnt, 足够计算POC
+type SliceHeader struct {
+	NalRefIdc              int
+	NalUnitType            int
+	FirstMbInSlice         int
+	SliceType              int
+	PPSId                  uint
+	ColourPlaneId          int
+	FrameNum               int
+	FieldPicFlag           bool
+	BottomFieldFlag        bool
+	IdrPicId               int
+	PicOrderCntLsb         int
+	DeltaPicOrderCntBottom int
+	DeltaPicOrderCnt       [2]int
+	RedundantPicCnt        int
+}
+
+func (h *SliceHeader) IsIDR() bool {
+	return h.NalUnitType == H264NalIDRSlice
+}
+
+// ParameterSets 保存解码slice header需要的SPS和PPS, 以id为索引
+type ParameterSets struct {
+	SPS map[uint]*SPS
+	PPS map[uint]*PPS
+}
+
+func NewParameterSets() *ParameterSets {
+	return &ParameterSets{SPS: map[uint]*SPS{}, PPS: map[uint]*PPS{}}
+}
+
+// Update 解析SPS或PPS NALU并保存, 其他类型的NALU忽略
+func (p *ParameterSets) Update(nalu []byte) error {
+	nalu = RemoveStartCode(nalu)
+	if len(nalu) < 1 {
+		return fmt.Errorf("invalid nal unit")
+	}
+
+	switch nalu[0] & 0x1F {
+	case H264NalSPS:
+		sps, err := ParseSPS(nalu)
+		if err != nil {
+			return err
+		}
+
+		p.SPS[sps.Id] = &sps
+	case H264NalPPS:
+		pps, err := ParsePPS(nalu)
+		if err != nil {
+			return err
+		}
+
+		p.PPS[pps.Id] = pps
+	}
+
+	return nil
+}
+
+// ParseSliceHeader 解析slice NALU的头, 返回使用的SPS
+func (p *ParameterSets) ParseSliceHeader(nalu []byte) (*SliceHeader, *SPS, error) {
+	nalu = RemoveStartCode(nalu)
+	if len(nalu) < 2 {
+		return nil, nil, fmt.Errorf("invalid slice nal unit")
+	}
+
+	header := &SliceHeader{NalRefIdc: int(nalu[0] >> 5 & 0x3), NalUnitType: int(nalu[0] & 0x1F)}
+	if header.NalUnitType != H264NalSlice && header.NalUnitType != H264NalIDRSlice {
+		return nil, nil, fmt.Errorf("unsupported nal unit type %d", header.NalUnitType)
+	}
+
+	// 只需要slice header开头的字段, 只转换前64个字节
+	data := nalu[1:bufio.MinInt(len(nalu), 64)]
+	r := &bufio.GolombBitReader{R: bytes.NewReader(EBSP2RBSP(data))}
+	header.FirstMbInSlice = r.UE()
+	header.SliceType = r.UE()
+	if header.SliceType > 9 {
+		return nil, nil, fmt.Errorf("invalid slice type %d", header.SliceType)
+	}
+
+	header.SliceType %= 5
+	header.PPSId = uint(r.UE())
+	pps, ok := p.PPS[header.PPSId]
+	if !ok {
+		return nil, nil, fmt.Errorf("pps %d not found", header.PPSId)
+	}
+
+	sps, ok := p.SPS[pps.SPSId]
+	if !ok {
+		return nil, nil, fmt.Errorf("sps %d not found", pps.SPSId)
+	}
+
+	if sps.SeparateColourPlaneFlag {
+		header.ColourPlaneId = r.Bits(2)
+	}
+
+	header.FrameNum = r.Bits(int(sps.Log2MaxFrameNum))
+	if !sps.FrameMbsOnlyFlag {
+		header.FieldPicFlag = r.Flag()
+		if header.FieldPicFlag {
+			header.BottomFieldFlag = r.Flag()
+		}
+	}
+
+	if header.IsIDR() {
+		header.IdrPicId = r.UE()
+	}
+
+	if sps.PicOrderCntType == 0 {
+		header.PicOrderCntLsb = r.Bits(int(sps.Log2MaxPicOrderCntLsb))
+		if pps.BottomFieldPicOrderInFramePresentFlag && !header.FieldPicFlag {
+			header.DeltaPicOrderCntBottom = r.SE()
+		}
+	} else if sps.PicOrderCntType == 1 && !sps.DeltaPicOrderAlwaysZeroFlag {
+		header.DeltaPicOrderCnt[0] = r.SE()
+		if pps.BottomFieldPicOrderInFramePresentFlag && !header.FieldPicFlag {
+			header.DeltaPicOrderCnt[1] = r.SE()
+		}
+	}
+
+	if pps.RedundantPicCntPresentFlag {
+		header.RedundantPicCnt = r.UE()
+	}
+
+	if r.Err() != nil {
+		return nil, nil, fmt.Errorf("invalid slice header")
+	}
+
+	return header, sps, nil
+}
+
+// POC 按解码顺序计算图像的PicOrderCnt, 8.2.1. 不处理memory_management_control_operation等于5的情况.
+type POC struct {
+	prevPicOrderCntMsb int
+	prevPicOrderCntLsb int
+	prevFrameNumOffset int
+	prevFrameNum       int
+}
+
+// Compute 返回当前图像的POC, 帧返回顶场和底场中较小的值
+func (c *POC) Compute(header *SliceHeader, sps *SPS) int {
+	var top, bottom int
+	maxFrameNum := 1 << sps.Log2MaxFrameNum
+
+	frameNumOffset := func() int {
+		if header.IsIDR() {
+			return 0
+		} else if c.prevFrameNum > header.FrameNum {
+			return c.prevFrameNumOffset + maxFrameNum
+		}
+
+		return c.prevFrameNumOffset
+	}
+
+	switch sps.PicOrderCntType {
+	case 0:
+		if header.IsIDR() {
+			c.prevPicOrderCntMsb = 0
+			c.prevPicOrderCntLsb = 0
+		}
+
+		maxLsb := 1 << sps.Log2MaxPicOrderCntLsb
+		lsb := header.PicOrderCntLsb
+		msb := c.prevPicOrderCntMsb
+		if lsb < c.prevPicOrderCntLsb && c.prevPicOrderCntLsb-lsb >= maxLsb/2 {
+			msb += maxLsb
+		} else if lsb > c.prevPicOrderCntLsb && lsb-c.prevPicOrderCntLsb > maxLsb/2 {
+			msb -= maxLsb
+		}
+
+		top = msb + lsb
+		bottom = top + header.DeltaPicOrderCntBottom
+		if header.FieldPicFlag && header.BottomFieldFlag {
+			bottom = msb + lsb
+		}
+
+		if header.NalRefIdc != 0 {
+			c.prevPicOrderCntMsb = msb
+			c.prevPicOrderCntLsb = lsb
+		}
+	case 1:
+		offset := frameNumOffset()
+		cycle := len(sps.OffsetForRefFrame)
+		absFrameNum := 0
+		if cycle != 0 {
+			absFrameNum = offset + header.FrameNum
+		}
+
+		if header.NalRefIdc == 0 && absFrameNum > 0 {
+			absFrameNum--
+		}
+
+		var expected int
+		if absFrameNum > 0 {
+			var expectedDelta int
+			for _, v := range sps.OffsetForRefFrame {
+				expectedDelta += v
+			}
+
+			cycleCnt := (absFrameNum - 1) / cycle
+			frameNumInCycle := (absFrameNum - 1) % cycle
+			expected = cycleCnt * expectedDelta
+			for i := 0; i <= frameNumInCycle; i++ {
+				expected += sps.OffsetForRefFrame[i]
+			}
+		}
+
+		if header.NalRefIdc == 0 {
+			expected += sps.OffsetForNonRefPic
+		}
+
+		top = expected + header.DeltaPicOrderCnt[0]
+		if !header.FieldPicFlag {
+			bottom = top + sps.OffsetForTopToBottomField + header.DeltaPicOrderCnt[1]
+		} else {
+			bottom = expected + sps.OffsetForTopToBottomField + header.DeltaPicOrderCnt[0]
+		}
+
+		c.prevFrameNumOffset = offset
+	case 2:
+		offset := frameNumOffset()
+		if !header.IsIDR() {
+			top = 2 * (offset + header.FrameNum)
+			if header.NalRefIdc == 0 {
+				top--
+			}
+		}
+
+		bottom = top
+		c.prevFrameNumOffset = offset
+	}
+
+	c.prevFrameNum = header.FrameNum
+	if header.FieldPicFlag && header.BottomFieldFlag {
+		return bottom
+	} else if header.FieldPicFlag {
+		return top
+	}
+
+	return bufio.MinInt(top, bottom)
+}
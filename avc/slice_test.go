@@ -0,0 +1,222 @@
+package avc
+
+import (
+	"github.com/lkmio/avformat/bufio"
+	"github.com/lkmio/avformat/utils"
+	"testing"
+)
+
+func finishNalU(header byte, writer *bufio.BitsWriter) []byte {
+	return append([]byte{header}, RBSP2EBSP(writer.WriteTrailingBits())...)
+}
+
+// 320x240, main profile, log2_max_frame_num=4, log2_max_pic_order_cnt_lsb=6
+func newTestSPS(pocType int) []byte {
+	writer := &bufio.BitsWriter{Data: make([]byte, 32)}
+	writer.Write(8, 77) // profile_idc
+	writer.Write(8, 0)  // constraint_set_flags
+	writer.Write(8, 30) // level_idc
+	writer.WriteUE(0)   // seq_parameter_set_id
+	writer.WriteUE(0)   // log2_max_frame_num_minus4
+	writer.WriteUE(pocType)
+	if pocType == 0 {
+		writer.WriteUE(2) // log2_max_pic_order_cnt_lsb_minus4
+	} else if pocType == 1 {
+		writer.Write(1, 0) // delta_pic_order_always_zero_flag
+		writer.WriteSE(-1)
+		writer.WriteSE(0)
+		writer.WriteUE(1)
+		writer.WriteSE(4)
+	}
+
+	writer.WriteUE(2)  // max_num_ref_frames
+	writer.Write(1, 0) // gaps_in_frame_num_value_allowed_flag
+	writer.WriteUE(19) // pic_width_in_mbs_minus1
+	writer.WriteUE(14) // pic_height_in_map_units_minus1
+	writer.Write(1, 1) // frame_mbs_only_flag
+	writer.Write(1, 1) // direct_8x8_inference_flag
+	writer.Write(1, 0) // frame_cropping_flag
+	writer.Write(1, 0) // vui_parameters_present_flag
+	return finishNalU(0x67, writer)
+}
+
+func newTestPPS() []byte {
+	writer := &bufio.BitsWriter{Data: make([]byte, 16)}
+	writer.WriteUE(0)    // pic_parameter_set_id
+	writer.WriteUE(0)    // seq_parameter_set_id
+	writer.Write(2, 0)   // entropy_coding_mode_flag, bottom_field_pic_order_in_frame_present_flag
+	writer.WriteUE(0)    // num_slice_groups_minus1
+	writer.WriteUE(0)    // num_ref_idx_l0_default_active_minus1
+	writer.WriteUE(0)    // num_ref_idx_l1_default_active_minus1
+	writer.Write(3, 0)   // weighted_pred_flag, weighted_bipred_idc
+	writer.WriteSE(-4)   // pic_init_qp_minus26
+	writer.WriteSE(0)    // pic_init_qs_minus26
+	writer.WriteSE(2)    // chroma_qp_index_offset
+	writer.Write(3, 0x4) // deblocking_filter_control_present_flag, constrained_intra_pred_flag, redundant_pic_cnt_present_flag
+	return finishNalU(0x68, writer)
+}
+
+// newTestSlice 生成slice NALU, sliceType为I/P/B
+func newTestSlice(sliceType, frameNum, pocLsb int, ref bool, pocType int) []byte {
+	writer := &bufio.BitsWriter{Data: make([]byte, 16)}
+	var header byte = H264NalSlice
+	if sliceType == SliceTypeI {
+		header = H264NalIDRSlice
+	}
+
+	if ref {
+		header |= 0x40
+	}
+
+	writer.WriteUE(0)             // first_mb_in_slice
+	writer.WriteUE(sliceType + 5) // slice_type
+	writer.WriteUE(0)             // pic_parameter_set_id
+	writer.Write(4, uint64(frameNum))
+	if sliceType == SliceTypeI {
+		writer.WriteUE(0) // idr_pic_id
+	}
+
+	if pocType == 0 {
+		writer.Write(6, uint64(pocLsb))
+	} else if pocType == 1 {
+		writer.WriteSE(0) // delta_pic_order_cnt[0]
+	}
+
+	writer.Write(7, 0x55)
+	return finishNalU(header, writer)
+}
+
+func newTestParameterSets(pocType int) *ParameterSets {
+	params := NewParameterSets()
+	if err := params.Update(append([]byte{0, 0, 0, 1}, newTestSPS(pocType)...)); err != nil {
+		panic(err)
+	} else if err = params.Update(newTestPPS()); err != nil {
+		panic(err)
+	}
+
+	return params
+}
+
+func TestParsePPS(t *testing.T) {
+	pps, err := ParsePPS(newTestPPS())
+	if err != nil {
+		panic(err)
+	}
+
+	utils.Assert(pps.NumSliceGroups == 1 && pps.NumRefIdxL0DefaultActive == 1 && pps.PicInitQp == 22)
+	utils.Assert(pps.ChromaQpIndexOffset == 2 && pps.DeblockingFilterControlPresentFlag && !pps.RedundantPicCntPresentFlag)
+	utils.Assert(!pps.IsCABAC() && !pps.Transform8x8ModeFlag && pps.SecondChromaQpIndexOffset == 2)
+
+	// High profile, CABAC, transform_8x8_mode_flag和一个8x8 scaling list
+	writer := &bufio.BitsWriter{Data: make([]byte, 32)}
+	writer.WriteUE(1)
+	writer.WriteUE(0)
+	writer.Write(2, 0x2) // entropy_coding_mode_flag, bottom_field_pic_order_in_frame_present_flag
+	writer.WriteUE(0)
+	writer.WriteUE(2)
+	writer.WriteUE(0)
+	writer.Write(3, 0)
+	writer.WriteSE(0)
+	writer.WriteSE(0)
+	writer.WriteSE(-3)
+	writer.Write(3, 0x4)
+	writer.Write(2, 0x3) // transform_8x8_mode_flag, pic_scaling_matrix_present_flag
+	writer.Write(6, 0)
+	writer.Write(1, 1) // pic_scaling_list_present_flag[6]
+	writer.WriteSE(4)
+	writer.WriteSE(-12) // nextScale等于0, 后续使用默认值
+	writer.Write(1, 0)
+	writer.WriteSE(5) // second_chroma_qp_index_offset
+
+	pps, err = ParsePPS(finishNalU(0x68, writer))
+	if err != nil {
+		panic(err)
+	}
+
+	utils.Assert(pps.Id == 1 && pps.IsCABAC() && pps.NumRefIdxL0DefaultActive == 3 && pps.ChromaQpIndexOffset == -3)
+	utils.Assert(pps.Transform8x8ModeFlag && pps.PicScalingMatrixPresentFlag && pps.SecondChromaQpIndexOffset == 5)
+
+	sps, err := ParseSPS(newTestSPS(1))
+	if err != nil {
+		panic(err)
+	}
+
+	utils.Assert(sps.Width == 320 && sps.Height == 240 && sps.PicOrderCntType == 1 && sps.Log2MaxFrameNum == 4)
+	utils.Assert(sps.OffsetForNonRefPic == -1 && len(sps.OffsetForRefFrame) == 1 && sps.OffsetForRefFrame[0] == 4)
+}
+
+func TestPOCType0(t *testing.T) {
+	params := newTestParameterSets(0)
+	var poc POC
+
+	// 解码顺序I0 P6 B2 B4 P12 B8 B10, 之后P帧的pic_order_cnt_lsb回绕
+	frames := []struct {
+		sliceType int
+		frameNum  int
+		poc       int
+		ref       bool
+	}{
+		{SliceTypeI, 0, 0, true},
+		{SliceTypeP, 1, 6, true},
+		{SliceTypeB, 2, 2, false},
+		{SliceTypeB, 2, 4, false},
+		{SliceTypeP, 2, 12, true},
+		{SliceTypeB, 3, 8, false},
+		{SliceTypeB, 3, 10, false},
+		{SliceTypeP, 3, 40, true},
+		{SliceTypeP, 4, 70, true},
+		{SliceTypeP, 5, 100, true},
+	}
+
+	for _, frame := range frames {
+		header, sps, err := params.ParseSliceHeader(newTestSlice(frame.sliceType, frame.frameNum, frame.poc%64, frame.ref, 0))
+		if err != nil {
+			panic(err)
+		}
+
+		utils.Assert(header.SliceType == frame.sliceType && header.FrameNum == frame.frameNum)
+		utils.Assert(poc.Compute(header, sps) == frame.poc)
+	}
+
+	_, _, err := NewParameterSets().ParseSliceHeader(newTestSlice(SliceTypeP, 1, 0, true, 0))
+	utils.Assert(err != nil)
+}
+
+func TestPOCType1And2(t *testing.T) {
+	params := newTestParameterSets(2)
+	var poc POC
+	for i := 0; i < 20; i++ {
+		sliceType := SliceTypeP
+		if i == 0 {
+			sliceType = SliceTypeI
+		}
+
+		header, sps, err := params.ParseSliceHeader(newTestSlice(sliceType, i%16, 0, true, 2))
+		if err != nil {
+			panic(err)
+		}
+
+		// frame_num回绕后FrameNumOffset增加
+		utils.Assert(poc.Compute(header, sps) == 2*i)
+	}
+
+	params = newTestParameterSets(1)
+	poc = POC{}
+	// I P b P b, 参考帧间隔4, 非参考帧偏移-1
+	expected := []int{0, 4, 3, 8, 7}
+	for i, value := range expected {
+		sliceType, frameNum, ref := SliceTypeP, (i+2)/2, i%2 == 1
+		if i == 0 {
+			sliceType, frameNum, ref = SliceTypeI, 0, true
+		} else if i%2 == 0 {
+			sliceType = SliceTypeB
+		}
+
+		header, sps, err := params.ParseSliceHeader(newTestSlice(sliceType, frameNum, 0, ref, 1))
+		if err != nil {
+			panic(err)
+		}
+
+		utils.Assert(poc.Compute(header, sps) == value)
+	}
+}
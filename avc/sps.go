@@ -24,10 +24,30 @@ type SPS struct {
 	Width  int
 	Height int
 	FPS    int
+
+	// 解析slice header和计算POC需要的字段
+	ChromaFormatIdc             uint
+	SeparateColourPlaneFlag     bool
+	BitDepthLumaMinus8          uint
+	BitDepthChromaMinus8        uint
+	Log2MaxFrameNum             uint
+	PicOrderCntType             uint
+	Log2MaxPicOrderCntLsb       uint
+	DeltaPicOrderAlwaysZeroFlag bool
+	OffsetForNonRefPic          int
+	OffsetForTopToBottomField   int
+	OffsetForRefFrame           []int
+	MaxNumRefFrames             uint
+	FrameMbsOnlyFlag            bool
+	MaxNumReorderFrames         int // VUI bitstream_restriction中的max_num_reorder_frames, -1表示未知
+
+	VUI *VUI // 没有vui_parameters时为nil
 }
 
 func ParseSPS(data []byte) (s SPS, err error) {
-	data = RemoveStartCode(data)
+	s.ChromaFormatIdc = 1
+	s.MaxNumReorderFrames = -1
+	data = EBSP2RBSP(RemoveStartCode(data))
 	r := &bufio.GolombBitReader{R: bytes.NewReader(data)}
 
 	if _, err = r.ReadBits(8); err != nil {
@@ -59,24 +79,22 @@ func ParseSPS(data []byte) (s SPS, err error) {
 		s.ProfileIdc == 44 || s.ProfileIdc == 83 ||
 		s.ProfileIdc == 86 || s.ProfileIdc == 118 {
 
-		var chroma_format_idc uint
-		if chroma_format_idc, err = r.ReadExponentialGolombCode(); err != nil {
+		if s.ChromaFormatIdc, err = r.ReadExponentialGolombCode(); err != nil {
 			return
 		}
 
-		if chroma_format_idc == 3 {
-			// residual_colour_transform_flag
-			if _, err = r.ReadBit(); err != nil {
+		if s.ChromaFormatIdc == 3 {
+			var separate_colour_plane_flag uint
+			if separate_colour_plane_flag, err = r.ReadBit(); err != nil {
 				return
 			}
+			s.SeparateColourPlaneFlag = separate_colour_plane_flag == 1
 		}
 
-		// bit_depth_luma_minus8
-		if _, err = r.ReadExponentialGolombCode(); err != nil {
+		if s.BitDepthLumaMinus8, err = r.ReadExponentialGolombCode(); err != nil {
 			return
 		}
-		// bit_depth_chroma_minus8
-		if _, err = r.ReadExponentialGolombCode(); err != nil {
+		if s.BitDepthChromaMinus8, err = r.ReadExponentialGolombCode(); err != nil {
 			return
 		}
 		// qpprime_y_zero_transform_bypass_flag
@@ -122,45 +140,57 @@ func ParseSPS(data []byte) (s SPS, err error) {
 	}
 
 	// log2_max_frame_num_minus4
-	if _, err = r.ReadExponentialGolombCode(); err != nil {
+	if s.Log2MaxFrameNum, err = r.ReadExponentialGolombCode(); err != nil {
 		return
 	}
+	s.Log2MaxFrameNum += 4
 
-	var pic_order_cnt_type uint
-	if pic_order_cnt_type, err = r.ReadExponentialGolombCode(); err != nil {
+	if s.PicOrderCntType, err = r.ReadExponentialGolombCode(); err != nil {
 		return
 	}
-	if pic_order_cnt_type == 0 {
+	if s.PicOrderCntType == 0 {
 		// log2_max_pic_order_cnt_lsb_minus4
-		if _, err = r.ReadExponentialGolombCode(); err != nil {
+		if s.Log2MaxPicOrderCntLsb, err = r.ReadExponentialGolombCode(); err != nil {
 			return
 		}
-	} else if pic_order_cnt_type == 1 {
-		// delta_pic_order_always_zero_flag
-		if _, err = r.ReadBit(); err != nil {
+		s.Log2MaxPicOrderCntLsb += 4
+	} else if s.PicOrderCntType == 1 {
+		var delta_pic_order_always_zero_flag, offset uint
+		if delta_pic_order_always_zero_flag, err = r.ReadBit(); err != nil {
 			return
 		}
+		s.DeltaPicOrderAlwaysZeroFlag = delta_pic_order_always_zero_flag == 1
+
 		// offset_for_non_ref_pic
-		if _, err = r.ReadSE(); err != nil {
+		if offset, err = r.ReadSE(); err != nil {
 			return
 		}
+		s.OffsetForNonRefPic = int(offset)
+
 		// offset_for_top_to_bottom_field
-		if _, err = r.ReadSE(); err != nil {
+		if offset, err = r.ReadSE(); err != nil {
 			return
 		}
+		s.OffsetForTopToBottomField = int(offset)
+
 		var num_ref_frames_in_pic_order_cnt_cycle uint
 		if num_ref_frames_in_pic_order_cnt_cycle, err = r.ReadExponentialGolombCode(); err != nil {
 			return
+		} else if num_ref_frames_in_pic_order_cnt_cycle > 255 {
+			err = fmt.Errorf("invalid num_ref_frames_in_pic_order_cnt_cycle %d", num_ref_frames_in_pic_order_cnt_cycle)
+			return
 		}
-		for i := uint(0); i < num_ref_frames_in_pic_order_cnt_cycle; i++ {
-			if _, err = r.ReadSE(); err != nil {
+
+		s.OffsetForRefFrame = make([]int, num_ref_frames_in_pic_order_cnt_cycle)
+		for i := range s.OffsetForRefFrame {
+			if offset, err = r.ReadSE(); err != nil {
 				return
 			}
+			s.OffsetForRefFrame[i] = int(offset)
 		}
 	}
 
-	// max_num_ref_frames
-	if _, err = r.ReadExponentialGolombCode(); err != nil {
+	if s.MaxNumRefFrames, err = r.ReadExponentialGolombCode(); err != nil {
 		return
 	}
 
@@ -183,6 +213,7 @@ func ParseSPS(data []byte) (s SPS, err error) {
 	if frame_mbs_only_flag, err = r.ReadBit(); err != nil {
 		return
 	}
+	s.FrameMbsOnlyFlag = frame_mbs_only_flag == 1
 	if frame_mbs_only_flag == 0 {
 		// mb_adaptive_frame_field_flag
 		if _, err = r.ReadBit(); err != nil {
@@ -223,124 +254,72 @@ func ParseSPS(data []byte) (s SPS, err error) {
 	}
 
 	if vui_parameter_present_flag != 0 {
-		aspect_ratio_info_present_flag, err := r.ReadBit()
-		if err != nil {
-			return s, err
+		if s.VUI, err = parseVUI(r); err != nil {
+			return
 		}
 
-		if aspect_ratio_info_present_flag != 0 {
-			aspect_ratio_idc, err := r.ReadBits(8)
-			if err != nil {
-				return s, err
-			}
-
-			if aspect_ratio_idc == 255 {
-				sar_width, err := r.ReadBits(16)
-				if err != nil {
-					return s, err
-				}
-				sar_height, err := r.ReadBits(16)
-				if err != nil {
-					return s, err
-				}
-
-				_, _ = sar_width, sar_height
-			}
-		}
+		s.FPS = int(math.Floor(s.VUI.FrameRate()))
+		s.MaxNumReorderFrames = s.VUI.MaxNumReorderFrames
+	}
 
-		overscan_info_present_flag, err := r.ReadBit()
-		if err != nil {
-			return s, err
-		}
+	// baseline没有B帧, 解码顺序和显示顺序相同
+	if s.MaxNumReorderFrames < 0 && s.ProfileIdc == 66 {
+		s.MaxNumReorderFrames = 0
+	}
 
-		if overscan_info_present_flag != 0 {
-			overscan_appropriate_flagu, err := r.ReadBit()
-			if err != nil {
-				return s, err
-			}
+	return
+}
 
-			_ = overscan_appropriate_flagu
+// MaxDpbFrames 根据level的MaxDpbMbs(表A-1)和图像大小计算DPB可容纳的最大帧数.
+// 没有bitstream_restriction时, max_num_reorder_frames推断为该值.
+func (s *SPS) MaxDpbFrames() int {
+	var maxDpbMbs uint
+	switch s.LevelIdc {
+	case 9, 10:
+		maxDpbMbs = 396
+	case 11:
+		maxDpbMbs = 900
+		if s.ConstraintSetFlag&0x04 != 0 && (s.ProfileIdc == 66 || s.ProfileIdc == 77 || s.ProfileIdc == 88) {
+			// constraint_set3_flag表示level 1b
+			maxDpbMbs = 396
 		}
-		video_signal_type_present_flag, err := r.ReadBit()
-		if video_signal_type_present_flag != 0 {
-			video_format, err := r.ReadBits(3)
-			if err != nil {
-				return s, err
-			}
-			_ = video_format
-			video_full_range_flag, err := r.ReadBit()
-			if err != nil {
-				return s, err
-			}
-			_ = video_full_range_flag
-			colour_description_present_flag, err := r.ReadBit()
-			if err != nil {
-				return s, err
-			}
-			if colour_description_present_flag != 0 {
-				colour_primaries, err := r.ReadBits(8)
-				if err != nil {
-					return s, err
-				}
-				_ = colour_primaries
-				transfer_characteristics, err := r.ReadBits(8)
-				if err != nil {
-					return s, err
-				}
-				_ = transfer_characteristics
-				matrix_coefficients, err := r.ReadBits(8)
-				if err != nil {
-					return s, err
-				}
-				_ = matrix_coefficients
-			}
-		}
-		chroma_loc_info_present_flag, err := r.ReadBit()
-		if err != nil {
-			return s, err
-		}
-		if chroma_loc_info_present_flag != 0 {
-			chroma_sample_loc_type_top_field, err := r.ReadSE()
-			if err != nil {
-				return s, err
-			}
-			_ = chroma_sample_loc_type_top_field
+	case 12, 13, 20:
+		maxDpbMbs = 2376
+	case 21:
+		maxDpbMbs = 4752
+	case 22, 30:
+		maxDpbMbs = 8100
+	case 31:
+		maxDpbMbs = 18000
+	case 32:
+		maxDpbMbs = 20480
+	case 40, 41:
+		maxDpbMbs = 32768
+	case 42:
+		maxDpbMbs = 34816
+	case 50:
+		maxDpbMbs = 110400
+	case 51, 52:
+		maxDpbMbs = 184320
+	default:
+		maxDpbMbs = 696320
+	}
 
-			chroma_sample_loc_type_bottom_field, err := r.ReadSE()
-			if err != nil {
-				return s, err
-			}
+	frameMbs := s.MbWidth * s.MbHeight
+	if !s.FrameMbsOnlyFlag {
+		frameMbs *= 2
+	}
 
-			_ = chroma_sample_loc_type_bottom_field
-		}
+	if frameMbs == 0 {
+		return H264MaxDpbFrames
+	}
 
-		timing_info_present_flag, err := r.ReadBit()
-		if err != nil {
-			return s, err
-		}
+	return int(bufio.MinInt(int(maxDpbMbs/frameMbs), H264MaxDpbFrames))
+}
 
-		if timing_info_present_flag != 0 {
-			num_units_in_tick, err := r.ReadBits(32)
-			if err != nil {
-				return s, err
-			}
-			time_scale, err := r.ReadBits(32)
-			if err != nil {
-				return s, err
-			}
-			s.FPS = int(math.Floor(float64(time_scale) / float64(num_units_in_tick) / 2.0))
-			fixed_frame_rate_flag, err := r.ReadBit()
-			if err != nil {
-				return s, err
-			}
-			if fixed_frame_rate_flag != 0 {
-				//utils.L.InfoLn("fixed_frame_rate_flag", fixed_frame_rate_flag)
-				//have been devide 2
-				//self.fps = self.fps / 2
-			}
-		}
-	}
-	return
+// MayHaveBFrames 显示顺序是否可能与解码顺序不同, 无法确定时返回true
+func (s *SPS) MayHaveBFrames() bool {
+	return s.MaxNumReorderFrames != 0
 }
 
 func NewCodecDataFromAVCDecoderConfigurationRecord(record []byte) (*AVCDecoderConfigurationRecord, *SPS, error) {
@@ -0,0 +1,209 @@
+package avc
+
+import (
+	"fmt"
+	"github.com/lkmio/avformat/bufio"
+)
+
+// Table E-1, aspect_ratio_idc 1-16对应的sar
+var sampleAspectRatios = [][2]int{
+	{0, 0}, {1, 1}, {12, 11}, {10, 11}, {16, 11}, {40, 33}, {24, 11}, {20, 11}, {32, 11},
+	{80, 33}, {18, 11}, {15, 11}, {64, 33}, {160, 99}, {4, 3}, {3, 2}, {2, 1},
+}
+
+const ExtendedSAR = 255
+
+// HRD hrd_parameters, E.1.2
+type HRD struct {
+	CpbCnt                       int // cpb_cnt_minus1 + 1
+	BitRateScale                 int
+	CpbSizeScale                 int
+	BitRateValueMinus1           []uint32
+	CpbSizeValueMinus1           []uint32
+	CbrFlag                      []bool
+	InitialCpbRemovalDelayLength int // initial_cpb_removal_delay_length_minus1 + 1
+	CpbRemovalDelayLength        int
+	DpbOutputDelayLength         int
+	TimeOffsetLength             int
+}
+
+// BitRate 返回第i个CPB的码率, 单位bit/s
+func (h *HRD) BitRate(i int) int {
+	return int(h.BitRateValueMinus1[i]+1) << (6 + h.BitRateScale)
+}
+
+// VUI vui_parameters, E.1.1. 未出现的字段为规范推导的默认值.
+type VUI struct {
+	AspectRatioIdc int
+	SarWidth       int
+	SarHeight      int
+
+	OverscanInfoPresentFlag bool
+	OverscanAppropriateFlag bool
+
+	VideoSignalTypePresentFlag   bool
+	VideoFormat                  int
+	VideoFullRangeFlag           bool
+	ColourDescriptionPresentFlag bool
+	ColourPrimaries              int
+	TransferCharacteristics      int
+	MatrixCoefficients           int
+
+	ChromaLocInfoPresentFlag       bool
+	ChromaSampleLocTypeTopField    int
+	ChromaSampleLocTypeBottomField int
+	TimingInfoPresentFlag          bool
+	NumUnitsInTick                 uint32
+	TimeScale                      uint32
+	FixedFrameRateFlag             bool
+	NalHRD                         *HRD
+	VclHRD                         *HRD
+	LowDelayHrdFlag                bool
+	PicStructPresentFlag           bool
+	BitstreamRestrictionFlag       bool
+	MotionVectorsOverPicBoundaries bool
+	MaxBytesPerPicDenom            int
+	MaxBitsPerMbDenom              int
+	Log2MaxMvLengthHorizontal      int
+	Log2MaxMvLengthVertical        int
+	MaxNumReorderFrames            int
+	MaxDecFrameBuffering           int
+}
+
+// FrameRate 根据timing_info计算帧率, 一帧包含两个tick. 没有timing_info返回0.
+func (v *VUI) FrameRate() float64 {
+	if !v.TimingInfoPresentFlag || v.NumUnitsInTick == 0 {
+		return 0
+	}
+
+	return float64(v.TimeScale) / float64(v.NumUnitsInTick) / 2
+}
+
+// SampleAspectRatio 返回sar, 未指定返回0, 0
+func (v *VUI) SampleAspectRatio() (int, int) {
+	if v.AspectRatioIdc == ExtendedSAR {
+		return v.SarWidth, v.SarHeight
+	} else if v.AspectRatioIdc < len(sampleAspectRatios) {
+		return sampleAspectRatios[v.AspectRatioIdc][0], sampleAspectRatios[v.AspectRatioIdc][1]
+	}
+
+	return 0, 0
+}
+
+// PicTimingParams 返回解析pic_timing SEI需要的参数
+func (v *VUI) PicTimingParams() *PicTimingParams {
+	params := &PicTimingParams{PicStructPresentFlag: v.PicStructPresentFlag, TimeOffsetLength: 24}
+	hrd := v.NalHRD
+	if hrd == nil {
+		hrd = v.VclHRD
+	}
+
+	if hrd != nil {
+		params.CpbDpbDelaysPresentFlag = true
+		params.CpbRemovalDelayLength = hrd.CpbRemovalDelayLength
+		params.DpbOutputDelayLength = hrd.DpbOutputDelayLength
+		params.TimeOffsetLength = hrd.TimeOffsetLength
+	}
+
+	return params
+}
+
+func parseHRD(r *bufio.GolombBitReader) *HRD {
+	hrd := &HRD{}
+	hrd.CpbCnt = r.UE() + 1
+	if hrd.CpbCnt > 32 {
+		r.SetErr(fmt.Errorf("invalid cpb_cnt_minus1 %d", hrd.CpbCnt-1))
+		return nil
+	}
+
+	hrd.BitRateScale = r.Bits(4)
+	hrd.CpbSizeScale = r.Bits(4)
+	for i := 0; i < hrd.CpbCnt; i++ {
+		hrd.BitRateValueMinus1 = append(hrd.BitRateValueMinus1, uint32(r.UE()))
+		hrd.CpbSizeValueMinus1 = append(hrd.CpbSizeValueMinus1, uint32(r.UE()))
+		hrd.CbrFlag = append(hrd.CbrFlag, r.Flag())
+	}
+
+	hrd.InitialCpbRemovalDelayLength = r.Bits(5) + 1
+	hrd.CpbRemovalDelayLength = r.Bits(5) + 1
+	hrd.DpbOutputDelayLength = r.Bits(5) + 1
+	hrd.TimeOffsetLength = r.Bits(5)
+	return hrd
+}
+
+// parseVUI 解析vui_parameters. timing_info之后的字段不完整时忽略, 部分编码器输出的SPS会截断bitstream_restriction.
+func parseVUI(r *bufio.GolombBitReader) (*VUI, error) {
+	vui := &VUI{VideoFormat: 5, ColourPrimaries: 2, TransferCharacteristics: 2, MatrixCoefficients: 2, MaxNumReorderFrames: -1, MaxDecFrameBuffering: -1}
+	if r.Flag() {
+		vui.AspectRatioIdc = r.Bits(8)
+		if vui.AspectRatioIdc == ExtendedSAR {
+			vui.SarWidth = r.Bits(16)
+			vui.SarHeight = r.Bits(16)
+		}
+	}
+
+	if vui.OverscanInfoPresentFlag = r.Flag(); vui.OverscanInfoPresentFlag {
+		vui.OverscanAppropriateFlag = r.Flag()
+	}
+
+	if vui.VideoSignalTypePresentFlag = r.Flag(); vui.VideoSignalTypePresentFlag {
+		vui.VideoFormat = r.Bits(3)
+		vui.VideoFullRangeFlag = r.Flag()
+		if vui.ColourDescriptionPresentFlag = r.Flag(); vui.ColourDescriptionPresentFlag {
+			vui.ColourPrimaries = r.Bits(8)
+			vui.TransferCharacteristics = r.Bits(8)
+			vui.MatrixCoefficients = r.Bits(8)
+		}
+	}
+
+	if vui.ChromaLocInfoPresentFlag = r.Flag(); vui.ChromaLocInfoPresentFlag {
+		vui.ChromaSampleLocTypeTopField = r.UE()
+		vui.ChromaSampleLocTypeBottomField = r.UE()
+	}
+
+	if vui.TimingInfoPresentFlag = r.Flag(); vui.TimingInfoPresentFlag {
+		vui.NumUnitsInTick = uint32(r.Bits(32))
+		vui.TimeScale = uint32(r.Bits(32))
+		vui.FixedFrameRateFlag = r.Flag()
+	}
+
+	if r.Err() != nil {
+		return nil, r.Err()
+	}
+
+	if r.Flag() {
+		vui.NalHRD = parseHRD(r)
+	}
+
+	if r.Flag() {
+		vui.VclHRD = parseHRD(r)
+	}
+
+	if vui.NalHRD != nil || vui.VclHRD != nil {
+		vui.LowDelayHrdFlag = r.Flag()
+	}
+
+	if r.Err() != nil {
+		vui.NalHRD, vui.VclHRD = nil, nil
+		return vui, nil
+	}
+
+	vui.PicStructPresentFlag = r.Flag()
+	if vui.BitstreamRestrictionFlag = r.Flag(); vui.BitstreamRestrictionFlag {
+		vui.MotionVectorsOverPicBoundaries = r.Flag()
+		vui.MaxBytesPerPicDenom = r.UE()
+		vui.MaxBitsPerMbDenom = r.UE()
+		vui.Log2MaxMvLengthHorizontal = r.UE()
+		vui.Log2MaxMvLengthVertical = r.UE()
+		vui.MaxNumReorderFrames = r.UE()
+		vui.MaxDecFrameBuffering = r.UE()
+	}
+
+	if r.Err() != nil || vui.MaxNumReorderFrames > 16 {
+		vui.BitstreamRestrictionFlag = false
+		vui.MaxNumReorderFrames = -1
+		vui.MaxDecFrameBuffering = -1
+	}
+
+	return vui, nil
+}
@@ -0,0 +1,113 @@
+package avc
+
+import (
+	"github.com/lkmio/avformat/bufio"
+	"github.com/lkmio/avformat/utils"
+	"testing"
+)
+
+// newTestVUISPS 1920x1080 high profile, sar 4:3, bt709 full range, 25fps, nal hrd, max_num_reorder_frames=2
+func newTestVUISPS(bitstreamRestriction bool) []byte {
+	writer := &bufio.BitsWriter{Data: make([]byte, 64)}
+	writer.Write(8, 100) // profile_idc
+	writer.Write(8, 0)   // constraint_set_flags
+	writer.Write(8, 40)  // level_idc
+	writer.WriteUE(0)    // seq_parameter_set_id
+	writer.WriteUE(1)    // chroma_format_idc
+	writer.WriteUE(0)    // bit_depth_luma_minus8
+	writer.WriteUE(0)    // bit_depth_chroma_minus8
+	writer.Write(2, 0)   // qpprime_y_zero_transform_bypass_flag, seq_scaling_matrix_present_flag
+	writer.WriteUE(0)    // log2_max_frame_num_minus4
+	writer.WriteUE(0)    // pic_order_cnt_type
+	writer.WriteUE(2)    // log2_max_pic_order_cnt_lsb_minus4
+	writer.WriteUE(4)    // max_num_ref_frames
+	writer.Write(1, 0)   // gaps_in_frame_num_value_allowed_flag
+	writer.WriteUE(119)  // pic_width_in_mbs_minus1
+	writer.WriteUE(67)   // pic_height_in_map_units_minus1
+	writer.Write(2, 0x3) // frame_mbs_only_flag, direct_8x8_inference_flag
+	writer.Write(1, 1)   // frame_cropping_flag
+	writer.WriteUE(0)
+	writer.WriteUE(0)
+	writer.WriteUE(0)
+	writer.WriteUE(4)
+	writer.Write(1, 1) // vui_parameters_present_flag
+
+	writer.Write(1, 1) // aspect_ratio_info_present_flag
+	writer.Write(8, 14)
+	writer.Write(1, 0) // overscan_info_present_flag
+	writer.Write(1, 1) // video_signal_type_present_flag
+	writer.Write(3, 5)
+	writer.Write(1, 1) // video_full_range_flag
+	writer.Write(1, 1) // colour_description_present_flag
+	writer.Write(8, 1)
+	writer.Write(8, 1)
+	writer.Write(8, 1)
+	writer.Write(1, 0) // chroma_loc_info_present_flag
+	writer.Write(1, 1) // timing_info_present_flag
+	writer.Write(32, 1)
+	writer.Write(32, 50)
+	writer.Write(1, 1) // fixed_frame_rate_flag
+	writer.Write(1, 1) // nal_hrd_parameters_present_flag
+	writer.WriteUE(0)  // cpb_cnt_minus1
+	writer.Write(4, 2) // bit_rate_scale
+	writer.Write(4, 3) // cpb_size_scale
+	writer.WriteUE(15624)
+	writer.WriteUE(31249)
+	writer.Write(1, 0)  // cbr_flag
+	writer.Write(5, 23) // initial_cpb_removal_delay_length_minus1
+	writer.Write(5, 23) // cpb_removal_delay_length_minus1
+	writer.Write(5, 23) // dpb_output_delay_length_minus1
+	writer.Write(5, 24) // time_offset_length
+	writer.Write(1, 0)  // vcl_hrd_parameters_present_flag
+	writer.Write(1, 0)  // low_delay_hrd_flag
+	writer.Write(1, 1)  // pic_struct_present_flag
+	if bitstreamRestriction {
+		writer.Write(1, 1)
+		writer.Write(1, 1)
+		writer.WriteUE(2)
+		writer.WriteUE(1)
+		writer.WriteUE(16)
+		writer.WriteUE(16)
+		writer.WriteUE(2) // max_num_reorder_frames
+		writer.WriteUE(4) // max_dec_frame_buffering
+	} else {
+		writer.Write(1, 0)
+	}
+
+	return finishNalU(0x67, writer)
+}
+
+func TestVUI(t *testing.T) {
+	sps, err := ParseSPS(newTestVUISPS(true))
+	if err != nil {
+		panic(err)
+	}
+
+	vui := sps.VUI
+	utils.Assert(sps.Width == 1920 && sps.Height == 1080 && sps.FPS == 25 && vui != nil)
+
+	sarWidth, sarHeight := vui.SampleAspectRatio()
+	utils.Assert(sarWidth == 4 && sarHeight == 3)
+	utils.Assert(vui.VideoFullRangeFlag && vui.ColourPrimaries == 1 && vui.TransferCharacteristics == 1 && vui.MatrixCoefficients == 1)
+	utils.Assert(vui.FrameRate() == 25 && vui.FixedFrameRateFlag && vui.VclHRD == nil)
+	utils.Assert(vui.NalHRD.BitRate(0) == 15625<<8 && vui.NalHRD.CpbRemovalDelayLength == 24 && vui.NalHRD.TimeOffsetLength == 24)
+	utils.Assert(vui.MaxNumReorderFrames == 2 && vui.MaxDecFrameBuffering == 4 && sps.MaxNumReorderFrames == 2 && sps.MayHaveBFrames())
+
+	params := vui.PicTimingParams()
+	utils.Assert(params.CpbDpbDelaysPresentFlag && params.PicStructPresentFlag && params.DpbOutputDelayLength == 24)
+
+	// 没有bitstream_restriction时无法确定
+	sps, err = ParseSPS(newTestVUISPS(false))
+	if err != nil {
+		panic(err)
+	}
+
+	utils.Assert(!sps.VUI.BitstreamRestrictionFlag && sps.MaxNumReorderFrames == -1 && sps.MayHaveBFrames())
+
+	sps, err = ParseSPS(newTestSPS(0))
+	if err != nil {
+		panic(err)
+	}
+
+	utils.Assert(sps.VUI == nil && sps.MaxNumReorderFrames == -1)
+}
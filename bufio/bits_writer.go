@@ -41,3 +41,30 @@ func (b *BitsWriter) WriteBytes(data []byte) {
 	copy(b.Data[b.Offset/8:], data)
 	b.Offset += len(data) * 8
 }
+
+// WriteUE 写入ue(v)
+func (b *BitsWriter) WriteUE(value int) {
+	var length int
+	for v := value + 1; v > 1; v >>= 1 {
+		length++
+	}
+
+	b.Write(length, 0)
+	b.Write(length+1, uint64(value+1))
+}
+
+// WriteSE 写入se(v), 正数映射为2v-1, 负数映射为-2v
+func (b *BitsWriter) WriteSE(value int) {
+	if value > 0 {
+		b.WriteUE(2*value - 1)
+	} else {
+		b.WriteUE(-2 * value)
+	}
+}
+
+// WriteTrailingBits 写入rbsp_trailing_bits, 返回按字节对齐后的数据
+func (b *BitsWriter) WriteTrailingBits() []byte {
+	b.Write(1, 1)
+	b.Offset = (b.Offset + 7) &^ 7
+	return b.Data[:b.Offset/8]
+}
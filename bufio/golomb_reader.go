@@ -5,9 +5,11 @@ import (
 )
 
 type GolombBitReader struct {
-	R    io.Reader
-	buf  [1]byte
-	left byte
+	R      io.Reader
+	buf    [1]byte
+	left   byte
+	offset int   // 已读取的bit数
+	err    error // Flag/Bits/UE/SE第一次读取失败的错误
 }
 
 func (self *GolombBitReader) ReadBit() (res uint, err error) {
@@ -18,6 +20,7 @@ func (self *GolombBitReader) ReadBit() (res uint, err error) {
 		self.left = 8
 	}
 	self.left--
+	self.offset++
 	res = uint(self.buf[0]>>self.left) & 1
 	return
 }
@@ -80,10 +83,76 @@ func (self *GolombBitReader) ReadSE() (res uint, err error) {
 	if res, err = self.ReadExponentialGolombCode(); err != nil {
 		return
 	}
+	// 负数以补码形式返回, 调用方转换为int
 	if res&0x01 != 0 {
 		res = (res + 1) / 2
 	} else {
-		res = -res / 2
+		res = uint(-int(res / 2))
 	}
 	return
 }
+
+// Offset 返回已读取的bit数
+func (self *GolombBitReader) Offset() int {
+	return self.offset
+}
+
+// Err 返回Flag/Bits/UE/SE第一次读取失败的错误, 解析完多个字段后统一判断
+func (self *GolombBitReader) Err() error {
+	return self.err
+}
+
+// SetErr 字段校验失败时设置错误, 之后的读取都返回0
+func (self *GolombBitReader) SetErr(err error) {
+	if self.err == nil {
+		self.err = err
+	}
+}
+
+func (self *GolombBitReader) Bits(n int) int {
+	if self.err != nil {
+		return 0
+	}
+
+	var v uint
+	v, self.err = self.ReadBits(n)
+	return int(v)
+}
+
+func (self *GolombBitReader) Flag() bool {
+	return self.Bits(1) == 1
+}
+
+// UE 读取ue(v)
+func (self *GolombBitReader) UE() int {
+	if self.err != nil {
+		return 0
+	}
+
+	var v uint
+	v, self.err = self.ReadExponentialGolombCode()
+	return int(v)
+}
+
+// SE 读取se(v)
+func (self *GolombBitReader) SE() int {
+	if self.err != nil {
+		return 0
+	}
+
+	var v uint
+	v, self.err = self.ReadSE()
+	return int(v)
+}
+
+// Skip 跳过n个bit
+func (self *GolombBitReader) Skip(n int) {
+	for ; n > 0 && self.err == nil; n-- {
+		_, self.err = self.ReadBit()
+	}
+}
+
+// ByteAlign 跳到下一个字节的开始
+func (self *GolombBitReader) ByteAlign() {
+	self.Skip(int(self.left))
+}
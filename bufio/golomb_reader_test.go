@@ -0,0 +1,44 @@
+package bufio
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestGolombReadSE(t *testing.T) {
+	values := []int{0, 1, -1, 2, -2, 127, -128, -100000}
+	writer := &BitsWriter{Data: make([]byte, 64)}
+	for _, value := range values {
+		writer.WriteSE(value)
+	}
+
+	reader := &GolombBitReader{R: bytes.NewReader(writer.Data)}
+	for _, value := range values {
+		se, err := reader.ReadSE()
+		if err != nil {
+			panic(err)
+		} else if int(se) != value {
+			t.Fatalf("expected %d, got %d", value, int(se))
+		}
+	}
+}
+
+func TestGolombStickyError(t *testing.T) {
+	// 1 010 011 00100 0000...
+	reader := &GolombBitReader{R: bytes.NewReader([]byte{0xA6, 0x40})}
+	if !reader.Flag() || reader.UE() != 1 || reader.SE() != -1 || reader.UE() != 3 {
+		t.Fatalf("invalid value")
+	} else if reader.Offset() != 12 || reader.Err() != nil {
+		t.Fatalf("offset %d err %v", reader.Offset(), reader.Err())
+	}
+
+	reader.ByteAlign()
+	if reader.Offset() != 16 || reader.Err() != nil {
+		t.Fatalf("offset %d err %v", reader.Offset(), reader.Err())
+	}
+
+	// 读取失败后都返回0
+	if reader.Bits(8) != 0 || reader.UE() != 0 || reader.Err() == nil {
+		t.Fatalf("expected error")
+	}
+}
@@ -2,8 +2,13 @@ package avformat
 
 import (
 	"fmt"
+	"github.com/lkmio/avformat/av1"
 	"github.com/lkmio/avformat/avc"
 	"github.com/lkmio/avformat/hevc"
+	"github.com/lkmio/avformat/utils"
+	"github.com/lkmio/avformat/vp8"
+	"github.com/lkmio/avformat/vp9"
+	"github.com/lkmio/avformat/vvc"
 )
 
 type CodecData interface {
@@ -37,7 +42,9 @@ func (c *codecData) Height() int {
 
 type AVCCodecData struct {
 	codecData
-	Record *avc.AVCDecoderConfigurationRecord
+	Record  *avc.AVCDecoderConfigurationRecord
+	SPSInfo *avc.SPS // 第一个SPS的解析结果, 包含VUI
+	PPSInfo *avc.PPS // 第一个PPS的解析结果, 解析失败为nil
 }
 
 func (h AVCCodecData) AnnexBExtraData() []byte {
@@ -66,7 +73,10 @@ func (h AVCCodecData) PPS() [][]byte {
 
 type HEVCCodecData struct {
 	codecData
-	Record *hevc.HEVCDecoderConfigurationRecord
+	Record  *hevc.HEVCDecoderConfigurationRecord
+	SPSInfo *hevc.HEVCSPSInfo // 第一个SPS的解析结果, 包含位深, 色度格式和VUI
+	PPSInfo *hevc.PPS         // 第一个PPS的解析结果, 解析失败为nil
+	VPSInfo *hevc.VPS         // 第一个VPS的解析结果, 解析失败为nil
 }
 
 func (h HEVCCodecData) SPS() [][]byte {
@@ -97,6 +107,102 @@ func (h HEVCCodecData) MP4ExtraData() []byte {
 	return h.m4vc
 }
 
+type VVCCodecData struct {
+	codecData
+	Record *vvc.VvcDecoderConfigurationRecord
+}
+
+func (v VVCCodecData) SPS() [][]byte {
+	return v.Record.SPSList
+}
+
+func (v VVCCodecData) PPS() [][]byte {
+	return v.Record.PPSList
+}
+
+func (v VVCCodecData) VPS() [][]byte {
+	return v.Record.VPSList
+}
+
+func (v VVCCodecData) AnnexBExtraData() []byte {
+	if v.annexB == nil {
+		v.annexB = mix(v.Record.VPSList, v.Record.SPSList, v.Record.PPSList)
+	}
+
+	return v.annexB
+}
+
+func (v VVCCodecData) MP4ExtraData() []byte {
+	if v.m4vc == nil {
+		v.m4vc, _ = v.Record.Marshal(v.Record.VPSList, v.Record.SPSList, v.Record.PPSList)
+	}
+
+	return v.m4vc
+}
+
+type AV1CodecData struct {
+	codecData
+	Record         *av1.AV1CodecConfigurationRecord
+	SequenceHeader *av1.SequenceHeader
+}
+
+// AnnexBExtraData 返回sequence header obu, 与关键帧中携带的格式一致
+func (a AV1CodecData) AnnexBExtraData() []byte {
+	return a.Record.ConfigOBUs
+}
+
+func (a AV1CodecData) MP4ExtraData() []byte {
+	if a.m4vc == nil {
+		a.m4vc = a.Record.Marshal()
+	}
+
+	return a.m4vc
+}
+
+func (a AV1CodecData) SPS() [][]byte {
+	return nil
+}
+
+func (a AV1CodecData) PPS() [][]byte {
+	return nil
+}
+
+// vpxCodecData VP8和VP9没有参数集, 编码器信息来自关键帧的frame header
+type vpxCodecData struct {
+	codecData
+	Record *vp9.VPCodecConfigurationRecord
+}
+
+func (v vpxCodecData) AnnexBExtraData() []byte {
+	return nil
+}
+
+func (v vpxCodecData) MP4ExtraData() []byte {
+	if v.m4vc == nil {
+		v.m4vc = v.Record.Marshal()
+	}
+
+	return v.m4vc
+}
+
+func (v vpxCodecData) SPS() [][]byte {
+	return nil
+}
+
+func (v vpxCodecData) PPS() [][]byte {
+	return nil
+}
+
+type VP8CodecData struct {
+	vpxCodecData
+	Header *vp8.FrameHeader // 从vpcC创建时为空
+}
+
+type VP9CodecData struct {
+	vpxCodecData
+	Header *vp9.FrameHeader // 从vpcC创建时为空
+}
+
 func ParseAVCDecoderConfigurationRecord(data []byte) (CodecData, error) {
 	configurationRecord := avc.AVCDecoderConfigurationRecord{}
 	if err := configurationRecord.Unmarshal(data); err != nil {
@@ -114,7 +220,9 @@ func ParseAVCDecoderConfigurationRecord(data []byte) (CodecData, error) {
 			width:  sps.Width,
 			height: sps.Height,
 		},
-		Record: &configurationRecord,
+		Record:  &configurationRecord,
+		SPSInfo: &sps,
+		PPSInfo: parseAVCPPS(configurationRecord.PPSList),
 	}
 
 	return &avcCodecData, nil
@@ -132,6 +240,31 @@ func ParseHEVCDecoderConfigurationRecord(data []byte) (CodecData, error) {
 	}
 
 	c := HEVCCodecData{
+		codecData: codecData{
+			m4vc:   data,
+			width:  sps.Width,
+			height: sps.Height,
+		},
+		Record:  &configurationRecord,
+		SPSInfo: &sps,
+		PPSInfo: parseHEVCPPS(configurationRecord.PPSList),
+		VPSInfo: parseHEVCVPS(configurationRecord.VPSList),
+	}
+	return &c, nil
+}
+
+func ParseVVCDecoderConfigurationRecord(data []byte) (CodecData, error) {
+	configurationRecord := vvc.VvcDecoderConfigurationRecord{}
+	if err := configurationRecord.Unmarshal(data); err != nil {
+		return nil, err
+	}
+
+	sps, err := vvc.ParseSPS(configurationRecord.SPSList[0])
+	if err != nil {
+		return nil, err
+	}
+
+	c := VVCCodecData{
 		codecData: codecData{
 			m4vc:   data,
 			width:  sps.Width,
@@ -142,6 +275,50 @@ func ParseHEVCDecoderConfigurationRecord(data []byte) (CodecData, error) {
 	return &c, nil
 }
 
+func ParseAV1CodecConfigurationRecord(data []byte) (CodecData, error) {
+	configurationRecord := av1.AV1CodecConfigurationRecord{}
+	if err := configurationRecord.Unmarshal(data); err != nil {
+		return nil, err
+	}
+
+	sequenceHeader, err := configurationRecord.SequenceHeader()
+	if err != nil {
+		return nil, err
+	}
+
+	c := AV1CodecData{
+		codecData: codecData{
+			m4vc:   data,
+			width:  sequenceHeader.Width(),
+			height: sequenceHeader.Height(),
+		},
+		Record:         &configurationRecord,
+		SequenceHeader: &sequenceHeader,
+	}
+	return &c, nil
+}
+
+// ParseVPCodecConfigurationRecord 解析vpcC, vpcC不包含宽高
+func ParseVPCodecConfigurationRecord(id utils.AVCodecID, data []byte) (CodecData, error) {
+	configurationRecord := vp9.VPCodecConfigurationRecord{}
+	if err := configurationRecord.Unmarshal(data); err != nil {
+		return nil, err
+	}
+
+	c := vpxCodecData{
+		codecData: codecData{
+			m4vc: data,
+		},
+		Record: &configurationRecord,
+	}
+
+	if utils.AVCodecIdVP8 == id {
+		return &VP8CodecData{vpxCodecData: c}, nil
+	}
+
+	return &VP9CodecData{vpxCodecData: c}, nil
+}
+
 func mix(data ...[][]byte) []byte {
 	var extra []byte
 	for _, v := range data {
@@ -153,53 +330,207 @@ func mix(data ...[][]byte) []byte {
 	return extra
 }
 
+// parseAVCPPS 解析第一个PPS, PPS不影响CodecData的创建, 解析失败返回nil
+func parseAVCPPS(list [][]byte) *avc.PPS {
+	if len(list) == 0 {
+		return nil
+	}
+
+	pps, err := avc.ParsePPS(list[0])
+	if err != nil {
+		return nil
+	}
+
+	return pps
+}
+
+func parseHEVCPPS(list [][]byte) *hevc.PPS {
+	if len(list) == 0 {
+		return nil
+	}
+
+	pps, err := hevc.ParsePPS(list[0])
+	if err != nil {
+		return nil
+	}
+
+	return pps
+}
+
+func parseHEVCVPS(list [][]byte) *hevc.VPS {
+	if len(list) == 0 {
+		return nil
+	}
+
+	vps, err := hevc.ParseVPS(list[0])
+	if err != nil {
+		return nil
+	}
+
+	return vps
+}
+
 func NewAVCCodecData(sps, pps []byte) (CodecData, error) {
-	spsInfo, err := avc.ParseSPS(sps)
+	return NewAVCCodecDataWithParameterSets([][]byte{sps}, [][]byte{pps})
+}
+
+// NewAVCCodecDataWithParameterSets 保留所有的SPS和PPS, 宽高等信息取自第一个SPS
+func NewAVCCodecDataWithParameterSets(spsList, ppsList [][]byte) (CodecData, error) {
+	if len(spsList) == 0 || len(ppsList) == 0 {
+		return nil, fmt.Errorf("sps or pps not found")
+	}
+
+	spsInfo, err := avc.ParseSPS(spsList[0])
 	if err != nil {
 		return nil, fmt.Errorf("h264parser: parse SPS failed(%s)", err)
 	}
 
 	recordInfo := avc.AVCDecoderConfigurationRecord{
-		SPSList: make([][]byte, 1),
-		PPSList: make([][]byte, 1),
+		SPSList: spsList,
+		PPSList: ppsList,
 	}
 
-	recordInfo.SPSList[0] = sps
-	recordInfo.PPSList[0] = pps
-
 	c := AVCCodecData{codecData: codecData{
 		annexB: mix(recordInfo.SPSList, recordInfo.PPSList),
 		width:  spsInfo.Width,
 		height: spsInfo.Height,
 	},
-		Record: &recordInfo,
+		Record:  &recordInfo,
+		SPSInfo: &spsInfo,
+		PPSInfo: parseAVCPPS(recordInfo.PPSList),
 	}
 
 	return &c, nil
 }
 
 func NewHEVCCodecData(vps, sps, pps []byte) (CodecData, error) {
-	spsInfo, err := hevc.ParseSPS(sps)
+	return NewHEVCCodecDataWithParameterSets([][]byte{vps}, [][]byte{sps}, [][]byte{pps})
+}
+
+// NewHEVCCodecDataWithParameterSets 保留所有的VPS, SPS和PPS, 宽高等信息取自第一个SPS
+func NewHEVCCodecDataWithParameterSets(vpsList, spsList, ppsList [][]byte) (CodecData, error) {
+	if len(vpsList) == 0 || len(spsList) == 0 || len(ppsList) == 0 {
+		return nil, fmt.Errorf("vps, sps or pps not found")
+	}
+
+	spsInfo, err := hevc.ParseSPS(spsList[0])
 	if err != nil {
 		return nil, fmt.Errorf("h265parser: parse SPS failed(%s)", err)
 	}
 
 	recordInfo := hevc.HEVCDecoderConfigurationRecord{
-		VPSList: make([][]byte, 1),
-		SPSList: make([][]byte, 1),
-		PPSList: make([][]byte, 1),
+		VPSList: vpsList,
+		SPSList: spsList,
+		PPSList: ppsList,
 	}
 
-	recordInfo.VPSList[0] = vps
-	recordInfo.SPSList[0] = sps
-	recordInfo.PPSList[0] = pps
-
 	c := HEVCCodecData{codecData: codecData{
 		annexB: mix(recordInfo.VPSList, recordInfo.SPSList, recordInfo.PPSList),
 		width:  spsInfo.Width,
 		height: spsInfo.Height,
 	},
-		Record: &recordInfo,
+		Record:  &recordInfo,
+		SPSInfo: &spsInfo,
+		PPSInfo: parseHEVCPPS(recordInfo.PPSList),
+		VPSInfo: parseHEVCVPS(recordInfo.VPSList),
+	}
+
+	return &c, nil
+}
+
+// NewVVCCodecData vps可以为空
+func NewVVCCodecData(vps, sps, pps []byte) (CodecData, error) {
+	recordInfo, spsInfo, err := vvc.NewVvcDecoderConfigurationRecord(vps, sps, pps)
+	if err != nil {
+		return nil, fmt.Errorf("h266parser: parse SPS failed(%s)", err)
+	}
+
+	c := VVCCodecData{codecData: codecData{
+		annexB: mix(recordInfo.VPSList, recordInfo.SPSList, recordInfo.PPSList),
+		width:  spsInfo.Width,
+		height: spsInfo.Height,
+	},
+		Record: recordInfo,
+	}
+
+	return &c, nil
+}
+
+// NewAV1CodecData 使用sequence header obu(Low Overhead Bitstream Format)创建CodecData
+func NewAV1CodecData(sequenceHeader []byte) (CodecData, error) {
+	record, header, err := av1.NewAV1CodecConfigurationRecord(sequenceHeader)
+	if err != nil {
+		return nil, fmt.Errorf("av1parser: parse sequence header failed(%s)", err)
+	}
+
+	c := AV1CodecData{codecData: codecData{
+		annexB: sequenceHeader,
+		width:  header.Width(),
+		height: header.Height(),
+	},
+		Record:         record,
+		SequenceHeader: header,
+	}
+
+	return &c, nil
+}
+
+// NewVP8CodecData 使用关键帧创建CodecData
+func NewVP8CodecData(keyFrame []byte) (CodecData, error) {
+	header, err := vp8.ParseFrameHeader(keyFrame)
+	if err != nil {
+		return nil, fmt.Errorf("vp8parser: parse frame header failed(%s)", err)
+	} else if !header.KeyFrame {
+		return nil, fmt.Errorf("vp8parser: not a key frame")
+	}
+
+	// VP8只支持8bit 4:2:0
+	record := &vp9.VPCodecConfigurationRecord{
+		Profile:                 byte(header.Version),
+		BitDepth:                8,
+		ChromaSubsampling:       vp9.ChromaSubsampling420Vertical,
+		ColourPrimaries:         2,
+		TransferCharacteristics: 2,
+		MatrixCoefficients:      2,
+	}
+
+	c := VP8CodecData{
+		vpxCodecData: vpxCodecData{
+			codecData: codecData{
+				width:  header.Width,
+				height: header.Height,
+			},
+			Record: record,
+		},
+		Header: &header,
+	}
+
+	return &c, nil
+}
+
+// NewVP9CodecData 使用关键帧创建CodecData, 支持superframe
+func NewVP9CodecData(keyFrame []byte) (CodecData, error) {
+	frames, err := vp9.SplitSuperframe(keyFrame)
+	if err != nil {
+		return nil, fmt.Errorf("vp9parser: parse superframe failed(%s)", err)
+	}
+
+	header, err := vp9.ParseFrameHeader(frames[0])
+	if err != nil {
+		return nil, fmt.Errorf("vp9parser: parse frame header failed(%s)", err)
+	} else if !header.KeyFrame {
+		return nil, fmt.Errorf("vp9parser: not a key frame")
+	}
+
+	c := VP9CodecData{
+		vpxCodecData: vpxCodecData{
+			codecData: codecData{
+				width:  header.Width,
+				height: header.Height,
+			},
+			Record: vp9.NewVPCodecConfigurationRecord(&header),
+		},
+		Header: &header,
 	}
 
 	return &c, nil
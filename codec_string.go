@@ -0,0 +1,334 @@
+package avformat
+
+import (
+	"fmt"
+	"github.com/lkmio/avformat/avc"
+	"github.com/lkmio/avformat/hevc"
+	"github.com/lkmio/avformat/utils"
+	"math/bits"
+	"strconv"
+	"strings"
+)
+
+// CodecStringInfo RFC 6381 codecs参数中一项的解析结果, 未出现的字段为0
+type CodecStringInfo struct {
+	CodecID   utils.AVCodecID
+	MediaType utils.AVMediaType
+	Tag       string // sample entry, 例如avc1/hvc1/mp4a
+
+	Profile                  int
+	ProfileSpace             int    // hevc general_profile_space
+	ProfileCompatibility     uint32 // avc的constraint_set flags, hevc的general_profile_compatibility_flags
+	ConstraintIndicatorFlags uint64 // hevc general_constraint_indicator_flags
+	Level                    int    // avc/hevc为level_idc, vp9为level*10, av1为seq_level_idx
+	Tier                     int
+	BitDepth                 int
+	ObjectType               int // mp4a的audio object type
+}
+
+// CodecString 生成RFC 6381的codecs参数, 用于HLS的CODECS, DASH的@codecs和MSE的isTypeSupported
+func (s *AVStream) CodecString() (string, error) {
+	switch s.CodecID {
+	case utils.AVCodecIdH264:
+		if s.CodecParameters == nil || len(s.CodecParameters.SPS()) == 0 {
+			return "", fmt.Errorf("sps not found")
+		}
+
+		sps := avc.RemoveStartCode(s.CodecParameters.SPS()[0])
+		if len(sps) < 4 {
+			return "", fmt.Errorf("invalid sps")
+		}
+
+		return fmt.Sprintf("avc1.%02X%02X%02X", sps[1], sps[2], sps[3]), nil
+	case utils.AVCodecIdH265:
+		codecData, ok := s.CodecParameters.(*HEVCCodecData)
+		if !ok || codecData.SPSInfo == nil {
+			return "", fmt.Errorf("sps not found")
+		}
+
+		return hevcCodecString(codecData.SPSInfo), nil
+	case utils.AVCodecIdAV1:
+		codecData, ok := s.CodecParameters.(*AV1CodecData)
+		if !ok {
+			return "", fmt.Errorf("sequence header not found")
+		}
+
+		return av1CodecString(codecData), nil
+	case utils.AVCodecIdVP9:
+		codecData, ok := s.CodecParameters.(*VP9CodecData)
+		if !ok {
+			return "", fmt.Errorf("vpcC not found")
+		}
+
+		record := codecData.Record
+		codec := fmt.Sprintf("vp09.%02d.%02d.%02d", record.Profile, record.Level, record.BitDepth)
+		// 可选字段全部为默认值(4:2:0 colocated, bt709, limited range)时省略
+		if record.ChromaSubsampling != 1 || record.ColourPrimaries != 1 || record.TransferCharacteristics != 1 || record.MatrixCoefficients != 1 || record.VideoFullRangeFlag != 0 {
+			codec += fmt.Sprintf(".%02d.%02d.%02d.%02d.%02d", record.ChromaSubsampling, record.ColourPrimaries, record.TransferCharacteristics, record.MatrixCoefficients, record.VideoFullRangeFlag)
+		}
+
+		return codec, nil
+	case utils.AVCodecIdVP8:
+		return "vp8", nil
+	case utils.AVCodecIdAAC, utils.AVCodecIdAACLATM:
+		// 没有AudioSpecificConfig时默认AAC-LC
+		objectType := int(utils.AotAacLc)
+		if len(s.Data) >= 2 {
+			config, err := utils.ParseMpeg4AudioConfig(s.Data)
+			if err != nil {
+				return "", err
+			}
+
+			// 显式声明的HE-AAC v1/v2
+			if objectType = config.ObjectType; config.PS == 1 {
+				objectType = int(utils.AotPs)
+			} else if config.SBR == 1 {
+				objectType = int(utils.AotSbr)
+			}
+		}
+
+		return "mp4a.40." + strconv.Itoa(objectType), nil
+	case utils.AVCodecIdMP3:
+		return "mp4a.40.34", nil
+	case utils.AVCodecIdAC3:
+		return "ac-3", nil
+	case utils.AVCodecIdEAC3:
+		return "ec-3", nil
+	case utils.AVCodecIdFLAC:
+		return "fLaC", nil
+	case utils.AVCodecIdOPUS:
+		return "opus", nil
+	}
+
+	return "", fmt.Errorf("unsupported codec %s", s.CodecID)
+}
+
+// hevcCodecString ISO/IEC 14496-15 E.3, 例如hvc1.1.6.L93.B0
+func hevcCodecString(sps *hevc.HEVCSPSInfo) string {
+	var builder strings.Builder
+	builder.WriteString("hvc1.")
+	if sps.GeneralProfileSpace > 0 {
+		builder.WriteByte(byte('A' + sps.GeneralProfileSpace - 1))
+	}
+
+	tier := 'L'
+	if sps.GeneralTierFlag == 1 {
+		tier = 'H'
+	}
+
+	// 兼容标志按位反转后输出, 约束标志省略末尾为0的字节
+	fmt.Fprintf(&builder, "%d.%X.%c%d", sps.GeneralProfileIDC, bits.Reverse32(sps.GeneralProfileCompatibilityFlags), tier, sps.GeneralLevelIDC)
+	constraint := sps.GeneralConstraintIndicatorFlags & 0xFFFFFFFFFFFF
+	for i := 5; constraint != 0; i-- {
+		fmt.Fprintf(&builder, ".%X", constraint>>(8*i)&0xFF)
+		constraint &= 1<<(8*i) - 1
+	}
+
+	return builder.String()
+}
+
+// av1CodecString AV1 Codec ISO Media File Format Binding 5, 例如av01.0.04M.08
+func av1CodecString(codecData *AV1CodecData) string {
+	record := codecData.Record
+	tier := 'M'
+	if record.SeqTier0 == 1 {
+		tier = 'H'
+	}
+
+	bitDepth := 8
+	if record.HighBitDepth == 1 && record.TwelveBit == 1 {
+		bitDepth = 12
+	} else if record.HighBitDepth == 1 {
+		bitDepth = 10
+	}
+
+	codec := fmt.Sprintf("av01.%d.%02d%c.%02d", record.SeqProfile, record.SeqLevelIdx0, tier, bitDepth)
+	header := codecData.SequenceHeader
+	if header == nil {
+		return codec
+	}
+
+	// 没有color_description时颜色参数按默认值bt709输出, color_range仍然有效
+	colorPrimaries, transferCharacteristics, matrixCoefficients := 1, 1, 1
+	if header.ColorDescriptionPresent {
+		colorPrimaries, transferCharacteristics, matrixCoefficients = header.ColorPrimaries, header.TransferCharacteristics, header.MatrixCoefficients
+	}
+
+	// 可选字段全部为默认值(4:2:0, bt709, limited range)时省略
+	chromaSamplePosition := 0
+	if record.ChromaSubsamplingX == 1 && record.ChromaSubsamplingY == 1 {
+		chromaSamplePosition = int(record.ChromaSamplePosition)
+	}
+
+	if record.MonoChrome == 0 && record.ChromaSubsamplingX == 1 && record.ChromaSubsamplingY == 1 && chromaSamplePosition == 0 &&
+		colorPrimaries == 1 && transferCharacteristics == 1 && matrixCoefficients == 1 && header.ColorRange == 0 {
+		return codec
+	}
+
+	return codec + fmt.Sprintf(".%d.%d%d%d.%02d.%02d.%02d.%d", record.MonoChrome, record.ChromaSubsamplingX, record.ChromaSubsamplingY, chromaSamplePosition,
+		colorPrimaries, transferCharacteristics, matrixCoefficients, header.ColorRange)
+}
+
+// ParseCodecString 解析RFC 6381 codecs参数中的一项
+func ParseCodecString(codec string) (*CodecStringInfo, error) {
+	fields := strings.Split(strings.TrimSpace(codec), ".")
+	info := &CodecStringInfo{Tag: fields[0], MediaType: utils.AVMediaTypeVideo}
+	fields = fields[1:]
+
+	var err error
+	switch strings.ToLower(info.Tag) {
+	case "avc1", "avc3":
+		info.CodecID = utils.AVCodecIdH264
+		if len(fields) < 1 {
+			return nil, fmt.Errorf("invalid codec string %s", codec)
+		} else if len(fields) == 1 {
+			// avc1.PPCCLL
+			value, err := strconv.ParseUint(fields[0], 16, 32)
+			if err != nil || len(fields[0]) != 6 {
+				return nil, fmt.Errorf("invalid avc codec string %s", codec)
+			}
+
+			info.Profile = int(value >> 16)
+			info.ProfileCompatibility = uint32(value >> 8 & 0xFF)
+			info.Level = int(value & 0xFF)
+		} else {
+			// 旧版本iOS使用的avc1.66.30
+			if info.Profile, err = strconv.Atoi(fields[0]); err != nil {
+				return nil, err
+			} else if info.Level, err = strconv.Atoi(fields[1]); err != nil {
+				return nil, err
+			}
+		}
+	case "hvc1", "hev1":
+		info.CodecID = utils.AVCodecIdH265
+		if len(fields) < 3 {
+			return nil, fmt.Errorf("invalid codec string %s", codec)
+		}
+
+		profile := fields[0]
+		if len(profile) > 0 && profile[0] >= 'A' && profile[0] <= 'C' {
+			info.ProfileSpace = int(profile[0]-'A') + 1
+			profile = profile[1:]
+		}
+
+		compatibility, err := strconv.ParseUint(fields[1], 16, 32)
+		if err != nil {
+			return nil, err
+		} else if info.Profile, err = strconv.Atoi(profile); err != nil {
+			return nil, err
+		} else if len(fields[2]) < 2 || (fields[2][0] != 'L' && fields[2][0] != 'H') {
+			return nil, fmt.Errorf("invalid hevc tier %s", fields[2])
+		} else if info.Level, err = strconv.Atoi(fields[2][1:]); err != nil {
+			return nil, err
+		} else if len(fields) > 9 {
+			return nil, fmt.Errorf("too many hevc constraint flags")
+		}
+
+		info.ProfileCompatibility = bits.Reverse32(uint32(compatibility))
+		if fields[2][0] == 'H' {
+			info.Tier = 1
+		}
+
+		for i, field := range fields[3:] {
+			value, err := strconv.ParseUint(field, 16, 8)
+			if err != nil {
+				return nil, err
+			}
+
+			info.ConstraintIndicatorFlags |= value << (8 * (5 - i))
+		}
+	case "av01":
+		info.CodecID = utils.AVCodecIdAV1
+		if len(fields) < 3 {
+			return nil, fmt.Errorf("invalid codec string %s", codec)
+		} else if len(fields[1]) != 3 || (fields[1][2] != 'M' && fields[1][2] != 'H') {
+			return nil, fmt.Errorf("invalid av1 level %s", fields[1])
+		} else if info.Profile, err = strconv.Atoi(fields[0]); err != nil {
+			return nil, err
+		} else if info.Level, err = strconv.Atoi(fields[1][:2]); err != nil {
+			return nil, err
+		} else if info.BitDepth, err = strconv.Atoi(fields[2]); err != nil {
+			return nil, err
+		}
+
+		if fields[1][2] == 'H' {
+			info.Tier = 1
+		}
+	case "vp09":
+		info.CodecID = utils.AVCodecIdVP9
+		if len(fields) < 3 {
+			return nil, fmt.Errorf("invalid codec string %s", codec)
+		} else if info.Profile, err = strconv.Atoi(fields[0]); err != nil {
+			return nil, err
+		} else if info.Level, err = strconv.Atoi(fields[1]); err != nil {
+			return nil, err
+		} else if info.BitDepth, err = strconv.Atoi(fields[2]); err != nil {
+			return nil, err
+		}
+	case "vp9":
+		info.CodecID = utils.AVCodecIdVP9
+	case "vp8", "vp08":
+		info.CodecID = utils.AVCodecIdVP8
+	case "mp4a":
+		info.MediaType = utils.AVMediaTypeAudio
+		if len(fields) < 1 {
+			return nil, fmt.Errorf("invalid codec string %s", codec)
+		}
+
+		// mp4a.OTI[.AOT], OTI为十六进制
+		oti, err := strconv.ParseUint(fields[0], 16, 8)
+		if err != nil {
+			return nil, err
+		}
+
+		switch oti {
+		case 0x40, 0x66, 0x67, 0x68:
+			info.CodecID = utils.AVCodecIdAAC
+			info.ObjectType = int(utils.AotAacLc)
+			if len(fields) > 1 {
+				if info.ObjectType, err = strconv.Atoi(fields[1]); err != nil {
+					return nil, err
+				}
+			}
+
+			if utils.AudioObjectType(info.ObjectType) == utils.AotL3 {
+				info.CodecID = utils.AVCodecIdMP3
+			}
+		case 0x69, 0x6B:
+			info.CodecID = utils.AVCodecIdMP3
+		case 0xA5:
+			info.CodecID = utils.AVCodecIdAC3
+		case 0xA6:
+			info.CodecID = utils.AVCodecIdEAC3
+		default:
+			return nil, fmt.Errorf("unsupported object type indication %X", oti)
+		}
+	case "ac-3":
+		info.CodecID, info.MediaType = utils.AVCodecIdAC3, utils.AVMediaTypeAudio
+	case "ec-3":
+		info.CodecID, info.MediaType = utils.AVCodecIdEAC3, utils.AVMediaTypeAudio
+	case "flac":
+		info.CodecID, info.MediaType = utils.AVCodecIdFLAC, utils.AVMediaTypeAudio
+	case "opus":
+		info.CodecID, info.MediaType = utils.AVCodecIdOPUS, utils.AVMediaTypeAudio
+	default:
+		return nil, fmt.Errorf("unsupported codec %s", codec)
+	}
+
+	return info, nil
+}
+
+// ParseCodecs 解析逗号分隔的codecs参数, 例如HLS的CODECS="avc1.64001F,mp4a.40.2"
+func ParseCodecs(value string) ([]*CodecStringInfo, error) {
+	var infos []*CodecStringInfo
+	for _, codec := range strings.Split(strings.Trim(strings.TrimSpace(value), "\""), ",") {
+		info, err := ParseCodecString(codec)
+		if err != nil {
+			return nil, err
+		}
+
+		infos = append(infos, info)
+	}
+
+	return infos, nil
+}
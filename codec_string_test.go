@@ -0,0 +1,124 @@
+package avformat
+
+import (
+	"encoding/hex"
+	"github.com/lkmio/avformat/av1"
+	"github.com/lkmio/avformat/hevc"
+	"github.com/lkmio/avformat/utils"
+	"github.com/lkmio/avformat/vp9"
+	"testing"
+)
+
+func assertCodecString(stream *AVStream, expected string) {
+	codec, err := stream.CodecString()
+	if err != nil {
+		panic(err)
+	} else if codec != expected {
+		panic(codec + " != " + expected)
+	}
+}
+
+func TestCodecString(t *testing.T) {
+	// avc
+	codecData, err := NewAVCCodecData(newTestSPS(100, -1), newTestPPS())
+	if err != nil {
+		panic(err)
+	}
+
+	assertCodecString(&AVStream{CodecID: utils.AVCodecIdH264, CodecParameters: codecData}, "avc1.64001F")
+
+	// hevc, 兼容标志按位反转, 约束标志省略末尾为0的字节
+	sps := &hevc.HEVCSPSInfo{GeneralProfileIDC: 1, GeneralProfileCompatibilityFlags: 0x60000000, GeneralLevelIDC: 93, GeneralConstraintIndicatorFlags: 0xB00000000000}
+	assertCodecString(&AVStream{CodecID: utils.AVCodecIdH265, CodecParameters: &HEVCCodecData{SPSInfo: sps}}, "hvc1.1.6.L93.B0")
+
+	sps = &hevc.HEVCSPSInfo{GeneralProfileSpace: 1, GeneralTierFlag: 1, GeneralProfileIDC: 4, GeneralProfileCompatibilityFlags: 0x08000000, GeneralLevelIDC: 153, GeneralConstraintIndicatorFlags: 0x9C0000100000}
+	assertCodecString(&AVStream{CodecID: utils.AVCodecIdH265, CodecParameters: &HEVCCodecData{SPSInfo: sps}}, "hvc1.A4.10.H153.9C.0.0.10")
+
+	// aac
+	for config, expected := range map[string]string{"1210": "mp4a.40.2", "2b118800": "mp4a.40.5", "eb098800": "mp4a.40.29"} {
+		data, _ := hex.DecodeString(config)
+		assertCodecString(&AVStream{CodecID: utils.AVCodecIdAAC, Data: data}, expected)
+	}
+
+	// vp9
+	record := vp9.VPCodecConfigurationRecord{Level: 10, BitDepth: 8, ChromaSubsampling: 1, ColourPrimaries: 1, TransferCharacteristics: 1, MatrixCoefficients: 1}
+	vp9Data, err := ParseVPCodecConfigurationRecord(utils.AVCodecIdVP9, record.Marshal())
+	if err != nil {
+		panic(err)
+	}
+
+	assertCodecString(&AVStream{CodecID: utils.AVCodecIdVP9, CodecParameters: vp9Data}, "vp09.00.10.08")
+
+	// av1
+	av1Record := &av1.AV1CodecConfigurationRecord{SeqLevelIdx0: 4, ChromaSubsamplingX: 1, ChromaSubsamplingY: 1}
+	header := &av1.SequenceHeader{ColorPrimaries: 2, TransferCharacteristics: 2, MatrixCoefficients: 2}
+	av1Stream := &AVStream{CodecID: utils.AVCodecIdAV1, CodecParameters: &AV1CodecData{Record: av1Record, SequenceHeader: header}}
+	assertCodecString(av1Stream, "av01.0.04M.08")
+
+	// full range必须输出完整格式
+	header.ColorRange = 1
+	assertCodecString(av1Stream, "av01.0.04M.08.0.110.01.01.01.1")
+
+	header.ColorDescriptionPresent, header.ColorPrimaries, header.TransferCharacteristics, header.MatrixCoefficients, header.ColorRange = true, 9, 16, 9, 0
+	assertCodecString(av1Stream, "av01.0.04M.08.0.110.09.16.09.0")
+}
+
+func TestParseCodecString(t *testing.T) {
+	info, err := ParseCodecString("avc1.64001F")
+	if err != nil {
+		panic(err)
+	}
+
+	utils.Assert(info.CodecID == utils.AVCodecIdH264 && info.Profile == 100 && info.ProfileCompatibility == 0 && info.Level == 31)
+
+	info, err = ParseCodecString("hvc1.1.6.L93.B0")
+	if err != nil {
+		panic(err)
+	}
+
+	utils.Assert(info.CodecID == utils.AVCodecIdH265 && info.Profile == 1 && info.ProfileCompatibility == 0x60000000)
+	utils.Assert(info.Tier == 0 && info.Level == 93 && info.ConstraintIndicatorFlags == 0xB00000000000)
+
+	info, err = ParseCodecString("hev1.A4.10.H153.9C.0.0.10")
+	if err != nil {
+		panic(err)
+	}
+
+	utils.Assert(info.ProfileSpace == 1 && info.Profile == 4 && info.ProfileCompatibility == 0x08000000)
+	utils.Assert(info.Tier == 1 && info.Level == 153 && info.ConstraintIndicatorFlags == 0x9C0000100000)
+
+	for codec, objectType := range map[string]int{"mp4a.40.2": 2, "mp4a.40.5": 5, "mp4a.40.29": 29} {
+		info, err = ParseCodecString(codec)
+		if err != nil {
+			panic(err)
+		}
+
+		utils.Assert(info.CodecID == utils.AVCodecIdAAC && info.MediaType == utils.AVMediaTypeAudio && info.ObjectType == objectType)
+	}
+
+	info, err = ParseCodecString("vp09.00.10.08")
+	if err != nil {
+		panic(err)
+	}
+
+	utils.Assert(info.CodecID == utils.AVCodecIdVP9 && info.Profile == 0 && info.Level == 10 && info.BitDepth == 8)
+
+	info, err = ParseCodecString("av01.0.04M.08")
+	if err != nil {
+		panic(err)
+	}
+
+	utils.Assert(info.CodecID == utils.AVCodecIdAV1 && info.Profile == 0 && info.Level == 4 && info.Tier == 0 && info.BitDepth == 8)
+
+	infos, err := ParseCodecs(`"avc1.64001F,mp4a.40.2"`)
+	if err != nil {
+		panic(err)
+	}
+
+	utils.Assert(len(infos) == 2 && infos[0].CodecID == utils.AVCodecIdH264 && infos[1].CodecID == utils.AVCodecIdAAC)
+
+	_, err = ParseCodecString("hvc1.1.6")
+	utils.Assert(err != nil)
+	_, err = ParseCodecs("avc1.64001F,unknown")
+	utils.Assert(err != nil)
+}
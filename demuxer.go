@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"github.com/lkmio/avformat/bufio"
 	"github.com/lkmio/avformat/collections"
+	"github.com/lkmio/avformat/opus"
 	"github.com/lkmio/avformat/utils"
 	"sort"
 )
@@ -38,6 +39,12 @@ type BaseDemuxer struct {
 	AutoFree                bool                                 // 回调Packet后, 是否自动释放Packet
 	streamIndex2BufferIndex map[int]int
 	onPreprocessPacket      func(packet *AVPacket)
+	latmParsers             map[int]*utils.LATMParser // LOAS流的StreamMuxConfig可能只在部分帧中出现
+	ReorderPTS              bool                      // 根据POC重建视频帧的pts, 用于每帧只有一个时间戳的带B帧码流
+	reorders                map[int]*POCReorder
+	NormalizeTimestamp      bool // 展开时间戳回绕并处理跳变, 用于长时间运行或会重启的源
+	TimestampBits           int  // 输入时间戳的有效位数, 0表示按Name推断. 例如rtp源转封装时设置为TimestampBitsRTP
+	normalizers             map[int]*TimestampNormalizer
 }
 
 func (s *BaseDemuxer) Input(data []byte) error {
@@ -60,6 +67,22 @@ func (s *BaseDemuxer) GetTimebase() int {
 	}
 }
 
+// GetTimestampBits 返回时间戳的有效位数, 0表示不回绕. 优先使用TimestampBits.
+func (s *BaseDemuxer) GetTimestampBits() int {
+	if s.TimestampBits > 0 {
+		return s.TimestampBits
+	}
+
+	switch s.Name {
+	case "ps", "ts":
+		return TimestampBitsMPEG
+	case "flv":
+		return TimestampBitsFLV
+	default:
+		return 0
+	}
+}
+
 func (s *BaseDemuxer) GetPackType() PacketType {
 
 	switch s.Name {
@@ -104,7 +127,14 @@ func (s *BaseDemuxer) createAudioTrack(bufferIndex int, id utils.AVCodecID, time
 		stream.Data = data
 	}
 
-	if utils.AVCodecIdAAC == id && !config.HasADTSHeader {
+	// LATM转换为raw AAC, extraData为AudioSpecificConfig
+	if utils.AVCodecIdAACLATM == id {
+		id = utils.AVCodecIdAAC
+		stream.CodecID = id
+		stream.HasADTSHeader = false
+	}
+
+	if utils.AVCodecIdAAC == id && !stream.HasADTSHeader {
 		utils.Assert(extraData != nil)
 		mpeg4AudioConfig, err := utils.ParseMpeg4AudioConfig(extraData)
 		if err != nil {
@@ -112,9 +142,19 @@ func (s *BaseDemuxer) createAudioTrack(bufferIndex int, id utils.AVCodecID, time
 			return nil
 		}
 
-		//, config.SampleRate, config.Channels
-		stream.AudioConfig.SampleRate = mpeg4AudioConfig.SampleRate
-		stream.AudioConfig.Channels = mpeg4AudioConfig.Channels
+		// HE-AAC使用SBR和PS的输出采样率和通道数
+		stream.AudioConfig.SampleRate = mpeg4AudioConfig.OutputSampleRate()
+		stream.AudioConfig.Channels = mpeg4AudioConfig.OutputChannels()
+	} else if utils.AVCodecIdOPUS == id && len(extraData) > 0 {
+		head, err := opus.ParseExtraData(extraData)
+		if err != nil {
+			println(err.Error())
+			return nil
+		}
+
+		// 解码输出固定为48000, InputSampleRate仅供参考
+		stream.AudioConfig.SampleRate = opus.SampleRate
+		stream.AudioConfig.Channels = head.ChannelCount
 	}
 
 	stream.Timebase = timebase
@@ -169,6 +209,11 @@ func (s *BaseDemuxer) createVideoTrack(bufferIndex int, id utils.AVCodecID, time
 }
 
 func (s *BaseDemuxer) OnAudioPacket(bufferIndex int, id utils.AVCodecID, data []byte, ts int64) {
+	if utils.AVCodecIdAACLATM == id {
+		s.onLOASPacket(bufferIndex, data, ts)
+		return
+	}
+
 	var ok bool
 	defer func() {
 		if !ok {
@@ -198,11 +243,84 @@ func (s *BaseDemuxer) OnAudioPacket(bufferIndex int, id utils.AVCodecID, data []
 		return
 	}
 
+	// opus的帧时长可从TOC获取, 不依赖下一个包的时间戳
+	if utils.AVCodecIdOPUS == id {
+		if samples, err := opus.PacketSamples(packet.Data); err == nil {
+			packet.Duration = int64(samples) * int64(track.GetStream().Timebase) / opus.SampleRate
+		}
+	}
+
 	ok = true
 	packet.BufferIndex = bufferIndex
+	s.normalizeTimestamp(track.GetStream(), packet)
 	s.processBufferedPacket(packet)
 }
 
+// onLOASPacket 将LOAS封装的AAC拆分为raw AAC packet.
+// 负载没有按字节对齐, 拷贝后每帧重新写入DataPipeline, 保证一个packet对应一块内存.
+func (s *BaseDemuxer) onLOASPacket(bufferIndex int, data []byte, ts int64) {
+	parser, ok := s.latmParsers[bufferIndex]
+	if !ok {
+		if s.latmParsers == nil {
+			s.latmParsers = make(map[int]*utils.LATMParser)
+		}
+
+		parser = utils.NewLATMParser(nil)
+		s.latmParsers[bufferIndex] = parser
+	}
+
+	var frames [][]byte
+	err := utils.SplitLOAS(data, func(element []byte) bool {
+		payloads, err := parser.ParseAudioMuxElement(element, true)
+		if err != nil {
+			println(err.Error())
+			return true
+		}
+
+		frames = append(frames, payloads...)
+		return true
+	})
+
+	s.DataPipeline.DiscardBackPacket(bufferIndex)
+	if err != nil {
+		println(err.Error())
+	}
+
+	track := s.findTrackByBufferIndex(bufferIndex)
+	if !s.Completed && track == nil && parser.Config != nil {
+		track = s.createAudioTrack(bufferIndex, utils.AVCodecIdAACLATM, s.GetTimebase(), parser.Config.AudioSpecificConfig, AudioConfig{SampleSize: 16})
+	}
+
+	if track == nil {
+		return
+	}
+
+	stream := track.GetStream()
+	var duration int64
+	if stream.SampleRate > 0 {
+		duration = int64(utils.DefaultAACFrameLength) * int64(stream.Timebase) / int64(stream.SampleRate)
+	}
+
+	for i, frame := range frames {
+		if _, err = s.DataPipeline.Write(frame, bufferIndex, utils.AVMediaTypeAudio); err != nil {
+			println(err.Error())
+			return
+		}
+
+		bytes, err := s.DataPipeline.Fetch(bufferIndex)
+		if err != nil {
+			println(err.Error())
+			return
+		}
+
+		packet := NewAudioPacket(bytes, ts+int64(i)*duration, utils.AVCodecIdAAC, stream.Index, stream.Timebase)
+		packet.Duration = duration
+		packet.BufferIndex = bufferIndex
+		s.normalizeTimestamp(stream, packet)
+		s.processBufferedPacket(packet)
+	}
+}
+
 func (s *BaseDemuxer) OnVideoPacket(bufferIndex int, id utils.AVCodecID, data []byte, key bool, dts, pts int64, packType PacketType) {
 	var ok bool
 	defer func() {
@@ -243,12 +361,64 @@ func (s *BaseDemuxer) OnVideoPacket(bufferIndex int, id utils.AVCodecID, data []
 
 	ok = true
 	packet.BufferIndex = bufferIndex
+	s.normalizeTimestamp(track.GetStream(), packet)
 	if s.onPreprocessPacket != nil {
 		s.onPreprocessPacket(packet)
 	}
+
+	if s.ReorderPTS {
+		s.reorderPacket(track.GetStream(), packet)
+		return
+	}
+
 	s.processBufferedPacket(packet)
 }
 
+// normalizeTimestamp 按track展开时间戳回绕, 跳变后接续到连续的时间轴
+func (s *BaseDemuxer) normalizeTimestamp(stream *AVStream, packet *AVPacket) {
+	if !s.NormalizeTimestamp {
+		return
+	}
+
+	normalizer, ok := s.normalizers[stream.Index]
+	if !ok {
+		if s.normalizers == nil {
+			s.normalizers = make(map[int]*TimestampNormalizer)
+		}
+
+		normalizer = NewTimestampNormalizer(stream.Timebase, s.GetTimestampBits())
+		s.normalizers[stream.Index] = normalizer
+	}
+
+	normalizer.Normalize(packet)
+}
+
+// reorderPacket 重建pts后再按解码顺序处理, 不支持的编码器直接处理
+func (s *BaseDemuxer) reorderPacket(stream *AVStream, packet *AVPacket) {
+	reorder, ok := s.reorders[stream.Index]
+	if !ok {
+		var err error
+		if reorder, err = NewPOCReorder(stream); err != nil {
+			println(err.Error())
+		}
+
+		if s.reorders == nil {
+			s.reorders = make(map[int]*POCReorder)
+		}
+
+		s.reorders[stream.Index] = reorder
+	}
+
+	if reorder == nil {
+		s.processBufferedPacket(packet)
+		return
+	}
+
+	for _, pkt := range reorder.Input(packet) {
+		s.processBufferedPacket(pkt)
+	}
+}
+
 // 回调AVPacket, 如果没有完成探测, 则保存到Packets中, 否则回调处理
 // 保证回调的顺序是OnNewTrack...->OnTrackComplete->OnPacket...
 func (s *BaseDemuxer) processBufferedPacket(packet *AVPacket) {
@@ -268,9 +438,11 @@ func (s *BaseDemuxer) processBufferedPacket(packet *AVPacket) {
 		return
 	}
 
-	// 计算上一个AVPacket的duration
+	// 计算上一个AVPacket的duration, 已经从码流中获取的保持不变
 	prevPacket := packets.Get(prevPacketIndex)
-	prevPacket.Duration = packet.Dts - prevPacket.Dts
+	if prevPacket.Duration == 0 {
+		prevPacket.Duration = packet.Dts - prevPacket.Dts
+	}
 
 	// 如果已经完成探测, 则回调处理
 	if s.Completed {
@@ -389,7 +561,7 @@ func (s *BaseDemuxer) calculateG726BitRate() bool {
 		}
 
 		pkt := pkts.Get(0)
-		if pkt.CodecID != utils.AVCodecIdADPCMG726 {
+		if pkt.CodecID != utils.AVCodecIdADPCMG726 && pkt.CodecID != utils.AVCodecIdADPCMG726LE {
 			continue
 		}
 
@@ -416,7 +588,7 @@ func (s *BaseDemuxer) calculateG726BitRate() bool {
 	}
 
 	for _, track := range s.Tracks.Tracks {
-		if track.GetStream().CodecID != utils.AVCodecIdADPCMG726 {
+		if track.GetStream().CodecID != utils.AVCodecIdADPCMG726 && track.GetStream().CodecID != utils.AVCodecIdADPCMG726LE {
 			continue
 		}
 		track.GetStream().BitRate = bitRate
@@ -479,6 +651,44 @@ func (s *BaseDemuxer) SetOnPreprocessPacketHandler(onPreprocessPacket func(packe
 	s.onPreprocessPacket = onPreprocessPacket
 }
 
+// Flush 输入结束时由调用方在Close之前调用, 输出重排序缓存的帧和每个track最后一个等待计算duration的packet
+func (s *BaseDemuxer) Flush() {
+	if s.Handler == nil {
+		return
+	}
+
+	for _, track := range s.Tracks.Tracks {
+		reorder := s.reorders[track.GetStream().Index]
+		if reorder == nil {
+			continue
+		}
+
+		for _, pkt := range reorder.Flush() {
+			s.processBufferedPacket(pkt)
+		}
+	}
+
+	if !s.Completed {
+		s.OnProbeComplete()
+	}
+
+	var result []*AVPacket
+	for _, packets := range s.Packets {
+		for packets.Size() > 0 {
+			result = append(result, packets.Remove(0))
+		}
+	}
+
+	// dts升序排序
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].Dts < result[j].Dts
+	})
+
+	for _, packet := range result {
+		s.forwardPacket(packet)
+	}
+}
+
 func (s *BaseDemuxer) Close() {
 	s.Handler = nil
 	s.onPreprocessPacket = nil
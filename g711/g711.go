@@ -0,0 +1,215 @@
+package g711
+
+import (
+	"encoding/binary"
+)
+
+// 参考Sun Microsystems的g711.c实现, 编解码都使用初始化时生成的表
+
+const (
+	signBit   = 0x80
+	quantMask = 0x0F
+	segShift  = 4
+	segMask   = 0x70
+	bias      = 0x84 // µ-law偏移
+	clip      = 8159
+)
+
+var (
+	segAEnd = [8]int{0x1F, 0x3F, 0x7F, 0xFF, 0x1FF, 0x3FF, 0x7FF, 0xFFF}
+	segUEnd = [8]int{0x3F, 0x7F, 0xFF, 0x1FF, 0x3FF, 0x7FF, 0xFFF, 0x1FFF}
+
+	alawDecodeTable [256]int16
+	ulawDecodeTable [256]int16
+	alawEncodeTable [1 << 13]byte // 13bit线性值
+	ulawEncodeTable [1 << 14]byte // 14bit线性值
+	alaw2ulawTable  [256]byte
+	ulaw2alawTable  [256]byte
+)
+
+func init() {
+	for i := 0; i < 256; i++ {
+		alawDecodeTable[i] = alaw2linear(byte(i))
+		ulawDecodeTable[i] = ulaw2linear(byte(i))
+	}
+
+	for i := range alawEncodeTable {
+		alawEncodeTable[i] = linear2alaw(int16((i - len(alawEncodeTable)/2) << 3))
+	}
+
+	for i := range ulawEncodeTable {
+		ulawEncodeTable[i] = linear2ulaw(int16((i - len(ulawEncodeTable)/2) << 2))
+	}
+
+	for i := 0; i < 256; i++ {
+		alaw2ulawTable[i] = LinearToMuLaw(alawDecodeTable[i])
+		ulaw2alawTable[i] = LinearToALaw(ulawDecodeTable[i])
+	}
+}
+
+func search(value int, table *[8]int) int {
+	for i, end := range table {
+		if value <= end {
+			return i
+		}
+	}
+
+	return len(table)
+}
+
+func linear2alaw(pcm int16) byte {
+	value := int(pcm) >> 3
+	mask := 0xD5
+	if value < 0 {
+		mask = 0x55
+		value = -value - 1
+	}
+
+	seg := search(value, &segAEnd)
+	if seg >= 8 {
+		return byte(0x7F ^ mask)
+	}
+
+	aval := seg << segShift
+	if seg < 2 {
+		aval |= (value >> 1) & quantMask
+	} else {
+		aval |= (value >> seg) & quantMask
+	}
+
+	return byte(aval ^ mask)
+}
+
+func alaw2linear(a byte) int16 {
+	a ^= 0x55
+	t := int(a&quantMask) << 4
+	seg := int(a&segMask) >> segShift
+	switch seg {
+	case 0:
+		t += 8
+	case 1:
+		t += 0x108
+	default:
+		t += 0x108
+		t <<= seg - 1
+	}
+
+	if a&signBit != 0 {
+		return int16(t)
+	}
+
+	return int16(-t)
+}
+
+func linear2ulaw(pcm int16) byte {
+	value := int(pcm) >> 2
+	mask := 0xFF
+	if value < 0 {
+		value = -value
+		mask = 0x7F
+	}
+
+	if value > clip {
+		value = clip
+	}
+
+	value += bias >> 2
+	seg := search(value, &segUEnd)
+	if seg >= 8 {
+		return byte(0x7F ^ mask)
+	}
+
+	uval := seg<<4 | (value>>(seg+1))&0xF
+	return byte(uval ^ mask)
+}
+
+func ulaw2linear(u byte) int16 {
+	u = ^u
+	t := int(u&quantMask)<<3 + bias
+	t <<= int(u&segMask) >> segShift
+	if u&signBit != 0 {
+		return int16(bias - t)
+	}
+
+	return int16(t - bias)
+}
+
+func ALawToLinear(a byte) int16 {
+	return alawDecodeTable[a]
+}
+
+func MuLawToLinear(u byte) int16 {
+	return ulawDecodeTable[u]
+}
+
+func LinearToALaw(pcm int16) byte {
+	return alawEncodeTable[int(pcm>>3)+len(alawEncodeTable)/2]
+}
+
+func LinearToMuLaw(pcm int16) byte {
+	return ulawEncodeTable[int(pcm>>2)+len(ulawEncodeTable)/2]
+}
+
+func ALawToMuLaw(a byte) byte {
+	return alaw2ulawTable[a]
+}
+
+func MuLawToALaw(u byte) byte {
+	return ulaw2alawTable[u]
+}
+
+// DecodeALaw 解码为S16LE, dst长度至少为2*len(src), 返回写入长度
+func DecodeALaw(dst, src []byte) int {
+	for i, a := range src {
+		binary.LittleEndian.PutUint16(dst[i*2:], uint16(alawDecodeTable[a]))
+	}
+
+	return len(src) * 2
+}
+
+// DecodeMuLaw 解码为S16LE, dst长度至少为2*len(src), 返回写入长度
+func DecodeMuLaw(dst, src []byte) int {
+	for i, u := range src {
+		binary.LittleEndian.PutUint16(dst[i*2:], uint16(ulawDecodeTable[u]))
+	}
+
+	return len(src) * 2
+}
+
+// EncodeALaw 编码S16LE, dst长度至少为len(src)/2, 返回写入长度
+func EncodeALaw(dst, src []byte) int {
+	n := len(src) / 2
+	for i := 0; i < n; i++ {
+		dst[i] = LinearToALaw(int16(binary.LittleEndian.Uint16(src[i*2:])))
+	}
+
+	return n
+}
+
+// EncodeMuLaw 编码S16LE, dst长度至少为len(src)/2, 返回写入长度
+func EncodeMuLaw(dst, src []byte) int {
+	n := len(src) / 2
+	for i := 0; i < n; i++ {
+		dst[i] = LinearToMuLaw(int16(binary.LittleEndian.Uint16(src[i*2:])))
+	}
+
+	return n
+}
+
+// ALawToMuLawBytes dst和src可以是同一个切片
+func ALawToMuLawBytes(dst, src []byte) int {
+	for i, a := range src {
+		dst[i] = alaw2ulawTable[a]
+	}
+
+	return len(src)
+}
+
+// MuLawToALawBytes dst和src可以是同一个切片
+func MuLawToALawBytes(dst, src []byte) int {
+	for i, u := range src {
+		dst[i] = ulaw2alawTable[u]
+	}
+
+	return len(src)
+}
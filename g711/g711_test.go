@@ -0,0 +1,78 @@
+package g711
+
+import (
+	"encoding/binary"
+	"github.com/lkmio/avformat"
+	"github.com/lkmio/avformat/utils"
+	"testing"
+)
+
+func TestG711(t *testing.T) {
+	// ITU G.711 Table 1/2: 0xD5(A-law)和0xFF(µ-law)为最小正值
+	utils.Assert(ALawToLinear(0xD5) == 8 && ALawToLinear(0x55) == -8)
+	utils.Assert(MuLawToLinear(0xFF) == 0 && MuLawToLinear(0x80) == 32124)
+	utils.Assert(LinearToALaw(0) == 0xD5 && LinearToMuLaw(0) == 0xFF)
+	utils.Assert(LinearToALaw(32767) == 0xAA && LinearToALaw(-32768) == 0x2A)
+
+	// 解码后再编码必须得到相同的码字
+	for i := 0; i < 256; i++ {
+		utils.Assert(LinearToALaw(ALawToLinear(byte(i))) == byte(i))
+		// µ-law的0x7F和0xFF都表示0
+		if i != 0x7F {
+			utils.Assert(LinearToMuLaw(MuLawToLinear(byte(i))) == byte(i))
+		}
+	}
+
+	// 与直接计算的结果一致
+	for pcm := -32768; pcm <= 32767; pcm += 7 {
+		utils.Assert(LinearToALaw(int16(pcm)) == linear2alaw(int16(pcm)))
+		utils.Assert(LinearToMuLaw(int16(pcm)) == linear2ulaw(int16(pcm)))
+	}
+
+	utils.Assert(MuLawToALaw(ALawToMuLaw(0xD5)) == 0xD5)
+}
+
+func TestTranscodePacket(t *testing.T) {
+	pcm := make([]byte, 320)
+	for i := 0; i < 160; i++ {
+		binary.LittleEndian.PutUint16(pcm[i*2:], uint16(int16(i*200-16000)))
+	}
+
+	stream := &avformat.AVStream{CodecID: utils.AVCodecIdPCMS16LE, AudioConfig: avformat.AudioConfig{SampleRate: 8000, Channels: 1, SampleSize: 16}}
+	if err := TranscodeStream(stream, utils.AVCodecIdPCMALAW); err != nil {
+		panic(err)
+	}
+
+	utils.Assert(stream.CodecID == utils.AVCodecIdPCMALAW && stream.SampleSize == 8 && stream.BitRate == 64000)
+
+	pkt := &avformat.AVPacket{CodecID: utils.AVCodecIdPCMS16LE, Data: pcm}
+	for _, id := range []utils.AVCodecID{utils.AVCodecIdPCMALAW, utils.AVCodecIdPCMMULAW, utils.AVCodecIdPCMALAW} {
+		if err := TranscodePacket(pkt, id); err != nil {
+			panic(err)
+		}
+
+		utils.Assert(pkt.CodecID == id && len(pkt.Data) == 160)
+	}
+
+	if err := TranscodePacket(pkt, utils.AVCodecIdPCMS16LE); err != nil {
+		panic(err)
+	}
+
+	utils.Assert(len(pkt.Data) == 320)
+	for i := 0; i < 160; i++ {
+		src := int(int16(binary.LittleEndian.Uint16(pcm[i*2:])))
+		dst := int(int16(binary.LittleEndian.Uint16(pkt.Data[i*2:])))
+		// 量化误差不超过所在段的步长
+		utils.Assert(abs(src-dst) <= 1024)
+	}
+
+	utils.Assert(TranscodePacket(pkt, utils.AVCodecIdAAC) != nil)
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+
+	return v
+}
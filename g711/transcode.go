@@ -0,0 +1,99 @@
+package g711
+
+import (
+	"fmt"
+	"github.com/lkmio/avformat"
+	"github.com/lkmio/avformat/utils"
+)
+
+func isSupported(id utils.AVCodecID) bool {
+	return utils.AVCodecIdPCMALAW == id || utils.AVCodecIdPCMMULAW == id || utils.AVCodecIdPCMS16LE == id
+}
+
+func sampleSize(id utils.AVCodecID) int {
+	if utils.AVCodecIdPCMS16LE == id {
+		return 16
+	}
+
+	return 8
+}
+
+// Transcode 在PCMALAW/PCMMULAW/PCMS16LE之间转换, 返回写入长度. dst为空时创建新的切片.
+func Transcode(dst, src []byte, srcID, dstID utils.AVCodecID) ([]byte, error) {
+	if !isSupported(srcID) || !isSupported(dstID) {
+		return nil, fmt.Errorf("unsupported transcoding from %s to %s", srcID, dstID)
+	}
+
+	size := len(src)
+	if utils.AVCodecIdPCMS16LE == srcID && utils.AVCodecIdPCMS16LE != dstID {
+		size /= 2
+	} else if utils.AVCodecIdPCMS16LE != srcID && utils.AVCodecIdPCMS16LE == dstID {
+		size *= 2
+	}
+
+	if dst == nil {
+		dst = make([]byte, size)
+	} else if len(dst) < size {
+		return nil, fmt.Errorf("buffer too small, need %d bytes got %d", size, len(dst))
+	}
+
+	switch {
+	case srcID == dstID:
+		copy(dst, src)
+	case utils.AVCodecIdPCMALAW == srcID && utils.AVCodecIdPCMMULAW == dstID:
+		ALawToMuLawBytes(dst, src)
+	case utils.AVCodecIdPCMMULAW == srcID && utils.AVCodecIdPCMALAW == dstID:
+		MuLawToALawBytes(dst, src)
+	case utils.AVCodecIdPCMALAW == srcID:
+		DecodeALaw(dst, src)
+	case utils.AVCodecIdPCMMULAW == srcID:
+		DecodeMuLaw(dst, src)
+	case utils.AVCodecIdPCMALAW == dstID:
+		EncodeALaw(dst, src)
+	default:
+		EncodeMuLaw(dst, src)
+	}
+
+	return dst[:size], nil
+}
+
+// TranscodePacket 转换packet的Data和CodecID, 优先使用OnBufferAlloc分配内存
+func TranscodePacket(pkt *avformat.AVPacket, dstID utils.AVCodecID) error {
+	if pkt.CodecID == dstID {
+		return nil
+	}
+
+	var dst []byte
+	if pkt.OnBufferAlloc != nil {
+		size := len(pkt.Data)
+		if utils.AVCodecIdPCMS16LE == dstID {
+			size *= 2
+		}
+
+		dst = pkt.OnBufferAlloc(size)
+	}
+
+	data, err := Transcode(dst, pkt.Data, pkt.CodecID, dstID)
+	if err != nil {
+		return err
+	}
+
+	pkt.Data = data
+	pkt.CodecID = dstID
+	return nil
+}
+
+// TranscodeStream 修改AVStream的CodecID和采样位深, 采样率和通道数不变
+func TranscodeStream(stream *avformat.AVStream, dstID utils.AVCodecID) error {
+	if !isSupported(stream.CodecID) || !isSupported(dstID) {
+		return fmt.Errorf("unsupported transcoding from %s to %s", stream.CodecID, dstID)
+	}
+
+	stream.CodecID = dstID
+	stream.SampleSize = sampleSize(dstID)
+	if stream.SampleRate > 0 && stream.Channels > 0 {
+		stream.BitRate = stream.SampleRate * stream.Channels * stream.SampleSize
+	}
+
+	return nil
+}
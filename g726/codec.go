@@ -0,0 +1,159 @@
+package g726
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// 码字打包方式:
+// G726(AVCodecIdADPCMG726)从字节高位开始打包, 和ffmpeg的g726一致;
+// G726LE(AVCodecIdADPCMG726LE)从字节低位开始打包, 和RFC 3551一致.
+
+// Encoder 将S16LE编码为G726
+type Encoder struct {
+	state        state
+	rate         *rate
+	littleEndian bool
+
+	bits     uint32 // 未输出的码字
+	bitCount int
+}
+
+// Decoder 将G726解码为S16LE
+type Decoder struct {
+	state        state
+	rate         *rate
+	littleEndian bool
+}
+
+func NewEncoder(bitRate int, littleEndian bool) (*Encoder, error) {
+	r := getRate(bitRate)
+	if r == nil {
+		return nil, fmt.Errorf("unsupported g726 bit rate %d", bitRate)
+	}
+
+	e := &Encoder{rate: r, littleEndian: littleEndian}
+	e.state.reset()
+	return e, nil
+}
+
+func NewDecoder(bitRate int, littleEndian bool) (*Decoder, error) {
+	r := getRate(bitRate)
+	if r == nil {
+		return nil, fmt.Errorf("unsupported g726 bit rate %d", bitRate)
+	}
+
+	d := &Decoder{rate: r, littleEndian: littleEndian}
+	d.state.reset()
+	return d, nil
+}
+
+// CodeSize 每个采样的码字位数
+func (e *Encoder) CodeSize() int {
+	return e.rate.bits
+}
+
+// EncodedSize 编码n字节S16LE后最多输出的字节数
+func (e *Encoder) EncodedSize(n int) int {
+	return ((n/2)*e.rate.bits + e.bitCount + 7) / 8
+}
+
+// Encode 编码S16LE, 不足一个字节的码字保留到下次编码或Flush, 返回写入长度
+func (e *Encoder) Encode(dst, src []byte) int {
+	var n int
+	for i := 0; i+1 < len(src); i += 2 {
+		code := uint32(e.state.encode(e.rate, int16(binary.LittleEndian.Uint16(src[i:]))))
+		if e.littleEndian {
+			e.bits |= code << e.bitCount
+		} else {
+			e.bits = e.bits<<e.rate.bits | code
+		}
+
+		e.bitCount += e.rate.bits
+		for e.bitCount >= 8 {
+			e.bitCount -= 8
+			if e.littleEndian {
+				dst[n] = byte(e.bits)
+				e.bits >>= 8
+			} else {
+				dst[n] = byte(e.bits >> e.bitCount)
+				e.bits &= 1<<e.bitCount - 1
+			}
+
+			n++
+		}
+	}
+
+	return n
+}
+
+// Flush 输出剩余的码字, 不足一个字节补0, 返回写入长度
+func (e *Encoder) Flush(dst []byte) int {
+	if e.bitCount == 0 {
+		return 0
+	}
+
+	if e.littleEndian {
+		dst[0] = byte(e.bits)
+	} else {
+		dst[0] = byte(e.bits << (8 - e.bitCount))
+	}
+
+	e.bits = 0
+	e.bitCount = 0
+	return 1
+}
+
+func (e *Encoder) Reset() {
+	e.state.reset()
+	e.bits = 0
+	e.bitCount = 0
+}
+
+// CodeSize 每个采样的码字位数
+func (d *Decoder) CodeSize() int {
+	return d.rate.bits
+}
+
+// DecodedSize 解码n字节G726后输出的S16LE字节数
+func (d *Decoder) DecodedSize(n int) int {
+	return n * 8 / d.rate.bits * 2
+}
+
+// Decode 解码为S16LE, 末尾不足一个码字的位被丢弃, 返回写入长度
+func (d *Decoder) Decode(dst, src []byte) int {
+	var bits uint32
+	var bitCount int
+	var n int
+	mask := uint32(1)<<d.rate.bits - 1
+
+	for _, b := range src {
+		if d.littleEndian {
+			bits |= uint32(b) << bitCount
+		} else {
+			bits = bits<<8 | uint32(b)
+		}
+
+		bitCount += 8
+		for bitCount >= d.rate.bits {
+			var code uint32
+			bitCount -= d.rate.bits
+			if d.littleEndian {
+				code = bits & mask
+				bits >>= d.rate.bits
+			} else {
+				code = bits >> bitCount & mask
+				bits &= 1<<bitCount - 1
+			}
+
+			binary.LittleEndian.PutUint16(dst[n:], uint16(d.state.decode(d.rate, byte(code))))
+			n += 2
+		}
+	}
+
+	return n
+}
+
+func (d *Decoder) Reset() {
+	d.state.reset()
+}
@@ -0,0 +1,454 @@
+// Package g726 实现ITU-T G.726(16/24/32/40kbit/s)编解码.
+// 算法参考Sun Microsystems的g72x.c, 16kbit/s的量化表来自spandsp, 内部运算和参考实现一样截断为16bit.
+// 与参考实现的唯一差异: 解码输出sr<<2超出int16范围时饱和处理, 参考实现直接截断回绕.
+package g726
+
+var power2 = [15]int{1, 2, 4, 8, 0x10, 0x20, 0x40, 0x80, 0x100, 0x200, 0x400, 0x800, 0x1000, 0x2000, 0x4000}
+
+// rate 每种比特率的量化表
+type rate struct {
+	bits    int
+	states  int // 量化器状态数
+	qtab    []int
+	dqlntab []int
+	witab   []int
+	fitab   []int
+}
+
+var (
+	rate16 = &rate{
+		bits:    2,
+		states:  4,
+		qtab:    []int{261},
+		dqlntab: []int{116, 365, 365, 116},
+		witab:   []int{-704, 14048, 14048, -704},
+		fitab:   []int{0, 0xE00, 0xE00, 0},
+	}
+
+	rate24 = &rate{
+		bits:    3,
+		states:  7,
+		qtab:    []int{8, 218, 331},
+		dqlntab: []int{-2048, 135, 273, 373, 373, 273, 135, -2048},
+		witab:   []int{-128, 960, 4384, 18624, 18624, 4384, 960, -128},
+		fitab:   []int{0, 0x200, 0x400, 0xE00, 0xE00, 0x400, 0x200, 0},
+	}
+
+	rate32 = &rate{
+		bits:    4,
+		states:  15,
+		qtab:    []int{-124, 80, 178, 246, 300, 349, 400},
+		dqlntab: []int{-2048, 4, 135, 213, 273, 323, 373, 425, 425, 373, 323, 273, 213, 135, 4, -2048},
+		// g721.c中witab使用时左移5位, 这里直接保存移位后的值
+		witab: []int{-384, 576, 1312, 2048, 3584, 6336, 11360, 35904, 35904, 11360, 6336, 3584, 2048, 1312, 576, -384},
+		fitab: []int{0, 0, 0, 0x200, 0x200, 0x200, 0x600, 0xE00, 0xE00, 0x600, 0x200, 0x200, 0x200, 0, 0, 0},
+	}
+
+	rate40 = &rate{
+		bits:    5,
+		states:  31,
+		qtab:    []int{-122, -16, 68, 139, 198, 250, 298, 339, 378, 413, 445, 475, 502, 528, 553},
+		dqlntab: []int{-2048, -66, 28, 104, 169, 224, 274, 318, 358, 395, 429, 459, 488, 514, 539, 566, 566, 539, 514, 488, 459, 429, 395, 358, 318, 274, 224, 169, 104, 28, -66, -2048},
+		witab:   []int{448, 448, 768, 1248, 1280, 1312, 1856, 3200, 4512, 5728, 7008, 8960, 11456, 14080, 16928, 22272, 22272, 16928, 14080, 11456, 8960, 7008, 5728, 4512, 3200, 1856, 1312, 1280, 1248, 768, 448, 448},
+		fitab:   []int{0, 0, 0, 0, 0, 0x200, 0x200, 0x200, 0x200, 0x200, 0x400, 0x600, 0x800, 0xA00, 0xC00, 0xC00, 0xC00, 0xC00, 0xA00, 0x800, 0x600, 0x400, 0x200, 0x200, 0x200, 0x200, 0x200, 0, 0, 0, 0, 0},
+	}
+)
+
+func getRate(bitRate int) *rate {
+	switch bitRate {
+	case 16000:
+		return rate16
+	case 24000:
+		return rate24
+	case 32000:
+		return rate32
+	case 40000:
+		return rate40
+	default:
+		return nil
+	}
+}
+
+// state 编解码器状态, 字段位宽和参考实现保持一致
+type state struct {
+	yl  int32    // 锁定(稳态)量化步长因子
+	yu  int16    // 非锁定(非稳态)量化步长因子
+	dms int16    // 短期能量估计
+	dml int16    // 长期能量估计
+	ap  int16    // yl和yu的线性加权系数
+	a   [2]int16 // 极点预测系数
+	b   [6]int16 // 零点预测系数
+	pk  [2]int16 // 前两个部分重建信号的符号
+	dq  [6]int16 // 前6个量化差值信号, 内部浮点格式
+	sr  [2]int16 // 前2个重建信号, 内部浮点格式
+	td  int8     // 延迟的单音检测
+}
+
+func (s *state) reset() {
+	*s = state{yl: 34816, yu: 544}
+	for i := range s.sr {
+		s.sr[i] = 32
+	}
+
+	for i := range s.dq {
+		s.dq[i] = 32
+	}
+}
+
+func quan(val int, table []int) int {
+	var i int
+	for i = 0; i < len(table); i++ {
+		if val < table[i] {
+			break
+		}
+	}
+
+	return i
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+
+	return v
+}
+
+// fmult 计算an和srn的乘积, srn为内部浮点格式
+func fmult(an, srn int) int {
+	var anmag int
+	if an > 0 {
+		anmag = an
+	} else {
+		anmag = (-an) & 0x1FFF
+	}
+
+	anexp := quan(anmag, power2[:]) - 6
+	var anmant int
+	if anmag == 0 {
+		anmant = 32
+	} else if anexp >= 0 {
+		anmant = anmag >> anexp
+	} else {
+		anmant = anmag << -anexp
+	}
+
+	wanexp := anexp + ((srn >> 6) & 0xF) - 13
+	wanmant := (anmant*(srn&077) + 0x30) >> 4
+
+	var retval int
+	if wanexp >= 0 {
+		retval = (wanmant << wanexp) & 0x7FFF
+	} else {
+		retval = wanmant >> -wanexp
+	}
+
+	if (an ^ srn) < 0 {
+		return -retval
+	}
+
+	return retval
+}
+
+func (s *state) predictorZero() int {
+	sezi := fmult(int(s.b[0]>>2), int(s.dq[0]))
+	for i := 1; i < 6; i++ {
+		sezi += fmult(int(s.b[i]>>2), int(s.dq[i]))
+	}
+
+	return sezi
+}
+
+func (s *state) predictorPole() int {
+	return fmult(int(s.a[1]>>2), int(s.sr[1])) + fmult(int(s.a[0]>>2), int(s.sr[0]))
+}
+
+func (s *state) stepSize() int {
+	if s.ap >= 256 {
+		return int(s.yu)
+	}
+
+	y := int(s.yl >> 6)
+	dif := int(s.yu) - y
+	al := int(s.ap >> 2)
+	if dif > 0 {
+		y += (dif * al) >> 6
+	} else if dif < 0 {
+		y += (dif*al + 0x3F) >> 6
+	}
+
+	return y
+}
+
+// quantize 量化差值信号d, 返回ADPCM码字
+func quantize(d, y int, r *rate) int {
+	dqm := abs(d)
+	exp := quan(dqm>>1, power2[:])
+	mant := ((dqm << 7) >> exp) & 0x7F
+	dl := (exp << 7) + mant
+	dln := dl - (y >> 2)
+
+	size := (r.states - 1) >> 1
+	i := quan(dln, r.qtab[:size])
+	if d < 0 {
+		return (size << 1) + 1 - i
+	} else if i == 0 && r.states&1 != 0 {
+		// 奇数个状态时0不是有效码字, 取反码
+		return r.states
+	}
+
+	return i
+}
+
+// reconstruct 根据量化后的对数值重建差值信号
+func reconstruct(sign bool, dqln, y int) int {
+	dql := dqln + (y >> 2)
+	if dql < 0 {
+		if sign {
+			return -0x8000
+		}
+
+		return 0
+	}
+
+	dex := (dql >> 7) & 15
+	dqt := 128 + (dql & 127)
+	dq := (dqt << 7) >> (14 - dex)
+	if sign {
+		return dq - 0x8000
+	}
+
+	return dq
+}
+
+// toFloat 转换为4bit指数和6bit尾数的内部浮点格式
+func toFloat(mag int, negative bool) int16 {
+	exp := quan(mag, power2[:])
+	v := (exp << 6) + ((mag << 6) >> exp)
+	if negative {
+		v -= 0x400
+	}
+
+	return int16(v)
+}
+
+func (s *state) update(bits, y, wi, fi, dq, sr, dqsez int) {
+	var pk0 int16
+	if dqsez < 0 {
+		pk0 = 1
+	}
+
+	mag := dq & 0x7FFF
+
+	// TRANS
+	ylint := int(s.yl >> 15)
+	ylfrac := int(s.yl>>10) & 0x1F
+	thr1 := (32 + ylfrac) << ylint
+	thr2 := thr1
+	if ylint > 9 {
+		thr2 = 31 << 10
+	}
+
+	dqthr := (thr2 + (thr2 >> 1)) >> 1
+	var tr bool
+	if s.td != 0 && mag > dqthr {
+		// 判定为数据信号(modem)
+		tr = true
+	}
+
+	// 量化器步长因子自适应
+	yu := y + ((wi - y) >> 5)
+	if yu < 544 {
+		yu = 544
+	} else if yu > 5120 {
+		yu = 5120
+	}
+
+	s.yu = int16(yu)
+	s.yl += int32(yu) + ((-s.yl) >> 6)
+
+	// 自适应预测系数
+	var a2p int
+	if tr {
+		s.a = [2]int16{}
+		s.b = [6]int16{}
+	} else {
+		pks1 := pk0 ^ s.pk[0]
+
+		// 更新极点系数a[1]
+		a2p = int(s.a[1]) - int(s.a[1]>>7)
+		if dqsez != 0 {
+			fa1 := -int(s.a[0])
+			if pks1 != 0 {
+				fa1 = int(s.a[0])
+			}
+
+			if fa1 < -8191 {
+				a2p -= 0x100
+			} else if fa1 > 8191 {
+				a2p += 0xFF
+			} else {
+				a2p += fa1 >> 5
+			}
+
+			if pk0^s.pk[1] != 0 {
+				if a2p <= -12160 {
+					a2p = -12288
+				} else if a2p >= 12416 {
+					a2p = 12288
+				} else {
+					a2p -= 0x80
+				}
+			} else if a2p <= -12416 {
+				a2p = -12288
+			} else if a2p >= 12160 {
+				a2p = 12288
+			} else {
+				a2p += 0x80
+			}
+		}
+
+		s.a[1] = int16(a2p)
+
+		// 更新极点系数a[0]
+		a0 := int(s.a[0]) - int(s.a[0]>>8)
+		if dqsez != 0 {
+			if pks1 == 0 {
+				a0 += 192
+			} else {
+				a0 -= 192
+			}
+		}
+
+		a1ul := 15360 - a2p
+		if a0 < -a1ul {
+			a0 = -a1ul
+		} else if a0 > a1ul {
+			a0 = a1ul
+		}
+
+		s.a[0] = int16(a0)
+
+		// 更新零点系数b
+		for i := range s.b {
+			if bits == 5 {
+				s.b[i] -= s.b[i] >> 9
+			} else {
+				s.b[i] -= s.b[i] >> 8
+			}
+
+			if dq&0x7FFF != 0 {
+				if (dq ^ int(s.dq[i])) >= 0 {
+					s.b[i] += 128
+				} else {
+					s.b[i] -= 128
+				}
+			}
+		}
+	}
+
+	copy(s.dq[1:], s.dq[:5])
+	if mag == 0 {
+		if dq >= 0 {
+			s.dq[0] = 0x20
+		} else {
+			s.dq[0] = -992 // 0xFC20
+		}
+	} else {
+		s.dq[0] = toFloat(mag, dq < 0)
+	}
+
+	s.sr[1] = s.sr[0]
+	if sr == 0 {
+		s.sr[0] = 0x20
+	} else if sr > 0 {
+		s.sr[0] = toFloat(sr, false)
+	} else if sr > -32768 {
+		s.sr[0] = toFloat(-sr, true)
+	} else {
+		s.sr[0] = -992 // 0xFC20
+	}
+
+	s.pk[1] = s.pk[0]
+	s.pk[0] = pk0
+
+	// TONE
+	if tr {
+		s.td = 0
+	} else if a2p < -11776 {
+		s.td = 1
+	} else {
+		s.td = 0
+	}
+
+	// 自适应速度控制
+	s.dms += int16((fi - int(s.dms)) >> 5)
+	s.dml += int16(((fi << 2) - int(s.dml)) >> 7)
+
+	if tr {
+		s.ap = 256
+	} else if y < 1536 || s.td == 1 || abs((int(s.dms)<<2)-int(s.dml)) >= int(s.dml>>3) {
+		s.ap += int16((0x200 - int(s.ap)) >> 4)
+	} else {
+		s.ap += int16((-int(s.ap)) >> 4)
+	}
+}
+
+// wrap16 按16bit补码截断, 和参考实现中short类型的运算保持一致
+func wrap16(v int) int {
+	return int(int16(v))
+}
+
+// encode 编码一个16bit线性采样, 返回ADPCM码字
+func (s *state) encode(r *rate, pcm int16) byte {
+	sl := int(pcm) >> 2 // 14bit动态范围
+	sezi := wrap16(s.predictorZero())
+	sez := sezi >> 1
+	se := wrap16((sezi + s.predictorPole()) >> 1)
+	d := wrap16(sl - se)
+
+	y := s.stepSize()
+	i := quantize(d, y, r)
+	dq := reconstruct(i&(1<<(r.bits-1)) != 0, r.dqlntab[i], y)
+
+	var sr int
+	if dq < 0 {
+		sr = wrap16(se - (dq & 0x3FFF))
+	} else {
+		sr = wrap16(se + dq)
+	}
+
+	dqsez := wrap16(sr + sez - se)
+	s.update(r.bits, y, r.witab[i], r.fitab[i], dq, sr, dqsez)
+	return byte(i)
+}
+
+// decode 解码一个ADPCM码字, 返回16bit线性采样
+func (s *state) decode(r *rate, code byte) int16 {
+	i := int(code) & (1<<r.bits - 1)
+	sezi := wrap16(s.predictorZero())
+	sez := sezi >> 1
+	se := wrap16(sezi+s.predictorPole()) >> 1
+
+	y := s.stepSize()
+	dq := reconstruct(i&(1<<(r.bits-1)) != 0, r.dqlntab[i], y)
+
+	var sr int
+	if dq < 0 {
+		sr = wrap16(se - (dq & 0x3FFF))
+	} else {
+		sr = wrap16(se + dq)
+	}
+
+	dqsez := wrap16(sr - se + sez)
+	s.update(r.bits, y, r.witab[i], r.fitab[i], dq, sr, dqsez)
+
+	sr <<= 2
+	if sr > 32767 {
+		return 32767
+	} else if sr < -32768 {
+		return -32768
+	}
+
+	return int16(sr)
+}
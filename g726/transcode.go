@@ -0,0 +1,154 @@
+package g726
+
+import (
+	"fmt"
+	"github.com/lkmio/avformat"
+	"github.com/lkmio/avformat/g711"
+	"github.com/lkmio/avformat/utils"
+)
+
+func isG726(id utils.AVCodecID) bool {
+	return utils.AVCodecIdADPCMG726 == id || utils.AVCodecIdADPCMG726LE == id
+}
+
+// PacketDecoder 将G726 packet解码为PCMS16LE/PCMALAW/PCMMULAW, 每个流使用一个实例
+type PacketDecoder struct {
+	*Decoder
+	dstID utils.AVCodecID
+	pcm   []byte
+}
+
+// PacketEncoder 将PCMS16LE/PCMALAW/PCMMULAW packet编码为G726, 每个流使用一个实例
+type PacketEncoder struct {
+	*Encoder
+	dstID utils.AVCodecID
+	pcm   []byte
+}
+
+// NewPacketDecoder 根据流的CodecID和BitRate创建解码器, BitRate未知时使用32kbit/s
+func NewPacketDecoder(stream *avformat.AVStream, dstID utils.AVCodecID) (*PacketDecoder, error) {
+	if !isG726(stream.CodecID) {
+		return nil, fmt.Errorf("unsupported transcoding from %s to %s", stream.CodecID, dstID)
+	} else if utils.AVCodecIdPCMS16LE != dstID && utils.AVCodecIdPCMALAW != dstID && utils.AVCodecIdPCMMULAW != dstID {
+		return nil, fmt.Errorf("unsupported transcoding from %s to %s", stream.CodecID, dstID)
+	}
+
+	bitRate := stream.BitRate
+	if bitRate == 0 {
+		bitRate = 32000
+	}
+
+	decoder, err := NewDecoder(bitRate, utils.AVCodecIdADPCMG726LE == stream.CodecID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &PacketDecoder{Decoder: decoder, dstID: dstID}, nil
+}
+
+// NewPacketEncoder 创建编码器, dstID为AVCodecIdADPCMG726或AVCodecIdADPCMG726LE
+func NewPacketEncoder(stream *avformat.AVStream, dstID utils.AVCodecID, bitRate int) (*PacketEncoder, error) {
+	if !isG726(dstID) {
+		return nil, fmt.Errorf("unsupported transcoding from %s to %s", stream.CodecID, dstID)
+	} else if utils.AVCodecIdPCMS16LE != stream.CodecID && utils.AVCodecIdPCMALAW != stream.CodecID && utils.AVCodecIdPCMMULAW != stream.CodecID {
+		return nil, fmt.Errorf("unsupported transcoding from %s to %s", stream.CodecID, dstID)
+	}
+
+	encoder, err := NewEncoder(bitRate, utils.AVCodecIdADPCMG726LE == dstID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &PacketEncoder{Encoder: encoder, dstID: dstID}, nil
+}
+
+func alloc(pkt *avformat.AVPacket, size int) []byte {
+	if pkt.OnBufferAlloc != nil {
+		return pkt.OnBufferAlloc(size)
+	}
+
+	return make([]byte, size)
+}
+
+// DecodePacket 解码packet的Data并修改CodecID
+func (d *PacketDecoder) DecodePacket(pkt *avformat.AVPacket) error {
+	if !isG726(pkt.CodecID) {
+		return fmt.Errorf("unsupported transcoding from %s to %s", pkt.CodecID, d.dstID)
+	}
+
+	size := d.DecodedSize(len(pkt.Data))
+	var dst []byte
+	if utils.AVCodecIdPCMS16LE == d.dstID {
+		dst = alloc(pkt, size)
+	} else {
+		if cap(d.pcm) < size {
+			d.pcm = make([]byte, size)
+		}
+
+		dst = d.pcm[:size]
+	}
+
+	n := d.Decode(dst, pkt.Data)
+	if utils.AVCodecIdPCMS16LE != d.dstID {
+		data, err := g711.Transcode(alloc(pkt, n/2), dst[:n], utils.AVCodecIdPCMS16LE, d.dstID)
+		if err != nil {
+			return err
+		}
+
+		dst = data
+		n = len(data)
+	}
+
+	pkt.Data = dst[:n]
+	pkt.CodecID = d.dstID
+	return nil
+}
+
+// DecodeStream 修改AVStream的CodecID, 采样位深和比特率
+func (d *PacketDecoder) DecodeStream(stream *avformat.AVStream) {
+	stream.CodecID = d.dstID
+	stream.SampleSize = 8
+	if utils.AVCodecIdPCMS16LE == d.dstID {
+		stream.SampleSize = 16
+	}
+
+	if stream.SampleRate > 0 && stream.Channels > 0 {
+		stream.BitRate = stream.SampleRate * stream.Channels * stream.SampleSize
+	}
+}
+
+// EncodePacket 编码packet的Data并修改CodecID, 不足一个字节的码字留到下一个packet
+func (e *PacketEncoder) EncodePacket(pkt *avformat.AVPacket) error {
+	src := pkt.Data
+	switch pkt.CodecID {
+	case utils.AVCodecIdPCMS16LE:
+		break
+	case utils.AVCodecIdPCMALAW, utils.AVCodecIdPCMMULAW:
+		size := len(src) * 2
+		if cap(e.pcm) < size {
+			e.pcm = make([]byte, size)
+		}
+
+		data, err := g711.Transcode(e.pcm[:size], src, pkt.CodecID, utils.AVCodecIdPCMS16LE)
+		if err != nil {
+			return err
+		}
+
+		src = data
+	default:
+		return fmt.Errorf("unsupported transcoding from %s to %s", pkt.CodecID, e.dstID)
+	}
+
+	dst := alloc(pkt, e.EncodedSize(len(src)))
+	n := e.Encode(dst, src)
+	pkt.Data = dst[:n]
+	pkt.CodecID = e.dstID
+	return nil
+}
+
+// EncodeStream 修改AVStream的CodecID, 采样位深和比特率
+func (e *PacketEncoder) EncodeStream(stream *avformat.AVStream) {
+	stream.CodecID = e.dstID
+	stream.SampleSize = e.CodeSize()
+	stream.BitRate = e.rate.bits * 8000
+}
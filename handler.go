@@ -71,8 +71,15 @@ func (o *OnUnpackStream2FileHandler) OnPacket(packet *AVPacket) {
 	o.OnUnpackStreamLogger.OnPacket(packet)
 
 	data := packet.Data
-	if utils.AVMediaTypeVideo == packet.MediaType {
-		stream := o.tracks.Get(packet.Index).GetStream()
+	stream := o.tracks.Get(packet.Index).GetStream()
+	if utils.AVCodecIdAAC == packet.CodecID {
+		// raw AAC添加ADTSHeader后才能播放, 无法用ADTS表示的保持原样写入
+		if adts, err := AACPacket2ADTS(stream, packet); err != nil {
+			println(err.Error())
+		} else {
+			data = adts
+		}
+	} else if utils.AVMediaTypeVideo == packet.MediaType {
 		data = AVCCPacket2AnnexB(stream, packet)
 		if packet.Key && PacketTypeAVCC == packet.PacketType && stream.CodecParameters != nil {
 			extraData := stream.CodecParameters.AnnexBExtraData()
@@ -70,8 +70,82 @@ type HEVCDecoderConfigurationRecord struct {
 	VPSList [][]byte
 	SPSList [][]byte
 	PPSList [][]byte
+	SEIList [][]byte // prefix和suffix SEI, 例如HDR的mastering_display_colour_volume
 }
 
+// updateFromParameterSets 根据参数集填充hvcC头部字段, 多个SPS时合并profile_tier_level.
+// VPS和PPS解析失败时忽略, 只影响numTemporalLayers和parallelismType.
+func (r *HEVCDecoderConfigurationRecord) updateFromParameterSets(vpsList, spsList, ppsList [][]byte) error {
+	var ctx HEVCSPSInfo
+	minSpatialSegmentationIdc := 4097
+	for i, data := range spsList {
+		sps, err := ParseSPS(data)
+		if err != nil {
+			return fmt.Errorf("h265parser: parse SPS failed(%s)", err)
+		}
+
+		if i == 0 {
+			ctx = sps
+		} else {
+			updatePTL(&ctx, &sps)
+			ctx.NumTemporalLayers = uint(bufio.MaxInt(int(ctx.NumTemporalLayers), int(sps.NumTemporalLayers)))
+		}
+
+		if sps.VUI != nil {
+			minSpatialSegmentationIdc = bufio.MinInt(minSpatialSegmentationIdc, sps.VUI.MinSpatialSegmentationIdc)
+		}
+	}
+
+	for _, data := range vpsList {
+		if vps, err := ParseVPS(data); err == nil {
+			ctx.NumTemporalLayers = uint(bufio.MaxInt(int(ctx.NumTemporalLayers), vps.MaxSubLayers))
+		}
+	}
+
+	// 0:未知或混合, 1:slice, 2:tile, 3:wavefront
+	var parallelismType byte
+	for _, data := range ppsList {
+		pps, err := ParsePPS(data)
+		if err != nil {
+			continue
+		} else if pps.EntropyCodingSyncEnabledFlag && pps.TilesEnabledFlag {
+			parallelismType = 0
+		} else if pps.EntropyCodingSyncEnabledFlag {
+			parallelismType = 3
+		} else if pps.TilesEnabledFlag {
+			parallelismType = 2
+		} else {
+			parallelismType = 1
+		}
+	}
+
+	// min_spatial_segmentation_idc只有12位, 没有bitstream_restriction时为0, 此时parallelismType也为0
+	if minSpatialSegmentationIdc > 4096 {
+		minSpatialSegmentationIdc = 0
+	}
+	if minSpatialSegmentationIdc == 0 {
+		parallelismType = 0
+	}
+
+	r.ConfigurationVersion = 1
+	r.GeneralProfileSpace = byte(ctx.GeneralProfileSpace)
+	r.GeneralTierFlag = byte(ctx.GeneralTierFlag)
+	r.GeneralProfileIdc = byte(ctx.GeneralProfileIDC)
+	r.GeneralProfileCompatibilityFlags = ctx.GeneralProfileCompatibilityFlags
+	r.GeneralConstraintIndicatorFlags = ctx.GeneralConstraintIndicatorFlags & 0xFFFFFFFFFFFF
+	r.GeneralLevelIdc = byte(ctx.GeneralLevelIDC)
+	r.MinSpatialSegmentationIdc = uint16(minSpatialSegmentationIdc)
+	r.ParallelismType = parallelismType
+	r.ChromaFormat = byte(ctx.ChromaFormat)
+	r.BitDepthLumaMinus8 = byte(ctx.BitDepthLumaMinus8)
+	r.BitDepthChromaMinus8 = byte(ctx.BitDepthChromaMinus8)
+	r.NumTemporalLayers = byte(ctx.NumTemporalLayers)
+	r.TemporalIdNested = byte(ctx.TemporalIdNested)
+	return nil
+}
+
+// Marshal 生成hvcC, 头部字段从参数集解析得到. r.SEIList不为空时, 按NALU类型写入额外的SEI数组.
+// avgFrameRate和constantFrameRate使用r中的值, 默认为0表示未指定.
 func (r *HEVCDecoderConfigurationRecord) Marshal(vpsList, spsList, ppsList [][]byte) ([]byte, error) {
 	if len(spsList) == 0 {
 		return nil, fmt.Errorf("sps cannot be null")
@@ -83,51 +157,62 @@ func (r *HEVCDecoderConfigurationRecord) Marshal(vpsList, spsList, ppsList [][]b
 		return nil, fmt.Errorf("vps cannot be null")
 	}
 
-	bytes := make([]byte, 1024)
-	bytes[0] = 1
-	bytes[21] = 3
-	bytes[22] = 3
-	writer := bufio.NewBytesWriter(bytes)
-	if err := writer.Seek(23); err != nil {
+	if err := r.updateFromParameterSets(vpsList, spsList, ppsList); err != nil {
 		return nil, err
 	}
 
-	write := func(data [][]byte) error {
-		noStartCodeData := avc.RemoveStartCode(data[0])
-		if err := writer.WriteUint8((noStartCodeData[0] >> 1) & 0x3F); err != nil {
-			return err
-		}
+	// LengthSizeMinusOne保存的是长度字段的字节数, 未设置时使用4字节
+	lengthSize := r.LengthSizeMinusOne
+	if lengthSize == 0 {
+		lengthSize = 4
+	}
 
-		if err := writer.WriteUint16(uint16(len(data))); err != nil {
-			return err
-		}
+	data := []byte{r.ConfigurationVersion, r.GeneralProfileSpace<<6 | (r.GeneralTierFlag&0x1)<<5 | r.GeneralProfileIdc&0x1F}
+	data = binary.BigEndian.AppendUint32(data, r.GeneralProfileCompatibilityFlags)
+	data = binary.BigEndian.AppendUint32(data, uint32(r.GeneralConstraintIndicatorFlags>>16))
+	data = binary.BigEndian.AppendUint16(data, uint16(r.GeneralConstraintIndicatorFlags))
+	data = append(data, r.GeneralLevelIdc)
+	// 保留位都为1
+	data = binary.BigEndian.AppendUint16(data, 0xF000|r.MinSpatialSegmentationIdc&0x0FFF)
+	data = append(data, 0xFC|r.ParallelismType&0x3, 0xFC|r.ChromaFormat&0x3, 0xF8|r.BitDepthLumaMinus8&0x7, 0xF8|r.BitDepthChromaMinus8&0x7)
+	data = binary.BigEndian.AppendUint16(data, r.AvgFrameRate)
+	data = append(data, (r.ConstantFrameRate&0x3)<<6|(r.NumTemporalLayers&0x7)<<3|(r.TemporalIdNested&0x1)<<2|(lengthSize-1)&0x3)
 
-		for _, i2 := range data {
-			noStartCodeData = avc.RemoveStartCode(i2)
-			if err := writer.WriteUint16(uint16(len(noStartCodeData))); err != nil {
-				return err
-			}
-
-			if err := writer.Write(noStartCodeData); err != nil {
-				return err
+	// 参数集都保存在hvcC中, array_completeness为1. SEI可能在码流中重复出现, array_completeness为0.
+	arrays := [][][]byte{vpsList, spsList, ppsList}
+	completeness := []bool{true, true, true}
+	for _, naluType := range []HEVCNALUnitType{HevcNalSeiPPrefix, HevcNalSeiSuffix} {
+		var list [][]byte
+		for _, sei := range r.SEIList {
+			if noStartCodeNALU := avc.RemoveStartCode(sei); len(noStartCodeNALU) > 1 && HEVCNALUnitType(noStartCodeNALU[0]>>1&0x3F) == naluType {
+				list = append(list, sei)
 			}
 		}
-		return nil
-	}
 
-	if err := write(vpsList); err != nil {
-		return nil, err
+		if len(list) > 0 {
+			arrays = append(arrays, list)
+			completeness = append(completeness, false)
+		}
 	}
 
-	if err := write(spsList); err != nil {
-		return nil, err
-	}
+	r.NumOfArrays = byte(len(arrays))
+	data = append(data, r.NumOfArrays)
+	for i, list := range arrays {
+		header := avc.RemoveStartCode(list[0])[0] >> 1 & 0x3F
+		if completeness[i] {
+			header |= 0x80
+		}
 
-	if err := write(ppsList); err != nil {
-		return nil, err
+		data = append(data, header)
+		data = binary.BigEndian.AppendUint16(data, uint16(len(list)))
+		for _, nalu := range list {
+			noStartCodeNALU := avc.RemoveStartCode(nalu)
+			data = binary.BigEndian.AppendUint16(data, uint16(len(noStartCodeNALU)))
+			data = append(data, noStartCodeNALU...)
+		}
 	}
 
-	return bytes[:writer.Offset()], nil
+	return data, nil
 }
 
 func (r *HEVCDecoderConfigurationRecord) Unmarshal(data []byte) error {
@@ -157,6 +242,7 @@ func (r *HEVCDecoderConfigurationRecord) Unmarshal(data []byte) error {
 	r.LengthSizeMinusOne = data[21]&0x3 + 1
 
 	r.NumOfArrays = data[22]
+	r.VPSList, r.SPSList, r.PPSList, r.SEIList = nil, nil, nil, nil
 
 	for i := 0; i < int(r.NumOfArrays); i++ {
 		readUint, err := reader.ReadUint8()
@@ -189,9 +275,11 @@ func (r *HEVCDecoderConfigurationRecord) Unmarshal(data []byte) error {
 			if HevcNalVPS == HEVCNALUnitType(headerType) {
 				r.VPSList = append(r.VPSList, nalu)
 			} else if HevcNalSPS == HEVCNALUnitType(headerType) {
-				r.SPSList = append(r.PPSList, nalu)
+				r.SPSList = append(r.SPSList, nalu)
 			} else if HevcNalPPS == HEVCNALUnitType(headerType) {
 				r.PPSList = append(r.PPSList, nalu)
+			} else if HevcNalSeiPPrefix == HEVCNALUnitType(headerType) || HevcNalSeiSuffix == HEVCNALUnitType(headerType) {
+				r.SEIList = append(r.SEIList, nalu)
 			}
 		}
 	}
@@ -214,26 +302,12 @@ func ExtraDataToAnnexB(data []byte) ([]byte, error) {
 		return nil, err
 	}
 
-	bytes := make([]byte, 1024)
-	writer := bufio.NewBytesWriter(bytes)
-
-	for _, sps := range record.VPSList {
-		if err := writer.Write(sps); err != nil {
-			return nil, err
-		}
-	}
-
-	for _, sps := range record.SPSList {
-		if err := writer.Write(sps); err != nil {
-			return nil, err
-		}
-	}
-
-	for _, pps := range record.PPSList {
-		if err := writer.Write(pps); err != nil {
-			return nil, err
+	var bytes []byte
+	for _, list := range [][][]byte{record.VPSList, record.SPSList, record.PPSList} {
+		for _, nalu := range list {
+			bytes = append(bytes, nalu...)
 		}
 	}
 
-	return bytes[:writer.Offset()], nil
+	return bytes, nil
 }
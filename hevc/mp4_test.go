@@ -1,8 +1,11 @@
 package hevc
 
 import (
+	"bytes"
 	"encoding/hex"
 	"fmt"
+	"github.com/lkmio/avformat/avc"
+	"github.com/lkmio/avformat/utils"
 	"testing"
 )
 
@@ -33,3 +36,45 @@ func TestNameHEVCDecoderConfigurationRecord(t *testing.T) {
 
 	println(fmt.Sprintf("width:%d height:%d", sps.Width, sps.Height))
 }
+
+func TestMarshalHEVCDecoderConfigurationRecord(t *testing.T) {
+	data := "0101600000009000000000005df000fcfdf8f800000f03a00001001840010c01ffff01600000030090000003000003005d999809a10001002d42010101600000030090000003000003005da00280802d165999a4932b9a808080820000030002000003003210a2000100074401c172b46240"
+	extraData, err := hex.DecodeString(data)
+	if err != nil {
+		panic(err)
+	}
+
+	record := HEVCDecoderConfigurationRecord{}
+	if err = record.Unmarshal(extraData); err != nil {
+		panic(err)
+	}
+
+	// 头部字段都从参数集推导, 和x265生成的hvcC一致
+	marshal, err := (&HEVCDecoderConfigurationRecord{}).Marshal(record.VPSList, record.SPSList, record.PPSList)
+	if err != nil {
+		panic(err)
+	}
+
+	utils.Assert(hex.EncodeToString(marshal) == data)
+
+	// RExt 4:2:2 10bit, 携带前缀SEI
+	sei := NewSEINalU(avc.SEIMessage{PayloadType: 137, Payload: make([]byte, 24)})
+	record = HEVCDecoderConfigurationRecord{SEIList: [][]byte{sei}}
+	marshal, err = record.Marshal([][]byte{newTestVPS()}, [][]byte{newTestRExtSPS()}, [][]byte{newTestPPS()})
+	if err != nil {
+		panic(err)
+	}
+
+	utils.Assert(marshal[1] == 4 && marshal[12] == 123 && marshal[16] == 0xFE && marshal[17] == 0xFA && marshal[18] == 0xFA)
+	utils.Assert(marshal[21]&0x3 == 3 && marshal[22] == 4)
+
+	unmarshal := HEVCDecoderConfigurationRecord{}
+	if err = unmarshal.Unmarshal(marshal); err != nil {
+		panic(err)
+	}
+
+	utils.Assert(unmarshal.GeneralProfileIdc == 4 && unmarshal.GeneralProfileCompatibilityFlags == 0x08000000 && unmarshal.ChromaFormat == 2)
+	utils.Assert(unmarshal.BitDepthLumaMinus8 == 2 && unmarshal.BitDepthChromaMinus8 == 2 && unmarshal.LengthSizeMinusOne == 4)
+	utils.Assert(len(unmarshal.VPSList) == 1 && len(unmarshal.SPSList) == 1 && len(unmarshal.PPSList) == 1 && len(unmarshal.SEIList) == 1)
+	utils.Assert(bytes.Equal(avc.RemoveStartCode(unmarshal.SEIList[0]), sei))
+}
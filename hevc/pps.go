@@ -0,0 +1,282 @@
+package hevc
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/lkmio/avformat/avc"
+	"github.com/lkmio/avformat/bufio"
+)
+
+func ceilLog2(v int) int {
+	var n int
+	for 1<<n < v {
+		n++
+	}
+
+	return n
+}
+
+// skipPTL 跳过profile_tier_level(1, maxSubLayersMinus1)
+func skipPTL(r *bufio.GolombBitReader, maxSubLayersMinus1 int) {
+	// general_profile_space...general_level_idc
+	r.Skip(96)
+	profilePresent := make([]bool, maxSubLayersMinus1)
+	levelPresent := make([]bool, maxSubLayersMinus1)
+	for i := 0; i < maxSubLayersMinus1; i++ {
+		profilePresent[i] = r.Flag()
+		levelPresent[i] = r.Flag()
+	}
+
+	if maxSubLayersMinus1 > 0 {
+		r.Skip(2 * (8 - maxSubLayersMinus1))
+	}
+
+	for i := 0; i < maxSubLayersMinus1; i++ {
+		if profilePresent[i] {
+			r.Skip(88)
+		}
+
+		if levelPresent[i] {
+			r.Skip(8)
+		}
+	}
+}
+
+// VPS video_parameter_set_rbsp, 7.3.2.1. 不解析vps_extension.
+type VPS struct {
+	Id                       int
+	MaxLayers                int
+	MaxSubLayers             int
+	TemporalIdNestingFlag    bool
+	MaxDecPicBufferingMinus1 []int
+	MaxNumReorderPics        []int
+	MaxLatencyIncreasePlus1  []int
+
+	MaxLayerId                  int
+	NumLayerSets                int      // vps_num_layer_sets_minus1 + 1
+	LayerIdIncludedFlag         [][]bool // 第0个layer set只包含nuh_layer_id等于0的layer, 为空
+	TimingInfoPresentFlag       bool
+	NumUnitsInTick              uint32
+	TimeScale                   uint32
+	PocProportionalToTimingFlag bool
+	NumTicksPocDiffOneMinus1    int
+	HRD                         []*HRD
+	ExtensionFlag               bool
+}
+
+func ParseVPS(data []byte) (*VPS, error) {
+	data = avc.EBSP2RBSP(avc.RemoveStartCode(data))
+	if len(data) < 3 || HEVCNALUnitType(data[0]>>1&0x3F) != HevcNalVPS {
+		return nil, fmt.Errorf("invalid vps nal unit")
+	}
+
+	r := &bufio.GolombBitReader{R: bytes.NewReader(data[2:])}
+	vps := &VPS{}
+	vps.Id = r.Bits(4)
+	// vps_base_layer_internal_flag, vps_base_layer_available_flag
+	r.Skip(2)
+	vps.MaxLayers = r.Bits(6) + 1
+	vps.MaxSubLayers = r.Bits(3) + 1
+	vps.TemporalIdNestingFlag = r.Flag()
+	// vps_reserved_0xffff_16bits
+	r.Skip(16)
+	skipPTL(r, vps.MaxSubLayers-1)
+
+	vps.MaxDecPicBufferingMinus1 = make([]int, vps.MaxSubLayers)
+	vps.MaxNumReorderPics = make([]int, vps.MaxSubLayers)
+	vps.MaxLatencyIncreasePlus1 = make([]int, vps.MaxSubLayers)
+	start := vps.MaxSubLayers - 1
+	if r.Flag() {
+		start = 0
+	}
+
+	for i := start; i < vps.MaxSubLayers; i++ {
+		vps.MaxDecPicBufferingMinus1[i] = r.UE()
+		vps.MaxNumReorderPics[i] = r.UE()
+		vps.MaxLatencyIncreasePlus1[i] = r.UE()
+	}
+
+	// 没有sub_layer_ordering_info时使用最高子层的值
+	for i := 0; i < start; i++ {
+		vps.MaxDecPicBufferingMinus1[i] = vps.MaxDecPicBufferingMinus1[start]
+		vps.MaxNumReorderPics[i] = vps.MaxNumReorderPics[start]
+		vps.MaxLatencyIncreasePlus1[i] = vps.MaxLatencyIncreasePlus1[start]
+	}
+
+	vps.MaxLayerId = r.Bits(6)
+	vps.NumLayerSets = r.UE() + 1
+	if vps.NumLayerSets > 1024 {
+		return nil, fmt.Errorf("invalid vps_num_layer_sets_minus1 %d", vps.NumLayerSets-1)
+	}
+
+	vps.LayerIdIncludedFlag = make([][]bool, vps.NumLayerSets)
+	for i := 1; i < vps.NumLayerSets; i++ {
+		vps.LayerIdIncludedFlag[i] = make([]bool, vps.MaxLayerId+1)
+		for j := range vps.LayerIdIncludedFlag[i] {
+			vps.LayerIdIncludedFlag[i][j] = r.Flag()
+		}
+	}
+
+	if vps.TimingInfoPresentFlag = r.Flag(); vps.TimingInfoPresentFlag {
+		vps.NumUnitsInTick = uint32(r.Bits(32))
+		vps.TimeScale = uint32(r.Bits(32))
+		if vps.PocProportionalToTimingFlag = r.Flag(); vps.PocProportionalToTimingFlag {
+			vps.NumTicksPocDiffOneMinus1 = r.UE()
+		}
+
+		numHrdParameters := r.UE()
+		if numHrdParameters > vps.NumLayerSets {
+			return nil, fmt.Errorf("invalid vps_num_hrd_parameters %d", numHrdParameters)
+		}
+
+		for i := 0; i < numHrdParameters; i++ {
+			// hrd_layer_set_idx
+			r.UE()
+			// 第一个hrd_parameters总是包含公共信息
+			cprmsPresent := true
+			if i > 0 {
+				cprmsPresent = r.Flag()
+			}
+
+			hrd, err := parseHRD(r, cprmsPresent, vps.MaxSubLayers-1)
+			if err != nil {
+				return nil, err
+			}
+
+			vps.HRD = append(vps.HRD, hrd)
+		}
+	}
+
+	vps.ExtensionFlag = r.Flag()
+	if r.Err() != nil {
+		return nil, fmt.Errorf("invalid vps")
+	}
+
+	return vps, nil
+}
+
+// PPS pic_parameter_set_rbsp, 7.3.2.3. 不解析pps扩展.
+type PPS struct {
+	Id                                int
+	SPSId                             int
+	DependentSliceSegmentsEnabledFlag bool
+	OutputFlagPresentFlag             bool
+	NumExtraSliceHeaderBits           int
+	SignDataHidingEnabledFlag         bool
+	CabacInitPresentFlag              bool
+	NumRefIdxL0DefaultActive          int
+	NumRefIdxL1DefaultActive          int
+	InitQp                            int
+	ConstrainedIntraPredFlag          bool
+	TransformSkipEnabledFlag          bool
+	CuQpDeltaEnabledFlag              bool
+	DiffCuQpDeltaDepth                int
+	CbQpOffset                        int
+	CrQpOffset                        int
+	SliceChromaQpOffsetsPresentFlag   bool
+	WeightedPredFlag                  bool
+	WeightedBipredFlag                bool
+	TransquantBypassEnabledFlag       bool
+	TilesEnabledFlag                  bool
+	EntropyCodingSyncEnabledFlag      bool
+
+	NumTileColumns                     int // num_tile_columns_minus1 + 1
+	NumTileRows                        int
+	UniformSpacingFlag                 bool
+	ColumnWidthMinus1                  []int
+	RowHeightMinus1                    []int
+	LoopFilterAcrossTilesEnabledFlag   bool
+	LoopFilterAcrossSlicesEnabledFlag  bool
+	DeblockingFilterControlPresentFlag bool
+	DeblockingFilterOverrideEnabled    bool
+	DeblockingFilterDisabledFlag       bool
+	BetaOffsetDiv2                     int
+	TcOffsetDiv2                       int
+	ScalingListDataPresentFlag         bool
+	ListsModificationPresentFlag       bool
+	Log2ParallelMergeLevel             int
+	SliceSegmentHeaderExtensionPresent bool
+	ExtensionPresentFlag               bool
+}
+
+func ParsePPS(data []byte) (*PPS, error) {
+	data = avc.EBSP2RBSP(avc.RemoveStartCode(data))
+	if len(data) < 3 || HEVCNALUnitType(data[0]>>1&0x3F) != HevcNalPPS {
+		return nil, fmt.Errorf("invalid pps nal unit")
+	}
+
+	r := &bufio.GolombBitReader{R: bytes.NewReader(data[2:])}
+	pps := &PPS{}
+	pps.Id = r.UE()
+	pps.SPSId = r.UE()
+	if pps.Id > 63 || pps.SPSId > 15 {
+		return nil, fmt.Errorf("invalid pps id %d or sps id %d", pps.Id, pps.SPSId)
+	}
+
+	pps.DependentSliceSegmentsEnabledFlag = r.Flag()
+	pps.OutputFlagPresentFlag = r.Flag()
+	pps.NumExtraSliceHeaderBits = r.Bits(3)
+	pps.SignDataHidingEnabledFlag = r.Flag()
+	pps.CabacInitPresentFlag = r.Flag()
+	pps.NumRefIdxL0DefaultActive = r.UE() + 1
+	pps.NumRefIdxL1DefaultActive = r.UE() + 1
+	pps.InitQp = 26 + r.SE()
+	pps.ConstrainedIntraPredFlag = r.Flag()
+	pps.TransformSkipEnabledFlag = r.Flag()
+	pps.CuQpDeltaEnabledFlag = r.Flag()
+	if pps.CuQpDeltaEnabledFlag {
+		pps.DiffCuQpDeltaDepth = r.UE()
+	}
+
+	pps.CbQpOffset = r.SE()
+	pps.CrQpOffset = r.SE()
+	pps.SliceChromaQpOffsetsPresentFlag = r.Flag()
+	pps.WeightedPredFlag = r.Flag()
+	pps.WeightedBipredFlag = r.Flag()
+	pps.TransquantBypassEnabledFlag = r.Flag()
+	pps.TilesEnabledFlag = r.Flag()
+	pps.EntropyCodingSyncEnabledFlag = r.Flag()
+	pps.NumTileColumns, pps.NumTileRows, pps.UniformSpacingFlag = 1, 1, true
+	if pps.TilesEnabledFlag {
+		pps.NumTileColumns = r.UE() + 1
+		pps.NumTileRows = r.UE() + 1
+		if pps.NumTileColumns > 20 || pps.NumTileRows > 22 {
+			return nil, fmt.Errorf("invalid tiles %dx%d", pps.NumTileColumns, pps.NumTileRows)
+		}
+
+		if pps.UniformSpacingFlag = r.Flag(); !pps.UniformSpacingFlag {
+			for i := 0; i < pps.NumTileColumns-1; i++ {
+				pps.ColumnWidthMinus1 = append(pps.ColumnWidthMinus1, r.UE())
+			}
+
+			for i := 0; i < pps.NumTileRows-1; i++ {
+				pps.RowHeightMinus1 = append(pps.RowHeightMinus1, r.UE())
+			}
+		}
+
+		pps.LoopFilterAcrossTilesEnabledFlag = r.Flag()
+	}
+
+	pps.LoopFilterAcrossSlicesEnabledFlag = r.Flag()
+	if pps.DeblockingFilterControlPresentFlag = r.Flag(); pps.DeblockingFilterControlPresentFlag {
+		pps.DeblockingFilterOverrideEnabled = r.Flag()
+		if pps.DeblockingFilterDisabledFlag = r.Flag(); !pps.DeblockingFilterDisabledFlag {
+			pps.BetaOffsetDiv2 = r.SE()
+			pps.TcOffsetDiv2 = r.SE()
+		}
+	}
+
+	if pps.ScalingListDataPresentFlag = r.Flag(); pps.ScalingListDataPresentFlag {
+		skipScalingListData(r)
+	}
+
+	pps.ListsModificationPresentFlag = r.Flag()
+	pps.Log2ParallelMergeLevel = r.UE() + 2
+	pps.SliceSegmentHeaderExtensionPresent = r.Flag()
+	pps.ExtensionPresentFlag = r.Flag()
+	if r.Err() != nil {
+		return nil, fmt.Errorf("invalid pps")
+	}
+
+	return pps, nil
+}
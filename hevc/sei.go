@@ -0,0 +1,135 @@
+package hevc
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/lkmio/avformat/avc"
+	"github.com/lkmio/avformat/bufio"
+)
+
+// RecoveryPoint H.265 D.2.8, recovery_poc_cnt为有符号值
+type RecoveryPoint struct {
+	RecoveryPocCnt int
+	ExactMatchFlag bool
+	BrokenLinkFlag bool
+}
+
+// PicTimingParams 解析pic_timing需要的SPS VUI参数
+type PicTimingParams struct {
+	FrameFieldInfoPresentFlag bool
+	CpbDpbDelaysPresentFlag   bool // nal_hrd_parameters_present_flag || vcl_hrd_parameters_present_flag
+	AuCpbRemovalDelayLength   int  // au_cpb_removal_delay_length_minus1 + 1
+	DpbOutputDelayLength      int  // dpb_output_delay_length_minus1 + 1
+}
+
+// PicTiming 不解析sub_pic_hrd_params相关的decoding unit信息
+type PicTiming struct {
+	PicStruct               int
+	SourceScanType          int
+	DuplicateFlag           bool
+	AuCpbRemovalDelayMinus1 int
+	PicDpbOutputDelay       int
+}
+
+func isSEI(nalu []byte) bool {
+	t := HEVCNALUnitType(nalu[0] >> 1 & 0x3F)
+	return t == HevcNalSeiPPrefix || t == HevcNalSeiSuffix
+}
+
+// ParseSEI 解析前缀或后缀SEI NALU, 可以包含起始码
+func ParseSEI(nalu []byte) ([]avc.SEIMessage, error) {
+	nalu = avc.RemoveStartCode(nalu)
+	if len(nalu) < 3 || !isSEI(nalu) {
+		return nil, fmt.Errorf("invalid sei nal unit")
+	}
+
+	return avc.ParseSEIMessages(avc.EBSP2RBSP(nalu[2:]))
+}
+
+// NewSEINalU 生成不包含起始码的前缀SEI NALU
+func NewSEINalU(messages ...avc.SEIMessage) []byte {
+	// nuh_layer_id=0, nuh_temporal_id_plus1=1
+	nalu := []byte{byte(HevcNalSeiPPrefix) << 1, 0x01}
+	return append(nalu, avc.RBSP2EBSP(avc.MarshalSEIMessages(messages))...)
+}
+
+func parseSEINalUnits(nalUnits [][]byte) ([]avc.SEIMessage, error) {
+	var messages []avc.SEIMessage
+	for _, nalu := range nalUnits {
+		result, err := ParseSEI(nalu)
+		messages = append(messages, result...)
+		if err != nil {
+			return messages, err
+		}
+	}
+
+	return messages, nil
+}
+
+// ExtractSEI 解析annexb帧中所有SEI NALU
+func ExtractSEI(annexB []byte) ([]avc.SEIMessage, error) {
+	var nalUnits [][]byte
+	avc.SplitNalU(annexB, func(nalu []byte) {
+		if nalu = avc.RemoveStartCode(nalu); len(nalu) > 0 && isSEI(nalu) {
+			nalUnits = append(nalUnits, nalu)
+		}
+	})
+
+	return parseSEINalUnits(nalUnits)
+}
+
+// ExtractSEIFromAVCC 解析hvcc帧中所有SEI NALU
+func ExtractSEIFromAVCC(data []byte, lengthSize int) ([]avc.SEIMessage, error) {
+	var nalUnits [][]byte
+	err := avc.SplitAVCC(data, lengthSize, func(nalu []byte) {
+		if len(nalu) > 0 && isSEI(nalu) {
+			nalUnits = append(nalUnits, nalu)
+		}
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return parseSEINalUnits(nalUnits)
+}
+
+func ParseRecoveryPoint(payload []byte) (*RecoveryPoint, error) {
+	r := &bufio.GolombBitReader{R: bytes.NewReader(payload)}
+	cnt, err := r.ReadSE()
+	if err != nil {
+		return nil, err
+	}
+
+	flags, err := r.ReadBits(2)
+	if err != nil {
+		return nil, err
+	}
+
+	return &RecoveryPoint{
+		RecoveryPocCnt: int(cnt),
+		ExactMatchFlag: flags>>1 == 1,
+		BrokenLinkFlag: flags&1 == 1,
+	}, nil
+}
+
+func ParsePicTiming(payload []byte, params *PicTimingParams) (*PicTiming, error) {
+	r := bufio.BitsReader{Data: payload}
+	timing := &PicTiming{}
+	if params.FrameFieldInfoPresentFlag {
+		timing.PicStruct = int(r.Read(4))
+		timing.SourceScanType = int(r.Read(2))
+		timing.DuplicateFlag = r.Read(1) == 1
+	}
+
+	if params.CpbDpbDelaysPresentFlag {
+		timing.AuCpbRemovalDelayMinus1 = int(r.Read(params.AuCpbRemovalDelayLength))
+		timing.PicDpbOutputDelay = int(r.Read(params.DpbOutputDelayLength))
+	}
+
+	if r.Offset > len(payload)*8 {
+		return nil, fmt.Errorf("invalid pic timing")
+	}
+
+	return timing, nil
+}
@@ -0,0 +1,60 @@
+package hevc
+
+import (
+	"encoding/binary"
+	"github.com/lkmio/avformat/avc"
+	"github.com/lkmio/avformat/utils"
+	"testing"
+)
+
+func TestSEI(t *testing.T) {
+	// 前缀SEI, recovery_poc_cnt=-2, exact_match_flag=1
+	prefix := []byte{byte(HevcNalSeiPPrefix) << 1, 0x01, avc.SEITypeRecoveryPoint, 1, 0x2D, 0x80}
+	// 后缀SEI, content_light_level_info, 包含防竞争字节
+	suffix := []byte{byte(HevcNalSeiSuffix) << 1, 0x01, avc.SEITypeContentLightLevelInfo, 4, 0x00, 0x00, 0x03, 0x00, 0x01, 0x80}
+	slice := []byte{0x26, 0x01, 0xAF, 0x00}
+
+	annexB := append([]byte{0, 0, 0, 1}, prefix...)
+	annexB = append(append(annexB, 0, 0, 0, 1), slice...)
+	annexB = append(append(annexB, 0, 0, 0, 1), suffix...)
+	messages, err := ExtractSEI(annexB)
+	if err != nil {
+		panic(err)
+	}
+
+	utils.Assert(len(messages) == 2)
+
+	recoveryPoint, err := ParseRecoveryPoint(messages[0].Payload)
+	if err != nil {
+		panic(err)
+	}
+
+	utils.Assert(recoveryPoint.RecoveryPocCnt == -2 && recoveryPoint.ExactMatchFlag && !recoveryPoint.BrokenLinkFlag)
+
+	cll, err := avc.ParseContentLightLevelInfo(messages[1].Payload)
+	if err != nil {
+		panic(err)
+	}
+
+	utils.Assert(cll.MaxContentLightLevel == 0 && cll.MaxPicAverageLightLevel == 1)
+
+	var hvcc []byte
+	for _, nalu := range [][]byte{prefix, slice, suffix} {
+		hvcc = binary.BigEndian.AppendUint32(hvcc, uint32(len(nalu)))
+		hvcc = append(hvcc, nalu...)
+	}
+
+	messages, err = ExtractSEIFromAVCC(hvcc, 4)
+	if err != nil {
+		panic(err)
+	}
+
+	utils.Assert(len(messages) == 2 && messages[1].PayloadType == avc.SEITypeContentLightLevelInfo)
+
+	timing, err := ParsePicTiming([]byte{0x12, 0x80}, &PicTimingParams{FrameFieldInfoPresentFlag: true})
+	if err != nil {
+		panic(err)
+	}
+
+	utils.Assert(timing.PicStruct == 1 && timing.SourceScanType == 0 && timing.DuplicateFlag)
+}
@@ -0,0 +1,263 @@
+package hevc
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/lkmio/avformat/avc"
+	"github.com/lkmio/avformat/bufio"
+)
+
+// SliceHeader 只解析到slice_temporal_mvp_enabled_flag, 足够计算POC.
+// 非独立的slice segment(DependentSliceSegmentFlag)的字段沿用所属slice, 不会解析.
+type SliceHeader struct {
+	NalUnitType                HEVCNALUnitType
+	TemporalId                 int
+	FirstSliceSegmentInPicFlag bool
+	NoOutputOfPriorPicsFlag    bool
+	PPSId                      int
+	DependentSliceSegmentFlag  bool
+	SegmentAddress             int
+	SliceType                  HEVCSliceType
+	PicOutputFlag              bool
+	ColourPlaneId              int
+	PicOrderCntLsb             int
+	ShortTermRefPicSetSPSFlag  bool
+	ShortTermRefPicSetIdx      int
+	ShortTermRefPicSet         *ShortTermRefPicSet // 使用的短期RPS, IDR帧为nil
+	NumLongTermSPS             int
+	NumLongTermPics            int
+	TemporalMVPEnabledFlag     bool
+}
+
+// IsIRAP BLA/IDR/CRA以及保留的IRAP类型
+func (h *SliceHeader) IsIRAP() bool {
+	return h.NalUnitType >= HevcNalBlaWLP && h.NalUnitType <= HevcNalRsvIRAPVCL23
+}
+
+func (h *SliceHeader) IsIDR() bool {
+	return h.NalUnitType == HevcNalIdrWRADL || h.NalUnitType == HevcNalIdrNLP
+}
+
+func (h *SliceHeader) IsBLA() bool {
+	return h.NalUnitType >= HevcNalBlaWLP && h.NalUnitType <= HevcNalBlaNLP
+}
+
+func (h *SliceHeader) IsRASL() bool {
+	return h.NalUnitType == HevcNalRASLN || h.NalUnitType == HevcNalRASLR
+}
+
+func (h *SliceHeader) IsRADL() bool {
+	return h.NalUnitType == HevcNalRADLN || h.NalUnitType == HevcNalRADLR
+}
+
+// IsSubLayerNonReference TRAIL_N, TSA_N, STSA_N, RADL_N, RASL_N, RSV_VCL_N10/12/14
+func (h *SliceHeader) IsSubLayerNonReference() bool {
+	return h.NalUnitType <= HevcNalVclR15 && h.NalUnitType%2 == 0
+}
+
+// ParameterSets 保存解码slice header需要的VPS/SPS/PPS, 以id为索引
+type ParameterSets struct {
+	VPS map[int]*VPS
+	SPS map[int]*HEVCSPSInfo
+	PPS map[int]*PPS
+}
+
+func NewParameterSets() *ParameterSets {
+	return &ParameterSets{VPS: map[int]*VPS{}, SPS: map[int]*HEVCSPSInfo{}, PPS: map[int]*PPS{}}
+}
+
+// Update 解析VPS/SPS/PPS NALU并保存, 其他类型的NALU忽略
+func (p *ParameterSets) Update(nalu []byte) error {
+	nalu = avc.RemoveStartCode(nalu)
+	if len(nalu) < 2 {
+		return fmt.Errorf("invalid nal unit")
+	}
+
+	switch HEVCNALUnitType(nalu[0] >> 1 & 0x3F) {
+	case HevcNalVPS:
+		vps, err := ParseVPS(nalu)
+		if err != nil {
+			return err
+		}
+
+		p.VPS[vps.Id] = vps
+	case HevcNalSPS:
+		sps, err := ParseSPS(nalu)
+		if err != nil {
+			return err
+		}
+
+		p.SPS[sps.Id] = &sps
+	case HevcNalPPS:
+		pps, err := ParsePPS(nalu)
+		if err != nil {
+			return err
+		}
+
+		p.PPS[pps.Id] = pps
+	}
+
+	return nil
+}
+
+// ParseSliceHeader 解析slice segment NALU的头, 返回使用的SPS
+func (p *ParameterSets) ParseSliceHeader(nalu []byte) (*SliceHeader, *HEVCSPSInfo, error) {
+	nalu = avc.RemoveStartCode(nalu)
+	if len(nalu) < 3 {
+		return nil, nil, fmt.Errorf("invalid slice nal unit")
+	}
+
+	header := &SliceHeader{NalUnitType: HEVCNALUnitType(nalu[0] >> 1 & 0x3F), TemporalId: int(nalu[1]&0x7) - 1, PicOutputFlag: true}
+	if header.NalUnitType > HevcNalRsvVCL31 {
+		return nil, nil, fmt.Errorf("unsupported nal unit type %d", header.NalUnitType)
+	}
+
+	// 短期RPS可能比较长, 只转换前256个字节
+	data := nalu[2:bufio.MinInt(len(nalu), 256)]
+	r := &bufio.GolombBitReader{R: bytes.NewReader(avc.EBSP2RBSP(data))}
+	header.FirstSliceSegmentInPicFlag = r.Flag()
+	if header.IsIRAP() {
+		header.NoOutputOfPriorPicsFlag = r.Flag()
+	}
+
+	header.PPSId = r.UE()
+	pps, ok := p.PPS[header.PPSId]
+	if !ok {
+		return nil, nil, fmt.Errorf("pps %d not found", header.PPSId)
+	}
+
+	sps, ok := p.SPS[pps.SPSId]
+	if !ok {
+		return nil, nil, fmt.Errorf("sps %d not found", pps.SPSId)
+	}
+
+	if !header.FirstSliceSegmentInPicFlag {
+		if pps.DependentSliceSegmentsEnabledFlag {
+			header.DependentSliceSegmentFlag = r.Flag()
+		}
+
+		header.SegmentAddress = r.Bits(ceilLog2(sps.PicSizeInCtbsY))
+	}
+
+	if header.DependentSliceSegmentFlag {
+		return header, sps, nil
+	}
+
+	// slice_reserved_flag
+	r.Skip(pps.NumExtraSliceHeaderBits)
+	sliceType := r.UE()
+	if sliceType > int(HevcSliceI) {
+		return nil, nil, fmt.Errorf("invalid slice type %d", sliceType)
+	}
+
+	header.SliceType = HEVCSliceType(sliceType)
+	if pps.OutputFlagPresentFlag {
+		header.PicOutputFlag = r.Flag()
+	}
+
+	if sps.SeparateColourPlaneFlag {
+		header.ColourPlaneId = r.Bits(2)
+	}
+
+	if !header.IsIDR() {
+		header.PicOrderCntLsb = r.Bits(sps.Log2MaxPicOrderCntLsb)
+		header.ShortTermRefPicSetSPSFlag = r.Flag()
+		numSets := len(sps.ShortTermRefPicSets)
+		if !header.ShortTermRefPicSetSPSFlag {
+			rps, err := parseShortTermRefPicSet(r, sps.ShortTermRefPicSets, numSets, numSets)
+			if err != nil {
+				return nil, nil, err
+			}
+
+			header.ShortTermRefPicSet = rps
+		} else if numSets == 0 {
+			return nil, nil, fmt.Errorf("short term ref pic set not found")
+		} else {
+			if numSets > 1 {
+				header.ShortTermRefPicSetIdx = r.Bits(ceilLog2(numSets))
+			}
+
+			if header.ShortTermRefPicSetIdx >= numSets {
+				return nil, nil, fmt.Errorf("invalid short_term_ref_pic_set_idx %d", header.ShortTermRefPicSetIdx)
+			}
+
+			header.ShortTermRefPicSet = sps.ShortTermRefPicSets[header.ShortTermRefPicSetIdx]
+		}
+
+		if sps.LongTermRefPicsPresentFlag {
+			if sps.NumLongTermRefPicsSPS > 0 {
+				header.NumLongTermSPS = r.UE()
+			}
+
+			header.NumLongTermPics = r.UE()
+			if header.NumLongTermSPS > sps.NumLongTermRefPicsSPS || header.NumLongTermSPS+header.NumLongTermPics > 32 {
+				return nil, nil, fmt.Errorf("invalid long term ref pics")
+			}
+
+			for i := 0; i < header.NumLongTermSPS+header.NumLongTermPics; i++ {
+				if i < header.NumLongTermSPS {
+					// lt_idx_sps
+					r.Skip(ceilLog2(sps.NumLongTermRefPicsSPS))
+				} else {
+					// poc_lsb_lt, used_by_curr_pic_lt_flag
+					r.Skip(sps.Log2MaxPicOrderCntLsb + 1)
+				}
+
+				// delta_poc_msb_present_flag, delta_poc_msb_cycle_lt
+				if r.Flag() {
+					r.UE()
+				}
+			}
+		}
+
+		if sps.TemporalMVPEnabledFlag {
+			header.TemporalMVPEnabledFlag = r.Flag()
+		}
+	}
+
+	if r.Err() != nil {
+		return nil, nil, fmt.Errorf("invalid slice header")
+	}
+
+	return header, sps, nil
+}
+
+// POC 按解码顺序计算图像的PicOrderCntVal, 8.3.1
+type POC struct {
+	// NoRaslOutputFlag 最近一次计算的IRAP图像是否重新开始POC计数(IDR, BLA以及第一个CRA)
+	NoRaslOutputFlag bool
+
+	started                bool
+	prevTid0PicOrderCntMsb int
+	prevTid0PicOrderCntLsb int
+}
+
+// Compute 返回当前图像的POC, 同一图像的多个slice segment只需要计算第一个
+func (c *POC) Compute(header *SliceHeader, sps *HEVCSPSInfo) int {
+	if header.IsIRAP() {
+		c.NoRaslOutputFlag = header.IsIDR() || header.IsBLA() || !c.started
+	} else {
+		c.NoRaslOutputFlag = false
+	}
+
+	c.started = true
+	lsb := header.PicOrderCntLsb
+	var msb int
+	if !header.IsIRAP() || !c.NoRaslOutputFlag {
+		maxLsb := 1 << sps.Log2MaxPicOrderCntLsb
+		msb = c.prevTid0PicOrderCntMsb
+		if lsb < c.prevTid0PicOrderCntLsb && c.prevTid0PicOrderCntLsb-lsb >= maxLsb/2 {
+			msb += maxLsb
+		} else if lsb > c.prevTid0PicOrderCntLsb && lsb-c.prevTid0PicOrderCntLsb > maxLsb/2 {
+			msb -= maxLsb
+		}
+	}
+
+	// prevTid0Pic: TemporalId等于0并且不是RASL/RADL/SLNR的前一个图像
+	if header.TemporalId == 0 && !header.IsRASL() && !header.IsRADL() && !header.IsSubLayerNonReference() {
+		c.prevTid0PicOrderCntMsb = msb
+		c.prevTid0PicOrderCntLsb = lsb
+	}
+
+	return msb + lsb
+}
@@ -0,0 +1,243 @@
+package hevc
+
+import (
+	"github.com/lkmio/avformat/avc"
+	"github.com/lkmio/avformat/bufio"
+	"github.com/lkmio/avformat/utils"
+	"testing"
+)
+
+func finishNalU(nalType HEVCNALUnitType, temporalId int, writer *bufio.BitsWriter) []byte {
+	return append([]byte{byte(nalType) << 1, byte(temporalId + 1)}, avc.RBSP2EBSP(writer.WriteTrailingBits())...)
+}
+
+// writePTL general_profile_idc=1(main), level 3.1, 不包含子层
+func writePTL(writer *bufio.BitsWriter) {
+	writer.Write(8, 1)
+	writer.Write(32, 0x60000000)
+	writer.Write(48, 0)
+	writer.Write(8, 93)
+}
+
+func newTestVPS() []byte {
+	writer := &bufio.BitsWriter{Data: make([]byte, 32)}
+	writer.Write(4, 0)       // vps_video_parameter_set_id
+	writer.Write(2, 0x3)     // vps_base_layer_internal_flag, vps_base_layer_available_flag
+	writer.Write(6, 0)       // vps_max_layers_minus1
+	writer.Write(3, 0)       // vps_max_sub_layers_minus1
+	writer.Write(1, 1)       // vps_temporal_id_nesting_flag
+	writer.Write(16, 0xFFFF) // vps_reserved_0xffff_16bits
+	writePTL(writer)
+	writer.Write(1, 1) // vps_sub_layer_ordering_info_present_flag
+	writer.WriteUE(4)
+	writer.WriteUE(2)
+	writer.WriteUE(0)
+	writer.Write(6, 0) // vps_max_layer_id
+	writer.WriteUE(0)  // vps_num_layer_sets_minus1
+	writer.Write(2, 0) // vps_timing_info_present_flag, vps_extension_flag
+	return finishNalU(HevcNalVPS, 0, writer)
+}
+
+// 320x240, ctb 64x64, log2_max_pic_order_cnt_lsb=8, 2个短期RPS, 第二个使用帧间预测
+func newTestSPS() []byte {
+	writer := &bufio.BitsWriter{Data: make([]byte, 64)}
+	writer.Write(4, 0) // sps_video_parameter_set_id
+	writer.Write(3, 0) // sps_max_sub_layers_minus1
+	writer.Write(1, 1) // sps_temporal_id_nesting_flag
+	writePTL(writer)
+	writer.WriteUE(0)   // sps_seq_parameter_set_id
+	writer.WriteUE(1)   // chroma_format_idc
+	writer.WriteUE(320) // pic_width_in_luma_samples
+	writer.WriteUE(240) // pic_height_in_luma_samples
+	writer.Write(1, 0)  // conformance_window_flag
+	writer.WriteUE(0)   // bit_depth_luma_minus8
+	writer.WriteUE(0)   // bit_depth_chroma_minus8
+	writer.WriteUE(4)   // log2_max_pic_order_cnt_lsb_minus4
+	writer.Write(1, 1)  // sps_sub_layer_ordering_info_present_flag
+	writer.WriteUE(4)
+	writer.WriteUE(2)
+	writer.WriteUE(0)
+	writer.WriteUE(0)    // log2_min_luma_coding_block_size_minus3
+	writer.WriteUE(3)    // log2_diff_max_min_luma_coding_block_size
+	writer.WriteUE(0)    // log2_min_luma_transform_block_size_minus2
+	writer.WriteUE(3)    // log2_diff_max_min_luma_transform_block_size
+	writer.WriteUE(0)    // max_transform_hierarchy_depth_inter
+	writer.WriteUE(0)    // max_transform_hierarchy_depth_intra
+	writer.Write(4, 0x2) // scaling_list_enabled_flag, amp_enabled_flag, sample_adaptive_offset_enabled_flag, pcm_enabled_flag
+
+	writer.WriteUE(2) // num_short_term_ref_pic_sets
+	// st_ref_pic_set(0): {-1}
+	writer.WriteUE(1)
+	writer.WriteUE(0)
+	writer.WriteUE(0)
+	writer.Write(1, 1)
+	// st_ref_pic_set(1): inter_ref_pic_set_prediction_flag=1, deltaRps=2
+	writer.Write(1, 1)
+	writer.Write(1, 0)
+	writer.WriteUE(1)
+	writer.Write(2, 0x3)
+
+	writer.Write(1, 0)   // long_term_ref_pics_present_flag
+	writer.Write(2, 0x3) // sps_temporal_mvp_enabled_flag, strong_intra_smoothing_enabled_flag
+	writer.Write(2, 0)   // vui_parameters_present_flag, sps_extension_present_flag
+	return finishNalU(HevcNalSPS, 0, writer)
+}
+
+func newTestPPS() []byte {
+	writer := &bufio.BitsWriter{Data: make([]byte, 32)}
+	writer.WriteUE(0)    // pps_pic_parameter_set_id
+	writer.WriteUE(0)    // pps_seq_parameter_set_id
+	writer.Write(7, 0)   // dependent_slice_segments_enabled_flag...cabac_init_present_flag
+	writer.WriteUE(0)    // num_ref_idx_l0_default_active_minus1
+	writer.WriteUE(1)    // num_ref_idx_l1_default_active_minus1
+	writer.WriteSE(-4)   // init_qp_minus26
+	writer.Write(3, 0x1) // constrained_intra_pred_flag, transform_skip_enabled_flag, cu_qp_delta_enabled_flag
+	writer.WriteUE(1)    // diff_cu_qp_delta_depth
+	writer.WriteSE(-2)   // pps_cb_qp_offset
+	writer.WriteSE(0)    // pps_cr_qp_offset
+	writer.Write(6, 0)   // pps_slice_chroma_qp_offsets_present_flag...entropy_coding_sync_enabled_flag
+	writer.Write(1, 1)   // pps_loop_filter_across_slices_enabled_flag
+	writer.Write(1, 1)   // deblocking_filter_control_present_flag
+	writer.Write(2, 0)   // deblocking_filter_override_enabled_flag, pps_deblocking_filter_disabled_flag
+	writer.WriteSE(3)    // pps_beta_offset_div2
+	writer.WriteSE(-1)   // pps_tc_offset_div2
+	writer.Write(2, 0)   // pps_scaling_list_data_present_flag, lists_modification_present_flag
+	writer.WriteUE(0)    // log2_parallel_merge_level_minus2
+	writer.Write(2, 0)   // slice_segment_header_extension_present_flag, pps_extension_present_flag
+	return finishNalU(HevcNalPPS, 0, writer)
+}
+
+// newTestSlice 生成slice segment, rpsIdx小于0时在slice header中使用帧间预测的RPS
+func newTestSlice(nalType HEVCNALUnitType, temporalId int, sliceType HEVCSliceType, pocLsb, rpsIdx int) []byte {
+	writer := &bufio.BitsWriter{Data: make([]byte, 32)}
+	header := &SliceHeader{NalUnitType: nalType}
+	writer.Write(1, 1) // first_slice_segment_in_pic_flag
+	if header.IsIRAP() {
+		writer.Write(1, 0) // no_output_of_prior_pics_flag
+	}
+
+	writer.WriteUE(0) // slice_pic_parameter_set_id
+	writer.WriteUE(int(sliceType))
+	if !header.IsIDR() {
+		writer.Write(8, uint64(pocLsb))
+		if rpsIdx >= 0 {
+			writer.Write(1, 1)
+			writer.Write(1, uint64(rpsIdx))
+		} else {
+			// inter_ref_pic_set_prediction_flag, delta_idx_minus1=0, deltaRps=-3
+			writer.Write(1, 0)
+			writer.Write(1, 1)
+			writer.WriteUE(0)
+			writer.Write(1, 1)
+			writer.WriteUE(2)
+			writer.Write(3, 0x7)
+		}
+
+		writer.Write(1, 1) // slice_temporal_mvp_enabled_flag
+	}
+
+	writer.Write(7, 0x55)
+	return finishNalU(nalType, temporalId, writer)
+}
+
+func TestParameterSets(t *testing.T) {
+	vps, err := ParseVPS(newTestVPS())
+	if err != nil {
+		panic(err)
+	}
+
+	utils.Assert(vps.MaxSubLayers == 1 && vps.TemporalIdNestingFlag && vps.MaxNumReorderPics[0] == 2)
+	utils.Assert(vps.NumLayerSets == 1 && !vps.TimingInfoPresentFlag && !vps.ExtensionFlag)
+
+	sps, err := ParseSPS(newTestSPS())
+	if err != nil {
+		panic(err)
+	}
+
+	utils.Assert(sps.Width == 320 && sps.Height == 240 && sps.Log2MaxPicOrderCntLsb == 8 && sps.MaxNumReorderPics == 2)
+	utils.Assert(sps.Log2CtbSize == 6 && sps.PicSizeInCtbsY == 20 && sps.TemporalMVPEnabledFlag && sps.StrongIntraSmoothingFlag)
+	utils.Assert(len(sps.ShortTermRefPicSets) == 2)
+
+	rps := sps.ShortTermRefPicSets[0]
+	utils.Assert(rps.NumNegativePics == 1 && rps.NumPositivePics == 0 && rps.DeltaPocS0[0] == -1 && rps.UsedByCurrPicS0[0])
+	rps = sps.ShortTermRefPicSets[1]
+	utils.Assert(rps.NumNegativePics == 0 && rps.NumPositivePics == 2 && rps.DeltaPocS1[0] == 1 && rps.DeltaPocS1[1] == 2)
+
+	pps, err := ParsePPS(newTestPPS())
+	if err != nil {
+		panic(err)
+	}
+
+	utils.Assert(pps.NumRefIdxL1DefaultActive == 2 && pps.InitQp == 22 && pps.CuQpDeltaEnabledFlag && pps.DiffCuQpDeltaDepth == 1 && pps.CbQpOffset == -2)
+	utils.Assert(pps.NumTileColumns == 1 && pps.LoopFilterAcrossSlicesEnabledFlag && pps.BetaOffsetDiv2 == 3 && pps.TcOffsetDiv2 == -1 && pps.Log2ParallelMergeLevel == 2)
+
+	params := NewParameterSets()
+	for _, nalu := range [][]byte{newTestVPS(), newTestSPS(), newTestPPS()} {
+		if err = params.Update(nalu); err != nil {
+			panic(err)
+		}
+	}
+
+	header, _, err := params.ParseSliceHeader(newTestSlice(HevcNalTrailN, 0, HevcSliceB, 3, -1))
+	if err != nil {
+		panic(err)
+	}
+
+	rps = header.ShortTermRefPicSet
+	utils.Assert(!header.ShortTermRefPicSetSPSFlag && header.SliceType == HevcSliceB && header.PicOrderCntLsb == 3 && header.TemporalMVPEnabledFlag)
+	utils.Assert(rps.NumNegativePics == 3 && rps.NumPositivePics == 0 && rps.DeltaPocS0[0] == -1 && rps.DeltaPocS0[2] == -3)
+
+	_, _, err = NewParameterSets().ParseSliceHeader(newTestSlice(HevcNalTrailR, 0, HevcSliceP, 4, 0))
+	utils.Assert(err != nil)
+}
+
+func TestPOC(t *testing.T) {
+	params := NewParameterSets()
+	for _, nalu := range [][]byte{newTestVPS(), newTestSPS(), newTestPPS()} {
+		if err := params.Update(nalu); err != nil {
+			panic(err)
+		}
+	}
+
+	// 解码顺序I0 P4 B2 b1 b3, 非参考帧和TemporalId大于0的帧不作为prevTid0Pic, 之后POC的lsb回绕
+	frames := []struct {
+		nalType    HEVCNALUnitType
+		temporalId int
+		sliceType  HEVCSliceType
+		poc        int
+	}{
+		{HevcNalIdrWRADL, 0, HevcSliceI, 0},
+		{HevcNalTrailR, 0, HevcSliceP, 4},
+		{HevcNalTrailR, 1, HevcSliceB, 2},
+		{HevcNalTrailN, 0, HevcSliceB, 1},
+		{HevcNalTrailN, 2, HevcSliceB, 3},
+		{HevcNalTrailR, 0, HevcSliceP, 100},
+		{HevcNalTrailR, 0, HevcSliceP, 200},
+		{HevcNalTrailR, 0, HevcSliceP, 300},
+		{HevcNalTrailR, 0, HevcSliceP, 400},
+		{HevcNalCraNUT, 0, HevcSliceI, 500},
+		{HevcNalRASLN, 0, HevcSliceB, 498},
+		{HevcNalIdrNLP, 0, HevcSliceI, 0},
+	}
+
+	var poc POC
+	for _, frame := range frames {
+		header, sps, err := params.ParseSliceHeader(newTestSlice(frame.nalType, frame.temporalId, frame.sliceType, frame.poc%256, 1))
+		if err != nil {
+			panic(err)
+		}
+
+		utils.Assert(header.SliceType == frame.sliceType && header.TemporalId == frame.temporalId)
+		utils.Assert(poc.Compute(header, sps) == frame.poc)
+		utils.Assert(poc.NoRaslOutputFlag == header.IsIDR())
+	}
+
+	// 第一个CRA图像重新开始POC计数
+	poc = POC{}
+	header, sps, err := params.ParseSliceHeader(newTestSlice(HevcNalCraNUT, 0, HevcSliceI, 10, 1))
+	if err != nil {
+		panic(err)
+	}
+
+	utils.Assert(poc.Compute(header, sps) == 10 && poc.NoRaslOutputFlag)
+}
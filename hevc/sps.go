@@ -11,22 +11,90 @@ import (
 type HEVCSPSInfo struct {
 	//avc.SPS
 
-	numTemporalLayers                uint
-	temporalIdNested                 uint
-	chromaFormat                     uint
+	NumTemporalLayers                uint
+	TemporalIdNested                 uint
+	ChromaFormat                     uint
 	PicWidthInLumaSamples            uint
 	PicHeightInLumaSamples           uint
-	bitDepthLumaMinus8               uint
-	bitDepthChromaMinus8             uint
-	generalProfileSpace              uint
-	generalTierFlag                  uint
-	generalProfileIDC                uint
-	generalProfileCompatibilityFlags uint32
-	generalConstraintIndicatorFlags  uint64
-	generalLevelIDC                  uint
-	fps                              uint
-	Width                            int
+	BitDepthLumaMinus8               uint
+	BitDepthChromaMinus8             uint
+	GeneralProfileSpace              uint
+	GeneralTierFlag                  uint
+	GeneralProfileIDC                uint
+	GeneralProfileCompatibilityFlags uint32
+	GeneralConstraintIndicatorFlags  uint64
+	GeneralLevelIDC                  uint
+	FPS                              uint
+	Width                            int // 按conformance window裁剪后的宽高
 	Height                           int
+
+	ConformanceWindowFlag bool
+	ConfWinLeftOffset     uint
+	ConfWinRightOffset    uint
+	ConfWinTopOffset      uint
+	ConfWinBottomOffset   uint
+
+	VPSId                      int
+	Id                         int
+	SeparateColourPlaneFlag    bool
+	Log2MaxPicOrderCntLsb      int
+	MaxNumReorderPics          int // 最高子层的sps_max_num_reorder_pics
+	MaxDecPicBufferingMinus1   int
+	Log2MinLumaCodingBlockSize int
+	Log2CtbSize                int
+	PicSizeInCtbsY             int
+	ShortTermRefPicSets        []*ShortTermRefPicSet
+	LongTermRefPicsPresentFlag bool
+	NumLongTermRefPicsSPS      int
+	TemporalMVPEnabledFlag     bool
+	StrongIntraSmoothingFlag   bool
+
+	VUI            *VUI               // 没有vui_parameters或者解析失败时为nil
+	RangeExtension *SPSRangeExtension // 没有sps_range_extension时为nil
+}
+
+// SPSRangeExtension sps_range_extension, 用于4:2:2/4:4:4和高位深的RExt profile
+type SPSRangeExtension struct {
+	TransformSkipRotationEnabledFlag    bool
+	TransformSkipContextEnabledFlag     bool
+	ImplicitRdpcmEnabledFlag            bool
+	ExplicitRdpcmEnabledFlag            bool
+	ExtendedPrecisionProcessingFlag     bool
+	IntraSmoothingDisabledFlag          bool
+	HighPrecisionOffsetsEnabledFlag     bool
+	PersistentRiceAdaptationEnabledFlag bool
+	CabacBypassAlignmentEnabledFlag     bool
+}
+
+func (s *HEVCSPSInfo) BitDepthLuma() int {
+	return int(s.BitDepthLumaMinus8) + 8
+}
+
+func (s *HEVCSPSInfo) BitDepthChroma() int {
+	return int(s.BitDepthChromaMinus8) + 8
+}
+
+// FrameRate 根据VUI的timing_info计算帧率, 没有timing_info返回0
+func (s *HEVCSPSInfo) FrameRate() float64 {
+	if s.VUI == nil {
+		return 0
+	}
+
+	return s.VUI.FrameRate()
+}
+
+// ShortTermRefPicSet st_ref_pic_set, 帧间预测的RPS已经按7.4.8推导为显式的形式
+type ShortTermRefPicSet struct {
+	NumNegativePics int
+	NumPositivePics int
+	DeltaPocS0      []int
+	DeltaPocS1      []int
+	UsedByCurrPicS0 []bool
+	UsedByCurrPicS1 []bool
+}
+
+func (s *ShortTermRefPicSet) NumDeltaPocs() int {
+	return s.NumNegativePics + s.NumPositivePics
 }
 
 func ParseSPS(sps []byte) (ctx HEVCSPSInfo, err error) {
@@ -37,37 +105,49 @@ func ParseSPS(sps []byte) (ctx HEVCSPSInfo, err error) {
 		return
 	}
 
-	rbsp := nal2rbsp(sps[2:])
+	rbsp := avc.EBSP2RBSP(sps[2:])
 	br := &bufio.GolombBitReader{R: bytes.NewReader(rbsp)}
-	if _, err = br.ReadBits(4); err != nil {
+	var vpsId uint
+	if vpsId, err = br.ReadBits(4); err != nil {
 		return
 	}
+	ctx.VPSId = int(vpsId)
 	spsMaxSubLayersMinus1, err := br.ReadBits(3)
 	if err != nil {
 		return
 	}
 
-	if spsMaxSubLayersMinus1+1 > ctx.numTemporalLayers {
-		ctx.numTemporalLayers = spsMaxSubLayersMinus1 + 1
+	if spsMaxSubLayersMinus1+1 > ctx.NumTemporalLayers {
+		ctx.NumTemporalLayers = spsMaxSubLayersMinus1 + 1
 	}
-	if ctx.temporalIdNested, err = br.ReadBit(); err != nil {
+	if ctx.TemporalIdNested, err = br.ReadBit(); err != nil {
 		return
 	}
+	// updatePTL按位与合并兼容性标记
+	ctx.GeneralProfileCompatibilityFlags = 0xFFFFFFFF
+	ctx.GeneralConstraintIndicatorFlags = 0xFFFFFFFFFFFF
 	if err = parsePTL(br, &ctx, spsMaxSubLayersMinus1); err != nil {
 		return
 	}
-	if _, err = br.ReadExponentialGolombCode(); err != nil {
+	var spsId uint
+	if spsId, err = br.ReadExponentialGolombCode(); err != nil {
+		return
+	} else if spsId > 15 {
+		err = fmt.Errorf("invalid sps id %d", spsId)
 		return
 	}
+	ctx.Id = int(spsId)
 	var cf uint
 	if cf, err = br.ReadExponentialGolombCode(); err != nil {
 		return
 	}
-	ctx.chromaFormat = uint(cf)
-	if ctx.chromaFormat == 3 {
-		if _, err = br.ReadBit(); err != nil {
+	ctx.ChromaFormat = uint(cf)
+	if ctx.ChromaFormat == 3 {
+		var separateColourPlane uint
+		if separateColourPlane, err = br.ReadBit(); err != nil {
 			return
 		}
+		ctx.SeparateColourPlaneFlag = separateColourPlane == 1
 	}
 	if ctx.PicWidthInLumaSamples, err = br.ReadExponentialGolombCode(); err != nil {
 		return
@@ -82,35 +162,62 @@ func ParseSPS(sps []byte) (ctx HEVCSPSInfo, err error) {
 		return
 	}
 	if conformanceWindowFlag != 0 {
-		if _, err = br.ReadExponentialGolombCode(); err != nil {
+		ctx.ConformanceWindowFlag = true
+		if ctx.ConfWinLeftOffset, err = br.ReadExponentialGolombCode(); err != nil {
 			return
 		}
-		if _, err = br.ReadExponentialGolombCode(); err != nil {
+		if ctx.ConfWinRightOffset, err = br.ReadExponentialGolombCode(); err != nil {
 			return
 		}
-		if _, err = br.ReadExponentialGolombCode(); err != nil {
+		if ctx.ConfWinTopOffset, err = br.ReadExponentialGolombCode(); err != nil {
 			return
 		}
-		if _, err = br.ReadExponentialGolombCode(); err != nil {
+		if ctx.ConfWinBottomOffset, err = br.ReadExponentialGolombCode(); err != nil {
+			return
+		}
+
+		// 偏移量以色度采样为单位
+		subWidthC, subHeightC := uint(1), uint(1)
+		if ctx.ChromaFormat == 1 {
+			subWidthC, subHeightC = 2, 2
+		} else if ctx.ChromaFormat == 2 {
+			subWidthC = 2
+		}
+
+		if ctx.SeparateColourPlaneFlag {
+			subWidthC, subHeightC = 1, 1
+		}
+
+		cropWidth := subWidthC * (ctx.ConfWinLeftOffset + ctx.ConfWinRightOffset)
+		cropHeight := subHeightC * (ctx.ConfWinTopOffset + ctx.ConfWinBottomOffset)
+		if cropWidth >= ctx.PicWidthInLumaSamples || cropHeight >= ctx.PicHeightInLumaSamples {
+			err = fmt.Errorf("invalid conformance window")
 			return
 		}
+
+		ctx.Width = int(ctx.PicWidthInLumaSamples - cropWidth)
+		ctx.Height = int(ctx.PicHeightInLumaSamples - cropHeight)
 	}
 
 	var bdlm8 uint
 	if bdlm8, err = br.ReadExponentialGolombCode(); err != nil {
 		return
 	}
-	ctx.bitDepthChromaMinus8 = uint(bdlm8)
+	ctx.BitDepthLumaMinus8 = uint(bdlm8)
 	var bdcm8 uint
 	if bdcm8, err = br.ReadExponentialGolombCode(); err != nil {
 		return
 	}
-	ctx.bitDepthChromaMinus8 = uint(bdcm8)
+	ctx.BitDepthChromaMinus8 = uint(bdcm8)
 
-	_, err = br.ReadExponentialGolombCode()
-	if err != nil {
+	var log2MaxPocLsbMinus4 uint
+	if log2MaxPocLsbMinus4, err = br.ReadExponentialGolombCode(); err != nil {
+		return
+	} else if log2MaxPocLsbMinus4 > 12 {
+		err = fmt.Errorf("invalid log2_max_pic_order_cnt_lsb_minus4 %d", log2MaxPocLsbMinus4)
 		return
 	}
+	ctx.Log2MaxPicOrderCntLsb = int(log2MaxPocLsbMinus4) + 4
 	spsSubLayerOrderingInfoPresentFlag, err := br.ReadBit()
 	if err != nil {
 		return
@@ -122,57 +229,278 @@ func ParseSPS(sps []byte) (ctx HEVCSPSInfo, err error) {
 		i = spsMaxSubLayersMinus1
 	}
 	for ; i <= spsMaxSubLayersMinus1; i++ {
-		if _, err = br.ReadExponentialGolombCode(); err != nil {
+		var maxDecPicBufferingMinus1, maxNumReorderPics uint
+		if maxDecPicBufferingMinus1, err = br.ReadExponentialGolombCode(); err != nil {
 			return
 		}
-		if _, err = br.ReadExponentialGolombCode(); err != nil {
+		if maxNumReorderPics, err = br.ReadExponentialGolombCode(); err != nil {
 			return
 		}
 		if _, err = br.ReadExponentialGolombCode(); err != nil {
 			return
 		}
+
+		// 只保留最高子层的值
+		ctx.MaxDecPicBufferingMinus1 = int(maxDecPicBufferingMinus1)
+		ctx.MaxNumReorderPics = int(maxNumReorderPics)
 	}
 
-	if _, err = br.ReadExponentialGolombCode(); err != nil {
+	ctx.Log2MinLumaCodingBlockSize = br.UE() + 3
+	ctx.Log2CtbSize = ctx.Log2MinLumaCodingBlockSize + br.UE()
+	// log2_min_luma_transform_block_size_minus2, log2_diff_max_min_luma_transform_block_size
+	// max_transform_hierarchy_depth_inter, max_transform_hierarchy_depth_intra
+	br.UE()
+	br.UE()
+	br.UE()
+	br.UE()
+	if br.Err() != nil {
+		err = br.Err()
 		return
-	}
-	if _, err = br.ReadExponentialGolombCode(); err != nil {
+	} else if ctx.Log2CtbSize > 6 {
+		err = fmt.Errorf("invalid ctb size %d", 1<<ctx.Log2CtbSize)
 		return
 	}
-	if _, err = br.ReadExponentialGolombCode(); err != nil {
-		return
+
+	ctbSize := 1 << ctx.Log2CtbSize
+	width, height := int(ctx.PicWidthInLumaSamples), int(ctx.PicHeightInLumaSamples)
+	ctx.PicSizeInCtbsY = ((width + ctbSize - 1) / ctbSize) * ((height + ctbSize - 1) / ctbSize)
+
+	// scaling_list_enabled_flag, sps_scaling_list_data_present_flag
+	if br.Flag() && br.Flag() {
+		skipScalingListData(br)
 	}
-	if _, err = br.ReadExponentialGolombCode(); err != nil {
-		return
+
+	// amp_enabled_flag, sample_adaptive_offset_enabled_flag
+	br.Bits(2)
+	// pcm_enabled_flag
+	if br.Flag() {
+		// pcm_sample_bit_depth_luma_minus1, pcm_sample_bit_depth_chroma_minus1
+		br.Bits(8)
+		br.UE()
+		br.UE()
+		// pcm_loop_filter_disabled_flag
+		br.Flag()
 	}
-	if _, err = br.ReadExponentialGolombCode(); err != nil {
+
+	numShortTermRefPicSets := br.UE()
+	if numShortTermRefPicSets > 64 {
+		err = fmt.Errorf("invalid num_short_term_ref_pic_sets %d", numShortTermRefPicSets)
 		return
 	}
-	if _, err = br.ReadExponentialGolombCode(); err != nil {
+
+	for j := 0; j < numShortTermRefPicSets && br.Err() == nil; j++ {
+		var rps *ShortTermRefPicSet
+		if rps, err = parseShortTermRefPicSet(br, ctx.ShortTermRefPicSets, j, numShortTermRefPicSets); err != nil {
+			return
+		}
+
+		ctx.ShortTermRefPicSets = append(ctx.ShortTermRefPicSets, rps)
+	}
+
+	ctx.LongTermRefPicsPresentFlag = br.Flag()
+	if ctx.LongTermRefPicsPresentFlag {
+		if ctx.NumLongTermRefPicsSPS = br.UE(); ctx.NumLongTermRefPicsSPS > 32 {
+			err = fmt.Errorf("invalid num_long_term_ref_pics_sps %d", ctx.NumLongTermRefPicsSPS)
+			return
+		}
+
+		// lt_ref_pic_poc_lsb_sps, used_by_curr_pic_lt_sps_flag
+		for j := 0; j < ctx.NumLongTermRefPicsSPS; j++ {
+			br.Bits(ctx.Log2MaxPicOrderCntLsb)
+			br.Flag()
+		}
+	}
+
+	ctx.TemporalMVPEnabledFlag = br.Flag()
+	ctx.StrongIntraSmoothingFlag = br.Flag()
+	if br.Err() != nil {
+		err = br.Err()
 		return
 	}
+
+	// vui_parameters_present_flag
+	if br.Flag() {
+		// 之前的版本不解析VUI, VUI不完整时忽略, 不影响SPS的其他字段
+		if ctx.VUI, err = parseVUI(br, int(spsMaxSubLayersMinus1)); err != nil {
+			ctx.VUI, err = nil, nil
+			return
+		}
+
+		if ctx.VUI.TimingInfoPresentFlag && ctx.VUI.NumUnitsInTick > 0 {
+			ctx.FPS = uint(ctx.VUI.TimeScale / ctx.VUI.NumUnitsInTick)
+		}
+	}
+
+	// sps_extension_present_flag, sps_range_extension_flag
+	if br.Flag() && br.Flag() {
+		// sps_multilayer_extension_flag, sps_3d_extension_flag, sps_scc_extension_flag, sps_extension_4bits
+		br.Bits(7)
+		ext := &SPSRangeExtension{}
+		ext.TransformSkipRotationEnabledFlag = br.Flag()
+		ext.TransformSkipContextEnabledFlag = br.Flag()
+		ext.ImplicitRdpcmEnabledFlag = br.Flag()
+		ext.ExplicitRdpcmEnabledFlag = br.Flag()
+		ext.ExtendedPrecisionProcessingFlag = br.Flag()
+		ext.IntraSmoothingDisabledFlag = br.Flag()
+		ext.HighPrecisionOffsetsEnabledFlag = br.Flag()
+		ext.PersistentRiceAdaptationEnabledFlag = br.Flag()
+		ext.CabacBypassAlignmentEnabledFlag = br.Flag()
+		if br.Err() == nil {
+			ctx.RangeExtension = ext
+		}
+	}
+
 	return
 }
 
+// skipScalingListData 跳过scaling_list_data
+func skipScalingListData(r *bufio.GolombBitReader) {
+	for sizeId := 0; sizeId < 4; sizeId++ {
+		step := 1
+		if sizeId == 3 {
+			step = 3
+		}
+
+		for matrixId := 0; matrixId < 6; matrixId += step {
+			// scaling_list_pred_mode_flag
+			if !r.Flag() {
+				// scaling_list_pred_matrix_id_delta
+				r.UE()
+				continue
+			}
+
+			coefNum := bufio.MinInt(64, 1<<(4+(sizeId<<1)))
+			if sizeId > 1 {
+				// scaling_list_dc_coef_minus8
+				r.SE()
+			}
+
+			for i := 0; i < coefNum; i++ {
+				r.SE()
+			}
+		}
+	}
+}
+
+// parseShortTermRefPicSet 解析st_ref_pic_set(idx), sets为已经解析的RPS, idx等于num_short_term_ref_pic_sets时为slice header中的RPS
+func parseShortTermRefPicSet(r *bufio.GolombBitReader, sets []*ShortTermRefPicSet, idx, numSets int) (*ShortTermRefPicSet, error) {
+	rps := &ShortTermRefPicSet{}
+	// inter_ref_pic_set_prediction_flag
+	if idx != 0 && r.Flag() {
+		deltaIdx := 1
+		if idx == numSets {
+			deltaIdx += r.UE()
+		}
+
+		if deltaIdx > idx {
+			return nil, fmt.Errorf("invalid delta_idx_minus1 %d", deltaIdx-1)
+		}
+
+		ref := sets[idx-deltaIdx]
+		sign := r.Flag()
+		deltaRps := r.UE() + 1
+		if sign {
+			deltaRps = -deltaRps
+		}
+
+		numDeltaPocs := ref.NumDeltaPocs()
+		usedByCurrPic := make([]bool, numDeltaPocs+1)
+		useDelta := make([]bool, numDeltaPocs+1)
+		for j := 0; j <= numDeltaPocs; j++ {
+			usedByCurrPic[j] = r.Flag()
+			useDelta[j] = true
+			if !usedByCurrPic[j] {
+				useDelta[j] = r.Flag()
+			}
+		}
+
+		// 7-61
+		for j := ref.NumPositivePics - 1; j >= 0; j-- {
+			if dPoc := ref.DeltaPocS1[j] + deltaRps; dPoc < 0 && useDelta[ref.NumNegativePics+j] {
+				rps.DeltaPocS0 = append(rps.DeltaPocS0, dPoc)
+				rps.UsedByCurrPicS0 = append(rps.UsedByCurrPicS0, usedByCurrPic[ref.NumNegativePics+j])
+			}
+		}
+
+		if deltaRps < 0 && useDelta[numDeltaPocs] {
+			rps.DeltaPocS0 = append(rps.DeltaPocS0, deltaRps)
+			rps.UsedByCurrPicS0 = append(rps.UsedByCurrPicS0, usedByCurrPic[numDeltaPocs])
+		}
+
+		for j := 0; j < ref.NumNegativePics; j++ {
+			if dPoc := ref.DeltaPocS0[j] + deltaRps; dPoc < 0 && useDelta[j] {
+				rps.DeltaPocS0 = append(rps.DeltaPocS0, dPoc)
+				rps.UsedByCurrPicS0 = append(rps.UsedByCurrPicS0, usedByCurrPic[j])
+			}
+		}
+
+		// 7-62
+		for j := ref.NumNegativePics - 1; j >= 0; j-- {
+			if dPoc := ref.DeltaPocS0[j] + deltaRps; dPoc > 0 && useDelta[j] {
+				rps.DeltaPocS1 = append(rps.DeltaPocS1, dPoc)
+				rps.UsedByCurrPicS1 = append(rps.UsedByCurrPicS1, usedByCurrPic[j])
+			}
+		}
+
+		if deltaRps > 0 && useDelta[numDeltaPocs] {
+			rps.DeltaPocS1 = append(rps.DeltaPocS1, deltaRps)
+			rps.UsedByCurrPicS1 = append(rps.UsedByCurrPicS1, usedByCurrPic[numDeltaPocs])
+		}
+
+		for j := 0; j < ref.NumPositivePics; j++ {
+			if dPoc := ref.DeltaPocS1[j] + deltaRps; dPoc > 0 && useDelta[ref.NumNegativePics+j] {
+				rps.DeltaPocS1 = append(rps.DeltaPocS1, dPoc)
+				rps.UsedByCurrPicS1 = append(rps.UsedByCurrPicS1, usedByCurrPic[ref.NumNegativePics+j])
+			}
+		}
+
+		rps.NumNegativePics = len(rps.DeltaPocS0)
+		rps.NumPositivePics = len(rps.DeltaPocS1)
+		return rps, nil
+	}
+
+	rps.NumNegativePics = r.UE()
+	rps.NumPositivePics = r.UE()
+	if rps.NumNegativePics > 16 || rps.NumPositivePics > 16 {
+		return nil, fmt.Errorf("invalid short term ref pic set")
+	}
+
+	var poc int
+	for i := 0; i < rps.NumNegativePics; i++ {
+		poc -= r.UE() + 1
+		rps.DeltaPocS0 = append(rps.DeltaPocS0, poc)
+		rps.UsedByCurrPicS0 = append(rps.UsedByCurrPicS0, r.Flag())
+	}
+
+	poc = 0
+	for i := 0; i < rps.NumPositivePics; i++ {
+		poc += r.UE() + 1
+		rps.DeltaPocS1 = append(rps.DeltaPocS1, poc)
+		rps.UsedByCurrPicS1 = append(rps.UsedByCurrPicS1, r.Flag())
+	}
+
+	return rps, nil
+}
+
 func parsePTL(br *bufio.GolombBitReader, ctx *HEVCSPSInfo, maxSubLayersMinus1 uint) error {
 	var err error
 	var ptl HEVCSPSInfo
-	if ptl.generalProfileSpace, err = br.ReadBits(2); err != nil {
+	if ptl.GeneralProfileSpace, err = br.ReadBits(2); err != nil {
 		return err
 	}
-	if ptl.generalTierFlag, err = br.ReadBit(); err != nil {
+	if ptl.GeneralTierFlag, err = br.ReadBit(); err != nil {
 		return err
 	}
-	if ptl.generalProfileIDC, err = br.ReadBits(5); err != nil {
+	if ptl.GeneralProfileIDC, err = br.ReadBits(5); err != nil {
 		return err
 	}
-	if ptl.generalProfileCompatibilityFlags, err = br.ReadBits32(32); err != nil {
+	if ptl.GeneralProfileCompatibilityFlags, err = br.ReadBits32(32); err != nil {
 		return err
 	}
-	if ptl.generalConstraintIndicatorFlags, err = br.ReadBits64(48); err != nil {
+	if ptl.GeneralConstraintIndicatorFlags, err = br.ReadBits64(48); err != nil {
 		return err
 	}
-	if ptl.generalLevelIDC, err = br.ReadBits(8); err != nil {
+	if ptl.GeneralLevelIDC, err = br.ReadBits(8); err != nil {
 		return err
 	}
 	updatePTL(ctx, &ptl)
@@ -219,29 +547,25 @@ func parsePTL(br *bufio.GolombBitReader, ctx *HEVCSPSInfo, maxSubLayersMinus1 ui
 }
 
 func updatePTL(ctx, ptl *HEVCSPSInfo) {
-	ctx.generalProfileSpace = ptl.generalProfileSpace
+	ctx.GeneralProfileSpace = ptl.GeneralProfileSpace
 
-	if ptl.generalTierFlag > ctx.generalTierFlag {
-		ctx.generalLevelIDC = ptl.generalLevelIDC
+	if ptl.GeneralTierFlag > ctx.GeneralTierFlag {
+		ctx.GeneralLevelIDC = ptl.GeneralLevelIDC
 
-		ctx.generalTierFlag = ptl.generalTierFlag
+		ctx.GeneralTierFlag = ptl.GeneralTierFlag
 	} else {
-		if ptl.generalLevelIDC > ctx.generalLevelIDC {
-			ctx.generalLevelIDC = ptl.generalLevelIDC
+		if ptl.GeneralLevelIDC > ctx.GeneralLevelIDC {
+			ctx.GeneralLevelIDC = ptl.GeneralLevelIDC
 		}
 	}
 
-	if ptl.generalProfileIDC > ctx.generalProfileIDC {
-		ctx.generalProfileIDC = ptl.generalProfileIDC
+	if ptl.GeneralProfileIDC > ctx.GeneralProfileIDC {
+		ctx.GeneralProfileIDC = ptl.GeneralProfileIDC
 	}
 
-	ctx.generalProfileCompatibilityFlags &= ptl.generalProfileCompatibilityFlags
-
-	ctx.generalConstraintIndicatorFlags &= ptl.generalConstraintIndicatorFlags
-}
+	ctx.GeneralProfileCompatibilityFlags &= ptl.GeneralProfileCompatibilityFlags
 
-func nal2rbsp(nal []byte) []byte {
-	return bytes.Replace(nal, []byte{0x0, 0x0, 0x3}, []byte{0x0, 0x0}, -1)
+	ctx.GeneralConstraintIndicatorFlags &= ptl.GeneralConstraintIndicatorFlags
 }
 
 func NewCodecDataFromHEVCDecoderConfigurationRecord(record []byte) (*HEVCDecoderConfigurationRecord, *HEVCSPSInfo, error) {
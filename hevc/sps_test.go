@@ -0,0 +1,106 @@
+package hevc
+
+import (
+	"github.com/lkmio/avformat/bufio"
+	"github.com/lkmio/avformat/utils"
+	"testing"
+)
+
+// newTestRExtSPS 4:2:2 10bit 1920x1088, conformance window裁剪为1080, bt2020 pq, 59.94fps, 包含hrd和sps_range_extension
+func newTestRExtSPS() []byte {
+	writer := &bufio.BitsWriter{Data: make([]byte, 64)}
+	writer.Write(4, 0) // sps_video_parameter_set_id
+	writer.Write(3, 0) // sps_max_sub_layers_minus1
+	writer.Write(1, 1) // sps_temporal_id_nesting_flag
+	writer.Write(8, 4) // general_profile_idc=4(RExt)
+	writer.Write(32, 0x08000000)
+	writer.Write(48, 0)
+	writer.Write(8, 123)
+	writer.WriteUE(1)    // sps_seq_parameter_set_id
+	writer.WriteUE(2)    // chroma_format_idc
+	writer.WriteUE(1920) // pic_width_in_luma_samples
+	writer.WriteUE(1088) // pic_height_in_luma_samples
+	writer.Write(1, 1)   // conformance_window_flag
+	writer.WriteUE(0)
+	writer.WriteUE(0)
+	writer.WriteUE(0)
+	writer.WriteUE(8)
+	writer.WriteUE(2)  // bit_depth_luma_minus8
+	writer.WriteUE(2)  // bit_depth_chroma_minus8
+	writer.WriteUE(4)  // log2_max_pic_order_cnt_lsb_minus4
+	writer.Write(1, 0) // sps_sub_layer_ordering_info_present_flag
+	writer.WriteUE(5)
+	writer.WriteUE(3)
+	writer.WriteUE(0)
+	writer.WriteUE(0)
+	writer.WriteUE(3)
+	writer.WriteUE(0)
+	writer.WriteUE(3)
+	writer.WriteUE(0)
+	writer.WriteUE(0)
+	writer.Write(4, 0x2) // scaling_list_enabled_flag, amp_enabled_flag, sample_adaptive_offset_enabled_flag, pcm_enabled_flag
+	writer.WriteUE(0)    // num_short_term_ref_pic_sets
+	writer.Write(3, 0x3) // long_term_ref_pics_present_flag, sps_temporal_mvp_enabled_flag, strong_intra_smoothing_enabled_flag
+
+	writer.Write(1, 1) // vui_parameters_present_flag
+	writer.Write(1, 1) // aspect_ratio_info_present_flag
+	writer.Write(8, 1)
+	writer.Write(1, 0) // overscan_info_present_flag
+	writer.Write(1, 1) // video_signal_type_present_flag
+	writer.Write(3, 5)
+	writer.Write(1, 0) // video_full_range_flag
+	writer.Write(1, 1) // colour_description_present_flag
+	writer.Write(8, 9)
+	writer.Write(8, 16)
+	writer.Write(8, 9)
+	writer.Write(1, 0)   // chroma_loc_info_present_flag
+	writer.Write(3, 0x1) // neutral_chroma_indication_flag, field_seq_flag, frame_field_info_present_flag
+	writer.Write(1, 0)   // default_display_window_flag
+	writer.Write(1, 1)   // vui_timing_info_present_flag
+	writer.Write(32, 1001)
+	writer.Write(32, 60000)
+	writer.Write(1, 0) // vui_poc_proportional_to_timing_flag
+	writer.Write(1, 1) // vui_hrd_parameters_present_flag
+	writer.Write(2, 0x2)
+	writer.Write(1, 0) // sub_pic_hrd_params_present_flag
+	writer.Write(8, 0) // bit_rate_scale, cpb_size_scale
+	writer.Write(5, 23)
+	writer.Write(5, 15) // au_cpb_removal_delay_length_minus1
+	writer.Write(5, 4)  // dpb_output_delay_length_minus1
+	writer.Write(1, 1)  // fixed_pic_rate_general_flag
+	writer.WriteUE(0)   // elemental_duration_in_tc_minus1
+	writer.WriteUE(0)   // cpb_cnt_minus1
+	writer.WriteUE(100)
+	writer.WriteUE(200)
+	writer.Write(1, 0)
+	writer.Write(1, 0) // bitstream_restriction_flag
+
+	writer.Write(1, 1) // sps_extension_present_flag
+	writer.Write(1, 1) // sps_range_extension_flag
+	writer.Write(7, 0)
+	writer.Write(9, 0x11) // extended_precision_processing_flag, cabac_bypass_alignment_enabled_flag
+	return finishNalU(HevcNalSPS, 0, writer)
+}
+
+func TestParseSPS(t *testing.T) {
+	sps, err := ParseSPS(newTestRExtSPS())
+	if err != nil {
+		panic(err)
+	}
+
+	utils.Assert(sps.Id == 1 && sps.GeneralProfileIDC == 4 && sps.GeneralLevelIDC == 123 && sps.GeneralProfileCompatibilityFlags == 0x08000000)
+	utils.Assert(sps.ChromaFormat == 2 && sps.BitDepthLuma() == 10 && sps.BitDepthChroma() == 10)
+	utils.Assert(sps.PicHeightInLumaSamples == 1088 && sps.Width == 1920 && sps.Height == 1080 && sps.ConfWinBottomOffset == 8)
+	utils.Assert(sps.MaxNumReorderPics == 3 && sps.PicSizeInCtbsY == 30*17 && sps.LongTermRefPicsPresentFlag == false)
+
+	vui := sps.VUI
+	utils.Assert(vui != nil && vui.AspectRatioIdc == 1 && vui.ColourPrimaries == 9 && vui.TransferCharacteristics == 16 && vui.MatrixCoeffs == 9)
+	utils.Assert(int(sps.FrameRate()*100) == 5994 && sps.FPS == 59 && vui.FrameFieldInfoPresentFlag)
+	utils.Assert(vui.HRD != nil && vui.HRD.NalHrdParametersPresentFlag && !vui.HRD.VclHrdParametersPresentFlag)
+
+	params := vui.PicTimingParams()
+	utils.Assert(params.CpbDpbDelaysPresentFlag && params.AuCpbRemovalDelayLength == 16 && params.DpbOutputDelayLength == 5)
+
+	ext := sps.RangeExtension
+	utils.Assert(ext != nil && ext.ExtendedPrecisionProcessingFlag && ext.CabacBypassAlignmentEnabledFlag && !ext.ImplicitRdpcmEnabledFlag)
+}
@@ -0,0 +1,238 @@
+package hevc
+
+import (
+	"fmt"
+	"github.com/lkmio/avformat/bufio"
+)
+
+// VUI vui_parameters, E.2.1. 未出现的字段为规范推导的默认值.
+type VUI struct {
+	AspectRatioIdc int
+	SarWidth       int
+	SarHeight      int
+
+	OverscanInfoPresentFlag bool
+	OverscanAppropriateFlag bool
+
+	VideoSignalTypePresentFlag   bool
+	VideoFormat                  int
+	VideoFullRangeFlag           bool
+	ColourDescriptionPresentFlag bool
+	ColourPrimaries              int
+	TransferCharacteristics      int
+	MatrixCoeffs                 int
+
+	ChromaLocInfoPresentFlag       bool
+	ChromaSampleLocTypeTopField    int
+	ChromaSampleLocTypeBottomField int
+	NeutralChromaIndicationFlag    bool
+	FieldSeqFlag                   bool
+	FrameFieldInfoPresentFlag      bool
+
+	DefaultDisplayWindowFlag bool
+	DefDispWinLeftOffset     int
+	DefDispWinRightOffset    int
+	DefDispWinTopOffset      int
+	DefDispWinBottomOffset   int
+
+	TimingInfoPresentFlag       bool
+	NumUnitsInTick              uint32
+	TimeScale                   uint32
+	PocProportionalToTimingFlag bool
+	NumTicksPocDiffOneMinus1    int
+	HRD                         *HRD
+
+	BitstreamRestrictionFlag       bool
+	TilesFixedStructureFlag        bool
+	MotionVectorsOverPicBoundaries bool
+	RestrictedRefPicListsFlag      bool
+	MinSpatialSegmentationIdc      int
+	MaxBytesPerPicDenom            int
+	MaxBitsPerMinCuDenom           int
+	Log2MaxMvLengthHorizontal      int
+	Log2MaxMvLengthVertical        int
+}
+
+// HRD hrd_parameters, E.2.2. 只保留公共部分.
+type HRD struct {
+	NalHrdParametersPresentFlag  bool
+	VclHrdParametersPresentFlag  bool
+	SubPicHrdParamsPresentFlag   bool
+	BitRateScale                 int
+	CpbSizeScale                 int
+	InitialCpbRemovalDelayLength int // initial_cpb_removal_delay_length_minus1 + 1
+	AuCpbRemovalDelayLength      int // au_cpb_removal_delay_length_minus1 + 1
+	DpbOutputDelayLength         int // dpb_output_delay_length_minus1 + 1
+}
+
+// FrameRate 根据timing_info计算帧率, 没有timing_info返回0
+func (v *VUI) FrameRate() float64 {
+	if !v.TimingInfoPresentFlag || v.NumUnitsInTick == 0 {
+		return 0
+	}
+
+	return float64(v.TimeScale) / float64(v.NumUnitsInTick)
+}
+
+// PicTimingParams 返回解析pic_timing SEI需要的参数
+func (v *VUI) PicTimingParams() *PicTimingParams {
+	params := &PicTimingParams{FrameFieldInfoPresentFlag: v.FrameFieldInfoPresentFlag}
+	if hrd := v.HRD; hrd != nil && (hrd.NalHrdParametersPresentFlag || hrd.VclHrdParametersPresentFlag) {
+		params.CpbDpbDelaysPresentFlag = true
+		params.AuCpbRemovalDelayLength = hrd.AuCpbRemovalDelayLength
+		params.DpbOutputDelayLength = hrd.DpbOutputDelayLength
+	}
+
+	return params
+}
+
+func skipSubLayerHRD(r *bufio.GolombBitReader, cpbCnt int, subPicHrdParamsPresent bool) {
+	for i := 0; i < cpbCnt; i++ {
+		// bit_rate_value_minus1, cpb_size_value_minus1
+		r.UE()
+		r.UE()
+		if subPicHrdParamsPresent {
+			// cpb_size_du_value_minus1, bit_rate_du_value_minus1
+			r.UE()
+			r.UE()
+		}
+
+		// cbr_flag
+		r.Flag()
+	}
+}
+
+func parseHRD(r *bufio.GolombBitReader, commonInfPresent bool, maxSubLayersMinus1 int) (*HRD, error) {
+	hrd := &HRD{InitialCpbRemovalDelayLength: 24, AuCpbRemovalDelayLength: 24, DpbOutputDelayLength: 24}
+	if commonInfPresent {
+		hrd.NalHrdParametersPresentFlag = r.Flag()
+		hrd.VclHrdParametersPresentFlag = r.Flag()
+	}
+
+	if commonInfPresent && (hrd.NalHrdParametersPresentFlag || hrd.VclHrdParametersPresentFlag) {
+		if hrd.SubPicHrdParamsPresentFlag = r.Flag(); hrd.SubPicHrdParamsPresentFlag {
+			// tick_divisor_minus2, du_cpb_removal_delay_increment_length_minus1
+			// sub_pic_cpb_params_in_pic_timing_sei_flag, dpb_output_delay_du_length_minus1
+			r.Bits(19)
+		}
+
+		hrd.BitRateScale = r.Bits(4)
+		hrd.CpbSizeScale = r.Bits(4)
+		if hrd.SubPicHrdParamsPresentFlag {
+			// cpb_size_du_scale
+			r.Bits(4)
+		}
+
+		hrd.InitialCpbRemovalDelayLength = r.Bits(5) + 1
+		hrd.AuCpbRemovalDelayLength = r.Bits(5) + 1
+		hrd.DpbOutputDelayLength = r.Bits(5) + 1
+	}
+
+	for i := 0; i <= maxSubLayersMinus1; i++ {
+		fixedPicRateWithinCvs := r.Flag()
+		if !fixedPicRateWithinCvs {
+			fixedPicRateWithinCvs = r.Flag()
+		}
+
+		var lowDelayHrd bool
+		if fixedPicRateWithinCvs {
+			// elemental_duration_in_tc_minus1
+			r.UE()
+		} else {
+			lowDelayHrd = r.Flag()
+		}
+
+		cpbCnt := 1
+		if !lowDelayHrd {
+			if cpbCnt = r.UE() + 1; cpbCnt > 32 {
+				return nil, fmt.Errorf("invalid cpb_cnt_minus1 %d", cpbCnt-1)
+			}
+		}
+
+		if hrd.NalHrdParametersPresentFlag {
+			skipSubLayerHRD(r, cpbCnt, hrd.SubPicHrdParamsPresentFlag)
+		}
+
+		if hrd.VclHrdParametersPresentFlag {
+			skipSubLayerHRD(r, cpbCnt, hrd.SubPicHrdParamsPresentFlag)
+		}
+	}
+
+	return hrd, nil
+}
+
+func parseVUI(r *bufio.GolombBitReader, maxSubLayersMinus1 int) (*VUI, error) {
+	vui := &VUI{VideoFormat: 5, ColourPrimaries: 2, TransferCharacteristics: 2, MatrixCoeffs: 2}
+	if r.Flag() {
+		vui.AspectRatioIdc = r.Bits(8)
+		// EXTENDED_SAR
+		if vui.AspectRatioIdc == 255 {
+			vui.SarWidth = r.Bits(16)
+			vui.SarHeight = r.Bits(16)
+		}
+	}
+
+	if vui.OverscanInfoPresentFlag = r.Flag(); vui.OverscanInfoPresentFlag {
+		vui.OverscanAppropriateFlag = r.Flag()
+	}
+
+	if vui.VideoSignalTypePresentFlag = r.Flag(); vui.VideoSignalTypePresentFlag {
+		vui.VideoFormat = r.Bits(3)
+		vui.VideoFullRangeFlag = r.Flag()
+		if vui.ColourDescriptionPresentFlag = r.Flag(); vui.ColourDescriptionPresentFlag {
+			vui.ColourPrimaries = r.Bits(8)
+			vui.TransferCharacteristics = r.Bits(8)
+			vui.MatrixCoeffs = r.Bits(8)
+		}
+	}
+
+	if vui.ChromaLocInfoPresentFlag = r.Flag(); vui.ChromaLocInfoPresentFlag {
+		vui.ChromaSampleLocTypeTopField = r.UE()
+		vui.ChromaSampleLocTypeBottomField = r.UE()
+	}
+
+	vui.NeutralChromaIndicationFlag = r.Flag()
+	vui.FieldSeqFlag = r.Flag()
+	vui.FrameFieldInfoPresentFlag = r.Flag()
+	if vui.DefaultDisplayWindowFlag = r.Flag(); vui.DefaultDisplayWindowFlag {
+		vui.DefDispWinLeftOffset = r.UE()
+		vui.DefDispWinRightOffset = r.UE()
+		vui.DefDispWinTopOffset = r.UE()
+		vui.DefDispWinBottomOffset = r.UE()
+	}
+
+	if vui.TimingInfoPresentFlag = r.Flag(); vui.TimingInfoPresentFlag {
+		vui.NumUnitsInTick = uint32(r.Bits(32))
+		vui.TimeScale = uint32(r.Bits(32))
+		if vui.PocProportionalToTimingFlag = r.Flag(); vui.PocProportionalToTimingFlag {
+			vui.NumTicksPocDiffOneMinus1 = r.UE()
+		}
+
+		// vui_hrd_parameters_present_flag
+		if r.Flag() {
+			hrd, err := parseHRD(r, true, maxSubLayersMinus1)
+			if err != nil {
+				return nil, err
+			}
+
+			vui.HRD = hrd
+		}
+	}
+
+	if vui.BitstreamRestrictionFlag = r.Flag(); vui.BitstreamRestrictionFlag {
+		vui.TilesFixedStructureFlag = r.Flag()
+		vui.MotionVectorsOverPicBoundaries = r.Flag()
+		vui.RestrictedRefPicListsFlag = r.Flag()
+		vui.MinSpatialSegmentationIdc = r.UE()
+		vui.MaxBytesPerPicDenom = r.UE()
+		vui.MaxBitsPerMinCuDenom = r.UE()
+		vui.Log2MaxMvLengthHorizontal = r.UE()
+		vui.Log2MaxMvLengthVertical = r.UE()
+	}
+
+	if r.Err() != nil {
+		return nil, r.Err()
+	}
+
+	return vui, nil
+}
@@ -0,0 +1,91 @@
+package ivf
+
+import (
+	"encoding/binary"
+	"fmt"
+	"github.com/lkmio/avformat"
+	"github.com/lkmio/avformat/utils"
+)
+
+type Demuxer struct {
+	avformat.BaseDemuxer
+	header       FileHeader
+	headerParsed bool
+}
+
+func (d *Demuxer) Header() FileHeader {
+	return d.header
+}
+
+// Input 解析文件头和完整的帧, 返回已经处理的数据长度. 剩余不完整的数据需要和后续数据拼接后重新输入.
+func (d *Demuxer) Input(data []byte) (int, error) {
+	var n int
+	if !d.headerParsed {
+		if len(data) < FileHeaderSize {
+			return 0, nil
+		}
+
+		if err := d.header.Unmarshal(data); err != nil {
+			return 0, err
+		} else if len(data) < int(d.header.HeaderSize) {
+			return 0, nil
+		}
+
+		d.headerParsed = true
+		n = int(d.header.HeaderSize)
+	}
+
+	for len(data)-n >= FrameHeaderSize {
+		size := int(binary.LittleEndian.Uint32(data[n:]))
+		timestamp := int64(binary.LittleEndian.Uint64(data[n+4:]))
+		if len(data)-n-FrameHeaderSize < size {
+			break
+		} else if size == 0 {
+			return n, fmt.Errorf("invalid ivf frame size 0")
+		}
+
+		n += FrameHeaderSize
+		d.onFrame(data[n:n+size], timestamp)
+		n += size
+	}
+
+	return n, nil
+}
+
+func (d *Demuxer) onFrame(frame []byte, timestamp int64) {
+	id := d.header.CodecID
+	// 时间戳单位为scale/rate秒, 统一转换为1/rate
+	timebase := int(d.header.Rate)
+	timestamp *= int64(d.header.Scale)
+	key := avformat.IsKeyFrame(id, frame)
+
+	bufferIndex := d.FindBufferIndexByMediaType(utils.AVMediaTypeVideo)
+	if !d.Completed && d.Tracks.FindTrackWithType(utils.AVMediaTypeVideo) == nil {
+		var extraData []byte
+		if key {
+			extraData, _ = avformat.ExtractVideoExtraDataFromKeyFrame(id, frame)
+		}
+
+		// 编码器信息也写入缓冲区, 创建track后会被释放
+		if len(extraData) > 0 {
+			_, _ = d.DataPipeline.Write(extraData, bufferIndex, utils.AVMediaTypeVideo)
+			extraData, _ = d.DataPipeline.Fetch(bufferIndex)
+		}
+
+		d.OnNewVideoTrack(bufferIndex, id, timebase, extraData)
+	}
+
+	_, _ = d.DataPipeline.Write(frame, bufferIndex, utils.AVMediaTypeVideo)
+	data, _ := d.DataPipeline.Fetch(bufferIndex)
+	d.OnVideoPacket(bufferIndex, id, data, key, timestamp, timestamp, avformat.PacketTypeNONE)
+}
+
+func NewDemuxer(autoFree bool) *Demuxer {
+	return &Demuxer{
+		BaseDemuxer: avformat.BaseDemuxer{
+			DataPipeline: &avformat.StreamsBuffer{},
+			Name:         "ivf",
+			AutoFree:     autoFree,
+		},
+	}
+}
@@ -0,0 +1,110 @@
+package ivf
+
+import (
+	"encoding/binary"
+	"fmt"
+	"github.com/lkmio/avformat/utils"
+)
+
+const (
+	FileHeaderSize  = 32
+	FrameHeaderSize = 12
+)
+
+var (
+	signature = []byte("DKIF")
+
+	fourCCs = map[utils.AVCodecID]string{
+		utils.AVCodecIdVP8: "VP80",
+		utils.AVCodecIdVP9: "VP90",
+		utils.AVCodecIdAV1: "AV01",
+	}
+)
+
+/*
+IVF文件头, 所有字段都是小端序
+bytes 0-3    signature: 'DKIF'
+bytes 4-5    version (should be 0)
+bytes 6-7    length of header in bytes
+bytes 8-11   codec FourCC (e.g., 'VP80')
+bytes 12-13  width in pixels
+bytes 14-15  height in pixels
+bytes 16-23  time base denominator, numerator
+bytes 24-27  number of frames in file
+bytes 28-31  unused
+*/
+
+type FileHeader struct {
+	Version    uint16
+	HeaderSize uint16
+	CodecID    utils.AVCodecID
+	Width      uint16
+	Height     uint16
+	Rate       uint32 // 时间基分母
+	Scale      uint32 // 时间基分子, 时间戳单位为Scale/Rate秒
+	FrameCount uint32
+}
+
+func (h *FileHeader) Marshal(dst []byte) (int, error) {
+	fourCC, ok := fourCCs[h.CodecID]
+	if !ok {
+		return 0, fmt.Errorf("unsupported codec %s", h.CodecID)
+	} else if len(dst) < FileHeaderSize {
+		return 0, fmt.Errorf("buffer too small")
+	}
+
+	copy(dst, signature)
+	binary.LittleEndian.PutUint16(dst[4:], h.Version)
+	binary.LittleEndian.PutUint16(dst[6:], FileHeaderSize)
+	copy(dst[8:], fourCC)
+	binary.LittleEndian.PutUint16(dst[12:], h.Width)
+	binary.LittleEndian.PutUint16(dst[14:], h.Height)
+	binary.LittleEndian.PutUint32(dst[16:], h.Rate)
+	binary.LittleEndian.PutUint32(dst[20:], h.Scale)
+	binary.LittleEndian.PutUint32(dst[24:], h.FrameCount)
+	binary.LittleEndian.PutUint32(dst[28:], 0)
+	return FileHeaderSize, nil
+}
+
+func (h *FileHeader) Unmarshal(data []byte) error {
+	if len(data) < FileHeaderSize {
+		return fmt.Errorf("need more data")
+	} else if string(data[:4]) != string(signature) {
+		return fmt.Errorf("invalid ivf signature")
+	}
+
+	h.Version = binary.LittleEndian.Uint16(data[4:])
+	h.HeaderSize = binary.LittleEndian.Uint16(data[6:])
+	if h.HeaderSize < FileHeaderSize {
+		return fmt.Errorf("invalid ivf header size %d", h.HeaderSize)
+	}
+
+	h.CodecID = utils.AVCodecIdNONE
+	for id, fourCC := range fourCCs {
+		if fourCC == string(data[8:12]) {
+			h.CodecID = id
+			break
+		}
+	}
+
+	if h.CodecID == utils.AVCodecIdNONE {
+		return fmt.Errorf("unsupported ivf fourcc %s", string(data[8:12]))
+	}
+
+	h.Width = binary.LittleEndian.Uint16(data[12:])
+	h.Height = binary.LittleEndian.Uint16(data[14:])
+	h.Rate = binary.LittleEndian.Uint32(data[16:])
+	h.Scale = binary.LittleEndian.Uint32(data[20:])
+	h.FrameCount = binary.LittleEndian.Uint32(data[24:])
+	if h.Rate == 0 || h.Scale == 0 {
+		return fmt.Errorf("invalid ivf time base %d/%d", h.Scale, h.Rate)
+	}
+
+	return nil
+}
+
+// PutFrameHeader 写入帧头: 4字节帧长度, 8字节时间戳
+func PutFrameHeader(dst []byte, size int, timestamp int64) {
+	binary.LittleEndian.PutUint32(dst, uint32(size))
+	binary.LittleEndian.PutUint64(dst[4:], uint64(timestamp))
+}
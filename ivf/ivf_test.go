@@ -0,0 +1,92 @@
+package ivf
+
+import (
+	"bytes"
+	"github.com/lkmio/avformat"
+	"github.com/lkmio/avformat/utils"
+	"testing"
+)
+
+type testHandler struct {
+	tracks  []avformat.Track
+	packets [][]byte
+	pts     []int64
+}
+
+func (h *testHandler) OnNewTrack(track avformat.Track) {
+	h.tracks = append(h.tracks, track)
+}
+
+func (h *testHandler) OnTrackComplete() {
+}
+
+func (h *testHandler) OnTrackNotFind() {
+}
+
+func (h *testHandler) OnPacket(packet *avformat.AVPacket) {
+	h.packets = append(h.packets, append([]byte{}, packet.Data...))
+	h.pts = append(h.pts, packet.Pts)
+}
+
+func TestIVF(t *testing.T) {
+	stream := &avformat.AVStream{MediaType: utils.AVMediaTypeVideo, CodecID: utils.AVCodecIdVP8, Timebase: 30}
+	muxer := NewMuxer()
+	if _, err := muxer.AddTrack(stream); err != nil {
+		panic(err)
+	}
+
+	file := make([]byte, 1024*64)
+	n, err := muxer.WriteHeader(file)
+	if err != nil {
+		panic(err)
+	}
+
+	var frames [][]byte
+	for i := 0; i < 10; i++ {
+		frame := bytes.Repeat([]byte{byte(i)}, 100+i)
+		frames = append(frames, frame)
+
+		size, err := muxer.Input(file[n:], 0, frame, int64(i), int64(i))
+		if err != nil {
+			panic(err)
+		}
+		n += size
+	}
+
+	file = file[:n]
+	utils.Assert(muxer.FrameCount() == 10)
+	if err = muxer.UpdateHeader(file); err != nil {
+		panic(err)
+	}
+
+	handler := &testHandler{}
+	demuxer := NewDemuxer(false)
+	demuxer.SetHandler(handler)
+
+	// 分段输入, 未处理的数据与后续数据拼接
+	var pending []byte
+	for offset := 0; offset < len(file); offset += 50 {
+		end := offset + 50
+		if end > len(file) {
+			end = len(file)
+		}
+
+		pending = append(pending, file[offset:end]...)
+		consumed, err := demuxer.Input(pending)
+		if err != nil {
+			panic(err)
+		}
+		pending = pending[consumed:]
+	}
+
+	demuxer.ProbeComplete()
+	header := demuxer.Header()
+	utils.Assert(header.CodecID == utils.AVCodecIdVP8 && header.Rate == 30 && header.FrameCount == 10)
+	utils.Assert(len(handler.tracks) == 1 && handler.tracks[0].GetStream().Timebase == 30)
+	// 最后一帧需要等待下一帧计算duration
+	utils.Assert(len(handler.packets) == 9)
+	for i, packet := range handler.packets {
+		utils.Assert(bytes.Equal(packet, frames[i]))
+		utils.Assert(handler.pts[i] == int64(i))
+	}
+}
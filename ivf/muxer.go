@@ -0,0 +1,78 @@
+package ivf
+
+import (
+	"fmt"
+	"github.com/lkmio/avformat"
+	"github.com/lkmio/avformat/utils"
+)
+
+// Muxer IVF只能封装一路VP8/VP9/AV1视频流
+type Muxer struct {
+	avformat.BaseMuxer
+	header FileHeader
+}
+
+func (m *Muxer) AddTrack(stream *avformat.AVStream) (int, error) {
+	if _, ok := fourCCs[stream.CodecID]; !ok {
+		return -1, fmt.Errorf("unsupported codec %s", stream.CodecID)
+	} else if m.Tracks.Size() > 0 {
+		return -1, fmt.Errorf("ivf only supports one video track")
+	}
+
+	m.header.CodecID = stream.CodecID
+	m.header.Rate = uint32(stream.Timebase)
+	m.header.Scale = 1
+	if stream.CodecParameters != nil {
+		m.header.Width = uint16(stream.CodecParameters.Width())
+		m.header.Height = uint16(stream.CodecParameters.Height())
+	}
+
+	return m.BaseMuxer.AddTrack(&avformat.SimpleTrack{Stream: stream})
+}
+
+func (m *Muxer) WriteHeader(dst []byte) (int, error) {
+	if m.Tracks.Size() == 0 {
+		return 0, fmt.Errorf("no track added")
+	} else if m.header.Rate == 0 {
+		return 0, fmt.Errorf("invalid timebase")
+	}
+
+	n, err := m.header.Marshal(dst)
+	if err != nil {
+		return 0, err
+	}
+
+	_, _ = m.BaseMuxer.WriteHeader(dst)
+	return n, nil
+}
+
+// Input 写入一帧, 时间戳使用pts, 单位与track的timebase一致
+func (m *Muxer) Input(dst []byte, index int, data []byte, dts, pts int64) (int, error) {
+	utils.Assert(m.Completed)
+
+	if index != 0 {
+		return 0, fmt.Errorf("invalid track index %d", index)
+	} else if len(dst) < FrameHeaderSize+len(data) {
+		return 0, fmt.Errorf("buffer too small")
+	}
+
+	PutFrameHeader(dst, len(data), pts)
+	copy(dst[FrameHeaderSize:], data)
+	m.header.FrameCount++
+	return FrameHeaderSize + len(data), nil
+}
+
+// FrameCount 返回已经写入的帧数
+func (m *Muxer) FrameCount() int {
+	return int(m.header.FrameCount)
+}
+
+// UpdateHeader 写入完成后重写文件头中的帧数
+func (m *Muxer) UpdateHeader(header []byte) error {
+	_, err := m.header.Marshal(header)
+	return err
+}
+
+func NewMuxer() *Muxer {
+	return &Muxer{}
+}
@@ -0,0 +1,183 @@
+package opus
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+/*
+RFC 7845 5.1 Identification Header
+
+	"OpusHead"	8 bytes
+	version	1 byte
+	channel count	1 byte
+	pre-skip	2 bytes, little endian
+	input sample rate	4 bytes, little endian
+	output gain	2 bytes, little endian, Q7.8
+	channel mapping family	1 byte
+	channel mapping table	可选, stream count(1) + coupled count(1) + channel mapping(channel count)
+
+Encapsulation of Opus in ISO Base Media File Format 4.3.2 OpusSpecificBox(dOps), 大端序
+
+	unsigned int(8) Version = 0;
+	unsigned int(8) OutputChannelCount;
+	unsigned int(16) PreSkip;
+	unsigned int(32) InputSampleRate;
+	signed int(16) OutputGain;
+	unsigned int(8) ChannelMappingFamily;
+	if (ChannelMappingFamily != 0) {
+		ChannelMappingTable(OutputChannelCount);
+	}
+*/
+
+const (
+	HeadMagic   = "OpusHead"
+	HeadVersion = 1
+	headSize    = 19
+	dOpsSize    = 11
+)
+
+type OpusHead struct {
+	Version              byte
+	ChannelCount         int
+	PreSkip              int
+	InputSampleRate      int
+	OutputGain           int16
+	ChannelMappingFamily byte
+	StreamCount          int
+	CoupledCount         int
+	ChannelMapping       []byte
+}
+
+func (h *OpusHead) mappingTableSize() int {
+	if h.ChannelMappingFamily == 0 {
+		return 0
+	}
+
+	return 2 + h.ChannelCount
+}
+
+func (h *OpusHead) writeMappingTable(dst []byte) error {
+	if h.ChannelMappingFamily == 0 {
+		return nil
+	} else if len(h.ChannelMapping) != h.ChannelCount {
+		return fmt.Errorf("channel mapping size %d does not match channel count %d", len(h.ChannelMapping), h.ChannelCount)
+	}
+
+	dst[0] = byte(h.StreamCount)
+	dst[1] = byte(h.CoupledCount)
+	copy(dst[2:], h.ChannelMapping)
+	return nil
+}
+
+func (h *OpusHead) readMappingTable(data []byte) error {
+	if h.ChannelMappingFamily == 0 {
+		// family 0只支持单声道和立体声
+		if h.ChannelCount > 2 {
+			return fmt.Errorf("invalid channel count %d for mapping family 0", h.ChannelCount)
+		}
+
+		h.StreamCount = 1
+		h.CoupledCount = h.ChannelCount - 1
+		h.ChannelMapping = nil
+		return nil
+	} else if len(data) < 2+h.ChannelCount {
+		return fmt.Errorf("need more data")
+	}
+
+	h.StreamCount = int(data[0])
+	h.CoupledCount = int(data[1])
+	h.ChannelMapping = data[2 : 2+h.ChannelCount]
+	return nil
+}
+
+func (h *OpusHead) Marshal() ([]byte, error) {
+	data := make([]byte, headSize+h.mappingTableSize())
+	copy(data, HeadMagic)
+	data[8] = h.Version
+	if data[8] == 0 {
+		data[8] = HeadVersion
+	}
+
+	data[9] = byte(h.ChannelCount)
+	binary.LittleEndian.PutUint16(data[10:], uint16(h.PreSkip))
+	binary.LittleEndian.PutUint32(data[12:], uint32(h.InputSampleRate))
+	binary.LittleEndian.PutUint16(data[16:], uint16(h.OutputGain))
+	data[18] = h.ChannelMappingFamily
+	if err := h.writeMappingTable(data[headSize:]); err != nil {
+		return nil, err
+	}
+
+	return data, nil
+}
+
+func (h *OpusHead) Unmarshal(data []byte) error {
+	if len(data) < headSize {
+		return fmt.Errorf("invalid data length %d", len(data))
+	} else if string(data[:8]) != HeadMagic {
+		return fmt.Errorf("invalid opus head magic")
+	} else if data[8]>>4 != 0 {
+		// 高4位是主版本号, 不兼容
+		return fmt.Errorf("unsupported opus head version %d", data[8])
+	} else if data[9] == 0 {
+		return fmt.Errorf("invalid channel count 0")
+	}
+
+	h.Version = data[8]
+	h.ChannelCount = int(data[9])
+	h.PreSkip = int(binary.LittleEndian.Uint16(data[10:]))
+	h.InputSampleRate = int(binary.LittleEndian.Uint32(data[12:]))
+	h.OutputGain = int16(binary.LittleEndian.Uint16(data[16:]))
+	h.ChannelMappingFamily = data[18]
+	return h.readMappingTable(data[headSize:])
+}
+
+// MarshalDOps 序列化为MP4的dOps box内容(不包含box header)
+func (h *OpusHead) MarshalDOps() ([]byte, error) {
+	data := make([]byte, dOpsSize+h.mappingTableSize())
+	data[1] = byte(h.ChannelCount)
+	binary.BigEndian.PutUint16(data[2:], uint16(h.PreSkip))
+	binary.BigEndian.PutUint32(data[4:], uint32(h.InputSampleRate))
+	binary.BigEndian.PutUint16(data[8:], uint16(h.OutputGain))
+	data[10] = h.ChannelMappingFamily
+	if err := h.writeMappingTable(data[dOpsSize:]); err != nil {
+		return nil, err
+	}
+
+	return data, nil
+}
+
+func (h *OpusHead) UnmarshalDOps(data []byte) error {
+	if len(data) < dOpsSize {
+		return fmt.Errorf("invalid data length %d", len(data))
+	} else if data[0] != 0 {
+		return fmt.Errorf("unsupported dOps version %d", data[0])
+	} else if data[1] == 0 {
+		return fmt.Errorf("invalid channel count 0")
+	}
+
+	h.Version = HeadVersion
+	h.ChannelCount = int(data[1])
+	h.PreSkip = int(binary.BigEndian.Uint16(data[2:]))
+	h.InputSampleRate = int(binary.BigEndian.Uint32(data[4:]))
+	h.OutputGain = int16(binary.BigEndian.Uint16(data[8:]))
+	h.ChannelMappingFamily = data[10]
+	return h.readMappingTable(data[dOpsSize:])
+}
+
+// ParseExtraData 解析OpusHead或dOps
+func ParseExtraData(data []byte) (*OpusHead, error) {
+	head := &OpusHead{}
+	var err error
+	if len(data) >= len(HeadMagic) && string(data[:len(HeadMagic)]) == HeadMagic {
+		err = head.Unmarshal(data)
+	} else {
+		err = head.UnmarshalDOps(data)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return head, nil
+}
@@ -0,0 +1,143 @@
+package opus
+
+import (
+	"fmt"
+)
+
+// SampleRate opus的时间戳和帧时长都以48000为单位
+const (
+	SampleRate        = 48000
+	MaxPacketDuration = 5760 // 120ms
+)
+
+type Mode int
+
+const (
+	ModeSILK   = Mode(0)
+	ModeHybrid = Mode(1)
+	ModeCELT   = Mode(2)
+)
+
+type Bandwidth int
+
+const (
+	BandwidthNarrowband    = Bandwidth(0) // 4kHz
+	BandwidthMediumband    = Bandwidth(1) // 6kHz
+	BandwidthWideband      = Bandwidth(2) // 8kHz
+	BandwidthSuperWideband = Bandwidth(3) // 12kHz
+	BandwidthFullband      = Bandwidth(4) // 20kHz
+)
+
+/*
+RFC 6716 3.1 The TOC Byte
+
+	 0
+	 0 1 2 3 4 5 6 7
+	+-+-+-+-+-+-+-+-+
+	| config  |s| c |
+	+-+-+-+-+-+-+-+-+
+*/
+
+type TOC struct {
+	Config         int  // 0-31
+	Stereo         bool // s
+	FrameCountCode int  // c, 0: 1帧 1: 2帧大小相同 2: 2帧大小不同 3: 任意帧数
+}
+
+func ParseTOC(b byte) TOC {
+	return TOC{
+		Config:         int(b >> 3),
+		Stereo:         b>>2&0x1 == 1,
+		FrameCountCode: int(b & 0x3),
+	}
+}
+
+func (t TOC) Mode() Mode {
+	if t.Config < 12 {
+		return ModeSILK
+	} else if t.Config < 16 {
+		return ModeHybrid
+	}
+
+	return ModeCELT
+}
+
+// Bandwidth RFC 6716 Table 2
+func (t TOC) Bandwidth() Bandwidth {
+	switch {
+	case t.Config < 4:
+		return BandwidthNarrowband
+	case t.Config < 8:
+		return BandwidthMediumband
+	case t.Config < 12:
+		return BandwidthWideband
+	case t.Config < 14:
+		return BandwidthSuperWideband
+	case t.Config < 16:
+		return BandwidthFullband
+	case t.Config < 20:
+		return BandwidthNarrowband
+	case t.Config < 24:
+		return BandwidthWideband
+	case t.Config < 28:
+		return BandwidthSuperWideband
+	default:
+		return BandwidthFullband
+	}
+}
+
+// FrameSamples 单帧的采样数(48000Hz)
+func (t TOC) FrameSamples() int {
+	switch t.Mode() {
+	case ModeSILK:
+		// 10/20/40/60ms
+		return []int{480, 960, 1920, 2880}[t.Config&0x3]
+	case ModeHybrid:
+		// 10/20ms
+		return []int{480, 960}[t.Config&0x1]
+	default:
+		// 2.5/5/10/20ms
+		return []int{120, 240, 480, 960}[t.Config&0x3]
+	}
+}
+
+// PacketFrameCount 返回opus packet中的帧数
+func PacketFrameCount(data []byte) (int, error) {
+	if len(data) < 1 {
+		return 0, fmt.Errorf("need more data")
+	}
+
+	switch data[0] & 0x3 {
+	case 0:
+		return 1, nil
+	case 1, 2:
+		return 2, nil
+	default:
+		// code 3的第二个字节: |v|p|     M     |
+		if len(data) < 2 {
+			return 0, fmt.Errorf("need more data")
+		}
+
+		count := int(data[1] & 0x3F)
+		if count == 0 {
+			return 0, fmt.Errorf("invalid opus frame count 0")
+		}
+
+		return count, nil
+	}
+}
+
+// PacketSamples 返回opus packet的采样数(48000Hz)
+func PacketSamples(data []byte) (int, error) {
+	count, err := PacketFrameCount(data)
+	if err != nil {
+		return 0, err
+	}
+
+	samples := count * ParseTOC(data[0]).FrameSamples()
+	if samples > MaxPacketDuration {
+		return 0, fmt.Errorf("invalid opus packet duration %d", samples)
+	}
+
+	return samples, nil
+}
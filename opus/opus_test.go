@@ -0,0 +1,71 @@
+package opus
+
+import (
+	"bytes"
+	"github.com/lkmio/avformat/utils"
+	"testing"
+)
+
+func TestPacketSamples(t *testing.T) {
+	// config 1: SILK NB 20ms, 单帧
+	samples, err := PacketSamples([]byte{1 << 3})
+	utils.Assert(err == nil && samples == 960)
+
+	// config 31: CELT FB 20ms, stereo, 2帧
+	toc := ParseTOC(31<<3 | 0x4 | 0x1)
+	utils.Assert(toc.Stereo && toc.Mode() == ModeCELT && toc.Bandwidth() == BandwidthFullband)
+	samples, err = PacketSamples([]byte{31<<3 | 0x4 | 0x1})
+	utils.Assert(err == nil && samples == 1920)
+
+	// config 16: CELT NB 2.5ms, code 3, 6帧
+	samples, err = PacketSamples([]byte{16<<3 | 0x3, 6})
+	utils.Assert(err == nil && samples == 720)
+
+	// code 3超过120ms
+	_, err = PacketSamples([]byte{3<<3 | 0x3, 3})
+	utils.Assert(err != nil)
+}
+
+func TestOpusHead(t *testing.T) {
+	head := OpusHead{
+		ChannelCount:         6,
+		PreSkip:              312,
+		InputSampleRate:      44100,
+		OutputGain:           -256,
+		ChannelMappingFamily: 1,
+		StreamCount:          4,
+		CoupledCount:         2,
+		ChannelMapping:       []byte{0, 4, 1, 2, 3, 5},
+	}
+
+	data, err := head.Marshal()
+	if err != nil {
+		panic(err)
+	}
+
+	dOps, err := head.MarshalDOps()
+	if err != nil {
+		panic(err)
+	}
+
+	for _, extraData := range [][]byte{data, dOps} {
+		other, err := ParseExtraData(extraData)
+		if err != nil {
+			panic(err)
+		}
+
+		utils.Assert(other.ChannelCount == 6 && other.PreSkip == 312 && other.InputSampleRate == 44100 && other.OutputGain == -256)
+		utils.Assert(other.StreamCount == 4 && other.CoupledCount == 2 && bytes.Equal(other.ChannelMapping, head.ChannelMapping))
+	}
+
+	// family 0
+	stereo := OpusHead{ChannelCount: 2, PreSkip: 3840, InputSampleRate: 48000}
+	data, _ = stereo.Marshal()
+	utils.Assert(len(data) == 19)
+	other := OpusHead{}
+	if err = other.Unmarshal(data); err != nil {
+		panic(err)
+	}
+
+	utils.Assert(other.StreamCount == 1 && other.CoupledCount == 1 && other.Version == HeadVersion)
+}
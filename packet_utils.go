@@ -1,9 +1,15 @@
 package avformat
 
 import (
+	"encoding/binary"
+	"fmt"
+	"github.com/lkmio/avformat/av1"
 	"github.com/lkmio/avformat/avc"
 	"github.com/lkmio/avformat/hevc"
 	"github.com/lkmio/avformat/utils"
+	"github.com/lkmio/avformat/vp8"
+	"github.com/lkmio/avformat/vp9"
+	"github.com/lkmio/avformat/vvc"
 )
 
 func ConvertTs(ts int64, srcTimeBase, dstTimeBase int) int64 {
@@ -95,11 +101,302 @@ func AnnexBPacket2AVCC(pkt *AVPacket) []byte {
 	return pkt.dataAVCC
 }
 
+// AACPacket2ADTS 为raw AAC添加ADTSHeader, 用于写入TS/PS和.aac文件. ADTSHeader根据stream.Data中的AudioSpecificConfig生成.
+func AACPacket2ADTS(stream *AVStream, pkt *AVPacket) ([]byte, error) {
+	if utils.AVMediaTypeAudio != pkt.MediaType {
+		return nil, fmt.Errorf("invalid media type %s", pkt.MediaType)
+	} else if utils.AVCodecIdAAC != pkt.CodecID || stream.HasADTSHeader {
+		return pkt.Data, nil
+	} else if pkt.dataADTS != nil {
+		return pkt.dataADTS, nil
+	}
+
+	config, err := utils.ParseMpeg4AudioConfig(stream.Data)
+	if err != nil {
+		return nil, err
+	} else if config.ObjectType < int(utils.AotAacMain) || config.ObjectType > int(utils.AotAacLtp) {
+		// ADTS的profile只有2位
+		return nil, fmt.Errorf("unsupported audio object type %d", config.ObjectType)
+	} else if config.SamplingIndex > 12 || config.ChanConfig == 0 || config.ChanConfig > 7 {
+		return nil, fmt.Errorf("unsupported sampling index %d or channel configuration %d", config.SamplingIndex, config.ChanConfig)
+	}
+
+	size := 7 + len(pkt.Data)
+	if size > 0x1FFF {
+		return nil, fmt.Errorf("aac frame too large %d", size)
+	}
+
+	var bytes []byte
+	if pkt.OnBufferAlloc != nil {
+		bytes = pkt.OnBufferAlloc(size)
+	} else {
+		bytes = make([]byte, size)
+	}
+
+	// HE-AAC使用核心编码的profile和采样率, 即隐式信令
+	utils.SetADtsHeader(bytes, 0, config.ObjectType-1, config.SamplingIndex, config.ChanConfig, size)
+	copy(bytes[7:], pkt.Data)
+	pkt.dataADTS = bytes[:size]
+	return pkt.dataADTS, nil
+}
+
+// ADTSPacket2Raw 去掉ADTSHeader, 用于写入FLV/MP4. 不包含ADTSHeader时直接返回.
+// PES负载可能包含多个ADTS帧, 此时返回错误, 使用ADTSPacket2RawFrames拆分.
+func ADTSPacket2Raw(stream *AVStream, pkt *AVPacket) ([]byte, error) {
+	frames, err := ADTSPacket2RawFrames(stream, pkt)
+	if err != nil {
+		return nil, err
+	} else if len(frames) != 1 {
+		return nil, fmt.Errorf("adts packet contains %d frames", len(frames))
+	}
+
+	return frames[0], nil
+}
+
+// ADTSPacket2RawFrames 遍历packet中的所有ADTS帧, 返回去掉ADTSHeader后的raw AAC帧
+func ADTSPacket2RawFrames(stream *AVStream, pkt *AVPacket) ([][]byte, error) {
+	if utils.AVMediaTypeAudio != pkt.MediaType {
+		return nil, fmt.Errorf("invalid media type %s", pkt.MediaType)
+	} else if utils.AVCodecIdAAC != pkt.CodecID || !stream.HasADTSHeader {
+		return [][]byte{pkt.Data}, nil
+	}
+
+	var frames [][]byte
+	for data := pkt.Data; len(data) > 0; {
+		if len(data) < 7 {
+			return nil, fmt.Errorf("invalid adts packet length %d", len(data))
+		}
+
+		header, err := utils.ReadADtsFixedHeader(data)
+		if err != nil {
+			return nil, err
+		}
+
+		// protection_absent为0时多2个字节crc
+		skip := 7
+		if header.ProtectionAbsent() == 0 {
+			skip += 2
+		}
+
+		length := header.FrameLength()
+		if length < skip || length > len(data) {
+			return nil, fmt.Errorf("invalid aac frame length %d", length)
+		}
+
+		frames = append(frames, data[skip:length])
+		data = data[length:]
+	}
+
+	if len(frames) == 0 {
+		return nil, fmt.Errorf("invalid adts packet length %d", len(pkt.Data))
+	}
+
+	return frames, nil
+}
+
+// nalLengthSize 返回AVCC打包时NALU长度字段的字节数, 未知时使用4字节
+func nalLengthSize(stream *AVStream) int {
+	if stream == nil {
+		return 4
+	}
+
+	switch data := stream.CodecParameters.(type) {
+	case *AVCCodecData:
+		if data.Record != nil {
+			return int(data.Record.LengthSizeMinusOne) + 1
+		}
+	case *HEVCCodecData:
+		// hevc的LengthSizeMinusOne保存的是字节数, 由参数集创建时为0
+		if data.Record != nil && data.Record.LengthSizeMinusOne > 0 {
+			return int(data.Record.LengthSizeMinusOne)
+		}
+	}
+
+	return 4
+}
+
+// ParsePacketSEI 解析H264/H265视频包中的SEI, 结果缓存在pkt.SEI
+func ParsePacketSEI(stream *AVStream, pkt *AVPacket) ([]avc.SEIMessage, error) {
+	if pkt.seiParsed {
+		return pkt.SEI, nil
+	}
+
+	var err error
+	var messages []avc.SEIMessage
+	if utils.AVCodecIdH264 == pkt.CodecID {
+		if PacketTypeAVCC == pkt.PacketType {
+			messages, err = avc.ExtractSEIFromAVCC(pkt.Data, nalLengthSize(stream))
+		} else {
+			messages, err = avc.ExtractSEI(pkt.Data)
+		}
+	} else if utils.AVCodecIdH265 == pkt.CodecID {
+		if PacketTypeAVCC == pkt.PacketType {
+			messages, err = hevc.ExtractSEIFromAVCC(pkt.Data, nalLengthSize(stream))
+		} else {
+			messages, err = hevc.ExtractSEI(pkt.Data)
+		}
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	pkt.SEI = messages
+	pkt.seiParsed = true
+	return messages, nil
+}
+
+// ParseHEVCFrameInfo 解析H265视频包的帧类型(I/P/B)和码流声明的重排序深度
+func ParseHEVCFrameInfo(stream *AVStream, pkt *AVPacket) (hevc.HEVCSliceType, int, error) {
+	if utils.AVCodecIdH265 != stream.CodecID {
+		return 0, 0, fmt.Errorf("unsupported codec %s", stream.CodecID)
+	}
+
+	parser, err := newHEVCPOCParser(stream)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	p := parser.(*hevcPOCParser)
+	header, err := p.parseSlice(pkt)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return header.SliceType, p.reorderDepth(), nil
+}
+
+// insertNalU 在第一个VCL NALU前插入nalu, 没有VCL NALU时插入到末尾. lengthSize为0表示annexb.
+func insertNalU(pkt *AVPacket, data, nalu []byte, lengthSize int, isVCL func(header byte) bool) ([]byte, error) {
+	offset := -1
+	if lengthSize == 0 {
+		if len(data) < 4 {
+			return nil, fmt.Errorf("invalid data")
+		}
+
+		var n int
+		avc.SplitNalU(data, func(unit []byte) {
+			if header := avc.RemoveStartCode(unit); offset < 0 && len(header) > 0 && isVCL(header[0]) {
+				offset = n
+			}
+
+			n += len(unit)
+		})
+	} else {
+		var n int
+		err := avc.SplitAVCC(data, lengthSize, func(unit []byte) {
+			if offset < 0 && len(unit) > 0 && isVCL(unit[0]) {
+				offset = n
+			}
+
+			n += lengthSize + len(unit)
+		})
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if offset < 0 {
+		offset = len(data)
+	}
+
+	prefixSize := lengthSize
+	if lengthSize == 0 {
+		prefixSize = 4
+	}
+
+	size := len(data) + prefixSize + len(nalu)
+	var bytes []byte
+	if pkt.OnBufferAlloc != nil {
+		bytes = pkt.OnBufferAlloc(size)
+	} else {
+		bytes = make([]byte, size)
+	}
+
+	n := copy(bytes, data[:offset])
+	if lengthSize == 0 {
+		binary.BigEndian.PutUint32(bytes[n:], 0x1)
+	} else {
+		for i := 0; i < lengthSize; i++ {
+			bytes[n+i] = byte(len(nalu) >> (8 * (lengthSize - 1 - i)))
+		}
+	}
+
+	n += prefixSize
+	n += copy(bytes[n:], nalu)
+	copy(bytes[n:], data[offset:])
+	return bytes[:size], nil
+}
+
+// InsertSEI 在H264/H265视频包的第一个VCL NALU前插入SEI, 同时更新已转换的avcc/annexb缓存
+func InsertSEI(stream *AVStream, pkt *AVPacket, messages ...avc.SEIMessage) error {
+	var nalu []byte
+	var isVCL func(header byte) bool
+	lengthSize := nalLengthSize(stream)
+	if utils.AVCodecIdH264 == pkt.CodecID {
+		nalu = avc.NewSEINalU(messages...)
+		isVCL = func(header byte) bool {
+			t := header & 0x1F
+			return t >= avc.H264NalSlice && t <= avc.H264NalIDRSlice
+		}
+	} else if utils.AVCodecIdH265 == pkt.CodecID {
+		nalu = hevc.NewSEINalU(messages...)
+		isVCL = func(header byte) bool {
+			return header>>1&0x3F < 32
+		}
+	} else {
+		return fmt.Errorf("unsupported codec %s", pkt.CodecID)
+	}
+
+	if PacketTypeAVCC == pkt.PacketType {
+		// AVCC2AnnexB会丢弃SEI, 先生成annexb缓存再插入
+		if utils.AVCodecIdH264 == pkt.CodecID && pkt.dataAnnexB == nil {
+			AVCCPacket2AnnexB(stream, pkt)
+		}
+	} else {
+		lengthSize = 0
+	}
+
+	data, err := insertNalU(pkt, pkt.Data, nalu, lengthSize, isVCL)
+	if err != nil {
+		return err
+	}
+
+	var dataAVCC, dataAnnexB []byte
+	if pkt.dataAVCC != nil {
+		if dataAVCC, err = insertNalU(pkt, pkt.dataAVCC, nalu, 4, isVCL); err != nil {
+			return err
+		}
+	}
+
+	if pkt.dataAnnexB != nil {
+		if dataAnnexB, err = insertNalU(pkt, pkt.dataAnnexB, nalu, 0, isVCL); err != nil {
+			return err
+		}
+	}
+
+	pkt.Data = data
+	pkt.dataAVCC = dataAVCC
+	pkt.dataAnnexB = dataAnnexB
+	pkt.SEI = nil
+	pkt.seiParsed = false
+	return nil
+}
+
 func IsKeyFrame(id utils.AVCodecID, data []byte) bool {
 	if utils.AVCodecIdH264 == id {
 		return avc.IsKeyFrame(data)
 	} else if utils.AVCodecIdH265 == id {
 		return hevc.IsKeyFrame(data)
+	} else if utils.AVCodecIdH266 == id {
+		return vvc.IsKeyFrame(data)
+	} else if utils.AVCodecIdAV1 == id {
+		return av1.IsKeyFrame(data)
+	} else if utils.AVCodecIdVP8 == id {
+		return vp8.IsKeyFrame(data)
+	} else if utils.AVCodecIdVP9 == id {
+		return vp9.IsKeyFrame(data)
 	} else {
 		return false
 	}
@@ -0,0 +1,116 @@
+package avformat
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"github.com/lkmio/avformat/avc"
+	"github.com/lkmio/avformat/hevc"
+	"github.com/lkmio/avformat/utils"
+	"testing"
+)
+
+func newAACPacket(data []byte) *AVPacket {
+	return NewAudioPacket(data, 0, utils.AVCodecIdAAC, 0, 44100)
+}
+
+func TestADTS(t *testing.T) {
+	raw := []byte{0x21, 0x10, 0x04, 0x60, 0x8C, 0x1C}
+	stream := &AVStream{MediaType: utils.AVMediaTypeAudio, CodecID: utils.AVCodecIdAAC, Data: []byte{0x12, 0x10}}
+
+	// raw -> ADTS -> raw
+	adts, err := AACPacket2ADTS(stream, newAACPacket(raw))
+	if err != nil {
+		panic(err)
+	}
+
+	header, err := utils.ReadADtsFixedHeader(adts)
+	if err != nil {
+		panic(err)
+	}
+
+	utils.Assert(header.Profile() == 1 && header.Frequency() == 4 && header.Channel() == 2)
+	utils.Assert(header.ProtectionAbsent() == 1 && header.FrameLength() == len(adts) && len(adts) == 7+len(raw))
+
+	adtsStream := &AVStream{MediaType: utils.AVMediaTypeAudio, CodecID: utils.AVCodecIdAAC, Data: stream.Data, AudioConfig: AudioConfig{HasADTSHeader: true}}
+	data, err := ADTSPacket2Raw(adtsStream, newAACPacket(adts))
+	if err != nil {
+		panic(err)
+	}
+
+	utils.Assert(bytes.Equal(data, raw))
+
+	// 带crc, 修改protection_absent和aac_frame_length
+	crc := append(append(append([]byte{}, adts[:7]...), 0xAB, 0xCD), raw...)
+	crc[1] &= 0xFE
+	crc[3] = crc[3]&0xFC | byte(len(crc)>>11&0x3)
+	crc[4] = byte(len(crc) >> 3)
+	crc[5] = crc[5]&0x1F | byte(len(crc)&0x7)<<5
+	data, err = ADTSPacket2Raw(adtsStream, newAACPacket(crc))
+	if err != nil {
+		panic(err)
+	}
+
+	utils.Assert(bytes.Equal(data, raw))
+
+	// 多帧
+	multi := append(append([]byte{}, adts...), crc...)
+	_, err = ADTSPacket2Raw(adtsStream, newAACPacket(multi))
+	utils.Assert(err != nil)
+
+	frames, err := ADTSPacket2RawFrames(adtsStream, newAACPacket(multi))
+	if err != nil {
+		panic(err)
+	}
+
+	utils.Assert(len(frames) == 2 && bytes.Equal(frames[0], raw) && bytes.Equal(frames[1], raw))
+
+	// 截断
+	_, err = ADTSPacket2RawFrames(adtsStream, newAACPacket(multi[:len(multi)-1]))
+	utils.Assert(err != nil)
+
+	// 不支持的ADTS配置返回错误
+	_, err = AACPacket2ADTS(&AVStream{MediaType: utils.AVMediaTypeAudio, CodecID: utils.AVCodecIdAAC, Data: []byte{0x12, 0x00}}, newAACPacket(raw))
+	utils.Assert(err != nil)
+
+	_, err = AACPacket2ADTS(stream, NewVideoPacket(raw, 0, 0, false, PacketTypeAnnexB, utils.AVCodecIdH264, 0, 90000))
+	utils.Assert(err != nil)
+}
+
+func newHEVCTestStream() *AVStream {
+	vps, _ := hex.DecodeString("40010c01ffff01600000030090000003000003005d999809")
+	sps, _ := hex.DecodeString("42010101600000030090000003000003005da00280802d165999a4932b9a808080820000030002000003003210")
+	pps, _ := hex.DecodeString("4401c172b46240")
+	codecData, err := NewHEVCCodecData(vps, sps, pps)
+	if err != nil {
+		panic(err)
+	}
+
+	return &AVStream{MediaType: utils.AVMediaTypeVideo, CodecID: utils.AVCodecIdH265, CodecParameters: codecData}
+}
+
+func TestPacketSEI(t *testing.T) {
+	// 由参数集创建的hvcC没有长度字段大小, 按4字节处理
+	stream := newHEVCTestStream()
+	sei := hevc.NewSEINalU(avc.SEIMessage{PayloadType: 137, Payload: make([]byte, 24)})
+	slice := []byte{0x26, 0x01, 0xAF}
+	data := binary.BigEndian.AppendUint32(nil, uint32(len(sei)))
+	data = append(data, sei...)
+	data = binary.BigEndian.AppendUint32(data, uint32(len(slice)))
+	data = append(data, slice...)
+
+	pkt := NewVideoPacket(data, 0, 0, true, PacketTypeAVCC, utils.AVCodecIdH265, 0, 90000)
+	messages, err := ParsePacketSEI(stream, pkt)
+	if err != nil {
+		panic(err)
+	}
+
+	utils.Assert(len(messages) == 1 && messages[0].PayloadType == 137)
+
+	pkt = NewVideoPacket(data[4+len(sei):], 0, 0, true, PacketTypeAVCC, utils.AVCodecIdH265, 0, 90000)
+	if err = InsertSEI(stream, pkt, avc.SEIMessage{PayloadType: 137, Payload: make([]byte, 24)}); err != nil {
+		panic(err)
+	}
+
+	utils.Assert(bytes.Equal(pkt.Data, data))
+}
@@ -0,0 +1,91 @@
+package pcm
+
+import (
+	"encoding/binary"
+)
+
+// 所有函数处理的都是S16LE采样
+
+func clip(v int) int16 {
+	if v > 32767 {
+		return 32767
+	} else if v < -32768 {
+		return -32768
+	}
+
+	return int16(v)
+}
+
+func sample(data []byte, index int) int {
+	return int(int16(binary.LittleEndian.Uint16(data[index*2:])))
+}
+
+func putSample(data []byte, index int, v int16) {
+	binary.LittleEndian.PutUint16(data[index*2:], uint16(v))
+}
+
+// Interleave planar转换为交织存储, dst长度至少为len(src), 返回写入长度
+func Interleave(dst, src []byte, channels int) int {
+	frames := len(src) / 2 / channels
+	for c := 0; c < channels; c++ {
+		plane := src[c*frames*2:]
+		for i := 0; i < frames; i++ {
+			copy(dst[(i*channels+c)*2:], plane[i*2:i*2+2])
+		}
+	}
+
+	return frames * channels * 2
+}
+
+// Deinterleave 交织存储转换为planar, dst长度至少为len(src), 返回写入长度
+func Deinterleave(dst, src []byte, channels int) int {
+	frames := len(src) / 2 / channels
+	for c := 0; c < channels; c++ {
+		plane := dst[c*frames*2:]
+		for i := 0; i < frames; i++ {
+			copy(plane[i*2:], src[(i*channels+c)*2:(i*channels+c)*2+2])
+		}
+	}
+
+	return frames * channels * 2
+}
+
+// RemixSize 重新混音后的长度
+func RemixSize(n, srcChannels, dstChannels int) int {
+	return n / 2 / srcChannels * dstChannels * 2
+}
+
+// Remix 转换交织存储的通道数, 返回写入长度.
+// 转单声道时取所有通道的平均值, 单声道转多声道时复制到每个通道, 其他情况保留前面的通道, 多出的通道填充静音.
+func Remix(dst, src []byte, srcChannels, dstChannels int) int {
+	frames := len(src) / 2 / srcChannels
+	for i := 0; i < frames; i++ {
+		switch {
+		case srcChannels == dstChannels:
+			copy(dst[i*dstChannels*2:], src[i*srcChannels*2:(i+1)*srcChannels*2])
+		case dstChannels == 1:
+			var sum int
+			for c := 0; c < srcChannels; c++ {
+				sum += sample(src, i*srcChannels+c)
+			}
+
+			putSample(dst, i, int16(sum/srcChannels))
+		case srcChannels == 1:
+			v := int16(sample(src, i))
+			for c := 0; c < dstChannels; c++ {
+				putSample(dst, i*dstChannels+c, v)
+			}
+		default:
+			for c := 0; c < dstChannels; c++ {
+				var v int16
+				if c < srcChannels {
+					v = int16(sample(src, i*srcChannels+c))
+				}
+
+				putSample(dst, i*dstChannels+c, v)
+			}
+		}
+	}
+
+	return frames * dstChannels * 2
+}
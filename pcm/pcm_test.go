@@ -0,0 +1,127 @@
+package pcm
+
+import (
+	"github.com/lkmio/avformat"
+	"github.com/lkmio/avformat/utils"
+	"math"
+	"testing"
+)
+
+func sine(frequency float64, sampleRate, frames, channels int) []byte {
+	data := make([]byte, frames*channels*2)
+	for i := 0; i < frames; i++ {
+		v := int16(10000 * math.Sin(2*math.Pi*frequency*float64(i)/float64(sampleRate)))
+		for c := 0; c < channels; c++ {
+			putSample(data, i*channels+c, v)
+		}
+	}
+
+	return data
+}
+
+func TestInterleave(t *testing.T) {
+	interleaved := []byte{1, 0, 2, 0, 3, 0, 4, 0, 5, 0, 6, 0}
+	planar := make([]byte, len(interleaved))
+	utils.Assert(Deinterleave(planar, interleaved, 2) == len(interleaved))
+	for i, v := range []byte{1, 0, 3, 0, 5, 0, 2, 0, 4, 0, 6, 0} {
+		utils.Assert(planar[i] == v)
+	}
+
+	dst := make([]byte, len(planar))
+	utils.Assert(Interleave(dst, planar, 2) == len(planar))
+	for i := range dst {
+		utils.Assert(dst[i] == interleaved[i])
+	}
+}
+
+func TestRemix(t *testing.T) {
+	stereo := make([]byte, 8)
+	putSample(stereo, 0, 100)
+	putSample(stereo, 1, 300)
+	putSample(stereo, 2, -100)
+	putSample(stereo, 3, -301)
+
+	mono := make([]byte, RemixSize(len(stereo), 2, 1))
+	utils.Assert(Remix(mono, stereo, 2, 1) == 4)
+	utils.Assert(sample(mono, 0) == 200 && sample(mono, 1) == -200)
+
+	dst := make([]byte, RemixSize(len(mono), 1, 2))
+	utils.Assert(Remix(dst, mono, 1, 2) == 8)
+	utils.Assert(sample(dst, 0) == 200 && sample(dst, 1) == 200 && sample(dst, 3) == -200)
+}
+
+func TestResample(t *testing.T) {
+	cases := [][2]int{{8000, 16000}, {8000, 48000}, {48000, 8000}, {44100, 48000}, {16000, 11025}}
+	for _, c := range cases {
+		srcRate, dstRate := c[0], c[1]
+		resampler, err := NewResampler(srcRate, dstRate, 2)
+		if err != nil {
+			panic(err)
+		}
+
+		// 分成多个packet输入
+		src := sine(440, srcRate, srcRate, 2)
+		var out []byte
+		packetSize := srcRate / 50 * 4
+		for i := 0; i < len(src); i += packetSize {
+			buffer := make([]byte, resampler.OutputSize(packetSize))
+			n := resampler.Resample(buffer, src[i:i+packetSize])
+			out = append(out, buffer[:n]...)
+		}
+
+		buffer := make([]byte, resampler.OutputSize(0))
+		out = append(out, buffer[:resampler.Flush(buffer)]...)
+		utils.Assert(len(out) == dstRate*4)
+
+		// 与理想正弦波比较, 跳过首尾
+		expected := sine(440, dstRate, dstRate, 2)
+		var signal, noise float64
+		for i := dstRate / 10; i < dstRate*9/10; i++ {
+			s := float64(sample(expected, i*2))
+			d := float64(sample(out, i*2))
+			utils.Assert(sample(out, i*2) == sample(out, i*2+1))
+			signal += s * s
+			noise += (s - d) * (s - d)
+		}
+
+		if snr := 10 * math.Log10(signal/noise); snr < 40 {
+			t.Fatalf("%d->%d snr %.2f too low", srcRate, dstRate, snr)
+		}
+	}
+}
+
+func TestProcessor(t *testing.T) {
+	stream := &avformat.AVStream{CodecID: utils.AVCodecIdPCMS16LEPLANAR}
+	stream.SampleRate = 8000
+	stream.Channels = 2
+
+	processor, err := NewProcessor(stream, 16000, 1)
+	if err != nil {
+		panic(err)
+	}
+
+	var frames int
+	for i := 0; i < 10; i++ {
+		pkt := &avformat.AVPacket{Data: sine(440, 8000, 160, 2), CodecID: utils.AVCodecIdPCMS16LEPLANAR, Timebase: 1000, Pts: int64(1000 + i*20)}
+		if err = processor.ProcessPacket(pkt); err != nil {
+			panic(err)
+		}
+
+		utils.Assert(pkt.CodecID == utils.AVCodecIdPCMS16LE)
+		utils.Assert(pkt.Pts == int64(1000+frames/16))
+		utils.Assert(pkt.Duration == int64(len(pkt.Data)/2/16))
+		frames += len(pkt.Data) / 2
+	}
+
+	// 时间戳跳变后重新对齐
+	pkt := &avformat.AVPacket{Data: sine(440, 8000, 160, 2), CodecID: utils.AVCodecIdPCMS16LEPLANAR, Timebase: 1000, Pts: 5000}
+	if err = processor.ProcessPacket(pkt); err != nil {
+		panic(err)
+	}
+
+	utils.Assert(pkt.Pts == 5000)
+
+	processor.ProcessStream(stream)
+	utils.Assert(stream.CodecID == utils.AVCodecIdPCMS16LE && stream.SampleRate == 16000 && stream.Channels == 1)
+	utils.Assert(stream.BitRate == 256000)
+}
@@ -0,0 +1,193 @@
+package pcm
+
+import (
+	"fmt"
+	"math"
+)
+
+const (
+	// ResampleHalfTaps 升采样时滤波器单边阶数, 降采样时按比例增加
+	ResampleHalfTaps = 16
+	// 相位数超过该值时不预先计算滤波器系数
+	maxPrecomputedPhases = 1024
+)
+
+func gcd(a, b int) int {
+	for b != 0 {
+		a, b = b, a%b
+	}
+
+	return a
+}
+
+// Resampler 多相加窗sinc(Blackman)重采样, 处理交织存储的S16LE.
+// 输出第k个采样对应输入的k*srcRate/dstRate时刻, 不引入时间偏移, 但需要缓存halfTaps个输入采样.
+type Resampler struct {
+	srcRate  int
+	dstRate  int
+	channels int
+
+	l        int // 插值倍数
+	m        int // 抽取倍数
+	halfTaps int
+	cutoff   float64
+	filters  [][]float32 // 每个相位的滤波器系数
+	scratch  []float32
+
+	buffer []int16 // 未处理的输入采样, 交织存储
+	index  int     // 下一个输出采样在buffer中的帧索引
+	phase  int     // 下一个输出采样的相位, [0, l)
+}
+
+func NewResampler(srcRate, dstRate, channels int) (*Resampler, error) {
+	if srcRate < 1 || dstRate < 1 || channels < 1 {
+		return nil, fmt.Errorf("invalid resample parameters %d->%d channels %d", srcRate, dstRate, channels)
+	}
+
+	divisor := gcd(srcRate, dstRate)
+	r := &Resampler{
+		srcRate:  srcRate,
+		dstRate:  dstRate,
+		channels: channels,
+		l:        dstRate / divisor,
+		m:        srcRate / divisor,
+		cutoff:   1,
+	}
+
+	// 降采样时截止频率为输出的奈奎斯特频率
+	if r.m > r.l {
+		r.cutoff = float64(r.l) / float64(r.m)
+	}
+
+	r.halfTaps = int(math.Ceil(ResampleHalfTaps / r.cutoff))
+	if r.l <= maxPrecomputedPhases {
+		r.filters = make([][]float32, r.l)
+		for i := range r.filters {
+			r.filters[i] = make([]float32, r.halfTaps*2)
+			r.computeFilter(r.filters[i], i)
+		}
+	} else {
+		r.scratch = make([]float32, r.halfTaps*2)
+	}
+
+	r.Reset()
+	return r, nil
+}
+
+func sinc(x float64) float64 {
+	if x == 0 {
+		return 1
+	}
+
+	return math.Sin(math.Pi*x) / (math.Pi * x)
+}
+
+// computeFilter 计算相位phase的滤波器系数, 归一化使直流增益为1
+func (r *Resampler) computeFilter(filter []float32, phase int) {
+	var sum float64
+	coefficients := make([]float64, len(filter))
+	for j := range filter {
+		// 第j个输入采样到输出时刻的距离
+		x := float64(j-r.halfTaps+1) - float64(phase)/float64(r.l)
+		w := 0.42 + 0.5*math.Cos(math.Pi*x/float64(r.halfTaps)) + 0.08*math.Cos(2*math.Pi*x/float64(r.halfTaps))
+		if x <= -float64(r.halfTaps) || x >= float64(r.halfTaps) {
+			w = 0
+		}
+
+		coefficients[j] = r.cutoff * sinc(r.cutoff*x) * w
+		sum += coefficients[j]
+	}
+
+	for j := range filter {
+		filter[j] = float32(coefficients[j] / sum)
+	}
+}
+
+func (r *Resampler) filter(phase int) []float32 {
+	if r.filters != nil {
+		return r.filters[phase]
+	}
+
+	r.computeFilter(r.scratch, phase)
+	return r.scratch
+}
+
+// Reset 清空缓存的采样
+func (r *Resampler) Reset() {
+	// 预先填充halfTaps-1个静音帧, 第一个输出采样对齐第一个输入采样
+	r.buffer = make([]int16, (r.halfTaps-1)*r.channels)
+	r.index = 0
+	r.phase = 0
+}
+
+// Delay 缓存未输出的输入帧数
+func (r *Resampler) Delay() int {
+	return len(r.buffer)/r.channels - r.index - (r.halfTaps - 1)
+}
+
+// OutputSize 输入n字节后最多输出的字节数
+func (r *Resampler) OutputSize(n int) int {
+	frames := len(r.buffer)/r.channels - r.index + n/2/r.channels
+	return (frames*r.l/r.m + 1) * r.channels * 2
+}
+
+// Resample 重采样交织存储的S16LE, dst长度至少为OutputSize(len(src)), 返回写入长度
+func (r *Resampler) Resample(dst, src []byte) int {
+	frames := len(src) / 2 / r.channels
+	for i := 0; i < frames*r.channels; i++ {
+		r.buffer = append(r.buffer, int16(sample(src, i)))
+	}
+
+	return r.process(dst)
+}
+
+// Flush 使用静音帧输出缓存的采样, dst长度至少为OutputSize(0), 返回写入长度
+func (r *Resampler) Flush(dst []byte) int {
+	delay := r.Delay()
+	if delay < 1 {
+		return 0
+	}
+
+	// 剩余输入对应的输出帧数
+	frames := (delay*r.l - r.phase + r.m - 1) / r.m
+	r.buffer = append(r.buffer, make([]int16, r.halfTaps*r.channels)...)
+	n := r.process(dst[:frames*r.channels*2])
+	r.Reset()
+	return n
+}
+
+func (r *Resampler) process(dst []byte) int {
+	taps := r.halfTaps * 2
+	total := len(r.buffer) / r.channels
+	var n int
+	for r.index+taps <= total && (n+1)*r.channels*2 <= len(dst) {
+		filter := r.filter(r.phase)
+		for c := 0; c < r.channels; c++ {
+			var sum float32
+			offset := r.index*r.channels + c
+			for j, h := range filter {
+				sum += h * float32(r.buffer[offset+j*r.channels])
+			}
+
+			putSample(dst, n*r.channels+c, clip(int(math.Round(float64(sum)))))
+		}
+
+		n++
+		r.phase += r.m
+		r.index += r.phase / r.l
+		r.phase %= r.l
+	}
+
+	// 丢弃已经不再需要的采样
+	if r.index > 0 {
+		consumed := r.index
+		if consumed > total {
+			consumed = total
+		}
+
+		r.buffer = append(r.buffer[:0], r.buffer[consumed*r.channels:]...)
+		r.index -= consumed
+	}
+
+	return n * r.channels * 2
+}
@@ -0,0 +1,147 @@
+package pcm
+
+import (
+	"fmt"
+	"github.com/lkmio/avformat"
+	"github.com/lkmio/avformat/utils"
+)
+
+// Processor 将PCMS16LE/PCMS16LEPLANAR packet转换为指定采样率和通道数的PCMS16LE, 每个流使用一个实例
+type Processor struct {
+	srcRate     int
+	srcChannels int
+	dstRate     int
+	dstChannels int
+	resampler   *Resampler
+
+	interleaved []byte
+	remixed     []byte
+
+	// 输出时间戳以第一个packet为起点, 按照输出的采样数累加
+	basePts      int64
+	inputFrames  int64
+	outputFrames int64
+	started      bool
+}
+
+// NewProcessor dstRate/dstChannels为0时保持不变
+func NewProcessor(stream *avformat.AVStream, dstRate, dstChannels int) (*Processor, error) {
+	if utils.AVCodecIdPCMS16LE != stream.CodecID && utils.AVCodecIdPCMS16LEPLANAR != stream.CodecID {
+		return nil, fmt.Errorf("unsupported codec %s", stream.CodecID)
+	} else if stream.SampleRate < 1 || stream.Channels < 1 {
+		return nil, fmt.Errorf("invalid audio config sample rate %d channels %d", stream.SampleRate, stream.Channels)
+	}
+
+	if dstRate == 0 {
+		dstRate = stream.SampleRate
+	}
+
+	if dstChannels == 0 {
+		dstChannels = stream.Channels
+	}
+
+	p := &Processor{
+		srcRate:     stream.SampleRate,
+		srcChannels: stream.Channels,
+		dstRate:     dstRate,
+		dstChannels: dstChannels,
+	}
+
+	if dstRate != stream.SampleRate {
+		resampler, err := NewResampler(stream.SampleRate, dstRate, dstChannels)
+		if err != nil {
+			return nil, err
+		}
+
+		p.resampler = resampler
+	}
+
+	return p, nil
+}
+
+func grow(buffer []byte, size int) []byte {
+	if cap(buffer) < size {
+		return make([]byte, size)
+	}
+
+	return buffer[:size]
+}
+
+// ProcessPacket 转换packet的Data, 修改CodecID和时间戳.
+// 重采样会缓存少量采样, 输出的Data可能比输入短, 时间戳仍然连续.
+func (p *Processor) ProcessPacket(pkt *avformat.AVPacket) error {
+	if utils.AVCodecIdPCMS16LE != pkt.CodecID && utils.AVCodecIdPCMS16LEPLANAR != pkt.CodecID {
+		return fmt.Errorf("unsupported codec %s", pkt.CodecID)
+	}
+
+	src := pkt.Data
+	// src是否指向内部缓冲区
+	var converted bool
+	if utils.AVCodecIdPCMS16LEPLANAR == pkt.CodecID && p.srcChannels > 1 {
+		p.interleaved = grow(p.interleaved, len(src))
+		src = p.interleaved[:Interleave(p.interleaved, src, p.srcChannels)]
+		converted = true
+	}
+
+	if p.srcChannels != p.dstChannels {
+		p.remixed = grow(p.remixed, RemixSize(len(src), p.srcChannels, p.dstChannels))
+		src = p.remixed[:Remix(p.remixed, src, p.srcChannels, p.dstChannels)]
+		converted = true
+	}
+
+	inputFrames := int64(len(src) / 2 / p.dstChannels)
+	p.updateBasePts(pkt)
+
+	data := src
+	if p.resampler != nil {
+		data = alloc(pkt, p.resampler.OutputSize(len(src)))
+		data = data[:p.resampler.Resample(data, src)]
+	} else if converted {
+		data = alloc(pkt, len(src))
+		copy(data, src)
+	}
+
+	frames := int64(len(data) / 2 / p.dstChannels)
+	pkt.Data = data
+	pkt.CodecID = utils.AVCodecIdPCMS16LE
+	pkt.Pts = p.basePts + avformat.ConvertTs(p.outputFrames, p.dstRate, pkt.Timebase)
+	pkt.Dts = pkt.Pts
+	pkt.Duration = avformat.ConvertTs(frames, p.dstRate, pkt.Timebase)
+
+	p.inputFrames += inputFrames
+	p.outputFrames += frames
+	return nil
+}
+
+// updateBasePts 第一个packet或时间戳不连续时, 重新计算输出时间戳的起点
+func (p *Processor) updateBasePts(pkt *avformat.AVPacket) {
+	if p.started {
+		expected := p.basePts + avformat.ConvertTs(p.inputFrames, p.srcRate, pkt.Timebase)
+		diff := pkt.Pts - expected
+		// 误差超过100ms认为不连续
+		if diff < int64(pkt.Timebase)/10 && diff > -int64(pkt.Timebase)/10 {
+			return
+		}
+	}
+
+	p.started = true
+	p.basePts = pkt.Pts - avformat.ConvertTs(p.outputFrames, p.dstRate, pkt.Timebase)
+	p.inputFrames = p.outputFrames * int64(p.srcRate) / int64(p.dstRate)
+}
+
+// ProcessStream 修改AVStream的CodecID和AudioConfig
+func (p *Processor) ProcessStream(stream *avformat.AVStream) {
+	stream.CodecID = utils.AVCodecIdPCMS16LE
+	stream.SampleRate = p.dstRate
+	stream.Channels = p.dstChannels
+	stream.SampleSize = 16
+	stream.BitRate = p.dstRate * p.dstChannels * 16
+}
+
+func alloc(pkt *avformat.AVPacket, size int) []byte {
+	if pkt.OnBufferAlloc != nil {
+		return pkt.OnBufferAlloc(size)
+	}
+
+	return make([]byte, size)
+}
@@ -0,0 +1,304 @@
+package avformat
+
+import (
+	"fmt"
+	"github.com/lkmio/avformat/avc"
+	"github.com/lkmio/avformat/bufio"
+	"github.com/lkmio/avformat/hevc"
+	"github.com/lkmio/avformat/utils"
+	"sort"
+)
+
+const (
+	// MaxReorderDepth 码流声明重排序深度前按该深度缓存, 保证输出的pts符合显示顺序
+	MaxReorderDepth = 16
+)
+
+// pocParser 解析视频帧的POC
+type pocParser interface {
+	// parse 返回帧的POC, reset表示POC重新开始计数
+	parse(pkt *AVPacket) (poc int, reset bool, err error)
+
+	// reorderDepth 返回码流声明的重排序深度, -1表示未知
+	reorderDepth() int
+}
+
+// splitNalUnits 按打包方式拆分NALU, 回调的NALU不包含起始码和长度
+func splitNalUnits(pkt *AVPacket, lengthSize int, cb func(nalu []byte)) error {
+	if PacketTypeAVCC == pkt.PacketType {
+		return avc.SplitAVCC(pkt.Data, lengthSize, cb)
+	} else if len(pkt.Data) < 4 {
+		return fmt.Errorf("invalid data")
+	}
+
+	avc.SplitNalU(pkt.Data, func(nalu []byte) {
+		if nalu = avc.RemoveStartCode(nalu); len(nalu) > 0 {
+			cb(nalu)
+		}
+	})
+
+	return nil
+}
+
+type avcPOCParser struct {
+	params     *avc.ParameterSets
+	poc        avc.POC
+	sps        *avc.SPS
+	lengthSize int
+}
+
+func (p *avcPOCParser) parse(pkt *AVPacket) (int, bool, error) {
+	var header *avc.SliceHeader
+	var err error
+	splitErr := splitNalUnits(pkt, p.lengthSize, func(nalu []byte) {
+		if header != nil || err != nil || len(nalu) == 0 {
+			return
+		}
+
+		switch nalu[0] & 0x1F {
+		case avc.H264NalSPS, avc.H264NalPPS:
+			err = p.params.Update(nalu)
+		case avc.H264NalSlice, avc.H264NalIDRSlice:
+			header, p.sps, err = p.params.ParseSliceHeader(nalu)
+		}
+	})
+
+	if splitErr != nil {
+		return 0, false, splitErr
+	} else if err != nil {
+		return 0, false, err
+	} else if header == nil {
+		return 0, false, fmt.Errorf("slice not found")
+	}
+
+	return p.poc.Compute(header, p.sps), header.IsIDR(), nil
+}
+
+func (p *avcPOCParser) reorderDepth() int {
+	if p.sps == nil {
+		return -1
+	} else if p.sps.MaxNumReorderFrames >= 0 {
+		return p.sps.MaxNumReorderFrames
+	} else if p.sps.PicOrderCntType == 2 {
+		// 显示顺序与解码顺序相同
+		return 0
+	}
+
+	// 没有bitstream_restriction, 按DPB大小推断
+	return p.sps.MaxDpbFrames()
+}
+
+func newAVCPOCParser(stream *AVStream) (pocParser, error) {
+	parser := &avcPOCParser{params: avc.NewParameterSets(), lengthSize: nalLengthSize(stream)}
+	if data, ok := stream.CodecParameters.(*AVCCodecData); ok {
+		for _, list := range [][][]byte{data.SPS(), data.PPS()} {
+			for _, nalu := range list {
+				if err := parser.params.Update(nalu); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+
+	return parser, nil
+}
+
+type hevcPOCParser struct {
+	params     *hevc.ParameterSets
+	poc        hevc.POC
+	sps        *hevc.HEVCSPSInfo
+	lengthSize int
+}
+
+// parseSlice 解析第一个slice segment的头, 同时更新带内的参数集
+func (p *hevcPOCParser) parseSlice(pkt *AVPacket) (*hevc.SliceHeader, error) {
+	var header *hevc.SliceHeader
+	var err error
+	splitErr := splitNalUnits(pkt, p.lengthSize, func(nalu []byte) {
+		if header != nil || err != nil || len(nalu) < 2 {
+			return
+		}
+
+		switch t := hevc.HEVCNALUnitType(nalu[0] >> 1 & 0x3F); {
+		case t == hevc.HevcNalVPS || t == hevc.HevcNalSPS || t == hevc.HevcNalPPS:
+			err = p.params.Update(nalu)
+		case t <= hevc.HevcNalRsvIRAPVCL23:
+			header, p.sps, err = p.params.ParseSliceHeader(nalu)
+		}
+	})
+
+	if splitErr != nil {
+		return nil, splitErr
+	} else if err != nil {
+		return nil, err
+	} else if header == nil {
+		return nil, fmt.Errorf("slice not found")
+	}
+
+	return header, nil
+}
+
+func (p *hevcPOCParser) parse(pkt *AVPacket) (int, bool, error) {
+	header, err := p.parseSlice(pkt)
+	if err != nil {
+		return 0, false, err
+	}
+
+	poc := p.poc.Compute(header, p.sps)
+	return poc, header.IsIRAP() && p.poc.NoRaslOutputFlag, nil
+}
+
+func (p *hevcPOCParser) reorderDepth() int {
+	if p.sps == nil {
+		return -1
+	}
+
+	return p.sps.MaxNumReorderPics
+}
+
+func newHEVCPOCParser(stream *AVStream) (pocParser, error) {
+	parser := &hevcPOCParser{params: hevc.NewParameterSets(), lengthSize: nalLengthSize(stream)}
+	if data, ok := stream.CodecParameters.(*HEVCCodecData); ok {
+		for _, list := range [][][]byte{data.VPS(), data.SPS(), data.PPS()} {
+			for _, nalu := range list {
+				if err := parser.params.Update(nalu); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+
+	return parser, nil
+}
+
+type reorderFrame struct {
+	pkt   *AVPacket
+	key   int64 // 显示顺序
+	ready bool  // 是否已经确定pts
+}
+
+// POCReorder 根据POC重建视频帧的pts, 用于每帧只有一个时间戳(pts等于dts)的带B帧码流.
+// 输入和输出都是解码顺序, dts保持不变, 按显示顺序将后续帧的dts作为pts.
+type POCReorder struct {
+	parser   pocParser
+	depth    int  // 重排序深度, 小于0表示还未确定
+	learned  bool // 是否已从码流获取重排序深度
+	resets   int64
+	lastKey  int64 // 上一个确定pts的帧
+	lastDts  int64
+	frames   []*reorderFrame // 等待输出的帧, 解码顺序
+	pending  []*reorderFrame // 还未确定pts的帧, 显示顺序
+	maxKey   int64
+	duration int64
+}
+
+func NewPOCReorder(stream *AVStream) (*POCReorder, error) {
+	var parser pocParser
+	var err error
+	switch stream.CodecID {
+	case utils.AVCodecIdH264:
+		parser, err = newAVCPOCParser(stream)
+	case utils.AVCodecIdH265:
+		parser, err = newHEVCPOCParser(stream)
+	default:
+		return nil, fmt.Errorf("unsupported codec %s", stream.CodecID)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &POCReorder{parser: parser, depth: -1, lastKey: -1 << 63, maxKey: -1 << 63}, nil
+}
+
+// Depth 返回当前的重排序深度
+func (r *POCReorder) Depth() int {
+	return r.depth
+}
+
+// Input 输入一帧, 返回可以输出的帧
+func (r *POCReorder) Input(pkt *AVPacket) []*AVPacket {
+	frame := &reorderFrame{pkt: pkt}
+	poc, reset, err := r.parser.parse(pkt)
+	if err != nil {
+		// 无法解析POC, 放在已有帧之后显示
+		frame.key = r.maxKey + 1
+	} else {
+		if reset {
+			r.resets++
+		}
+
+		frame.key = r.resets<<32 + int64(poc)
+	}
+
+	// 获取到参数集之前按最大深度缓存, 不提前确定pts
+	if !r.learned {
+		if depth := r.parser.reorderDepth(); depth >= 0 {
+			r.depth = depth
+			r.learned = true
+		} else {
+			r.depth = MaxReorderDepth
+		}
+	}
+
+	if pkt.Dts > r.lastDts && len(r.frames) > 0 {
+		r.duration = pkt.Dts - r.lastDts
+	}
+
+	r.lastDts = pkt.Dts
+	if frame.key > r.maxKey {
+		r.maxKey = frame.key
+	}
+
+	r.frames = append(r.frames, frame)
+	if frame.key < r.lastKey {
+		// 实际的重排序深度更大, 当前帧已经错过了它的pts
+		r.depth = bufio.MinInt(r.depth+1, MaxReorderDepth)
+		frame.pkt.Pts = pkt.Dts
+		frame.ready = true
+	} else {
+		index := sort.Search(len(r.pending), func(i int) bool {
+			return r.pending[i].key > frame.key
+		})
+
+		r.pending = append(r.pending, nil)
+		copy(r.pending[index+1:], r.pending[index:])
+		r.pending[index] = frame
+	}
+
+	for len(r.pending) > 0 && (len(r.pending) > r.depth || len(r.frames) > MaxReorderDepth*2) {
+		r.assign(pkt.Dts)
+	}
+
+	return r.output()
+}
+
+// assign 为显示顺序最小的帧确定pts
+func (r *POCReorder) assign(pts int64) {
+	frame := r.pending[0]
+	r.pending = r.pending[1:]
+	frame.pkt.Pts = pts
+	frame.ready = true
+	r.lastKey = frame.key
+}
+
+func (r *POCReorder) output() []*AVPacket {
+	var packets []*AVPacket
+	for len(r.frames) > 0 && r.frames[0].ready {
+		packets = append(packets, r.frames[0].pkt)
+		r.frames = r.frames[1:]
+	}
+
+	return packets
+}
+
+// Flush 输出所有缓存的帧, 剩余帧的pts根据帧间隔推算
+func (r *POCReorder) Flush() []*AVPacket {
+	pts := r.lastDts
+	for len(r.pending) > 0 {
+		pts += r.duration
+		r.assign(pts)
+	}
+
+	return r.output()
+}
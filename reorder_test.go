@@ -0,0 +1,258 @@
+package avformat
+
+import (
+	"github.com/lkmio/avformat/avc"
+	"github.com/lkmio/avformat/bufio"
+	"github.com/lkmio/avformat/utils"
+	"testing"
+)
+
+func finishNalU(header byte, writer *bufio.BitsWriter) []byte {
+	return append([]byte{header}, avc.RBSP2EBSP(writer.WriteTrailingBits())...)
+}
+
+// newTestSPS 320x240, poc type 0, log2_max_pic_order_cnt_lsb=8. profileIdc为100时level 3.1, 否则为main profile level 3.0.
+// reorderFrames小于0时不携带vui
+func newTestSPS(profileIdc, reorderFrames int) []byte {
+	writer := &bufio.BitsWriter{Data: make([]byte, 32)}
+	writer.Write(8, uint64(profileIdc))
+	writer.Write(8, 0) // constraint_set_flags
+	if profileIdc == 100 {
+		writer.Write(8, 31) // level_idc
+		writer.WriteUE(0)   // seq_parameter_set_id
+		writer.WriteUE(1)   // chroma_format_idc
+		writer.WriteUE(0)   // bit_depth_luma_minus8
+		writer.WriteUE(0)   // bit_depth_chroma_minus8
+		writer.Write(2, 0)  // qpprime_y_zero_transform_bypass_flag, seq_scaling_matrix_present_flag
+	} else {
+		writer.Write(8, 30) // level_idc
+		writer.WriteUE(0)   // seq_parameter_set_id
+	}
+
+	writer.WriteUE(0)  // log2_max_frame_num_minus4
+	writer.WriteUE(0)  // pic_order_cnt_type
+	writer.WriteUE(4)  // log2_max_pic_order_cnt_lsb_minus4
+	writer.WriteUE(4)  // max_num_ref_frames
+	writer.Write(1, 0) // gaps_in_frame_num_value_allowed_flag
+	writer.WriteUE(19) // pic_width_in_mbs_minus1
+	writer.WriteUE(14) // pic_height_in_map_units_minus1
+	writer.Write(1, 1) // frame_mbs_only_flag
+	writer.Write(1, 1) // direct_8x8_inference_flag
+	writer.Write(1, 0) // frame_cropping_flag
+	if reorderFrames < 0 {
+		writer.Write(1, 0) // vui_parameters_present_flag
+		return finishNalU(0x67, writer)
+	}
+
+	writer.Write(1, 1) // vui_parameters_present_flag
+	writer.Write(9, 0) // aspect_ratio...pic_struct_present_flag
+	writer.Write(1, 1) // bitstream_restriction_flag
+	writer.Write(1, 1) // motion_vectors_over_pic_boundaries_flag
+	writer.WriteUE(0)  // max_bytes_per_pic_denom
+	writer.WriteUE(0)  // max_bits_per_mb_denom
+	writer.WriteUE(16)
+	writer.WriteUE(16)
+	writer.WriteUE(reorderFrames)
+	writer.WriteUE(4) // max_dec_frame_buffering
+	return finishNalU(0x67, writer)
+}
+
+func newTestPPS() []byte {
+	writer := &bufio.BitsWriter{Data: make([]byte, 16)}
+	writer.WriteUE(0)    // pic_parameter_set_id
+	writer.WriteUE(0)    // seq_parameter_set_id
+	writer.Write(2, 0)   // entropy_coding_mode_flag, bottom_field_pic_order_in_frame_present_flag
+	writer.WriteUE(0)    // num_slice_groups_minus1
+	writer.WriteUE(0)    // num_ref_idx_l0_default_active_minus1
+	writer.WriteUE(0)    // num_ref_idx_l1_default_active_minus1
+	writer.Write(3, 0)   // weighted_pred_flag, weighted_bipred_idc
+	writer.WriteUE(0)    // pic_init_qp_minus26
+	writer.WriteUE(0)    // pic_init_qs_minus26
+	writer.WriteUE(0)    // chroma_qp_index_offset
+	writer.Write(3, 0x4) // deblocking_filter_control_present_flag, constrained_intra_pred_flag, redundant_pic_cnt_present_flag
+	return finishNalU(0x68, writer)
+}
+
+func newTestSlice(sliceType, frameNum, poc int, ref bool) []byte {
+	writer := &bufio.BitsWriter{Data: make([]byte, 16)}
+	var header byte = avc.H264NalSlice
+	if sliceType == avc.SliceTypeI {
+		header = avc.H264NalIDRSlice
+	}
+
+	if ref {
+		header |= 0x40
+	}
+
+	writer.WriteUE(0)             // first_mb_in_slice
+	writer.WriteUE(sliceType + 5) // slice_type
+	writer.WriteUE(0)             // pic_parameter_set_id
+	writer.Write(4, uint64(frameNum))
+	if sliceType == avc.SliceTypeI {
+		writer.WriteUE(0) // idr_pic_id
+	}
+
+	writer.Write(8, uint64(poc))
+	writer.Write(7, 0x55)
+	return finishNalU(header, writer)
+}
+
+// runReorder 按解码顺序输入显示序号为displays的帧, 每帧的dts间隔3600. 每个GOP以IDR开始.
+// 返回输出的帧, 以及所有帧按显示顺序排列后的pts.
+func runReorder(reorderFrames int, displays []int, gop int) (output []*AVPacket, ptsInDisplayOrder []int64) {
+	sps := newTestSPS(77, reorderFrames)
+	stream := &AVStream{MediaType: utils.AVMediaTypeVideo, CodecID: utils.AVCodecIdH264}
+	reorder, err := NewPOCReorder(stream)
+	if err != nil {
+		panic(err)
+	}
+
+	var frameNum, maxDisplay int
+	byDisplay := make(map[int]*AVPacket)
+	for i, display := range displays {
+		sliceType, ref := avc.SliceTypeB, false
+		// 显示序号大于之前所有帧的作为P帧
+		if i%gop == 0 {
+			sliceType, ref, frameNum = avc.SliceTypeI, true, 0
+		} else if display > maxDisplay {
+			sliceType, ref = avc.SliceTypeP, true
+		}
+
+		maxDisplay = bufio.MaxInt(maxDisplay, display)
+
+		var data []byte
+		if sliceType == avc.SliceTypeI {
+			for _, nalu := range [][]byte{sps, newTestPPS()} {
+				data = append(data, 0, 0, 0, 1)
+				data = append(data, nalu...)
+			}
+		}
+
+		data = append(data, 0, 0, 0, 1)
+		data = append(data, newTestSlice(sliceType, frameNum&0xF, display%gop*2, ref)...)
+		if ref {
+			frameNum++
+		}
+
+		pkt := NewVideoPacket(data, int64(i)*3600, int64(i)*3600, sliceType == avc.SliceTypeI, PacketTypeAnnexB, utils.AVCodecIdH264, 0, 90000)
+		byDisplay[display] = pkt
+		output = append(output, reorder.Input(pkt)...)
+	}
+
+	output = append(output, reorder.Flush()...)
+	for i := range displays {
+		ptsInDisplayOrder = append(ptsInDisplayOrder, byDisplay[i].Pts)
+	}
+
+	return
+}
+
+func assertReorder(reorderFrames int, displays []int, gop int) {
+	output, pts := runReorder(reorderFrames, displays, gop)
+
+	// 全部输出, 保持解码顺序
+	utils.Assert(len(output) == len(displays))
+	for i, pkt := range output {
+		utils.Assert(pkt.Dts == int64(i)*3600)
+		utils.Assert(pkt.Pts >= pkt.Dts)
+	}
+
+	// 按显示顺序pts严格递增, 帧间隔不变
+	for i := 1; i < len(pts); i++ {
+		utils.Assert(pts[i]-pts[i-1] == 3600)
+	}
+}
+
+func TestPOCReorderIBBP(t *testing.T) {
+	// 解码顺序 I0 P3 B1 B2 P6 B4 B5 P9 B7 B8
+	var displays []int
+	for g := 0; g < 3; g++ {
+		base := g * 10
+		displays = append(displays, base)
+		for i := 1; i < 10; i += 3 {
+			displays = append(displays, base+i+2, base+i, base+i+1)
+		}
+	}
+
+	assertReorder(2, displays, 10)
+	// 没有bitstream_restriction, 按DPB大小推断深度
+	assertReorder(-1, displays, 10)
+
+	// 结束时缓存的帧全部输出
+	output, _ := runReorder(2, displays[:4], 10)
+	utils.Assert(len(output) == 4)
+}
+
+func TestPOCReorderHierarchicalB(t *testing.T) {
+	// 解码顺序 I0 P8 B4 b2 b1 b3 b6 b5 b7
+	var displays []int
+	for g := 0; g < 3; g++ {
+		base := g * 9
+		for _, display := range []int{0, 8, 4, 2, 1, 3, 6, 5, 7} {
+			displays = append(displays, base+display)
+		}
+	}
+
+	assertReorder(3, displays, 9)
+	assertReorder(-1, displays, 9)
+}
+
+type packetRecorder struct {
+	packets []*AVPacket
+}
+
+func (r *packetRecorder) OnNewTrack(track Track) {}
+
+func (r *packetRecorder) OnTrackComplete() {}
+
+func (r *packetRecorder) OnTrackNotFind() {}
+
+func (r *packetRecorder) OnPacket(packet *AVPacket) {
+	r.packets = append(r.packets, packet)
+}
+
+func TestPOCReorderFlush(t *testing.T) {
+	displays := []int{0, 3, 1, 2, 6, 4, 5, 9, 7, 8}
+
+	// 复用runReorder生成的码流, 按解码顺序重新输入demuxer
+	input, _ := runReorder(2, displays, 10)
+	stream := &AVStream{MediaType: utils.AVMediaTypeVideo, CodecID: utils.AVCodecIdH264, Timebase: 90000}
+	recorder := &packetRecorder{}
+	demuxer := &BaseDemuxer{Handler: recorder, ReorderPTS: true, Completed: true}
+	demuxer.Tracks.Add(&SimpleTrack{stream})
+	for _, pkt := range input {
+		pkt.Pts = pkt.Dts
+		demuxer.reorderPacket(stream, pkt)
+	}
+
+	utils.Assert(len(recorder.packets) < len(input))
+	demuxer.Flush()
+	utils.Assert(len(recorder.packets) == len(input))
+	for i, pkt := range recorder.packets {
+		utils.Assert(pkt.Dts == int64(i)*3600)
+	}
+}
+
+func TestAVCPOCParserLengthSize(t *testing.T) {
+	codecData, err := NewAVCCodecData(newTestSPS(77, 2), newTestPPS())
+	if err != nil {
+		panic(err)
+	}
+
+	// 2字节的NALU长度
+	codecData.(*AVCCodecData).Record.LengthSizeMinusOne = 1
+	stream := &AVStream{MediaType: utils.AVMediaTypeVideo, CodecID: utils.AVCodecIdH264, CodecParameters: codecData}
+	parser, err := newAVCPOCParser(stream)
+	if err != nil {
+		panic(err)
+	}
+
+	slice := newTestSlice(avc.SliceTypeI, 0, 8, true)
+	data := append([]byte{byte(len(slice) >> 8), byte(len(slice))}, slice...)
+	poc, reset, err := parser.parse(NewVideoPacket(data, 0, 0, true, PacketTypeAVCC, utils.AVCodecIdH264, 0, 90000))
+	if err != nil {
+		panic(err)
+	}
+
+	utils.Assert(poc == 8 && reset)
+}
@@ -0,0 +1,110 @@
+package rtmp
+
+type ChunkType byte
+
+type MessageTypeID byte
+
+const (
+	ChunkType0 = ChunkType(0) // 11字节message header, 绝对时间戳
+	ChunkType1 = ChunkType(1) // 7字节message header, 时间戳增量, 省略stream id
+	ChunkType2 = ChunkType(2) // 3字节message header, 只有时间戳增量
+	ChunkType3 = ChunkType(3) // 没有message header, 沿用前一个chunk的header
+)
+
+const (
+	MessageTypeIDSetChunkSize     = MessageTypeID(1)
+	MessageTypeIDAbort            = MessageTypeID(2)
+	MessageTypeIDAcknowledgement  = MessageTypeID(3)
+	MessageTypeIDUserControl      = MessageTypeID(4)
+	MessageTypeIDWindowAckSize    = MessageTypeID(5)
+	MessageTypeIDSetPeerBandwidth = MessageTypeID(6)
+	MessageTypeIDAudio            = MessageTypeID(8)
+	MessageTypeIDVideo            = MessageTypeID(9)
+	MessageTypeIDDataAMF3         = MessageTypeID(15)
+	MessageTypeIDSharedObjectAMF3 = MessageTypeID(16)
+	MessageTypeIDCommandAMF3      = MessageTypeID(17)
+	MessageTypeIDDataAMF0         = MessageTypeID(18)
+	MessageTypeIDSharedObjectAMF0 = MessageTypeID(19)
+	MessageTypeIDCommandAMF0      = MessageTypeID(20)
+	MessageTypeIDAggregate        = MessageTypeID(22)
+)
+
+const (
+	// DefaultChunkSize 握手完成后双方默认的chunk大小
+	DefaultChunkSize = 128
+	// MaxChunkSize set chunk size消息的有效范围是[1, 0x7FFFFFFF], 这里限制为message length的最大值
+	MaxChunkSize = 0xFFFFFF
+
+	// ExtendedTimestamp 时间戳字段等于该值时, 使用4字节扩展时间戳
+	ExtendedTimestamp = 0xFFFFFF
+
+	// ChunkStreamIDControl 协议控制消息使用的chunk stream id
+	ChunkStreamIDControl = 2
+
+	MaxChunkStreamID = 65599
+)
+
+var messageHeaderSize = [4]int{11, 7, 3, 0}
+
+// Message RTMP消息, Timestamp为绝对时间戳
+type Message struct {
+	ChunkStreamID uint32
+	Timestamp     uint32
+	Type          MessageTypeID
+	StreamID      uint32
+	Payload       []byte
+}
+
+// basicHeaderSize 根据chunk stream id计算basic header长度
+func basicHeaderSize(csid uint32) int {
+	if csid < 64 {
+		return 1
+	} else if csid < 320 {
+		return 2
+	}
+
+	return 3
+}
+
+// writeBasicHeader 写入basic header, 返回写入长度
+func writeBasicHeader(dst []byte, fmt ChunkType, csid uint32) int {
+	if csid < 64 {
+		dst[0] = byte(fmt)<<6 | byte(csid)
+		return 1
+	} else if csid < 320 {
+		dst[0] = byte(fmt) << 6
+		dst[1] = byte(csid - 64)
+		return 2
+	}
+
+	dst[0] = byte(fmt)<<6 | 1
+	dst[1] = byte((csid - 64) & 0xFF)
+	dst[2] = byte((csid - 64) >> 8)
+	return 3
+}
+
+// readBasicHeader 读取basic header, 返回fmt/chunk stream id/header长度, 数据不足返回长度-1
+func readBasicHeader(data []byte) (ChunkType, uint32, int) {
+	if len(data) < 1 {
+		return 0, 0, -1
+	}
+
+	fmt := ChunkType(data[0] >> 6)
+	csid := uint32(data[0] & 0x3F)
+	switch csid {
+	case 0:
+		if len(data) < 2 {
+			return 0, 0, -1
+		}
+
+		return fmt, 64 + uint32(data[1]), 2
+	case 1:
+		if len(data) < 3 {
+			return 0, 0, -1
+		}
+
+		return fmt, 64 + uint32(data[1]) + uint32(data[2])<<8, 3
+	default:
+		return fmt, csid, 1
+	}
+}
@@ -0,0 +1,228 @@
+package rtmp
+
+import (
+	"encoding/binary"
+	"fmt"
+	"github.com/lkmio/avformat/bufio"
+)
+
+// chunkStream 单个chunk stream的解析状态
+type chunkStream struct {
+	timestamp uint32 // 当前消息的绝对时间戳
+	delta     uint32 // 最近一次type1/type2 chunk携带的时间戳增量
+	length    int
+	type_     MessageTypeID
+	streamId  uint32
+	extended  bool // 前一个chunk是否使用了扩展时间戳
+
+	payload  []byte // 正在组装的消息
+	received int
+}
+
+// ChunkDecoder 将chunk流重组为完整的Message, 不关心数据来源
+type ChunkDecoder struct {
+	chunkSize int
+	streams   map[uint32]*chunkStream
+	buffer    []byte // 未能组成完整chunk的剩余数据
+	onMessage func(message *Message) error
+}
+
+func (d *ChunkDecoder) SetChunkSize(size int) error {
+	if size < 1 || size > MaxChunkSize {
+		return fmt.Errorf("invalid chunk size %d", size)
+	}
+
+	d.chunkSize = size
+	return nil
+}
+
+func (d *ChunkDecoder) ChunkSize() int {
+	return d.chunkSize
+}
+
+// Input 输入任意长度的数据, 每组装出一个完整消息回调一次. 不足一个chunk的数据会被缓存.
+func (d *ChunkDecoder) Input(data []byte) error {
+	var src []byte
+	if len(d.buffer) > 0 {
+		d.buffer = append(d.buffer, data...)
+		src = d.buffer
+	} else {
+		src = data
+	}
+
+	var offset int
+	for offset < len(src) {
+		n, err := d.readChunk(src[offset:])
+		if err != nil {
+			d.buffer = d.buffer[:0]
+			return err
+		} else if n == 0 {
+			break
+		}
+
+		offset += n
+	}
+
+	// 缓存剩余数据
+	remain := src[offset:]
+	if len(d.buffer) > 0 {
+		n := copy(d.buffer, remain)
+		d.buffer = d.buffer[:n]
+	} else if len(remain) > 0 {
+		d.buffer = append(d.buffer[:0], remain...)
+	}
+
+	return nil
+}
+
+// readChunk 读取一个完整的chunk, 数据不足返回0
+func (d *ChunkDecoder) readChunk(data []byte) (int, error) {
+	fmt_, csid, n := readBasicHeader(data)
+	if n < 0 {
+		return 0, nil
+	}
+
+	stream, ok := d.streams[csid]
+	if !ok {
+		if fmt_ != ChunkType0 {
+			return 0, fmt.Errorf("the first chunk of chunk stream %d must be type 0, got type %d", csid, fmt_)
+		}
+
+		stream = &chunkStream{}
+		d.streams[csid] = stream
+	}
+
+	offset := n
+	headerSize := messageHeaderSize[fmt_]
+	if len(data) < offset+headerSize {
+		return 0, nil
+	}
+
+	// 解析message header, 暂存到局部变量, 数据不足时不修改状态
+	header := data[offset : offset+headerSize]
+	offset += headerSize
+
+	var timestamp uint32
+	length := stream.length
+	type_ := stream.type_
+	streamId := stream.streamId
+	extended := stream.extended
+
+	if fmt_ <= ChunkType2 {
+		timestamp = bufio.Uint24(header)
+		extended = timestamp == ExtendedTimestamp
+	}
+
+	if fmt_ <= ChunkType1 {
+		length = int(bufio.Uint24(header[3:]))
+		type_ = MessageTypeID(header[6])
+	}
+
+	if fmt_ == ChunkType0 {
+		streamId = binary.LittleEndian.Uint32(header[7:])
+	}
+
+	if extended {
+		if len(data) < offset+4 {
+			return 0, nil
+		}
+
+		extendedTimestamp := binary.BigEndian.Uint32(data[offset:])
+		offset += 4
+		if fmt_ <= ChunkType2 {
+			timestamp = extendedTimestamp
+		}
+	}
+
+	// 新消息的第一个chunk
+	first := stream.payload == nil
+	if !first && fmt_ != ChunkType3 {
+		return 0, fmt.Errorf("chunk stream %d expect type 3 chunk for an unfinished message, got type %d", csid, fmt_)
+	}
+
+	size := length
+	if !first {
+		size -= stream.received
+	}
+	size = bufio.MinInt(size, d.chunkSize)
+	if len(data) < offset+size {
+		return 0, nil
+	}
+
+	// 数据充足, 更新chunk stream状态
+	if first {
+		switch fmt_ {
+		case ChunkType0:
+			stream.timestamp = timestamp
+		case ChunkType1, ChunkType2:
+			stream.delta = timestamp
+			stream.timestamp += timestamp
+		case ChunkType3:
+			stream.timestamp += stream.delta
+		}
+
+		stream.length = length
+		stream.type_ = type_
+		stream.streamId = streamId
+		stream.extended = extended
+		stream.payload = make([]byte, length)
+		stream.received = 0
+	}
+
+	copy(stream.payload[stream.received:], data[offset:offset+size])
+	stream.received += size
+	offset += size
+
+	if stream.received < stream.length {
+		return offset, nil
+	}
+
+	message := &Message{
+		ChunkStreamID: csid,
+		Timestamp:     stream.timestamp,
+		Type:          stream.type_,
+		StreamID:      stream.streamId,
+		Payload:       stream.payload,
+	}
+
+	stream.payload = nil
+	stream.received = 0
+	return offset, d.processMessage(message)
+}
+
+// processMessage 处理影响chunk层状态的协议控制消息, 再回调给上层
+func (d *ChunkDecoder) processMessage(message *Message) error {
+	switch message.Type {
+	case MessageTypeIDSetChunkSize:
+		if len(message.Payload) < 4 {
+			return fmt.Errorf("invalid set chunk size message")
+		}
+
+		if err := d.SetChunkSize(int(binary.BigEndian.Uint32(message.Payload) & 0x7FFFFFFF)); err != nil {
+			return err
+		}
+	case MessageTypeIDAbort:
+		if len(message.Payload) < 4 {
+			return fmt.Errorf("invalid abort message")
+		}
+
+		if stream, ok := d.streams[binary.BigEndian.Uint32(message.Payload)]; ok {
+			stream.payload = nil
+			stream.received = 0
+		}
+	}
+
+	if d.onMessage != nil {
+		return d.onMessage(message)
+	}
+
+	return nil
+}
+
+func NewChunkDecoder(onMessage func(message *Message) error) *ChunkDecoder {
+	return &ChunkDecoder{
+		chunkSize: DefaultChunkSize,
+		streams:   make(map[uint32]*chunkStream),
+		onMessage: onMessage,
+	}
+}
@@ -0,0 +1,166 @@
+package rtmp
+
+import (
+	"encoding/binary"
+	"fmt"
+	"github.com/lkmio/avformat/bufio"
+)
+
+// chunkHeader 记录每个chunk stream最近一次发送的消息头, 用于压缩后续header
+type chunkHeader struct {
+	timestamp uint32
+	delta     uint32
+	hasDelta  bool // 对端是否已经通过type1/type2记录了delta
+	length    int
+	type_     MessageTypeID
+	streamId  uint32
+}
+
+// ChunkEncoder 将Message序列化为chunk, 根据前一个消息的header选择fmt 0-3
+type ChunkEncoder struct {
+	chunkSize int
+	headers   map[uint32]*chunkHeader
+}
+
+func (e *ChunkEncoder) SetChunkSize(size int) error {
+	if size < 1 || size > MaxChunkSize {
+		return fmt.Errorf("invalid chunk size %d", size)
+	}
+
+	e.chunkSize = size
+	return nil
+}
+
+func (e *ChunkEncoder) ChunkSize() int {
+	return e.chunkSize
+}
+
+// selectChunkType 选择第一个chunk的类型, 返回类型和时间戳字段的值
+func (e *ChunkEncoder) selectChunkType(message *Message) (ChunkType, uint32) {
+	prev, ok := e.headers[message.ChunkStreamID]
+	if !ok || prev.streamId != message.StreamID || message.Timestamp < prev.timestamp {
+		return ChunkType0, message.Timestamp
+	}
+
+	delta := message.Timestamp - prev.timestamp
+	if prev.length != len(message.Payload) || prev.type_ != message.Type {
+		return ChunkType1, delta
+	} else if prev.hasDelta && prev.delta == delta {
+		return ChunkType3, delta
+	}
+
+	return ChunkType2, delta
+}
+
+// EncodedSize 返回序列化该消息所需的字节数
+func (e *ChunkEncoder) EncodedSize(message *Message) int {
+	fmt_, timestamp := e.selectChunkType(message)
+	return e.encodedSize(message, fmt_, timestamp)
+}
+
+func (e *ChunkEncoder) encodedSize(message *Message, fmt_ ChunkType, timestamp uint32) int {
+	length := len(message.Payload)
+	chunks := (length + e.chunkSize - 1) / e.chunkSize
+	if chunks == 0 {
+		chunks = 1
+	}
+
+	basicSize := basicHeaderSize(message.ChunkStreamID)
+	size := length + basicSize*chunks + messageHeaderSize[fmt_]
+	if timestamp >= ExtendedTimestamp {
+		size += 4 * chunks
+	}
+
+	return size
+}
+
+// Encode 序列化消息到dst, 返回写入长度. dst空间不足返回错误, 并且不修改编码状态.
+func (e *ChunkEncoder) Encode(dst []byte, message *Message) (int, error) {
+	if message.ChunkStreamID < ChunkStreamIDControl || message.ChunkStreamID > MaxChunkStreamID {
+		return 0, fmt.Errorf("invalid chunk stream id %d", message.ChunkStreamID)
+	} else if len(message.Payload) > 0xFFFFFF {
+		return 0, fmt.Errorf("message length %d exceeds 24 bits", len(message.Payload))
+	}
+
+	fmt_, timestamp := e.selectChunkType(message)
+	if size := e.encodedSize(message, fmt_, timestamp); len(dst) < size {
+		return 0, fmt.Errorf("buffer too small, need %d bytes got %d", size, len(dst))
+	}
+
+	extended := timestamp >= ExtendedTimestamp
+	timestampField := timestamp
+	if extended {
+		timestampField = ExtendedTimestamp
+	}
+
+	n := writeBasicHeader(dst, fmt_, message.ChunkStreamID)
+	if fmt_ <= ChunkType2 {
+		bufio.PutUint24(dst[n:], timestampField)
+		n += 3
+	}
+
+	if fmt_ <= ChunkType1 {
+		bufio.PutUint24(dst[n:], uint32(len(message.Payload)))
+		dst[n+3] = byte(message.Type)
+		n += 4
+	}
+
+	if fmt_ == ChunkType0 {
+		binary.LittleEndian.PutUint32(dst[n:], message.StreamID)
+		n += 4
+	}
+
+	var offset int
+	for {
+		if extended {
+			binary.BigEndian.PutUint32(dst[n:], timestamp)
+			n += 4
+		}
+
+		size := bufio.MinInt(len(message.Payload)-offset, e.chunkSize)
+		copy(dst[n:], message.Payload[offset:offset+size])
+		n += size
+		offset += size
+
+		if offset >= len(message.Payload) {
+			break
+		}
+
+		// 后续chunk都使用type3
+		n += writeBasicHeader(dst[n:], ChunkType3, message.ChunkStreamID)
+	}
+
+	header, ok := e.headers[message.ChunkStreamID]
+	if !ok {
+		header = &chunkHeader{}
+		e.headers[message.ChunkStreamID] = header
+	}
+
+	// type0之后对端的扩展时间戳状态与delta不再一致, 下个消息不能使用type3
+	if fmt_ == ChunkType1 || fmt_ == ChunkType2 {
+		header.delta = timestamp
+		header.hasDelta = true
+	} else if fmt_ == ChunkType0 {
+		header.hasDelta = false
+	}
+
+	header.timestamp = message.Timestamp
+	header.length = len(message.Payload)
+	header.type_ = message.Type
+	header.streamId = message.StreamID
+	return n, nil
+}
+
+func NewChunkEncoder() *ChunkEncoder {
+	return &ChunkEncoder{
+		chunkSize: DefaultChunkSize,
+		headers:   make(map[uint32]*chunkHeader),
+	}
+}
+
+// NewSetChunkSizeMessage 创建set chunk size协议控制消息
+func NewSetChunkSizeMessage(size int) *Message {
+	payload := make([]byte, 4)
+	binary.BigEndian.PutUint32(payload, uint32(size)&0x7FFFFFFF)
+	return &Message{ChunkStreamID: ChunkStreamIDControl, Type: MessageTypeIDSetChunkSize, Payload: payload}
+}
@@ -0,0 +1,71 @@
+package rtmp
+
+import (
+	"bytes"
+	"github.com/lkmio/avformat/utils"
+	"testing"
+)
+
+func TestChunkCodec(t *testing.T) {
+	var messages []*Message
+	payload := make([]byte, 1000)
+	for i := range payload {
+		payload[i] = byte(i)
+	}
+
+	messages = append(messages,
+		NewSetChunkSizeMessage(300),
+		&Message{ChunkStreamID: 6, Timestamp: 0, Type: MessageTypeIDVideo, StreamID: 1, Payload: payload},
+		&Message{ChunkStreamID: 6, Timestamp: 40, Type: MessageTypeIDVideo, StreamID: 1, Payload: payload},
+		&Message{ChunkStreamID: 6, Timestamp: 80, Type: MessageTypeIDVideo, StreamID: 1, Payload: payload},
+		&Message{ChunkStreamID: 6, Timestamp: 120, Type: MessageTypeIDVideo, StreamID: 1, Payload: payload[:10]},
+		&Message{ChunkStreamID: 4, Timestamp: 0x1000000, Type: MessageTypeIDAudio, StreamID: 1, Payload: payload[:400]},
+		&Message{ChunkStreamID: 4, Timestamp: 0x1000020, Type: MessageTypeIDAudio, StreamID: 1, Payload: payload[:400]},
+		&Message{ChunkStreamID: 400, Timestamp: 0x2000000, Type: MessageTypeIDDataAMF0, StreamID: 1, Payload: payload[:700]},
+	)
+
+	encoder := NewChunkEncoder()
+	var stream []byte
+	for _, message := range messages {
+		dst := make([]byte, encoder.EncodedSize(message))
+		n, err := encoder.Encode(dst, message)
+		if err != nil {
+			panic(err)
+		}
+
+		utils.Assert(n == len(dst))
+		stream = append(stream, dst[:n]...)
+		if message.Type == MessageTypeIDSetChunkSize {
+			_ = encoder.SetChunkSize(300)
+		}
+	}
+
+	var decoded []*Message
+	decoder := NewChunkDecoder(func(message *Message) error {
+		decoded = append(decoded, message)
+		return nil
+	})
+
+	// 按随机长度输入, 验证跨chunk缓存
+	for offset := 0; offset < len(stream); {
+		size := utils.RandomIntInRange(1, 97)
+		if offset+size > len(stream) {
+			size = len(stream) - offset
+		}
+
+		if err := decoder.Input(stream[offset : offset+size]); err != nil {
+			panic(err)
+		}
+		offset += size
+	}
+
+	utils.Assert(decoder.ChunkSize() == 300)
+	utils.Assert(len(decoded) == len(messages))
+	for i, message := range messages {
+		utils.Assert(decoded[i].ChunkStreamID == message.ChunkStreamID)
+		utils.Assert(decoded[i].Timestamp == message.Timestamp)
+		utils.Assert(decoded[i].Type == message.Type)
+		utils.Assert(decoded[i].StreamID == message.StreamID)
+		utils.Assert(bytes.Equal(decoded[i].Payload, message.Payload))
+	}
+}
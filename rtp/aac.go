@@ -0,0 +1,433 @@
+package rtp
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"github.com/lkmio/avformat"
+	"github.com/lkmio/avformat/bufio"
+	"github.com/lkmio/avformat/utils"
+	"strconv"
+	"strings"
+)
+
+// RFC 3640 RTP Payload Format for Transport of MPEG-4 Elementary Streams
+
+// AUConfig AU-header各字段的位长, 来自SDP的fmtp
+type AUConfig struct {
+	SizeLength              int
+	IndexLength             int
+	IndexDeltaLength        int
+	CTSDeltaLength          int
+	DTSDeltaLength          int
+	RandomAccessIndication  bool
+	StreamStateIndication   int
+	ConstantSize            int // SizeLength为0时每个AU的固定长度
+	AuxiliaryDataSizeLength int
+}
+
+var (
+	// AACHbrConfig mode=AAC-hbr
+	AACHbrConfig = AUConfig{SizeLength: 13, IndexLength: 3, IndexDeltaLength: 3}
+	// AACLbrConfig mode=AAC-lbr
+	AACLbrConfig = AUConfig{SizeLength: 6, IndexLength: 2, IndexDeltaLength: 2}
+)
+
+// ParseAUConfig 从fmtp解析AU-header配置, 未指定的字段使用mode对应的默认值
+func ParseAUConfig(fmtp map[string]string) (AUConfig, error) {
+	var config AUConfig
+	switch strings.ToLower(fmtp["mode"]) {
+	case "aac-hbr":
+		config = AACHbrConfig
+	case "aac-lbr":
+		config = AACLbrConfig
+	}
+
+	for key, field := range map[string]*int{
+		"sizelength":              &config.SizeLength,
+		"indexlength":             &config.IndexLength,
+		"indexdeltalength":        &config.IndexDeltaLength,
+		"ctsdeltalength":          &config.CTSDeltaLength,
+		"dtsdeltalength":          &config.DTSDeltaLength,
+		"streamstateindication":   &config.StreamStateIndication,
+		"constantsize":            &config.ConstantSize,
+		"auxiliarydatasizelength": &config.AuxiliaryDataSizeLength,
+	} {
+		value, ok := fmtp[key]
+		if !ok {
+			continue
+		}
+
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 || (n > 32 && key != "constantsize") {
+			return config, fmt.Errorf("invalid %s: %s", key, value)
+		}
+
+		*field = n
+	}
+
+	config.RandomAccessIndication = fmtp["randomaccessindication"] == "1"
+	if config.SizeLength == 0 && config.ConstantSize == 0 {
+		return config, fmt.Errorf("sizelength or constantsize is required")
+	}
+
+	// 后续AU-header长度为0时无法确定AU个数
+	if config.HeaderBits(false) == 0 && config.HeaderBits(true) > 0 {
+		return config, fmt.Errorf("invalid AU-header length")
+	}
+
+	return config, nil
+}
+
+// HeaderBits 每个AU-header的最大位长, 第一个AU使用IndexLength, 后续使用IndexDeltaLength
+func (c *AUConfig) HeaderBits(first bool) int {
+	bits := c.SizeLength
+	if first {
+		bits += c.IndexLength
+	} else {
+		bits += c.IndexDeltaLength
+	}
+
+	if c.CTSDeltaLength > 0 {
+		bits++ // CTS-flag, 第一个AU不携带CTS-delta
+		if !first {
+			bits += c.CTSDeltaLength
+		}
+	}
+
+	if c.DTSDeltaLength > 0 {
+		bits++ // DTS-flag, 打包时不携带DTS-delta
+	}
+
+	if c.RandomAccessIndication {
+		bits++
+	}
+
+	return bits + c.StreamStateIndication
+}
+
+func (c *AUConfig) hasHeaderSection() bool {
+	return c.HeaderBits(true) > 0 || c.HeaderBits(false) > 0
+}
+
+// accessUnit 负载中的一个AU, index为相对第一个AU的序号
+type accessUnit struct {
+	size  int
+	index int
+}
+
+// parseAUHeaders 解析AU-header section和auxiliary section, 返回AU列表和数据部分
+func (c *AUConfig) parseAUHeaders(payload []byte) ([]accessUnit, []byte, error) {
+	if !c.hasHeaderSection() {
+		if c.ConstantSize == 0 || len(payload)%c.ConstantSize != 0 {
+			return nil, nil, fmt.Errorf("invalid payload length %d", len(payload))
+		}
+
+		units := make([]accessUnit, len(payload)/c.ConstantSize)
+		for i := range units {
+			units[i] = accessUnit{size: c.ConstantSize, index: i}
+		}
+
+		return units, payload, nil
+	}
+
+	if len(payload) < 2 {
+		return nil, nil, fmt.Errorf("invalid payload length %d", len(payload))
+	}
+
+	headersLength := int(binary.BigEndian.Uint16(payload))
+	offset := 2 + (headersLength+7)/8
+	if offset > len(payload) {
+		return nil, nil, fmt.Errorf("invalid AU-headers-length %d", headersLength)
+	}
+
+	var units []accessUnit
+	reader := bufio.BitsReader{Data: payload[2:offset]}
+	for index := 0; reader.Offset < headersLength; {
+		start := reader.Offset
+		unit := accessUnit{size: c.ConstantSize}
+		if c.SizeLength > 0 {
+			unit.size = int(reader.Read(c.SizeLength))
+		}
+
+		if len(units) == 0 {
+			reader.Seek(c.IndexLength)
+		} else {
+			index += int(reader.Read(c.IndexDeltaLength)) + 1
+		}
+
+		unit.index = index
+		if c.CTSDeltaLength > 0 && reader.Read(1) == 1 {
+			reader.Seek(c.CTSDeltaLength)
+		}
+
+		if c.DTSDeltaLength > 0 && reader.Read(1) == 1 {
+			reader.Seek(c.DTSDeltaLength)
+		}
+
+		if c.RandomAccessIndication {
+			reader.Seek(1)
+		}
+
+		reader.Seek(c.StreamStateIndication)
+		if reader.Offset > headersLength || reader.Offset == start {
+			return nil, nil, fmt.Errorf("invalid AU-headers-length %d", headersLength)
+		}
+
+		units = append(units, unit)
+	}
+
+	if c.AuxiliaryDataSizeLength > 0 {
+		aux := bufio.BitsReader{Data: payload[offset:]}
+		auxBits := c.AuxiliaryDataSizeLength + int(aux.Read(c.AuxiliaryDataSizeLength))
+		offset += (auxBits + 7) / 8
+		if offset > len(payload) {
+			return nil, nil, fmt.Errorf("invalid auxiliary data size")
+		}
+	}
+
+	return units, payload[offset:], nil
+}
+
+// newAudioSpecificConfig 从AVStream或fmtp的config参数获取AudioSpecificConfig
+func newAudioSpecificConfig(stream *avformat.AVStream, fmtp map[string]string) ([]byte, *utils.MPEG4AudioConfig, error) {
+	asc := stream.Data
+	if value, ok := fmtp["config"]; ok && len(asc) == 0 {
+		data, err := hex.DecodeString(value)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		asc = data
+	}
+
+	if len(asc) < 2 {
+		return nil, nil, fmt.Errorf("audio specific config not found")
+	}
+
+	config, err := utils.ParseMpeg4AudioConfig(asc)
+	if err != nil {
+		return nil, nil, err
+	} else if config.SampleRate < 1 {
+		return nil, nil, fmt.Errorf("invalid sampling frequency index %d", config.SamplingIndex)
+	}
+
+	return asc, config, nil
+}
+
+// AACDepacketizer 将mpeg4-generic负载解析为raw AAC packet
+type AACDepacketizer struct {
+	config     AUConfig
+	stream     *avformat.AVStream
+	frameTicks int64 // 每帧在时钟频率下的时长
+
+	fragment          []byte
+	fragmentSize      int
+	fragmentTimestamp uint32
+	nextSequence      uint16
+}
+
+// NewAACDepacketizer 使用SDP中的fmtp创建解包器, 并使用AudioSpecificConfig填充AVStream的AudioConfig.
+// stream.Timebase为RTP时钟频率, 为0时使用采样率.
+func NewAACDepacketizer(stream *avformat.AVStream, fmtp map[string]string) (*AACDepacketizer, error) {
+	config, err := ParseAUConfig(fmtp)
+	if err != nil {
+		return nil, err
+	}
+
+	asc, m4ac, err := newAudioSpecificConfig(stream, fmtp)
+	if err != nil {
+		return nil, err
+	}
+
+	stream.CodecID = utils.AVCodecIdAAC
+	stream.Data = asc
+	stream.SampleRate = m4ac.OutputSampleRate()
+	stream.Channels = m4ac.OutputChannels()
+	stream.SampleSize = 16
+	stream.HasADTSHeader = false
+	if stream.Timebase == 0 {
+		stream.Timebase = m4ac.SampleRate
+	}
+
+	return &AACDepacketizer{
+		config:     config,
+		stream:     stream,
+		frameTicks: int64(utils.DefaultAACFrameLength) * int64(stream.Timebase) / int64(m4ac.SampleRate),
+	}, nil
+}
+
+func (d *AACDepacketizer) newPacket(data []byte, timestamp int64) *avformat.AVPacket {
+	packet := avformat.NewAudioPacket(data, timestamp, utils.AVCodecIdAAC, d.stream.Index, d.stream.Timebase)
+	packet.Duration = d.frameTicks
+	return packet
+}
+
+// Depacketize 解析一个RTP包的负载, 返回完整的AAC帧. 分片丢失时丢弃整个帧.
+// 返回的packet时间戳为RTP时间戳, 未处理回绕.
+func (d *AACDepacketizer) Depacketize(header *Header, payload []byte) ([]*avformat.AVPacket, error) {
+	sequence := d.nextSequence
+	d.nextSequence = header.SequenceNumber + 1
+
+	units, data, err := d.config.parseAUHeaders(payload)
+	if err != nil {
+		d.fragment = d.fragment[:0]
+		return nil, err
+	}
+
+	// 分片: 只有一个AU, 并且AU-size大于数据长度, 或者正在组包
+	if len(d.fragment) > 0 {
+		if header.SequenceNumber != sequence || header.Timestamp != d.fragmentTimestamp || len(units) != 1 || units[0].size != d.fragmentSize {
+			d.fragment = d.fragment[:0]
+		} else {
+			d.fragment = append(d.fragment, data...)
+			if len(d.fragment) < d.fragmentSize {
+				return nil, nil
+			}
+
+			fragment := d.fragment
+			d.fragment = d.fragment[:0]
+			if len(fragment) != d.fragmentSize || !header.Marker {
+				return nil, fmt.Errorf("invalid aac fragment")
+			}
+
+			frame := make([]byte, len(fragment))
+			copy(frame, fragment)
+			return []*avformat.AVPacket{d.newPacket(frame, int64(header.Timestamp))}, nil
+		}
+	}
+
+	if len(units) == 1 && units[0].size > len(data) {
+		d.fragment = append(d.fragment[:0], data...)
+		d.fragmentSize = units[0].size
+		d.fragmentTimestamp = header.Timestamp
+		return nil, nil
+	}
+
+	var packets []*avformat.AVPacket
+	for _, unit := range units {
+		if unit.size > len(data) {
+			return packets, fmt.Errorf("invalid AU-size %d", unit.size)
+		}
+
+		frame := make([]byte, unit.size)
+		copy(frame, data)
+		data = data[unit.size:]
+		packets = append(packets, d.newPacket(frame, int64(header.Timestamp)+int64(unit.index)*d.frameTicks))
+	}
+
+	return packets, nil
+}
+
+// AACPacketizer 将raw AAC packet打包为mpeg4-generic负载, 多个帧聚合到一个RTP包, 超过MTU的帧分片
+type AACPacketizer struct {
+	Header     Header // 使用PayloadType和SSRC, 每打包一个RTP包SequenceNumber加1
+	MTU        int
+	config     AUConfig
+	sampleRate int
+}
+
+// NewAACPacketizer 使用AAC-hbr模式, RTP时钟频率为采样率
+func NewAACPacketizer(stream *avformat.AVStream, payloadType uint8, ssrc uint32) (*AACPacketizer, error) {
+	_, m4ac, err := newAudioSpecificConfig(stream, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return &AACPacketizer{
+		Header:     Header{PayloadType: payloadType, SSRC: ssrc},
+		MTU:        DefaultMTU,
+		config:     AACHbrConfig,
+		sampleRate: m4ac.SampleRate,
+	}, nil
+}
+
+// Fmtp 打包器对应的fmtp参数
+func (p *AACPacketizer) Fmtp() []string {
+	return []string{"mode=AAC-hbr", "sizelength=" + strconv.Itoa(p.config.SizeLength), "indexlength=" + strconv.Itoa(p.config.IndexLength), "indexdeltalength=" + strconv.Itoa(p.config.IndexDeltaLength)}
+}
+
+// packetSize 打包n个AU后的RTP包长度
+func (p *AACPacketizer) packetSize(n, dataSize int) int {
+	bits := p.config.HeaderBits(true) + (n-1)*p.config.HeaderBits(false)
+	return p.Header.Size() + 2 + (bits+7)/8 + dataSize
+}
+
+func (p *AACPacketizer) writePacket(frames [][]byte, auSize int, timestamp uint32, marker bool) []byte {
+	var dataSize int
+	for _, frame := range frames {
+		dataSize += len(frame)
+	}
+
+	packet := make([]byte, p.packetSize(len(frames), dataSize))
+	p.Header.Timestamp = timestamp
+	p.Header.Marker = marker
+	n := p.Header.Marshal(packet)
+	p.Header.SequenceNumber++
+
+	writer := bufio.BitsWriter{Data: packet[n+2:]}
+	for i, frame := range frames {
+		size := auSize
+		if size == 0 {
+			size = len(frame)
+		}
+
+		writer.Write(p.config.SizeLength, uint64(size))
+		if i == 0 {
+			writer.Write(p.config.IndexLength, 0)
+		} else {
+			writer.Write(p.config.IndexDeltaLength, 0)
+		}
+	}
+
+	binary.BigEndian.PutUint16(packet[n:], uint16(writer.Offset))
+	n += 2 + (writer.Offset+7)/8
+	for _, frame := range frames {
+		n += copy(packet[n:], frame)
+	}
+
+	return packet
+}
+
+// Packetize 打包连续的raw AAC packet, 时间戳转换为采样率. 时间戳不连续的帧不会聚合到同一个RTP包.
+func (p *AACPacketizer) Packetize(pkts []*avformat.AVPacket) ([][]byte, error) {
+	var packets [][]byte
+	for i := 0; i < len(pkts); {
+		timestamp := uint32(pkts[i].ConvertPts(p.sampleRate))
+		if len(pkts[i].Data) >= 1<<p.config.SizeLength {
+			return packets, fmt.Errorf("aac frame size %d exceeds sizelength %d", len(pkts[i].Data), p.config.SizeLength)
+		}
+
+		// 单帧超过MTU, 分片
+		if p.packetSize(1, len(pkts[i].Data)) > p.MTU {
+			data := pkts[i].Data
+			max := p.MTU - p.packetSize(1, 0)
+			for len(data) > 0 {
+				size := bufio.MinInt(max, len(data))
+				packets = append(packets, p.writePacket([][]byte{data[:size]}, len(pkts[i].Data), timestamp, size == len(data)))
+				data = data[size:]
+			}
+
+			i++
+			continue
+		}
+
+		frames := [][]byte{pkts[i].Data}
+		dataSize := len(pkts[i].Data)
+		for j := i + 1; j < len(pkts); j++ {
+			next := uint32(pkts[j].ConvertPts(p.sampleRate))
+			if next != timestamp+uint32(len(frames)*utils.DefaultAACFrameLength) || len(pkts[j].Data) >= 1<<p.config.SizeLength ||
+				p.packetSize(len(frames)+1, dataSize+len(pkts[j].Data)) > p.MTU {
+				break
+			}
+
+			frames = append(frames, pkts[j].Data)
+			dataSize += len(pkts[j].Data)
+		}
+
+		packets = append(packets, p.writePacket(frames, 0, timestamp, true))
+		i += len(frames)
+	}
+
+	return packets, nil
+}
@@ -0,0 +1,147 @@
+package rtp
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+const (
+	Version    = 2
+	HeaderSize = 12 // 不包含CSRC和扩展头的固定头长度
+
+	// DefaultMTU 打包时RTP包的最大长度, 包含RTP头
+	DefaultMTU = 1400
+)
+
+/*
+RFC 3550 5.1 RTP Fixed Header Fields
+
+	 0                   1                   2                   3
+	 0 1 2 3 4 5 6 7 8 9 0 1 2 3 4 5 6 7 8 9 0 1 2 3 4 5 6 7 8 9 0 1
+	+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+	|V=2|P|X|  CC   |M|     PT      |       sequence number         |
+	+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+	|                           timestamp                           |
+	+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+	|           synchronization source (SSRC) identifier            |
+	+=+=+=+=+=+=+=+=+=+=+=+=+=+=+=+=+=+=+=+=+=+=+=+=+=+=+=+=+=+=+=+=+
+	|            contributing source (CSRC) identifiers             |
+	|                             ....                              |
+	+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+*/
+
+type Header struct {
+	Padding          bool
+	Extension        bool
+	Marker           bool
+	PayloadType      uint8
+	SequenceNumber   uint16
+	Timestamp        uint32
+	SSRC             uint32
+	CSRC             []uint32
+	ExtensionProfile uint16
+	ExtensionPayload []byte // 长度必须是4的倍数
+}
+
+func (h *Header) Size() int {
+	size := HeaderSize + 4*len(h.CSRC)
+	if h.Extension {
+		size += 4 + len(h.ExtensionPayload)
+	}
+
+	return size
+}
+
+// Marshal 写入RTP头, 返回写入长度. 不写入padding.
+func (h *Header) Marshal(dst []byte) int {
+	dst[0] = Version<<6 | byte(len(h.CSRC))&0xF
+	if h.Padding {
+		dst[0] |= 0x20
+	}
+
+	if h.Extension {
+		dst[0] |= 0x10
+	}
+
+	dst[1] = h.PayloadType & 0x7F
+	if h.Marker {
+		dst[1] |= 0x80
+	}
+
+	binary.BigEndian.PutUint16(dst[2:], h.SequenceNumber)
+	binary.BigEndian.PutUint32(dst[4:], h.Timestamp)
+	binary.BigEndian.PutUint32(dst[8:], h.SSRC)
+
+	n := HeaderSize
+	for _, csrc := range h.CSRC {
+		binary.BigEndian.PutUint32(dst[n:], csrc)
+		n += 4
+	}
+
+	if h.Extension {
+		binary.BigEndian.PutUint16(dst[n:], h.ExtensionProfile)
+		binary.BigEndian.PutUint16(dst[n+2:], uint16(len(h.ExtensionPayload)/4))
+		n += 4
+		n += copy(dst[n:], h.ExtensionPayload)
+	}
+
+	return n
+}
+
+// Unmarshal 解析RTP头, 返回去掉padding的负载
+func (h *Header) Unmarshal(packet []byte) ([]byte, error) {
+	if len(packet) < HeaderSize {
+		return nil, fmt.Errorf("invalid rtp packet length %d", len(packet))
+	} else if version := packet[0] >> 6; version != Version {
+		return nil, fmt.Errorf("invalid rtp version %d", version)
+	}
+
+	h.Padding = packet[0]&0x20 != 0
+	h.Extension = packet[0]&0x10 != 0
+	h.Marker = packet[1]&0x80 != 0
+	h.PayloadType = packet[1] & 0x7F
+	h.SequenceNumber = binary.BigEndian.Uint16(packet[2:])
+	h.Timestamp = binary.BigEndian.Uint32(packet[4:])
+	h.SSRC = binary.BigEndian.Uint32(packet[8:])
+
+	n := HeaderSize
+	cc := int(packet[0] & 0xF)
+	if len(packet) < n+4*cc {
+		return nil, fmt.Errorf("invalid rtp packet length %d", len(packet))
+	}
+
+	h.CSRC = h.CSRC[:0]
+	for i := 0; i < cc; i++ {
+		h.CSRC = append(h.CSRC, binary.BigEndian.Uint32(packet[n:]))
+		n += 4
+	}
+
+	h.ExtensionPayload = nil
+	if h.Extension {
+		if len(packet) < n+4 {
+			return nil, fmt.Errorf("invalid rtp packet length %d", len(packet))
+		}
+
+		h.ExtensionProfile = binary.BigEndian.Uint16(packet[n:])
+		length := 4 * int(binary.BigEndian.Uint16(packet[n+2:]))
+		n += 4
+		if len(packet) < n+length {
+			return nil, fmt.Errorf("invalid rtp extension length %d", length)
+		}
+
+		h.ExtensionPayload = packet[n : n+length]
+		n += length
+	}
+
+	end := len(packet)
+	if h.Padding {
+		padding := int(packet[end-1])
+		if padding == 0 || end-padding < n {
+			return nil, fmt.Errorf("invalid rtp padding length %d", padding)
+		}
+
+		end -= padding
+	}
+
+	return packet[n:end], nil
+}
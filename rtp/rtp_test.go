@@ -0,0 +1,155 @@
+package rtp
+
+import (
+	"bytes"
+	"github.com/lkmio/avformat"
+	"github.com/lkmio/avformat/utils"
+	"testing"
+)
+
+func TestHeader(t *testing.T) {
+	header := Header{Marker: true, PayloadType: 97, SequenceNumber: 65535, Timestamp: 0xFFFFFFF0, SSRC: 0x12345678,
+		CSRC: []uint32{1, 2}, Extension: true, ExtensionProfile: 0xBEDE, ExtensionPayload: []byte{1, 2, 3, 4}}
+
+	packet := make([]byte, header.Size()+3)
+	n := header.Marshal(packet)
+	utils.Assert(n == header.Size() && n == 12+8+8)
+	copy(packet[n:], []byte{0xAA, 0xBB, 0xCC})
+
+	var parsed Header
+	payload, err := parsed.Unmarshal(packet)
+	if err != nil {
+		panic(err)
+	}
+
+	utils.Assert(bytes.Equal(payload, []byte{0xAA, 0xBB, 0xCC}))
+	utils.Assert(parsed.Marker && parsed.PayloadType == 97 && parsed.SequenceNumber == 65535 && parsed.Timestamp == 0xFFFFFFF0)
+	utils.Assert(parsed.SSRC == 0x12345678 && len(parsed.CSRC) == 2 && parsed.CSRC[1] == 2)
+	utils.Assert(parsed.ExtensionProfile == 0xBEDE && bytes.Equal(parsed.ExtensionPayload, []byte{1, 2, 3, 4}))
+
+	// padding
+	packet[0] |= 0x20
+	packet[len(packet)-1] = 2
+	payload, err = parsed.Unmarshal(packet)
+	if err != nil {
+		panic(err)
+	}
+
+	utils.Assert(bytes.Equal(payload, []byte{0xAA}))
+
+	_, err = parsed.Unmarshal(packet[:8])
+	utils.Assert(err != nil)
+}
+
+func TestParseAUConfig(t *testing.T) {
+	config, err := ParseAUConfig(map[string]string{"mode": "AAC-hbr"})
+	if err != nil {
+		panic(err)
+	}
+
+	utils.Assert(config == AACHbrConfig)
+
+	config, err = ParseAUConfig(map[string]string{"mode": "aac-lbr", "sizelength": "6", "indexlength": "2", "indexdeltalength": "2", "randomaccessindication": "1"})
+	if err != nil {
+		panic(err)
+	}
+
+	utils.Assert(config.SizeLength == 6 && config.RandomAccessIndication)
+
+	_, err = ParseAUConfig(map[string]string{"mode": "generic"})
+	utils.Assert(err != nil)
+
+	// 只有第一个AU-header有长度, 后续AU-header不消耗任何位
+	_, err = ParseAUConfig(map[string]string{"constantsize": "100", "indexlength": "3"})
+	utils.Assert(err != nil)
+
+	config = AUConfig{ConstantSize: 100, IndexLength: 3}
+	_, _, err = config.parseAUHeaders([]byte{0, 4, 0xff, 1, 2, 3})
+	utils.Assert(err != nil)
+}
+
+func newAACStream() *avformat.AVStream {
+	stream := &avformat.AVStream{MediaType: utils.AVMediaTypeAudio, CodecID: utils.AVCodecIdAAC, Data: []byte{0x12, 0x10}, Timebase: 44100}
+	return stream
+}
+
+func TestAAC(t *testing.T) {
+	packetizer, err := NewAACPacketizer(newAACStream(), 97, 1)
+	if err != nil {
+		panic(err)
+	}
+
+	packetizer.MTU = 1000
+	var pkts []*avformat.AVPacket
+	sizes := []int{100, 200, 300, 2500, 400}
+	for i, size := range sizes {
+		data := bytes.Repeat([]byte{byte(i + 1)}, size)
+		pkts = append(pkts, &avformat.AVPacket{Data: data, Pts: int64(i * 1024), Dts: int64(i * 1024), Timebase: 44100})
+	}
+
+	packets, err := packetizer.Packetize(pkts)
+	if err != nil {
+		panic(err)
+	}
+
+	// 前3帧聚合, 第4帧分成3片, 第5帧单独打包
+	utils.Assert(len(packets) == 5)
+	for _, packet := range packets {
+		utils.Assert(len(packet) <= packetizer.MTU)
+	}
+
+	stream := &avformat.AVStream{Index: 1}
+	depacketizer, err := NewAACDepacketizer(stream, map[string]string{"mode": "AAC-hbr", "sizelength": "13", "indexlength": "3", "indexdeltalength": "3", "config": "1210"})
+	if err != nil {
+		panic(err)
+	}
+
+	utils.Assert(stream.CodecID == utils.AVCodecIdAAC && stream.SampleRate == 44100 && stream.Channels == 2 && stream.Timebase == 44100)
+
+	var frames []*avformat.AVPacket
+	for _, packet := range packets {
+		var header Header
+		payload, err := header.Unmarshal(packet)
+		if err != nil {
+			panic(err)
+		}
+
+		result, err := depacketizer.Depacketize(&header, payload)
+		if err != nil {
+			panic(err)
+		}
+
+		frames = append(frames, result...)
+	}
+
+	utils.Assert(len(frames) == len(pkts))
+	for i, frame := range frames {
+		utils.Assert(bytes.Equal(frame.Data, pkts[i].Data))
+		utils.Assert(frame.Pts == int64(i*1024) && frame.Duration == 1024 && frame.Index == 1)
+	}
+
+	// 丢失中间的分片, 丢弃整个帧
+	packets, _ = packetizer.Packetize(pkts[3:])
+	for i, packet := range packets {
+		if i == 1 {
+			continue
+		}
+
+		var header Header
+		payload, _ := header.Unmarshal(packet)
+		result, _ := depacketizer.Depacketize(&header, payload)
+		if i == len(packets)-1 {
+			utils.Assert(len(result) == 1 && bytes.Equal(result[0].Data, pkts[4].Data))
+		} else {
+			utils.Assert(len(result) == 0)
+		}
+	}
+	// HE-AAC: AVStream使用输出的采样率和通道数, 时钟和帧间隔使用核心采样率
+	stream = &avformat.AVStream{Index: 1}
+	depacketizer, err = NewAACDepacketizer(stream, map[string]string{"mode": "AAC-hbr", "sizelength": "13", "indexlength": "3", "indexdeltalength": "3", "config": "eb098800"})
+	if err != nil {
+		panic(err)
+	}
+
+	utils.Assert(stream.SampleRate == 48000 && stream.Channels == 2 && stream.Timebase == 24000 && depacketizer.frameTicks == 1024)
+}
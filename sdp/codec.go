@@ -0,0 +1,383 @@
+package sdp
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"github.com/lkmio/avformat"
+	"github.com/lkmio/avformat/avc"
+	"github.com/lkmio/avformat/utils"
+	"strconv"
+	"strings"
+)
+
+// encoding name与编码器的映射, rtpmap中的encoding name不区分大小写
+var encodingNames = map[utils.AVCodecID]string{
+	utils.AVCodecIdH264:      "H264",
+	utils.AVCodecIdH265:      "H265",
+	utils.AVCodecIdVP8:       "VP8",
+	utils.AVCodecIdVP9:       "VP9",
+	utils.AVCodecIdAV1:       "AV1",
+	utils.AVCodecIdAAC:       "MPEG4-GENERIC",
+	utils.AVCodecIdAACLATM:   "MP4A-LATM",
+	utils.AVCodecIdPCMALAW:   "PCMA",
+	utils.AVCodecIdPCMMULAW:  "PCMU",
+	utils.AVCodecIdOPUS:      "opus",
+	utils.AVCodecIdADPCMG722: "G722",
+	utils.AVCodecIdMP3:       "MPA",
+}
+
+func findCodecByEncodingName(name string) (utils.AVCodecID, bool) {
+	for id, encodingName := range encodingNames {
+		if strings.EqualFold(encodingName, name) {
+			return id, true
+		}
+	}
+
+	// RFC 3551 4.5.4: G726-16/24/32/40从字节低位开始打包, AAL2-G726-xx从字节高位开始打包
+	if upper := strings.ToUpper(name); strings.HasPrefix(upper, "G726-") {
+		return utils.AVCodecIdADPCMG726LE, true
+	} else if strings.HasPrefix(upper, "AAL2-G726-") {
+		return utils.AVCodecIdADPCMG726, true
+	}
+
+	return utils.AVCodecIdNONE, false
+}
+
+// latmProfileLevel ISO/IEC 14496-3 Table 1.14 AAC Profile的level, 和ffmpeg一致
+func latmProfileLevel(config *utils.MPEG4AudioConfig) int {
+	switch {
+	case config.SampleRate <= 24000:
+		if config.Channels <= 2 {
+			return 0x28 // Level 1
+		}
+	case config.SampleRate <= 48000:
+		if config.Channels <= 2 {
+			return 0x29 // Level 2
+		} else if config.Channels <= 5 {
+			return 0x2A // Level 4
+		}
+	}
+
+	return 0x2B // Level 5
+}
+
+func encodeParameterSet(data []byte) string {
+	return base64.StdEncoding.EncodeToString(avc.RemoveStartCode(data))
+}
+
+// decodeParameterSet base64解码参数集, 并添加start code
+func decodeParameterSet(value string) ([]byte, error) {
+	bytes, err := base64.StdEncoding.DecodeString(value)
+	if err != nil {
+		// 部分设备省略了base64的填充
+		if bytes, err = base64.RawStdEncoding.DecodeString(strings.TrimRight(value, "=")); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(bytes) == 0 {
+		return nil, fmt.Errorf("empty parameter set")
+	}
+
+	nalu := make([]byte, 4+len(bytes))
+	binary.BigEndian.PutUint32(nalu, 0x1)
+	copy(nalu[4:], bytes)
+	return nalu, nil
+}
+
+// NewMediaDescription 根据AVStream生成媒体描述, 包含rtpmap和fmtp
+func NewMediaDescription(stream *avformat.AVStream, payloadType int) (*MediaDescription, error) {
+	encodingName, ok := encodingNames[stream.CodecID]
+	if !ok && stream.CodecID != utils.AVCodecIdADPCMG726 && stream.CodecID != utils.AVCodecIdADPCMG726LE {
+		return nil, fmt.Errorf("unsupported codec %s", stream.CodecID)
+	}
+
+	media := &MediaDescription{MediaType: stream.MediaType, Protocol: "RTP/AVP", Formats: []int{payloadType}}
+	rtpMap := RTPMap{PayloadType: payloadType, EncodingName: encodingName, ClockRate: 90000}
+	var fmtp []string
+
+	switch stream.CodecID {
+	case utils.AVCodecIdH264:
+		if stream.CodecParameters == nil {
+			break
+		}
+
+		spsList, ppsList := stream.CodecParameters.SPS(), stream.CodecParameters.PPS()
+		if len(spsList) == 0 || len(ppsList) == 0 {
+			return nil, fmt.Errorf("sps or pps not found")
+		}
+
+		sps := avc.RemoveStartCode(spsList[0])
+		if len(sps) < 4 {
+			return nil, fmt.Errorf("invalid sps")
+		}
+
+		var sets []string
+		for _, data := range append(append([][]byte{}, spsList...), ppsList...) {
+			sets = append(sets, encodeParameterSet(data))
+		}
+
+		fmtp = append(fmtp, "packetization-mode=1", "profile-level-id="+hex.EncodeToString(sps[1:4]), "sprop-parameter-sets="+strings.Join(sets, ","))
+	case utils.AVCodecIdH265:
+		codecData, ok := stream.CodecParameters.(*avformat.HEVCCodecData)
+		if !ok {
+			break
+		}
+
+		for _, set := range []struct {
+			name string
+			list [][]byte
+		}{{"sprop-vps", codecData.VPS()}, {"sprop-sps", codecData.SPS()}, {"sprop-pps", codecData.PPS()}} {
+			var values []string
+			for _, data := range set.list {
+				values = append(values, encodeParameterSet(data))
+			}
+
+			fmtp = append(fmtp, set.name+"="+strings.Join(values, ","))
+		}
+	case utils.AVCodecIdAAC:
+		if len(stream.Data) < 2 {
+			return nil, fmt.Errorf("audio specific config not found")
+		}
+
+		config, err := utils.ParseMpeg4AudioConfig(stream.Data)
+		if err != nil {
+			return nil, err
+		}
+
+		rtpMap.ClockRate = config.SampleRate
+		rtpMap.EncodingParameters = config.Channels
+		fmtp = append(fmtp, "streamtype=5", "profile-level-id=1", "mode=AAC-hbr", "sizelength=13", "indexlength=3", "indexdeltalength=3", "config="+hex.EncodeToString(stream.Data))
+	case utils.AVCodecIdAACLATM:
+		// RFC 6416: cpresent=0时StreamMuxConfig通过config参数传递
+		if len(stream.Data) < 2 {
+			return nil, fmt.Errorf("audio specific config not found")
+		}
+
+		config, err := utils.ParseMpeg4AudioConfig(stream.Data)
+		if err != nil {
+			return nil, err
+		}
+
+		rtpMap.ClockRate = config.SampleRate
+		rtpMap.EncodingParameters = config.Channels
+		muxConfig := utils.NewStreamMuxConfig(stream.Data).Marshal()
+		fmtp = append(fmtp, "profile-level-id="+strconv.Itoa(latmProfileLevel(config)), "cpresent=0", "object="+strconv.Itoa(config.ObjectType), "config="+hex.EncodeToString(muxConfig))
+	case utils.AVCodecIdOPUS:
+		// RFC 7587: 时钟频率固定为48000, 通道数固定为2
+		rtpMap.ClockRate = 48000
+		rtpMap.EncodingParameters = 2
+		if stream.Channels == 2 {
+			fmtp = append(fmtp, "sprop-stereo=1")
+		}
+	case utils.AVCodecIdPCMALAW, utils.AVCodecIdPCMMULAW:
+		rtpMap.ClockRate = 8000
+		if stream.SampleRate > 0 {
+			rtpMap.ClockRate = stream.SampleRate
+		}
+		rtpMap.EncodingParameters = stream.Channels
+	case utils.AVCodecIdADPCMG722:
+		// RFC 3551 4.5.2: 由于历史原因, G722的时钟频率是8000
+		rtpMap.ClockRate = 8000
+		rtpMap.EncodingParameters = stream.Channels
+	case utils.AVCodecIdADPCMG726, utils.AVCodecIdADPCMG726LE:
+		bitRate := stream.BitRate
+		if bitRate == 0 {
+			bitRate = 32000
+		}
+
+		rtpMap.EncodingName = "G726-" + strconv.Itoa(bitRate/1000)
+		if utils.AVCodecIdADPCMG726 == stream.CodecID {
+			rtpMap.EncodingName = "AAL2-" + rtpMap.EncodingName
+		}
+		rtpMap.ClockRate = 8000
+	}
+
+	media.AddAttribute("rtpmap", rtpMap.String())
+	if len(fmtp) > 0 {
+		media.AddAttribute("fmtp", strconv.Itoa(payloadType)+" "+strings.Join(fmtp, ";"))
+	}
+
+	return media, nil
+}
+
+// ParseAVStream 使用媒体描述中第一个支持的payload type创建AVStream, 返回AVStream和对应的payload type
+func ParseAVStream(media *MediaDescription, index int) (*avformat.AVStream, int, error) {
+	for _, payloadType := range media.Formats {
+		rtpMap, ok := media.RTPMap(payloadType)
+		if !ok {
+			continue
+		}
+
+		id, ok := findCodecByEncodingName(rtpMap.EncodingName)
+		if !ok {
+			continue
+		}
+
+		stream, err := newAVStream(id, rtpMap, media.Fmtp(payloadType), index)
+		if err != nil {
+			return nil, payloadType, err
+		}
+
+		stream.MediaType = media.MediaType
+		return stream, payloadType, nil
+	}
+
+	return nil, -1, fmt.Errorf("no supported payload type found in media %s", media.MediaType.String())
+}
+
+func newAVStream(id utils.AVCodecID, rtpMap RTPMap, fmtp map[string]string, index int) (*avformat.AVStream, error) {
+	stream := &avformat.AVStream{
+		Index:    index,
+		CodecID:  id,
+		Timebase: rtpMap.ClockRate,
+	}
+
+	if id < utils.AVCodecIdFIRSTAUDIO {
+		stream.MediaType = utils.AVMediaTypeVideo
+	} else {
+		stream.MediaType = utils.AVMediaTypeAudio
+		stream.SampleRate = rtpMap.ClockRate
+		stream.SampleSize = 16
+		stream.Channels = 1
+		if rtpMap.EncodingParameters > 0 {
+			stream.Channels = rtpMap.EncodingParameters
+		}
+	}
+
+	switch id {
+	case utils.AVCodecIdH264:
+		sets, ok := fmtp["sprop-parameter-sets"]
+		if !ok {
+			break
+		}
+
+		// 保留所有的参数集, 多PPS的摄像头很常见
+		var spsList, ppsList [][]byte
+		for _, value := range strings.Split(sets, ",") {
+			nalu, err := decodeParameterSet(value)
+			if err != nil {
+				return nil, err
+			}
+
+			switch nalu[4] & 0x1F {
+			case avc.H264NalSPS:
+				spsList = append(spsList, nalu)
+			case avc.H264NalPPS:
+				ppsList = append(ppsList, nalu)
+			}
+		}
+
+		if spsList == nil || ppsList == nil {
+			return nil, fmt.Errorf("sps or pps not found in sprop-parameter-sets")
+		}
+
+		codecData, err := avformat.NewAVCCodecDataWithParameterSets(spsList, ppsList)
+		if err != nil {
+			return nil, err
+		}
+
+		stream.CodecParameters = codecData
+		stream.Data = codecData.AnnexBExtraData()
+	case utils.AVCodecIdH265:
+		// 和H264一样保留所有的参数集
+		var sets [3][][]byte
+		for i, name := range []string{"sprop-vps", "sprop-sps", "sprop-pps"} {
+			value, ok := fmtp[name]
+			if !ok {
+				continue
+			}
+
+			for _, v := range strings.Split(value, ",") {
+				nalu, err := decodeParameterSet(v)
+				if err != nil {
+					return nil, err
+				}
+
+				sets[i] = append(sets[i], nalu)
+			}
+		}
+
+		if sets[0] == nil || sets[1] == nil || sets[2] == nil {
+			break
+		}
+
+		codecData, err := avformat.NewHEVCCodecDataWithParameterSets(sets[0], sets[1], sets[2])
+		if err != nil {
+			return nil, err
+		}
+
+		stream.CodecParameters = codecData
+		stream.Data = codecData.AnnexBExtraData()
+	case utils.AVCodecIdAAC:
+		value, ok := fmtp["config"]
+		if !ok {
+			return nil, fmt.Errorf("config not found in mpeg4-generic fmtp")
+		}
+
+		data, err := hex.DecodeString(value)
+		if err != nil {
+			return nil, err
+		} else if len(data) < 2 {
+			return nil, fmt.Errorf("invalid audio specific config: %s", value)
+		}
+
+		config, err := utils.ParseMpeg4AudioConfig(data)
+		if err != nil {
+			return nil, err
+		}
+
+		stream.Data = data
+		stream.SampleRate = config.OutputSampleRate()
+		stream.Channels = config.OutputChannels()
+	case utils.AVCodecIdAACLATM:
+		// cpresent默认为1, StreamMuxConfig在码流中
+		value, ok := fmtp["config"]
+		if !ok {
+			break
+		}
+
+		data, err := hex.DecodeString(value)
+		if err != nil {
+			return nil, err
+		}
+
+		muxConfig, err := utils.ParseStreamMuxConfig(data)
+		if err != nil {
+			return nil, err
+		}
+
+		config, err := utils.ParseMpeg4AudioConfig(muxConfig.AudioSpecificConfig)
+		if err != nil {
+			return nil, err
+		}
+
+		stream.Data = muxConfig.AudioSpecificConfig
+		stream.SampleRate = config.OutputSampleRate()
+		stream.Channels = config.OutputChannels()
+	case utils.AVCodecIdOPUS:
+		// rtpmap固定为opus/48000/2, 实际通道数由sprop-stereo决定
+		stream.Channels = 1
+		if fmtp["sprop-stereo"] == "1" {
+			stream.Channels = 2
+		}
+	case utils.AVCodecIdADPCMG726, utils.AVCodecIdADPCMG726LE:
+		bitRate, err := strconv.Atoi(rtpMap.EncodingName[strings.LastIndex(rtpMap.EncodingName, "-")+1:])
+		if err != nil {
+			return nil, fmt.Errorf("invalid g726 encoding name: %s", rtpMap.EncodingName)
+		}
+
+		stream.BitRate = bitRate * 1000
+	case utils.AVCodecIdADPCMG722:
+		stream.SampleRate = 16000
+	case utils.AVCodecIdPCMALAW, utils.AVCodecIdPCMMULAW:
+		stream.SampleSize = 8
+	case utils.AVCodecIdMP3:
+		// RFC 3551 MPA的时钟频率固定为90000, 采样率从帧头获取
+		stream.SampleRate = 0
+	}
+
+	return stream, nil
+}
@@ -0,0 +1,227 @@
+package sdp
+
+import (
+	"fmt"
+	"github.com/lkmio/avformat/utils"
+	"strconv"
+	"strings"
+)
+
+type Attribute struct {
+	Key   string
+	Value string
+}
+
+// RTPMap a=rtpmap:<payload type> <encoding name>/<clock rate>[/<encoding parameters>]
+type RTPMap struct {
+	PayloadType        int
+	EncodingName       string
+	ClockRate          int
+	EncodingParameters int // 音频通道数
+}
+
+// MediaDescription m=行及其后的属性, 不包含会话级信息(o=/s=/c=由信令层负责)
+type MediaDescription struct {
+	MediaType  utils.AVMediaType
+	Port       int
+	Protocol   string // RTP/AVP, TCP/RTP/AVP...
+	Formats    []int  // payload types
+	Attributes []Attribute
+}
+
+// 没有rtpmap时使用的静态payload type, RFC 3551 Table 4
+var staticPayloadTypes = map[int]RTPMap{
+	0:  {PayloadType: 0, EncodingName: "PCMU", ClockRate: 8000, EncodingParameters: 1},
+	8:  {PayloadType: 8, EncodingName: "PCMA", ClockRate: 8000, EncodingParameters: 1},
+	9:  {PayloadType: 9, EncodingName: "G722", ClockRate: 8000, EncodingParameters: 1},
+	14: {PayloadType: 14, EncodingName: "MPA", ClockRate: 90000},
+}
+
+func (m *MediaDescription) AddAttribute(key, value string) {
+	m.Attributes = append(m.Attributes, Attribute{key, value})
+}
+
+// Attribute 返回第一个匹配的属性值
+func (m *MediaDescription) Attribute(key string) (string, bool) {
+	for _, attribute := range m.Attributes {
+		if attribute.Key == key {
+			return attribute.Value, true
+		}
+	}
+
+	return "", false
+}
+
+// RTPMap 查找payload type对应的rtpmap, 不存在则查找静态payload type
+func (m *MediaDescription) RTPMap(payloadType int) (RTPMap, bool) {
+	for _, attribute := range m.Attributes {
+		if attribute.Key != "rtpmap" {
+			continue
+		}
+
+		rtpMap, err := ParseRTPMap(attribute.Value)
+		if err == nil && rtpMap.PayloadType == payloadType {
+			return rtpMap, true
+		}
+	}
+
+	rtpMap, ok := staticPayloadTypes[payloadType]
+	return rtpMap, ok
+}
+
+// Fmtp 查找payload type对应的fmtp参数, key统一转为小写
+func (m *MediaDescription) Fmtp(payloadType int) map[string]string {
+	prefix := strconv.Itoa(payloadType) + " "
+	for _, attribute := range m.Attributes {
+		if attribute.Key == "fmtp" && strings.HasPrefix(attribute.Value, prefix) {
+			return ParseFmtp(attribute.Value[len(prefix):])
+		}
+	}
+
+	return nil
+}
+
+func (m *MediaDescription) String() string {
+	builder := strings.Builder{}
+	builder.WriteString(fmt.Sprintf("m=%s %d %s", m.MediaType.String(), m.Port, m.Protocol))
+	for _, format := range m.Formats {
+		builder.WriteString(" " + strconv.Itoa(format))
+	}
+	builder.WriteString("\r\n")
+
+	for _, attribute := range m.Attributes {
+		builder.WriteString("a=" + attribute.Key)
+		if attribute.Value != "" {
+			builder.WriteString(":" + attribute.Value)
+		}
+		builder.WriteString("\r\n")
+	}
+
+	return builder.String()
+}
+
+func ParseRTPMap(value string) (RTPMap, error) {
+	rtpMap := RTPMap{}
+	fields := strings.Fields(value)
+	if len(fields) != 2 {
+		return rtpMap, fmt.Errorf("invalid rtpmap: %s", value)
+	}
+
+	var err error
+	if rtpMap.PayloadType, err = strconv.Atoi(fields[0]); err != nil {
+		return rtpMap, err
+	}
+
+	params := strings.Split(fields[1], "/")
+	if len(params) < 2 {
+		return rtpMap, fmt.Errorf("invalid rtpmap: %s", value)
+	}
+
+	rtpMap.EncodingName = params[0]
+	if rtpMap.ClockRate, err = strconv.Atoi(params[1]); err != nil {
+		return rtpMap, err
+	}
+
+	if len(params) > 2 {
+		if rtpMap.EncodingParameters, err = strconv.Atoi(params[2]); err != nil {
+			return rtpMap, err
+		}
+	}
+
+	return rtpMap, nil
+}
+
+func (r RTPMap) String() string {
+	value := fmt.Sprintf("%d %s/%d", r.PayloadType, r.EncodingName, r.ClockRate)
+	if r.EncodingParameters > 1 {
+		value += "/" + strconv.Itoa(r.EncodingParameters)
+	}
+
+	return value
+}
+
+// ParseFmtp 解析fmtp参数部分(不含payload type), 形如"key1=value1;key2=value2"
+func ParseFmtp(value string) map[string]string {
+	params := make(map[string]string)
+	for _, param := range strings.Split(value, ";") {
+		param = strings.TrimSpace(param)
+		if param == "" {
+			continue
+		}
+
+		// sprop-parameter-sets等参数的base64值可能包含'='
+		if index := strings.Index(param, "="); index > 0 {
+			params[strings.ToLower(strings.TrimSpace(param[:index]))] = strings.TrimSpace(param[index+1:])
+		} else {
+			params[strings.ToLower(param)] = ""
+		}
+	}
+
+	return params
+}
+
+func parseMediaType(value string) utils.AVMediaType {
+	switch value {
+	case "video":
+		return utils.AVMediaTypeVideo
+	case "audio":
+		return utils.AVMediaTypeAudio
+	case "application":
+		return utils.AVMediaTypeData
+	default:
+		return utils.AVMediaTypeUnknown
+	}
+}
+
+// Parse 解析SDP中所有的媒体描述, 忽略会话级信息
+func Parse(sdp string) ([]*MediaDescription, error) {
+	var medias []*MediaDescription
+	var media *MediaDescription
+
+	for _, line := range strings.Split(sdp, "\n") {
+		line = strings.TrimRight(line, "\r")
+		if len(line) < 2 || line[1] != '=' {
+			continue
+		}
+
+		value := line[2:]
+		switch line[0] {
+		case 'm':
+			fields := strings.Fields(value)
+			if len(fields) < 3 {
+				return nil, fmt.Errorf("invalid media description: %s", line)
+			}
+
+			media = &MediaDescription{MediaType: parseMediaType(fields[0]), Protocol: fields[2]}
+			// 端口可能是<port>/<number of ports>
+			port, err := strconv.Atoi(strings.Split(fields[1], "/")[0])
+			if err != nil {
+				return nil, fmt.Errorf("invalid media port: %s", line)
+			}
+
+			media.Port = port
+			for _, format := range fields[3:] {
+				payloadType, err := strconv.Atoi(format)
+				if err != nil {
+					return nil, fmt.Errorf("invalid media format: %s", line)
+				}
+
+				media.Formats = append(media.Formats, payloadType)
+			}
+
+			medias = append(medias, media)
+		case 'a':
+			if media == nil {
+				continue
+			}
+
+			if index := strings.Index(value, ":"); index > 0 {
+				media.AddAttribute(value[:index], value[index+1:])
+			} else {
+				media.AddAttribute(value, "")
+			}
+		}
+	}
+
+	return medias, nil
+}
@@ -0,0 +1,212 @@
+package sdp
+
+import (
+	"bytes"
+	"encoding/hex"
+	"github.com/lkmio/avformat"
+	"github.com/lkmio/avformat/utils"
+	"testing"
+)
+
+func TestMediaDescription(t *testing.T) {
+	sps, _ := hex.DecodeString("000000016742c01eda01e0089f961000000300100000030320f162ea")
+	pps, _ := hex.DecodeString("0000000168ce0f2c80")
+	codecData, err := avformat.NewAVCCodecData(sps, pps)
+	if err != nil {
+		panic(err)
+	}
+
+	video := avformat.NewAVStream(utils.AVMediaTypeVideo, 0, utils.AVCodecIdH264, codecData.AnnexBExtraData(), codecData)
+	audio := &avformat.AVStream{MediaType: utils.AVMediaTypeAudio, Index: 1, CodecID: utils.AVCodecIdAAC, Data: []byte{0x12, 0x10}}
+
+	var sdp string
+	for i, stream := range []*avformat.AVStream{video, audio} {
+		media, err := NewMediaDescription(stream, 96+i)
+		if err != nil {
+			panic(err)
+		}
+
+		sdp += media.String()
+	}
+
+	println(sdp)
+	medias, err := Parse("v=0\r\no=- 0 0 IN IP4 127.0.0.1\r\ns=-\r\nt=0 0\r\n" + sdp)
+	if err != nil {
+		panic(err)
+	}
+
+	utils.Assert(len(medias) == 2)
+	stream, payloadType, err := ParseAVStream(medias[0], 0)
+	if err != nil {
+		panic(err)
+	}
+
+	utils.Assert(payloadType == 96)
+	utils.Assert(stream.CodecID == utils.AVCodecIdH264 && stream.MediaType == utils.AVMediaTypeVideo)
+	utils.Assert(bytes.Equal(stream.CodecParameters.SPS()[0], sps))
+	utils.Assert(bytes.Equal(stream.CodecParameters.PPS()[0], pps))
+	utils.Assert(stream.CodecParameters.Width() == 1920 && stream.CodecParameters.Height() == 1080)
+
+	stream, payloadType, err = ParseAVStream(medias[1], 1)
+	if err != nil {
+		panic(err)
+	}
+
+	utils.Assert(payloadType == 97)
+	utils.Assert(stream.CodecID == utils.AVCodecIdAAC && stream.SampleRate == 44100 && stream.Channels == 2)
+	utils.Assert(bytes.Equal(stream.Data, audio.Data))
+}
+
+func TestParseHEVC(t *testing.T) {
+	sdp := "m=video 0 RTP/AVP 98\r\n" +
+		"a=rtpmap:98 H265/90000\r\n" +
+		"a=fmtp:98 sprop-vps=QAEMAf//AWAAAAMAkAAAAwAAAwBdmZgJ; sprop-sps=QgEBAWAAAAMAkAAAAwAAAwBdoAKAgC0WWZmkkyuagICAggAAAwACAAADADIQ; sprop-pps=RAHBcrRiQA==\r\n" +
+		"m=audio 0 RTP/AVP 8\r\n"
+
+	medias, err := Parse(sdp)
+	if err != nil {
+		panic(err)
+	}
+
+	stream, _, err := ParseAVStream(medias[0], 0)
+	if err != nil {
+		panic(err)
+	}
+
+	utils.Assert(stream.CodecID == utils.AVCodecIdH265)
+	utils.Assert(stream.CodecParameters.Width() == 1280 && stream.CodecParameters.Height() == 720)
+
+	stream, payloadType, err := ParseAVStream(medias[1], 1)
+	if err != nil {
+		panic(err)
+	}
+
+	utils.Assert(payloadType == 8 && stream.CodecID == utils.AVCodecIdPCMALAW && stream.SampleRate == 8000)
+}
+
+func TestLATM(t *testing.T) {
+	audio := &avformat.AVStream{MediaType: utils.AVMediaTypeAudio, CodecID: utils.AVCodecIdAACLATM, Data: []byte{0x12, 0x10}}
+	media, err := NewMediaDescription(audio, 96)
+	if err != nil {
+		panic(err)
+	}
+
+	fmtp, _ := media.Attribute("fmtp")
+	utils.Assert(fmtp == "96 profile-level-id=41;cpresent=0;object=2;config=400024203fc0")
+
+	stream, _, err := ParseAVStream(media, 0)
+	if err != nil {
+		panic(err)
+	}
+
+	utils.Assert(stream.CodecID == utils.AVCodecIdAACLATM && stream.SampleRate == 44100 && stream.Channels == 2)
+	utils.Assert(bytes.Equal(stream.Data, audio.Data))
+}
+
+func TestHEAAC(t *testing.T) {
+	// HE-AAC v1和v2的核心采样率为24000, 输出48000双声道
+	for _, config := range []string{"2b118800", "eb098800"} {
+		data, _ := hex.DecodeString(config)
+		for _, codecID := range []utils.AVCodecID{utils.AVCodecIdAAC, utils.AVCodecIdAACLATM} {
+			media, err := NewMediaDescription(&avformat.AVStream{MediaType: utils.AVMediaTypeAudio, CodecID: codecID, Data: data}, 96)
+			if err != nil {
+				panic(err)
+			}
+
+			stream, _, err := ParseAVStream(media, 0)
+			if err != nil {
+				panic(err)
+			}
+
+			utils.Assert(stream.SampleRate == 48000 && stream.Channels == 2)
+			utils.Assert(bytes.Equal(stream.Data, data))
+		}
+	}
+}
+
+func TestStaticAudio(t *testing.T) {
+	for _, test := range []struct {
+		payloadType string
+		codecID     utils.AVCodecID
+		sampleRate  int
+		sampleSize  int
+	}{
+		{"0", utils.AVCodecIdPCMMULAW, 8000, 8},
+		{"8", utils.AVCodecIdPCMALAW, 8000, 8},
+		// MPA的时钟频率为90000, 不是采样率
+		{"14", utils.AVCodecIdMP3, 0, 16},
+	} {
+		medias, err := Parse("v=0\r\no=- 0 0 IN IP4 127.0.0.1\r\ns=-\r\nt=0 0\r\nm=audio 0 RTP/AVP " + test.payloadType + "\r\n")
+		if err != nil {
+			panic(err)
+		}
+
+		stream, _, err := ParseAVStream(medias[0], 0)
+		if err != nil {
+			panic(err)
+		}
+
+		utils.Assert(stream.CodecID == test.codecID && stream.SampleRate == test.sampleRate && stream.SampleSize == test.sampleSize)
+	}
+}
+
+func TestG726(t *testing.T) {
+	// RFC 3551: G726-xx为低位优先打包, AAL2-G726-xx为高位优先打包
+	for _, id := range []utils.AVCodecID{utils.AVCodecIdADPCMG726LE, utils.AVCodecIdADPCMG726} {
+		audio := &avformat.AVStream{MediaType: utils.AVMediaTypeAudio, CodecID: id, AudioConfig: avformat.AudioConfig{BitRate: 24000}}
+		media, err := NewMediaDescription(audio, 97)
+		if err != nil {
+			panic(err)
+		}
+
+		encodingName := "AAL2-G726-24"
+		if utils.AVCodecIdADPCMG726LE == id {
+			encodingName = "G726-24"
+		}
+
+		rtpMap, _ := media.RTPMap(97)
+		utils.Assert(rtpMap.EncodingName == encodingName)
+
+		medias, err := Parse("v=0\r\no=- 0 0 IN IP4 127.0.0.1\r\ns=-\r\nt=0 0\r\n" + media.String())
+		if err != nil {
+			panic(err)
+		}
+
+		stream, _, err := ParseAVStream(medias[0], 0)
+		if err != nil {
+			panic(err)
+		}
+
+		utils.Assert(stream.CodecID == id && stream.BitRate == 24000 && stream.SampleRate == 8000)
+	}
+}
+
+func TestMultiplePPS(t *testing.T) {
+	sps, _ := hex.DecodeString("000000016742c01eda01e0089f961000000300100000030320f162ea")
+	pps0, _ := hex.DecodeString("0000000168ce0f2c80")
+	pps1, _ := hex.DecodeString("00000001685383cb20") // pic_parameter_set_id = 1
+	codecData, err := avformat.NewAVCCodecDataWithParameterSets([][]byte{sps}, [][]byte{pps0, pps1})
+	if err != nil {
+		panic(err)
+	}
+
+	video := avformat.NewAVStream(utils.AVMediaTypeVideo, 0, utils.AVCodecIdH264, codecData.AnnexBExtraData(), codecData)
+	media, err := NewMediaDescription(video, 96)
+	if err != nil {
+		panic(err)
+	}
+
+	medias, err := Parse("v=0\r\no=- 0 0 IN IP4 127.0.0.1\r\ns=-\r\nt=0 0\r\n" + media.String())
+	if err != nil {
+		panic(err)
+	}
+
+	stream, _, err := ParseAVStream(medias[0], 0)
+	if err != nil {
+		panic(err)
+	}
+
+	ppsList := stream.CodecParameters.PPS()
+	utils.Assert(len(ppsList) == 2 && bytes.Equal(ppsList[0], pps0) && bytes.Equal(ppsList[1], pps1))
+	utils.Assert(bytes.Equal(stream.Data, append(append(append([]byte{}, sps...), pps0...), pps1...)))
+}
@@ -0,0 +1,96 @@
+package avformat
+
+const (
+	TimestampBitsMPEG = 33 // ps/ts的pts/dts, 90kHz下约26.5小时回绕一次
+	TimestampBitsRTP  = 32 // rtp timestamp
+	TimestampBitsFLV  = 32 // flv扩展后的毫秒时间戳
+
+	DefaultTimestampJumpThreshold = 10 // 默认跳变阈值, 单位秒
+)
+
+// TimestampNormalizer 单个track的时间戳归一化.
+// 展开回绕后的时间戳, 前后跳变超过阈值时以上一帧的间隔接续, 保证输出的dts单调递增.
+type TimestampNormalizer struct {
+	Threshold int64 // 跳变阈值, 单位为track的timebase
+
+	bits     int // 时间戳有效位数, 0表示不回绕
+	started  bool
+	lastRaw  int64 // 上一帧未处理的dts
+	lastDts  int64 // 上一帧展开后的dts, 未经过单调处理
+	lastOut  int64 // 上一帧实际输出的dts
+	lastStep int64 // 上一次正常的帧间隔
+}
+
+// delta 计算两个时间戳的差值, 按有效位数取模后映射到[-2^(bits-1), 2^(bits-1))
+func (n *TimestampNormalizer) delta(ts, prev int64) int64 {
+	d := ts - prev
+	if n.bits <= 0 || n.bits >= 64 {
+		return d
+	}
+
+	mask := int64(1)<<n.bits - 1
+	d &= mask
+	if d >= int64(1)<<(n.bits-1) {
+		d -= int64(1) << n.bits
+	}
+
+	return d
+}
+
+// Normalize 修改packet的dts和pts. 发生回绕时继续累加, 发生跳变时重新映射并标记Discontinuity.
+func (n *TimestampNormalizer) Normalize(packet *AVPacket) {
+	// pts和dts的差值同样可能跨越回绕点
+	ptsDelta := n.delta(packet.Pts, packet.Dts)
+	if ptsDelta < 0 || ptsDelta > n.Threshold {
+		ptsDelta = 0
+	}
+
+	var dts int64
+	if !n.started {
+		// 第一帧作为时间轴的起点, 后续只累加差值
+		dts = packet.Dts
+		n.lastOut = dts
+		n.started = true
+	} else {
+		d := n.delta(packet.Dts, n.lastRaw)
+		if d > n.Threshold || -d > n.Threshold {
+			// 源重启或跳变, 以上一帧的间隔接续. 还没有正常的帧间隔时使用packet的duration, 至少前进1
+			step := n.lastStep
+			if step <= 0 {
+				step = packet.Duration
+			}
+
+			if step <= 0 {
+				step = 1
+			}
+
+			dts = n.lastOut + step
+			packet.Discontinuity = true
+		} else {
+			dts = n.lastDts + d
+			if d > 0 {
+				n.lastStep = d
+			}
+		}
+	}
+
+	n.lastRaw = packet.Dts
+	n.lastDts = dts
+
+	// dts不允许回退
+	if dts < n.lastOut {
+		dts = n.lastOut
+	}
+
+	n.lastOut = dts
+	packet.Dts = dts
+	packet.Pts = dts + ptsDelta
+}
+
+// NewTimestampNormalizer 创建时间戳归一化器, bits为时间戳的有效位数, 0表示不回绕
+func NewTimestampNormalizer(timebase, bits int) *TimestampNormalizer {
+	return &TimestampNormalizer{
+		Threshold: int64(DefaultTimestampJumpThreshold * timebase),
+		bits:      bits,
+	}
+}
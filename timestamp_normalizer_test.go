@@ -0,0 +1,119 @@
+package avformat
+
+import (
+	"github.com/lkmio/avformat/utils"
+	"testing"
+)
+
+func TestTimestampNormalizer(t *testing.T) {
+	const wrap33 = int64(1) << 33
+	const wrap32 = int64(1) << 32
+
+	type sample struct {
+		dts, pts      int64 // 输入
+		outDts        int64 // 期望输出
+		outPts        int64
+		discontinuity bool
+	}
+
+	tests := []struct {
+		name     string
+		timebase int
+		bits     int
+		samples  []sample
+	}{
+		{"mpeg wrap", 90000, TimestampBitsMPEG, []sample{
+			{wrap33 - 7200, wrap33 - 7200, wrap33 - 7200, wrap33 - 7200, false},
+			{wrap33 - 3600, wrap33 - 3600, wrap33 - 3600, wrap33 - 3600, false},
+			{0, 0, wrap33, wrap33, false},
+			{3600, 3600, wrap33 + 3600, wrap33 + 3600, false},
+		}},
+		{"rtp wrap", 90000, TimestampBitsRTP, []sample{
+			{wrap32 - 3000, wrap32 - 3000, wrap32 - 3000, wrap32 - 3000, false},
+			{0, 0, wrap32, wrap32, false},
+			{3000, 3000, wrap32 + 3000, wrap32 + 3000, false},
+		}},
+		{"flv wrap", 1000, TimestampBitsFLV, []sample{
+			{wrap32 - 40, wrap32 - 40, wrap32 - 40, wrap32 - 40, false},
+			{0, 0, wrap32, wrap32, false},
+		}},
+		// I P B B, pts跨越回绕点, dts未跨越
+		{"b-frames near wrap", 90000, TimestampBitsMPEG, []sample{
+			{wrap33 - 10800, wrap33 - 7200, wrap33 - 10800, wrap33 - 7200, false},
+			{wrap33 - 7200, 3600, wrap33 - 7200, wrap33 + 3600, false},
+			{wrap33 - 3600, wrap33 - 3600, wrap33 - 3600, wrap33 - 3600, false},
+			{0, 0, wrap33, wrap33, false},
+			{3600, 7200, wrap33 + 3600, wrap33 + 7200, false},
+		}},
+		{"forward jump", 90000, TimestampBitsMPEG, []sample{
+			{90000, 93600, 90000, 93600, false},
+			{93600, 97200, 93600, 97200, false},
+			{93600 + 90000*3600, 97200 + 90000*3600, 97200, 100800, true},
+			{97200 + 90000*3600, 97200 + 90000*3600, 100800, 100800, false},
+		}},
+		{"backward restart", 90000, TimestampBitsMPEG, []sample{
+			{900000, 900000, 900000, 900000, false},
+			{903600, 903600, 903600, 903600, false},
+			{0, 0, 907200, 907200, true},
+			{3600, 3600, 910800, 910800, false},
+			// 小幅回退不认为是跳变, 但dts不允许回退
+			{0, 0, 910800, 910800, false},
+			{7200, 7200, 914400, 914400, false},
+		}},
+		{"negative origin", 90000, TimestampBitsMPEG, []sample{
+			{-3600, 0, -3600, 0, false},
+			{0, 3600, 0, 3600, false},
+		}},
+	}
+
+	for _, test := range tests {
+		normalizer := NewTimestampNormalizer(test.timebase, test.bits)
+		var lastDts int64
+		for i, s := range test.samples {
+			packet := &AVPacket{Dts: s.dts, Pts: s.pts}
+			if test.bits > 0 {
+				mask := int64(1)<<test.bits - 1
+				if s.dts >= 0 {
+					packet.Dts &= mask
+				}
+				if s.pts >= 0 {
+					packet.Pts &= mask
+				}
+			}
+
+			normalizer.Normalize(packet)
+			if packet.Dts != s.outDts || packet.Pts != s.outPts || packet.Discontinuity != s.discontinuity {
+				t.Fatalf("%s[%d]: dts=%d pts=%d discontinuity=%t", test.name, i, packet.Dts, packet.Pts, packet.Discontinuity)
+			}
+
+			utils.Assert(i == 0 || packet.Dts >= lastDts)
+			lastDts = packet.Dts
+		}
+	}
+
+	// 第二帧就发生跳变, 没有正常的帧间隔, 使用packet的duration接续
+	normalizer := NewTimestampNormalizer(48000, TimestampBitsMPEG)
+	normalizer.Normalize(&AVPacket{Dts: 48000, Pts: 48000, Duration: 1024})
+	packet := &AVPacket{Dts: 48000 * 3600, Pts: 48000 * 3600, Duration: 1024}
+	normalizer.Normalize(packet)
+	utils.Assert(packet.Dts == 48000+1024 && packet.Discontinuity)
+
+	// 没有duration时至少前进1
+	packet = &AVPacket{Dts: 0, Pts: 0}
+	normalizer.Normalize(packet)
+	utils.Assert(packet.Dts == 48000+1024+1 && packet.Discontinuity)
+}
+
+func TestDemuxerTimestampBits(t *testing.T) {
+	utils.Assert((&BaseDemuxer{Name: "ps"}).GetTimestampBits() == TimestampBitsMPEG)
+	utils.Assert((&BaseDemuxer{Name: "jt1078"}).GetTimestampBits() == 0)
+
+	// rtp源由使用方指定32位回绕
+	demuxer := &BaseDemuxer{Name: "ps", NormalizeTimestamp: true, TimestampBits: TimestampBitsRTP}
+	stream := &AVStream{MediaType: utils.AVMediaTypeVideo, CodecID: utils.AVCodecIdH264, Timebase: 90000}
+	for i, dts := range []int64{1<<32 - 3600, 0, 3600} {
+		packet := &AVPacket{Dts: dts, Pts: dts}
+		demuxer.normalizeTimestamp(stream, packet)
+		utils.Assert(packet.Dts == 1<<32-3600+int64(i)*3600 && !packet.Discontinuity)
+	}
+}
@@ -94,22 +94,6 @@ var (
 	}
 )
 
-type MPEG4AudioConfig struct {
-	ObjectType    int //5bits
-	SamplingIndex int //4bits
-	SampleRate    int
-	ChanConfig    int //4bits
-	Channels      int
-
-	sbr              int ///< -1 implicit, 1 presence
-	extObjectType    int
-	extSamplingIndex int
-	extSampleRate    int
-	extChanConfig    int
-	ps               int ///< -1 implicit, 1 presence
-	frameLengthShort int
-}
-
 type ADtsHeader uint64
 
 func (a ADtsHeader) SyncWord() int {
@@ -244,27 +228,6 @@ func ADtsHeader2MpegAudioConfigData(header ADtsHeader) ([]byte, error) {
 	return bytes, nil
 }
 
-func ParseMpeg4AudioConfig(data []byte) (*MPEG4AudioConfig, error) {
-	//audio specific config
-	config := &MPEG4AudioConfig{}
-	config.ObjectType = int(data[0] >> 3)
-	if AudioObjectType(config.ObjectType) == AotEscape {
-		//config.ObjectType = 32+bits6
-	}
-	config.SamplingIndex = int((data[0] & 7 << 1) | data[1]>>7)
-	config.SampleRate = audioSamplingRates[(config.SamplingIndex)]
-	config.ChanConfig = int(data[1] >> 3 & 0xF)
-	if config.ChanConfig >= len(mpeg4AudioChannels) {
-		return nil, fmt.Errorf("invalid data")
-	}
-
-	config.Channels = mpeg4AudioChannels[config.ChanConfig]
-	return config, nil
-	//config.sbr = -1
-	//config.ps = -1
-	//if config.ObjectType == AotSbr || (config.ObjectType == AotPs && )
-}
-
 func ComputeAACFrameDuration(sampleRate int) float32 {
 	return float32(sampleRate) / float32(DefaultAACFrameLength)
 }
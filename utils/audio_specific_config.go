@@ -0,0 +1,341 @@
+package utils
+
+import (
+	"fmt"
+	"github.com/lkmio/avformat/bufio"
+)
+
+// ISO/IEC 14496-3 1.6.2.1 AudioSpecificConfig
+
+const (
+	syncExtensionTypeSBR = 0x2B7
+	syncExtensionTypePS  = 0x548
+)
+
+type MPEG4AudioConfig struct {
+	ObjectType    int // 5bits, 分层信令(aot 5/29)时为核心编码的类型
+	SamplingIndex int // 4bits
+	SampleRate    int // 核心编码的采样率
+	ChanConfig    int // 4bits
+	Channels      int // ChanConfig为0时从program_config_element统计
+
+	SBR              int // -1隐式信令或未知, 0不存在, 1存在
+	PS               int // -1隐式信令或未知, 0不存在, 1存在
+	ExtObjectType    int // 5-SBR, 22-ER BSAC
+	ExtSamplingIndex int // SBR的输出采样率
+	ExtSampleRate    int
+	ExtChanConfig    int  // 仅ER BSAC
+	SyncExtension    bool // 在GASpecificConfig之后通过syncExtensionType向后兼容地声明SBR/PS, 否则使用分层信令
+
+	// GASpecificConfig
+	FrameLengthFlag    bool // 1-每帧960个采样点, 0-每帧1024个采样点
+	DependsOnCoreCoder bool
+	CoreCoderDelay     int
+	ExtensionFlag      bool
+	LayerNr            int
+	NumOfSubFrame      int
+	LayerLength        int
+	ResilienceFlags    int // aacSectionDataResilienceFlag, aacScalefactorDataResilienceFlag, aacSpectralDataResilienceFlag
+	ExtensionFlag3     bool
+	EpConfig           int
+}
+
+// OutputSampleRate 解码输出的采样率, 隐式信令的SBR无法从配置中确定, 返回核心采样率
+func (c *MPEG4AudioConfig) OutputSampleRate() int {
+	if c.SBR == 1 && c.ExtSampleRate > 0 {
+		return c.ExtSampleRate
+	}
+
+	return c.SampleRate
+}
+
+// OutputChannels 解码输出的通道数, 存在PS时单声道输出为双声道
+func (c *MPEG4AudioConfig) OutputChannels() int {
+	if c.PS == 1 && c.Channels == 1 {
+		return 2
+	}
+
+	return c.Channels
+}
+
+// isGAObjectType 使用GASpecificConfig的audio object type
+func isGAObjectType(aot AudioObjectType) bool {
+	switch aot {
+	case AotAacMain, AotAacLc, AotAacSsr, AotAacLtp, AotAacScalable, AotTwinvq,
+		AotErAacLc, AotErAacLtp, AotErAacScalable, AotErTwinvq, AotErBsac, AotErAacLd:
+		return true
+	}
+
+	return false
+}
+
+func isERObjectType(aot AudioObjectType) bool {
+	return aot >= AotErAacLc && aot <= AotErParam && aot != 18
+}
+
+// readProgramConfigElement 解析program_config_element, 返回通道数. start为AudioSpecificConfig的起始位置
+func (r *latmReader) readProgramConfigElement(start int) int {
+	r.Seek(4 + 2 + 4) // element_instance_tag, object_type, sampling_frequency_index
+	numElements := int(r.Read(4) + r.Read(4) + r.Read(4))
+	numLfe := int(r.Read(2))
+	numAssocData := int(r.Read(3))
+	numValidCc := int(r.Read(4))
+	if r.flag() {
+		r.Seek(4) // mono_mixdown_element_number
+	}
+	if r.flag() {
+		r.Seek(4) // stereo_mixdown_element_number
+	}
+	if r.flag() {
+		r.Seek(3) // matrix_mixdown_idx, pseudo_surround_enable
+	}
+
+	// front, side, back
+	var channels int
+	for i := 0; i < numElements; i++ {
+		if r.flag() {
+			channels += 2
+		} else {
+			channels++
+		}
+
+		r.Seek(4)
+	}
+
+	channels += numLfe
+	r.Seek(numLfe*4 + numAssocData*4 + numValidCc*5)
+
+	// byte_alignment()相对于AudioSpecificConfig的起始位置
+	r.Offset = start + (r.Offset-start+7)&^7
+	r.Seek(int(r.Read(8)) * 8) // comment_field_data
+	return channels
+}
+
+func (c *MPEG4AudioConfig) readGASpecificConfig(r *latmReader, start int) {
+	aot := AudioObjectType(c.ObjectType)
+	c.FrameLengthFlag = r.flag()
+	if c.DependsOnCoreCoder = r.flag(); c.DependsOnCoreCoder {
+		c.CoreCoderDelay = int(r.Read(14))
+	}
+
+	c.ExtensionFlag = r.flag()
+	if c.ChanConfig == 0 {
+		c.Channels = r.readProgramConfigElement(start)
+	}
+
+	if AotAacScalable == aot || AotErAacScalable == aot {
+		c.LayerNr = int(r.Read(3))
+	}
+
+	if c.ExtensionFlag {
+		if AotErBsac == aot {
+			c.NumOfSubFrame = int(r.Read(5))
+			c.LayerLength = int(r.Read(11))
+		}
+
+		if AotErAacLc == aot || AotErAacLtp == aot || AotErAacScalable == aot || AotErAacLd == aot {
+			c.ResilienceFlags = int(r.Read(3))
+		}
+
+		c.ExtensionFlag3 = r.flag()
+	}
+}
+
+// readSyncExtension 解析GASpecificConfig之后向后兼容的SBR/PS信令, end为AudioSpecificConfig的结束位置
+func (c *MPEG4AudioConfig) readSyncExtension(r *latmReader, end int) {
+	if end-r.Offset < 16 || r.Read(11) != syncExtensionTypeSBR {
+		return
+	}
+
+	switch r.readAudioObjectType() {
+	case AotSbr:
+		c.SyncExtension = true
+		c.ExtObjectType = int(AotSbr)
+		if c.SBR = int(r.Read(1)); c.SBR == 1 {
+			c.ExtSamplingIndex, c.ExtSampleRate = r.readSamplingFrequency()
+			if end-r.Offset >= 12 && r.Read(11) == syncExtensionTypePS {
+				c.PS = int(r.Read(1))
+			}
+		}
+	case AotErBsac:
+		c.SyncExtension = true
+		c.ExtObjectType = int(AotErBsac)
+		if c.SBR = int(r.Read(1)); c.SBR == 1 {
+			c.ExtSamplingIndex, c.ExtSampleRate = r.readSamplingFrequency()
+		}
+
+		c.ExtChanConfig = int(r.Read(4))
+	}
+}
+
+// ParseMpeg4AudioConfig 解析AudioSpecificConfig. 不使用GASpecificConfig的类型只解析公共字段.
+func ParseMpeg4AudioConfig(data []byte) (*MPEG4AudioConfig, error) {
+	if len(data) < 2 {
+		return nil, fmt.Errorf("invalid audio specific config length %d", len(data))
+	}
+
+	return (&latmReader{bufio.BitsReader{Data: data}}).readAudioSpecificConfig(len(data) * 8)
+}
+
+// readAudioSpecificConfig 从当前位置解析AudioSpecificConfig. end为结束的bit位置, 小于0表示长度未知, 不解析syncExtension.
+func (r *latmReader) readAudioSpecificConfig(end int) (*MPEG4AudioConfig, error) {
+	start := r.Offset
+	config := &MPEG4AudioConfig{SBR: -1, PS: -1}
+	aot := r.readAudioObjectType()
+	config.SamplingIndex, config.SampleRate = r.readSamplingFrequency()
+	config.ChanConfig = int(r.Read(4))
+	if config.ChanConfig >= len(mpeg4AudioChannels) {
+		return nil, fmt.Errorf("invalid channel configuration %d", config.ChanConfig)
+	}
+
+	config.Channels = mpeg4AudioChannels[config.ChanConfig]
+	// 分层信令, 例如HE-AAC v1/v2
+	if AotSbr == aot || AotPs == aot {
+		config.ExtObjectType = int(AotSbr)
+		config.SBR = 1
+		if AotPs == aot {
+			config.PS = 1
+		}
+
+		config.ExtSamplingIndex, config.ExtSampleRate = r.readSamplingFrequency()
+		if aot = r.readAudioObjectType(); AotErBsac == aot {
+			config.ExtChanConfig = int(r.Read(4))
+		}
+	}
+
+	config.ObjectType = int(aot)
+	if isGAObjectType(aot) {
+		config.readGASpecificConfig(r, start)
+		if isERObjectType(aot) {
+			config.EpConfig = int(r.Read(2))
+		}
+
+		if config.ExtObjectType != int(AotSbr) && !r.overflow() {
+			config.readSyncExtension(r, end)
+		}
+	}
+
+	if r.overflow() || (end >= 0 && r.Offset > end) {
+		return nil, fmt.Errorf("invalid audio specific config")
+	}
+
+	return config, nil
+}
+
+func writeAudioObjectType(writer *bufio.BitsWriter, aot int) {
+	if aot >= int(AotEscape) {
+		writer.Write(5, uint64(AotEscape))
+		writer.Write(6, uint64(aot-32))
+	} else {
+		writer.Write(5, uint64(aot))
+	}
+}
+
+func writeSamplingFrequency(writer *bufio.BitsWriter, index, sampleRate int) {
+	writer.Write(4, uint64(index))
+	if index == 0xF {
+		writer.Write(24, uint64(sampleRate))
+	}
+}
+
+// Marshal 生成AudioSpecificConfig, 只支持GASpecificConfig类型和ChanConfig不为0的配置.
+// SBR为1时, SyncExtension决定使用向后兼容信令还是分层信令.
+func (c *MPEG4AudioConfig) Marshal() ([]byte, error) {
+	aot := AudioObjectType(c.ObjectType)
+	if !isGAObjectType(aot) {
+		return nil, fmt.Errorf("unsupported audio object type %d", aot)
+	} else if c.ChanConfig == 0 {
+		return nil, fmt.Errorf("program config element is not supported")
+	}
+
+	writer := &bufio.BitsWriter{Data: make([]byte, 32)}
+	hierarchical := c.SBR == 1 && !c.SyncExtension
+	if !hierarchical {
+		writeAudioObjectType(writer, c.ObjectType)
+	} else if c.PS == 1 {
+		writeAudioObjectType(writer, int(AotPs))
+	} else {
+		writeAudioObjectType(writer, int(AotSbr))
+	}
+
+	writeSamplingFrequency(writer, c.SamplingIndex, c.SampleRate)
+	writer.Write(4, uint64(c.ChanConfig))
+	if hierarchical {
+		writeSamplingFrequency(writer, c.ExtSamplingIndex, c.ExtSampleRate)
+		writeAudioObjectType(writer, c.ObjectType)
+		if AotErBsac == aot {
+			writer.Write(4, uint64(c.ExtChanConfig))
+		}
+	}
+
+	// GASpecificConfig
+	writer.Write(1, boolToUint64(c.FrameLengthFlag))
+	writer.Write(1, boolToUint64(c.DependsOnCoreCoder))
+	if c.DependsOnCoreCoder {
+		writer.Write(14, uint64(c.CoreCoderDelay))
+	}
+
+	writer.Write(1, boolToUint64(c.ExtensionFlag))
+	if AotAacScalable == aot || AotErAacScalable == aot {
+		writer.Write(3, uint64(c.LayerNr))
+	}
+
+	if c.ExtensionFlag {
+		if AotErBsac == aot {
+			writer.Write(5, uint64(c.NumOfSubFrame))
+			writer.Write(11, uint64(c.LayerLength))
+		}
+
+		if AotErAacLc == aot || AotErAacLtp == aot || AotErAacScalable == aot || AotErAacLd == aot {
+			writer.Write(3, uint64(c.ResilienceFlags))
+		}
+
+		writer.Write(1, boolToUint64(c.ExtensionFlag3))
+	}
+
+	if isERObjectType(aot) {
+		writer.Write(2, uint64(c.EpConfig))
+	}
+
+	if c.SyncExtension {
+		writer.Write(11, syncExtensionTypeSBR)
+		writeAudioObjectType(writer, c.ExtObjectType)
+		writer.Write(1, boolToUint64(c.SBR == 1))
+		if c.SBR == 1 {
+			writeSamplingFrequency(writer, c.ExtSamplingIndex, c.ExtSampleRate)
+		}
+
+		if AotErBsac == AudioObjectType(c.ExtObjectType) {
+			writer.Write(4, uint64(c.ExtChanConfig))
+		} else if c.SBR == 1 && c.PS != -1 {
+			writer.Write(11, syncExtensionTypePS)
+			writer.Write(1, boolToUint64(c.PS == 1))
+		}
+	}
+
+	return writer.Data[:(writer.Offset+7)/8], nil
+}
+
+// NewMPEG4AudioConfig 创建AudioSpecificConfig, 采样率不在采样率表中时使用显式采样率
+func NewMPEG4AudioConfig(objectType AudioObjectType, sampleRate, channels int) (*MPEG4AudioConfig, error) {
+	config := &MPEG4AudioConfig{ObjectType: int(objectType), SamplingIndex: 0xF, SampleRate: sampleRate, Channels: channels, SBR: -1, PS: -1}
+	for i := 0; i < 13; i++ {
+		if audioSamplingRates[i] == sampleRate {
+			config.SamplingIndex = i
+			break
+		}
+	}
+
+	for i := 1; i < len(mpeg4AudioChannels); i++ {
+		if mpeg4AudioChannels[i] == channels {
+			config.ChanConfig = i
+			break
+		}
+	}
+
+	if config.ChanConfig == 0 {
+		return nil, fmt.Errorf("unsupported channels %d", channels)
+	}
+
+	return config, nil
+}
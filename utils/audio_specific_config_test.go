@@ -0,0 +1,105 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/hex"
+	"github.com/lkmio/avformat/bufio"
+	"testing"
+)
+
+func TestMPEG4AudioConfig(t *testing.T) {
+	for _, test := range []struct {
+		asc        string
+		objectType int
+		sampleRate int
+		channels   int
+	}{
+		{"1210", 2, 44100, 2},
+		// HE-AAC v1/v2分层信令
+		{"2b118800", 2, 48000, 2},
+		{"eb098800", 2, 48000, 2},
+		// AAC-LC 22050 + syncExtension SBR 44100 + PS
+		{"139056e5a54880", 2, 44100, 2},
+	} {
+		data, _ := hex.DecodeString(test.asc)
+		config, err := ParseMpeg4AudioConfig(data)
+		if err != nil {
+			panic(err)
+		}
+
+		Assert(config.ObjectType == test.objectType && config.OutputSampleRate() == test.sampleRate && config.OutputChannels() == test.channels)
+
+		marshal, err := config.Marshal()
+		if err != nil {
+			panic(err)
+		}
+
+		Assert(bytes.Equal(marshal, data))
+	}
+
+	data, _ := hex.DecodeString("eb098800")
+	config, _ := ParseMpeg4AudioConfig(data)
+	Assert(config.SBR == 1 && config.PS == 1 && !config.SyncExtension && config.SampleRate == 24000 && config.Channels == 1)
+
+	// 隐式信令无法确定SBR
+	config, _ = ParseMpeg4AudioConfig([]byte{0x13, 0x90})
+	Assert(config.SBR == -1 && config.PS == -1 && config.OutputSampleRate() == 22050)
+
+	// 显式采样率, 960点帧长
+	config, err := NewMPEG4AudioConfig(AotAacLc, 44000, 6)
+	if err != nil {
+		panic(err)
+	}
+
+	config.FrameLengthFlag = true
+	marshal, err := config.Marshal()
+	if err != nil {
+		panic(err)
+	}
+
+	config, err = ParseMpeg4AudioConfig(marshal)
+	if err != nil {
+		panic(err)
+	}
+
+	Assert(config.SamplingIndex == 0xF && config.SampleRate == 44000 && config.ChanConfig == 6 && config.FrameLengthFlag)
+
+	// escape object type(USAC), 只解析公共字段
+	writer := bufio.BitsWriter{Data: make([]byte, 4)}
+	writer.Write(5, uint64(AotEscape))
+	writer.Write(6, uint64(AotUsac-32))
+	writer.Write(4, 3)
+	writer.Write(4, 2)
+	config, err = ParseMpeg4AudioConfig(writer.Data[:(writer.Offset+7)/8])
+	if err != nil {
+		panic(err)
+	}
+
+	Assert(config.ObjectType == int(AotUsac) && config.SampleRate == 48000 && config.Channels == 2)
+
+	// program_config_element, 5.1声道
+	writer = bufio.BitsWriter{Data: make([]byte, 16)}
+	writer.Write(5, uint64(AotAacLc))
+	writer.Write(4, 3)
+	writer.Write(4, 0)
+	writer.Write(3, 0)
+	writer.Write(4+2+4, 0)
+	writer.Write(4, 2) // num_front_channel_elements
+	writer.Write(4, 0)
+	writer.Write(4, 1) // num_back_channel_elements
+	writer.Write(2, 1) // num_lfe_channel_elements
+	writer.Write(3+4, 0)
+	writer.Write(3, 0)
+	writer.Write(5, 0)  // front sce
+	writer.Write(5, 16) // front cpe
+	writer.Write(5, 16) // back cpe
+	writer.Write(4, 0)  // lfe
+	writer.Offset = (writer.Offset + 7) &^ 7
+	writer.Write(8, 0) // comment_field_bytes
+	config, err = ParseMpeg4AudioConfig(writer.Data[:writer.Offset/8])
+	if err != nil {
+		panic(err)
+	}
+
+	Assert(config.ChanConfig == 0 && config.Channels == 6)
+}
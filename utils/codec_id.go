@@ -562,6 +562,8 @@ func (a AVCodecID) String() string {
 		return "G722"
 	case AVCodecIdADPCMG726:
 		return "G726"
+	case AVCodecIdADPCMG726LE:
+		return "G726LE"
 	case AVCodecIdPCMS16LE:
 		return "PCMS16LE"
 	default:
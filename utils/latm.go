@@ -0,0 +1,332 @@
+package utils
+
+import (
+	"fmt"
+	"github.com/lkmio/avformat/bufio"
+)
+
+// ISO/IEC 14496-3 1.7 LATM/LOAS
+
+const (
+	LOASSyncWord   = 0x2B7
+	LOASHeaderSize = 3
+)
+
+// StreamMuxConfig 仅支持单program单layer, frameLengthType为0的配置
+type StreamMuxConfig struct {
+	AudioMuxVersion           int
+	AudioMuxVersionA          int
+	TaraBufferFullness        int
+	AllStreamsSameTimeFraming bool
+	NumSubFrames              int
+	AudioSpecificConfig       []byte // 按字节对齐拷贝的AudioSpecificConfig
+	FrameLengthType           int
+	LatmBufferFullness        int
+	OtherDataPresent          bool
+	OtherDataLenBits          int
+	CrcCheckPresent           bool
+	CrcCheckSum               int
+}
+
+type latmReader struct {
+	bufio.BitsReader
+}
+
+func (r *latmReader) flag() bool {
+	return r.Read(1) == 1
+}
+
+func (r *latmReader) overflow() bool {
+	return r.Offset > len(r.Data)*8
+}
+
+// latmGetValue LatmGetValue()
+func (r *latmReader) latmGetValue() int {
+	bytesForValue := int(r.Read(2))
+	var value int
+	for i := 0; i <= bytesForValue; i++ {
+		value = value<<8 | int(r.Read(8))
+	}
+
+	return value
+}
+
+func (r *latmReader) readAudioObjectType() AudioObjectType {
+	aot := AudioObjectType(r.Read(5))
+	if AotEscape == aot {
+		aot = 32 + AudioObjectType(r.Read(6))
+	}
+
+	return aot
+}
+
+// readSamplingFrequency 返回samplingFrequencyIndex和采样率, 索引为0xF时读取24位的显式采样率
+func (r *latmReader) readSamplingFrequency() (int, int) {
+	index := int(r.Read(4))
+	if index == 0xF {
+		return index, int(r.Read(24))
+	}
+
+	return index, audioSamplingRates[index]
+}
+
+// readLATMAudioSpecificConfig 解析StreamMuxConfig中的AudioSpecificConfig, 只支持使用GASpecificConfig的类型, 否则无法确定长度
+func (r *latmReader) readLATMAudioSpecificConfig(end int) error {
+	config, err := r.readAudioSpecificConfig(end)
+	if err != nil {
+		return err
+	} else if !isGAObjectType(AudioObjectType(config.ObjectType)) {
+		return fmt.Errorf("unsupported audio object type %d", config.ObjectType)
+	} else if config.EpConfig == 2 || config.EpConfig == 3 {
+		return fmt.Errorf("unsupported epConfig %d", config.EpConfig)
+	}
+
+	return nil
+}
+
+// copyBits 拷贝[start, end)之间的bit, 不足一个字节的部分补0
+func copyBits(data []byte, start, end int) []byte {
+	bytes := make([]byte, (end-start+7)/8)
+	writer := bufio.BitsWriter{Data: bytes}
+	reader := bufio.BitsReader{Data: data, Offset: start}
+	for n := end - start; n > 0; n -= 8 {
+		length := bufio.MinInt(8, n)
+		writer.Write(length, reader.Read(length))
+	}
+
+	return bytes
+}
+
+func (r *latmReader) readStreamMuxConfig() (*StreamMuxConfig, error) {
+	config := &StreamMuxConfig{}
+	config.AudioMuxVersion = int(r.Read(1))
+	if config.AudioMuxVersion == 1 {
+		config.AudioMuxVersionA = int(r.Read(1))
+	}
+
+	if config.AudioMuxVersionA != 0 {
+		return nil, fmt.Errorf("unsupported audioMuxVersionA %d", config.AudioMuxVersionA)
+	}
+
+	if config.AudioMuxVersion == 1 {
+		config.TaraBufferFullness = r.latmGetValue()
+	}
+
+	config.AllStreamsSameTimeFraming = r.flag()
+	config.NumSubFrames = int(r.Read(6))
+	if numProgram := r.Read(4); numProgram != 0 {
+		return nil, fmt.Errorf("unsupported numProgram %d", numProgram+1)
+	} else if numLayer := r.Read(3); numLayer != 0 {
+		return nil, fmt.Errorf("unsupported numLayer %d", numLayer+1)
+	}
+
+	// 第一个program的第一个layer, useSameConfig为0
+	if config.AudioMuxVersion == 0 {
+		// 长度未知, 不解析syncExtension
+		start := r.Offset
+		if err := r.readLATMAudioSpecificConfig(-1); err != nil {
+			return nil, err
+		}
+
+		config.AudioSpecificConfig = copyBits(r.Data, start, r.Offset)
+	} else {
+		ascLen := r.latmGetValue()
+		start := r.Offset
+		if err := r.readLATMAudioSpecificConfig(start + ascLen); err != nil {
+			return nil, err
+		} else if r.Offset-start > ascLen {
+			return nil, fmt.Errorf("invalid ascLen %d", ascLen)
+		}
+
+		config.AudioSpecificConfig = copyBits(r.Data, start, r.Offset)
+		// fillBits
+		r.Offset = start + ascLen
+	}
+
+	config.FrameLengthType = int(r.Read(3))
+	if config.FrameLengthType != 0 {
+		return nil, fmt.Errorf("unsupported frameLengthType %d", config.FrameLengthType)
+	}
+
+	config.LatmBufferFullness = int(r.Read(8))
+	config.OtherDataPresent = r.flag()
+	if config.OtherDataPresent {
+		if config.AudioMuxVersion == 1 {
+			config.OtherDataLenBits = r.latmGetValue()
+		} else {
+			for {
+				esc := r.flag()
+				config.OtherDataLenBits = config.OtherDataLenBits<<8 + int(r.Read(8))
+				if !esc || r.overflow() {
+					break
+				}
+			}
+		}
+	}
+
+	config.CrcCheckPresent = r.flag()
+	if config.CrcCheckPresent {
+		config.CrcCheckSum = int(r.Read(8))
+	}
+
+	if r.overflow() {
+		return nil, fmt.Errorf("invalid stream mux config")
+	}
+
+	return config, nil
+}
+
+// ParseStreamMuxConfig 解析StreamMuxConfig, 例如SDP中MP4A-LATM的config参数
+func ParseStreamMuxConfig(data []byte) (*StreamMuxConfig, error) {
+	reader := latmReader{bufio.BitsReader{Data: data}}
+	return reader.readStreamMuxConfig()
+}
+
+// NewStreamMuxConfig 使用AudioSpecificConfig创建单帧的StreamMuxConfig
+func NewStreamMuxConfig(asc []byte) *StreamMuxConfig {
+	return &StreamMuxConfig{
+		AllStreamsSameTimeFraming: true,
+		AudioSpecificConfig:       asc,
+		LatmBufferFullness:        0xFF,
+	}
+}
+
+// Marshal 生成audioMuxVersion为0的StreamMuxConfig
+func (c *StreamMuxConfig) Marshal() []byte {
+	// AudioSpecificConfig不一定按字节对齐, 只写入有效的bit
+	ascBits := len(c.AudioSpecificConfig) * 8
+	reader := latmReader{bufio.BitsReader{Data: c.AudioSpecificConfig}}
+	if reader.readLATMAudioSpecificConfig(-1) == nil {
+		ascBits = reader.Offset
+	}
+
+	bytes := make([]byte, (15+ascBits+13+7)/8)
+	writer := bufio.BitsWriter{Data: bytes}
+	writer.Write(1, 0) // audioMuxVersion
+	writer.Write(1, boolToUint64(c.AllStreamsSameTimeFraming))
+	writer.Write(6, uint64(c.NumSubFrames))
+	writer.Write(4, 0) // numProgram
+	writer.Write(3, 0) // numLayer
+	asc := bufio.BitsReader{Data: c.AudioSpecificConfig}
+	for n := ascBits; n > 0; n -= 8 {
+		length := bufio.MinInt(8, n)
+		writer.Write(length, asc.Read(length))
+	}
+
+	writer.Write(3, 0) // frameLengthType
+	writer.Write(8, uint64(c.LatmBufferFullness))
+	writer.Write(1, 0) // otherDataPresent
+	writer.Write(1, 0) // crcCheckPresent
+	return bytes
+}
+
+func boolToUint64(b bool) uint64 {
+	if b {
+		return 1
+	}
+
+	return 0
+}
+
+// LATMParser 解析AudioMuxElement, useSameStreamMux为1时使用上一次的StreamMuxConfig
+type LATMParser struct {
+	Config *StreamMuxConfig
+}
+
+// NewLATMParser config可以为空, RTP打包(cpresent=0)时使用SDP中的config
+func NewLATMParser(config *StreamMuxConfig) *LATMParser {
+	return &LATMParser{Config: config}
+}
+
+// ParseAudioMuxElement 解析AudioMuxElement, 返回每个子帧的raw AAC数据. 返回的数据都是拷贝.
+func (p *LATMParser) ParseAudioMuxElement(data []byte, muxConfigPresent bool) ([][]byte, error) {
+	reader := latmReader{bufio.BitsReader{Data: data}}
+	if muxConfigPresent && !reader.flag() {
+		config, err := reader.readStreamMuxConfig()
+		if err != nil {
+			return nil, err
+		}
+
+		p.Config = config
+	}
+
+	if p.Config == nil {
+		return nil, fmt.Errorf("stream mux config not found")
+	}
+
+	var payloads [][]byte
+	for i := 0; i <= p.Config.NumSubFrames; i++ {
+		// PayloadLengthInfo
+		var length int
+		for {
+			tmp := int(reader.Read(8))
+			length += tmp
+			if tmp != 255 || reader.overflow() {
+				break
+			}
+		}
+
+		// PayloadMux
+		if reader.Offset+length*8 > len(data)*8 {
+			return nil, fmt.Errorf("invalid payload length %d", length)
+		}
+
+		payloads = append(payloads, copyBits(data, reader.Offset, reader.Offset+length*8))
+		reader.Seek(length * 8)
+	}
+
+	return payloads, nil
+}
+
+// ReadLOASHeader 读取AudioSyncStream的头, 返回audioMuxLengthBytes
+func ReadLOASHeader(data []byte) (int, error) {
+	if len(data) < LOASHeaderSize {
+		return 0, fmt.Errorf("need more data")
+	} else if int(data[0])<<3|int(data[1])>>5 != LOASSyncWord {
+		return 0, fmt.Errorf("not find loas syncword")
+	}
+
+	return int(data[1]&0x1F)<<8 | int(data[2]), nil
+}
+
+// SplitLOAS 拆分AudioSyncStream, 回调不包含同步头的AudioMuxElement, 回调返回false停止
+func SplitLOAS(data []byte, cb func(element []byte) bool) error {
+	for len(data) > 0 {
+		length, err := ReadLOASHeader(data)
+		if err != nil {
+			return err
+		} else if LOASHeaderSize+length > len(data) {
+			return fmt.Errorf("invalid audioMuxLengthBytes %d", length)
+		}
+
+		if !cb(data[LOASHeaderSize : LOASHeaderSize+length]) {
+			break
+		}
+
+		data = data[LOASHeaderSize+length:]
+	}
+
+	return nil
+}
+
+// ParseLOASStreamMuxConfig 从AudioSyncStream中找到第一个StreamMuxConfig
+func ParseLOASStreamMuxConfig(data []byte) (*StreamMuxConfig, error) {
+	var config *StreamMuxConfig
+	err := SplitLOAS(data, func(element []byte) bool {
+		reader := latmReader{bufio.BitsReader{Data: element}}
+		if reader.flag() {
+			return true
+		}
+
+		config, _ = reader.readStreamMuxConfig()
+		return config == nil
+	})
+
+	if err != nil {
+		return nil, err
+	} else if config == nil {
+		return nil, fmt.Errorf("stream mux config not found")
+	}
+
+	return config, nil
+}
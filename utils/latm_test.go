@@ -0,0 +1,123 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/hex"
+	"github.com/lkmio/avformat/bufio"
+	"testing"
+)
+
+// newLOASFrame 生成包含一个子帧的AudioSyncStream
+func newLOASFrame(muxConfig []byte, muxConfigBits int, payload []byte) []byte {
+	element := make([]byte, 64+len(payload))
+	writer := bufio.BitsWriter{Data: element}
+	if muxConfig != nil {
+		writer.Write(1, 0) // useSameStreamMux
+		reader := bufio.BitsReader{Data: muxConfig}
+		for n := muxConfigBits; n > 0; n -= 8 {
+			length := bufio.MinInt(8, n)
+			writer.Write(length, reader.Read(length))
+		}
+	} else {
+		writer.Write(1, 1)
+	}
+
+	length := len(payload)
+	for ; length >= 255; length -= 255 {
+		writer.Write(8, 255)
+	}
+
+	writer.Write(8, uint64(length))
+	for _, b := range payload {
+		writer.Write(8, uint64(b))
+	}
+
+	size := (writer.Offset + 7) / 8
+	frame := make([]byte, LOASHeaderSize+size)
+	header := bufio.BitsWriter{Data: frame}
+	header.Write(11, LOASSyncWord)
+	header.Write(13, uint64(size))
+	copy(frame[LOASHeaderSize:], element[:size])
+	return frame
+}
+
+func TestLATM(t *testing.T) {
+	// 44100Hz双声道AAC-LC, 常见的SDP config
+	data, _ := hex.DecodeString("400024203fc0")
+	config, err := ParseStreamMuxConfig(data)
+	if err != nil {
+		panic(err)
+	}
+
+	Assert(bytes.Equal(config.AudioSpecificConfig, []byte{0x12, 0x10}))
+	Assert(config.AllStreamsSameTimeFraming && config.NumSubFrames == 0 && config.LatmBufferFullness == 0xFF)
+	Assert(bytes.Equal(NewStreamMuxConfig([]byte{0x12, 0x10}).Marshal(), data))
+
+	payload1 := bytes.Repeat([]byte{0x21, 0x43}, 200)
+	payload2 := []byte{0x01, 0x02, 0x03}
+	// StreamMuxConfig有效长度为44bit, 负载不按字节对齐
+	stream := append(newLOASFrame(data, 44, payload1), newLOASFrame(nil, 0, payload2)...)
+
+	loasConfig, err := ParseLOASStreamMuxConfig(stream)
+	if err != nil {
+		panic(err)
+	}
+
+	Assert(bytes.Equal(loasConfig.AudioSpecificConfig, config.AudioSpecificConfig))
+
+	parser := NewLATMParser(nil)
+	var payloads [][]byte
+	err = SplitLOAS(stream, func(element []byte) bool {
+		frames, err := parser.ParseAudioMuxElement(element, true)
+		if err != nil {
+			panic(err)
+		}
+
+		payloads = append(payloads, frames...)
+		return true
+	})
+
+	if err != nil {
+		panic(err)
+	}
+
+	Assert(len(payloads) == 2)
+	Assert(bytes.Equal(payloads[0], payload1) && bytes.Equal(payloads[1], payload2))
+
+	// 没有StreamMuxConfig时无法解析
+	_, err = NewLATMParser(nil).ParseAudioMuxElement(newLOASFrame(nil, 0, payload2)[LOASHeaderSize:], true)
+	Assert(err != nil)
+}
+
+func TestLATMProgramConfigElement(t *testing.T) {
+	// ChanConfig为0, 通道数由program_config_element给出. LATM中AudioSpecificConfig不按字节对齐, byte_alignment相对于AudioSpecificConfig的起始位置
+	asc := make([]byte, 8)
+	writer := bufio.BitsWriter{Data: asc}
+	writer.Write(5, uint64(AotAacLc))
+	writer.Write(4, 4) // 44100
+	writer.Write(4, 0) // channelConfiguration
+	writer.Write(3, 0) // frameLengthFlag, dependsOnCoreCoder, extensionFlag
+	writer.Write(4, 0) // element_instance_tag
+	writer.Write(2, 1) // object_type
+	writer.Write(4, 4) // sampling_frequency_index
+	writer.Write(12, 0x100)
+	writer.Write(9, 0)    // num_lfe_channel_elements, num_assoc_data_elements, num_valid_cc_elements
+	writer.Write(3, 0)    // mono_mixdown_present, stereo_mixdown_present, matrix_mixdown_idx_present
+	writer.Write(5, 0x10) // front_element_is_cpe, front_element_tag_select
+	writer.Write(1, 0)    // byte_alignment
+	writer.Write(8, 0)    // comment_field_bytes
+
+	audioConfig, err := ParseMpeg4AudioConfig(asc)
+	if err != nil {
+		panic(err)
+	}
+
+	Assert(audioConfig.ChanConfig == 0 && audioConfig.Channels == 2)
+
+	config, err := ParseStreamMuxConfig(NewStreamMuxConfig(asc).Marshal())
+	if err != nil {
+		panic(err)
+	}
+
+	Assert(bytes.Equal(config.AudioSpecificConfig, asc))
+}
@@ -3,9 +3,14 @@ package avformat
 import (
 	"encoding/hex"
 	"fmt"
+	"github.com/lkmio/avformat/av1"
 	"github.com/lkmio/avformat/avc"
 	"github.com/lkmio/avformat/hevc"
+	"github.com/lkmio/avformat/opus"
 	"github.com/lkmio/avformat/utils"
+	"github.com/lkmio/avformat/vp8"
+	"github.com/lkmio/avformat/vp9"
+	"github.com/lkmio/avformat/vvc"
 )
 
 // CreateHevcStreamFromKeyFrame 从关键帧中提取sps和pps创建AVStream
@@ -55,6 +60,37 @@ func ExtractVideoExtraDataFromKeyFrame(codec utils.AVCodecID, data []byte) ([]by
 		}
 
 		return append(append(vps, sps...), pps...), nil
+	} else if utils.AVCodecIdH266 == codec {
+		vps, sps, pps, err := vvc.ParseExtraDataFromKeyNALU(data)
+		if err != nil {
+			fmt.Printf("从关键帧中解析vps sps pps失败  data:%s \r\n", hex.EncodeToString(data))
+			return nil, err
+		}
+
+		return append(append(vps, sps...), pps...), nil
+	} else if utils.AVCodecIdAV1 == codec {
+		return av1.ExtractSequenceHeader(data)
+	} else if utils.AVCodecIdVP8 == codec {
+		// VP8/VP9没有参数集, 使用关键帧的头部作为编码器信息
+		if !vp8.IsKeyFrame(data) {
+			return nil, fmt.Errorf("not a vp8 key frame")
+		}
+
+		return data[:vp8.KeyFrameHeaderSize], nil
+	} else if utils.AVCodecIdVP9 == codec {
+		frames, err := vp9.SplitSuperframe(data)
+		if err != nil {
+			return nil, err
+		}
+
+		header, err := vp9.ParseFrameHeader(frames[0])
+		if err != nil {
+			return nil, err
+		} else if !header.KeyFrame {
+			return nil, fmt.Errorf("not a vp9 key frame")
+		}
+
+		return frames[0][:header.Size], nil
 	}
 
 	return nil, nil
@@ -94,8 +130,41 @@ func ExtractAudioExtraData(codec utils.AVCodecID, data []byte) ([]byte, int, Aud
 			Channels:      header.Channel(),
 			HasADTSHeader: true,
 		}, nil
+	} else if utils.AVCodecIdAACLATM == codec {
+		// LOAS封装, 从StreamMuxConfig中获取AudioSpecificConfig
+		config, err := utils.ParseLOASStreamMuxConfig(data)
+		if err != nil {
+			return nil, -1, AudioConfig{}, err
+		}
+
+		m4ac, err := utils.ParseMpeg4AudioConfig(config.AudioSpecificConfig)
+		if err != nil {
+			return nil, -1, AudioConfig{}, err
+		}
+
+		return config.AudioSpecificConfig, 0, AudioConfig{
+			SampleRate: m4ac.OutputSampleRate(),
+			SampleSize: 16,
+			Channels:   m4ac.OutputChannels(),
+		}, nil
 	} else if utils.AVCodecIdPCMALAW == codec || utils.AVCodecIdPCMMULAW == codec {
 
+	} else if utils.AVCodecIdOPUS == codec {
+		// 没有OpusHead, 通道数从TOC的stereo标记获取
+		if len(data) < 1 {
+			return nil, -1, AudioConfig{}, fmt.Errorf("need more data")
+		}
+
+		channels := 1
+		if opus.ParseTOC(data[0]).Stereo {
+			channels = 2
+		}
+
+		return nil, 0, AudioConfig{
+			SampleRate: opus.SampleRate,
+			SampleSize: 16,
+			Channels:   channels,
+		}, nil
 	}
 
 	return nil, 0, AudioConfig{
@@ -186,6 +255,12 @@ func generateVideoCodecData(packType PacketType, id utils.AVCodecID, data []byte
 			return ParseAVCDecoderConfigurationRecord(data)
 		case utils.AVCodecIdH265:
 			return ParseHEVCDecoderConfigurationRecord(data)
+		case utils.AVCodecIdH266:
+			return ParseVVCDecoderConfigurationRecord(data)
+		case utils.AVCodecIdAV1:
+			return ParseAV1CodecConfigurationRecord(data)
+		case utils.AVCodecIdVP8, utils.AVCodecIdVP9:
+			return ParseVPCodecConfigurationRecord(id, data)
 		}
 	} else {
 		switch id {
@@ -201,6 +276,27 @@ func generateVideoCodecData(packType PacketType, id utils.AVCodecID, data []byte
 				return nil, err
 			}
 			return NewHEVCCodecData(vps, sps, pps)
+		case utils.AVCodecIdH266:
+			vps, sps, pps, err := vvc.ParseExtraDataFromKeyNALU(data)
+			if err != nil {
+				return nil, err
+			}
+			return NewVVCCodecData(vps, sps, pps)
+		case utils.AVCodecIdAV1:
+			sequenceHeader, err := av1.ExtractSequenceHeader(data)
+			if err != nil {
+				return nil, err
+			}
+			return NewAV1CodecData(sequenceHeader)
+		case utils.AVCodecIdVP8:
+			// 没有关键帧时不创建CodecData
+			if len(data) > 0 {
+				return NewVP8CodecData(data)
+			}
+		case utils.AVCodecIdVP9:
+			if len(data) > 0 {
+				return NewVP9CodecData(data)
+			}
 		}
 	}
 
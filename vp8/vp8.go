@@ -0,0 +1,74 @@
+package vp8
+
+import (
+	"fmt"
+)
+
+/*
+RFC 6386 9.1 Uncompressed Data Chunk
+
+frame tag(3 bytes, little endian):
+	key_frame	1 bit, 0表示关键帧
+	version	3 bits
+	show_frame	1 bit
+	first_part_size	19 bits
+
+关键帧还包含7字节:
+	start code	3 bytes, 0x9d 0x01 0x2a
+	horizontal_scale << 14 | width	2 bytes, little endian
+	vertical_scale << 14 | height	2 bytes, little endian
+*/
+
+const (
+	FrameTagSize       = 3
+	KeyFrameHeaderSize = 10
+	startCode0         = 0x9D
+	startCode1         = 0x01
+	startCode2         = 0x2A
+)
+
+type FrameHeader struct {
+	KeyFrame        bool
+	Version         int // 对应profile 0-3
+	ShowFrame       bool
+	FirstPartSize   int
+	Width           int
+	Height          int
+	HorizontalScale int
+	VerticalScale   int
+}
+
+// ParseFrameHeader 解析frame tag, 关键帧继续解析宽高
+func ParseFrameHeader(data []byte) (FrameHeader, error) {
+	header := FrameHeader{}
+	if len(data) < FrameTagSize {
+		return header, fmt.Errorf("need more data")
+	}
+
+	tag := uint32(data[0]) | uint32(data[1])<<8 | uint32(data[2])<<16
+	header.KeyFrame = tag&0x1 == 0
+	header.Version = int(tag >> 1 & 0x7)
+	header.ShowFrame = tag>>4&0x1 == 1
+	header.FirstPartSize = int(tag >> 5)
+	if header.Version > 3 {
+		return header, fmt.Errorf("invalid vp8 version %d", header.Version)
+	} else if !header.KeyFrame {
+		return header, nil
+	} else if len(data) < KeyFrameHeaderSize {
+		return header, fmt.Errorf("need more data")
+	} else if data[3] != startCode0 || data[4] != startCode1 || data[5] != startCode2 {
+		return header, fmt.Errorf("invalid vp8 start code %x", data[3:6])
+	}
+
+	header.Width = int(data[6]) | int(data[7]&0x3F)<<8
+	header.HorizontalScale = int(data[7] >> 6)
+	header.Height = int(data[8]) | int(data[9]&0x3F)<<8
+	header.VerticalScale = int(data[9] >> 6)
+	return header, nil
+}
+
+// IsKeyFrame 判断是否是关键帧, 校验关键帧的start code
+func IsKeyFrame(data []byte) bool {
+	header, err := ParseFrameHeader(data)
+	return err == nil && header.KeyFrame
+}
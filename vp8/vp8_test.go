@@ -0,0 +1,29 @@
+package vp8
+
+import (
+	"github.com/lkmio/avformat/utils"
+	"testing"
+)
+
+func TestParseFrameHeader(t *testing.T) {
+	// version 0, show_frame, first_part_size 0x1234, 640x480
+	tag := uint32(0x1234)<<5 | 1<<4
+	keyFrame := []byte{byte(tag), byte(tag >> 8), byte(tag >> 16), 0x9D, 0x01, 0x2A, 0x80, 0x02, 0xE0, 0x01, 0xFF}
+	header, err := ParseFrameHeader(keyFrame)
+	if err != nil {
+		panic(err)
+	}
+
+	utils.Assert(header.KeyFrame && header.ShowFrame && header.Version == 0 && header.FirstPartSize == 0x1234)
+	utils.Assert(header.Width == 640 && header.Height == 480)
+	utils.Assert(IsKeyFrame(keyFrame))
+
+	// 帧间帧
+	interFrame := append([]byte{}, keyFrame...)
+	interFrame[0] |= 0x1
+	utils.Assert(!IsKeyFrame(interFrame))
+
+	// 错误的start code
+	keyFrame[4] = 0
+	utils.Assert(!IsKeyFrame(keyFrame))
+}
@@ -0,0 +1,154 @@
+package vp9
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+/*
+VP Codec ISO Media File Format Binding, VP8和VP9共用
+
+aligned (8) class VPCodecConfigurationRecord {
+	unsigned int (8) profile;
+	unsigned int (8) level;
+	unsigned int (4) bitDepth;
+	unsigned int (3) chromaSubsampling;
+	unsigned int (1) videoFullRangeFlag;
+	unsigned int (8) colourPrimaries;
+	unsigned int (8) transferCharacteristics;
+	unsigned int (8) matrixCoefficients;
+	unsigned int (16) codecIntializationDataSize;
+	unsigned int (8)[] codecIntializationData;
+}
+
+vpcC是FullBox, Marshal/Unmarshal的数据包含version和flags.
+*/
+
+const (
+	ChromaSubsampling420Vertical   = 0
+	ChromaSubsampling420Collocated = 1
+	ChromaSubsampling422           = 2
+	ChromaSubsampling444           = 3
+
+	vpcCVersion = 1
+)
+
+// 按图像大小选择的level, Annex A Levels
+var levels = []struct {
+	level          byte
+	maxPictureSize int
+}{
+	{10, 36864},
+	{11, 73728},
+	{20, 122880},
+	{21, 245760},
+	{30, 552960},
+	{31, 983040},
+	{40, 2228224},
+	{50, 8912896},
+	{60, 35651584},
+}
+
+type VPCodecConfigurationRecord struct {
+	Profile                 byte
+	Level                   byte
+	BitDepth                byte
+	ChromaSubsampling       byte
+	VideoFullRangeFlag      byte
+	ColourPrimaries         byte
+	TransferCharacteristics byte
+	MatrixCoefficients      byte
+	CodecInitializationData []byte
+}
+
+func (v *VPCodecConfigurationRecord) Marshal() []byte {
+	data := make([]byte, 12+len(v.CodecInitializationData))
+	data[0] = vpcCVersion
+	data[4] = v.Profile
+	data[5] = v.Level
+	data[6] = v.BitDepth<<4 | (v.ChromaSubsampling&0x7)<<1 | v.VideoFullRangeFlag&0x1
+	data[7] = v.ColourPrimaries
+	data[8] = v.TransferCharacteristics
+	data[9] = v.MatrixCoefficients
+	binary.BigEndian.PutUint16(data[10:], uint16(len(v.CodecInitializationData)))
+	copy(data[12:], v.CodecInitializationData)
+	return data
+}
+
+func (v *VPCodecConfigurationRecord) Unmarshal(data []byte) error {
+	if len(data) < 12 {
+		return fmt.Errorf("invalid data length %d", len(data))
+	} else if data[0] != vpcCVersion {
+		return fmt.Errorf("unsupported vpcC version %d", data[0])
+	}
+
+	v.Profile = data[4]
+	v.Level = data[5]
+	v.BitDepth = data[6] >> 4
+	v.ChromaSubsampling = data[6] >> 1 & 0x7
+	v.VideoFullRangeFlag = data[6] & 0x1
+	v.ColourPrimaries = data[7]
+	v.TransferCharacteristics = data[8]
+	v.MatrixCoefficients = data[9]
+
+	size := int(binary.BigEndian.Uint16(data[10:]))
+	if len(data)-12 < size {
+		return fmt.Errorf("invalid codec initialization data size %d", size)
+	}
+
+	v.CodecInitializationData = data[12 : 12+size]
+	return nil
+}
+
+// Level 根据图像大小估算level, 码流中不包含level
+func Level(width, height int) byte {
+	for _, l := range levels {
+		if width*height <= l.maxPictureSize {
+			return l.level
+		}
+	}
+
+	return 62
+}
+
+// colorDescription 将color_space映射为ISO/IEC 23091-2的colour_primaries/transfer_characteristics/matrix_coefficients
+func colorDescription(colorSpace ColorSpace) (byte, byte, byte) {
+	switch colorSpace {
+	case ColorSpaceBT601, ColorSpaceSMPTE170:
+		return 6, 6, 6
+	case ColorSpaceBT709:
+		return 1, 1, 1
+	case ColorSpaceSMPTE240:
+		return 7, 7, 7
+	case ColorSpaceBT2020:
+		return 9, 14, 9
+	case ColorSpaceRGB:
+		return 2, 2, 0
+	default:
+		return 2, 2, 2
+	}
+}
+
+func chromaSubsampling(subsamplingX, subsamplingY int) byte {
+	if subsamplingX == 1 && subsamplingY == 1 {
+		return ChromaSubsampling420Vertical
+	} else if subsamplingX == 1 {
+		return ChromaSubsampling422
+	}
+
+	return ChromaSubsampling444
+}
+
+// NewVPCodecConfigurationRecord 根据关键帧的frame header创建vpcC
+func NewVPCodecConfigurationRecord(header *FrameHeader) *VPCodecConfigurationRecord {
+	record := &VPCodecConfigurationRecord{
+		Profile:            byte(header.Profile),
+		Level:              Level(header.Width, header.Height),
+		BitDepth:           byte(header.BitDepth),
+		ChromaSubsampling:  chromaSubsampling(header.SubsamplingX, header.SubsamplingY),
+		VideoFullRangeFlag: byte(header.ColorRange),
+	}
+
+	record.ColourPrimaries, record.TransferCharacteristics, record.MatrixCoefficients = colorDescription(header.ColorSpace)
+	return record
+}
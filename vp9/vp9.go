@@ -0,0 +1,236 @@
+package vp9
+
+import (
+	"fmt"
+	"github.com/lkmio/avformat/bufio"
+)
+
+type ColorSpace int
+
+// VP9 Bitstream Specification 7.2.2 Color config semantics
+const (
+	ColorSpaceUnknown  = ColorSpace(0)
+	ColorSpaceBT601    = ColorSpace(1)
+	ColorSpaceBT709    = ColorSpace(2)
+	ColorSpaceSMPTE170 = ColorSpace(3)
+	ColorSpaceSMPTE240 = ColorSpace(4)
+	ColorSpaceBT2020   = ColorSpace(5)
+	ColorSpaceReserved = ColorSpace(6)
+	ColorSpaceRGB      = ColorSpace(7)
+)
+
+const (
+	FrameMarker = 2
+	SyncCode    = 0x498342
+)
+
+/*
+uncompressed_header( ) {
+	frame_marker	f(2)
+	profile_low_bit	f(1)
+	profile_high_bit	f(1)
+	Profile = (profile_high_bit << 1) + profile_low_bit
+	if ( Profile == 3 )
+		reserved_zero	f(1)
+	show_existing_frame	f(1)
+	if ( show_existing_frame == 1 ) {
+		frame_to_show_map_idx	f(3)
+		...
+		return
+	}
+	frame_type	f(1)
+	show_frame	f(1)
+	error_resilient_mode	f(1)
+	if ( frame_type == KEY_FRAME ) {
+		frame_sync_code( )
+		color_config( )
+		frame_size( )
+		render_size( )
+	} else {
+		if ( show_frame == 0 )
+			intra_only	f(1)
+		if ( error_resilient_mode == 0 )
+			reset_frame_context	f(2)
+		if ( intra_only == 1 ) {
+			frame_sync_code( )
+			if ( Profile > 0 ) {
+				color_config( )
+			}
+			refresh_frame_flags	f(8)
+			frame_size( )
+			render_size( )
+		}
+		...
+	}
+	...
+}
+*/
+
+type FrameHeader struct {
+	Profile            int
+	ShowExistingFrame  bool
+	KeyFrame           bool
+	ShowFrame          bool
+	ErrorResilientMode bool
+	IntraOnly          bool
+
+	// 关键帧和intra only帧才有以下字段
+	BitDepth     int
+	ColorSpace   ColorSpace
+	ColorRange   int
+	SubsamplingX int
+	SubsamplingY int
+	Width        int
+	Height       int
+
+	Size int // 已解析的头部字节数
+}
+
+// ParseFrameHeader 解析uncompressed header, 解析到frame_size()为止
+func ParseFrameHeader(data []byte) (FrameHeader, error) {
+	header := FrameHeader{}
+	if len(data) < 1 {
+		return header, fmt.Errorf("need more data")
+	}
+
+	reader := &bufio.BitsReader{Data: data}
+	if marker := reader.Read(2); marker != FrameMarker {
+		return header, fmt.Errorf("invalid vp9 frame marker %d", marker)
+	}
+
+	profileLowBit := reader.Read(1)
+	header.Profile = int(reader.Read(1)<<1 | profileLowBit)
+	if header.Profile == 3 {
+		reader.Seek(1)
+	}
+
+	if header.ShowExistingFrame = reader.Read(1) == 1; header.ShowExistingFrame {
+		header.ShowFrame = true
+		header.Size = (reader.Offset + 3 + 7) / 8
+		return header, nil
+	}
+
+	header.KeyFrame = reader.Read(1) == 0
+	header.ShowFrame = reader.Read(1) == 1
+	header.ErrorResilientMode = reader.Read(1) == 1
+	if !header.KeyFrame {
+		if !header.ShowFrame {
+			header.IntraOnly = reader.Read(1) == 1
+		}
+
+		if !header.ErrorResilientMode {
+			// reset_frame_context
+			reader.Seek(2)
+		}
+
+		if !header.IntraOnly {
+			header.Size = (reader.Offset + 7) / 8
+			return header, nil
+		}
+	}
+
+	if code := reader.Read(24); code != SyncCode {
+		return header, fmt.Errorf("invalid vp9 sync code %x", code)
+	}
+
+	if header.KeyFrame || header.Profile > 0 {
+		parseColorConfig(reader, &header)
+	} else {
+		// intra only帧在profile 0时使用默认值
+		header.BitDepth = 8
+		header.ColorSpace = ColorSpaceBT601
+		header.SubsamplingX, header.SubsamplingY = 1, 1
+	}
+
+	if header.IntraOnly {
+		// refresh_frame_flags
+		reader.Seek(8)
+	}
+
+	header.Width = int(reader.Read(16)) + 1
+	header.Height = int(reader.Read(16)) + 1
+	if reader.Offset > len(data)*8 {
+		return header, fmt.Errorf("need more data")
+	}
+
+	header.Size = (reader.Offset + 7) / 8
+	return header, nil
+}
+
+// parseColorConfig 6.2.2 Color config syntax
+func parseColorConfig(reader *bufio.BitsReader, header *FrameHeader) {
+	header.BitDepth = 8
+	if header.Profile >= 2 {
+		if reader.Read(1) == 1 {
+			header.BitDepth = 12
+		} else {
+			header.BitDepth = 10
+		}
+	}
+
+	header.ColorSpace = ColorSpace(reader.Read(3))
+	if header.ColorSpace != ColorSpaceRGB {
+		header.ColorRange = int(reader.Read(1))
+		if header.Profile == 1 || header.Profile == 3 {
+			header.SubsamplingX = int(reader.Read(1))
+			header.SubsamplingY = int(reader.Read(1))
+			reader.Seek(1)
+		} else {
+			header.SubsamplingX, header.SubsamplingY = 1, 1
+		}
+	} else {
+		header.ColorRange = 1
+		if header.Profile == 1 || header.Profile == 3 {
+			reader.Seek(1)
+		}
+	}
+}
+
+// SplitSuperframe 拆分superframe, 不是superframe则返回整帧. Annex B Superframes
+func SplitSuperframe(data []byte) ([][]byte, error) {
+	if len(data) < 1 {
+		return nil, fmt.Errorf("need more data")
+	}
+
+	marker := data[len(data)-1]
+	if marker&0xE0 != 0xC0 {
+		return [][]byte{data}, nil
+	}
+
+	bytesPerFrameSize := int(marker>>3&0x3) + 1
+	frames := int(marker&0x7) + 1
+	indexSize := 2 + bytesPerFrameSize*frames
+	if len(data) < indexSize || data[len(data)-indexSize] != marker {
+		return [][]byte{data}, nil
+	}
+
+	var result [][]byte
+	index := data[len(data)-indexSize+1:]
+	var offset int
+	for i := 0; i < frames; i++ {
+		var size int
+		for j := 0; j < bytesPerFrameSize; j++ {
+			size |= int(index[i*bytesPerFrameSize+j]) << (j * 8)
+		}
+
+		if offset+size > len(data)-indexSize {
+			return nil, fmt.Errorf("invalid superframe size %d", size)
+		}
+
+		result = append(result, data[offset:offset+size])
+		offset += size
+	}
+
+	return result, nil
+}
+
+// IsKeyFrame 判断是否是关键帧, superframe使用第一帧判断
+func IsKeyFrame(data []byte) bool {
+	frames, err := SplitSuperframe(data)
+	if err != nil {
+		return false
+	}
+
+	header, err := ParseFrameHeader(frames[0])
+	return err == nil && header.KeyFrame
+}
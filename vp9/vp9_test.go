@@ -0,0 +1,63 @@
+package vp9
+
+import (
+	"bytes"
+	"github.com/lkmio/avformat/bufio"
+	"github.com/lkmio/avformat/utils"
+	"testing"
+)
+
+// profile 0, 1280x720, BT709
+func newKeyFrame() []byte {
+	writer := &bufio.BitsWriter{Data: make([]byte, 16)}
+	writer.Write(2, FrameMarker)
+	writer.Write(2, 0) // profile_low_bit, profile_high_bit
+	writer.Write(1, 0) // show_existing_frame
+	writer.Write(1, 0) // frame_type
+	writer.Write(1, 1) // show_frame
+	writer.Write(1, 0) // error_resilient_mode
+	writer.Write(24, SyncCode)
+	writer.Write(3, uint64(ColorSpaceBT709))
+	writer.Write(1, 0) // color_range
+	writer.Write(16, 1279)
+	writer.Write(16, 719)
+	return writer.Data
+}
+
+func TestVP9(t *testing.T) {
+	keyFrame := newKeyFrame()
+	header, err := ParseFrameHeader(keyFrame)
+	if err != nil {
+		panic(err)
+	}
+
+	utils.Assert(header.KeyFrame && header.ShowFrame && header.Profile == 0 && header.BitDepth == 8)
+	utils.Assert(header.Width == 1280 && header.Height == 720 && header.ColorSpace == ColorSpaceBT709)
+	utils.Assert(header.SubsamplingX == 1 && header.SubsamplingY == 1 && header.Size == 9)
+
+	// 帧间帧: frame_type=1
+	interFrame := []byte{0x86, 0x00}
+	utils.Assert(!IsKeyFrame(interFrame))
+
+	// superframe: 2帧, 每帧大小使用1字节
+	marker := byte(0xC0 | 0x1)
+	superframe := append(append(append([]byte{}, keyFrame...), interFrame...), marker, byte(len(keyFrame)), byte(len(interFrame)), marker)
+	frames, err := SplitSuperframe(superframe)
+	if err != nil {
+		panic(err)
+	}
+
+	utils.Assert(len(frames) == 2 && bytes.Equal(frames[0], keyFrame) && bytes.Equal(frames[1], interFrame))
+	utils.Assert(IsKeyFrame(superframe))
+
+	record := NewVPCodecConfigurationRecord(&header)
+	utils.Assert(record.Level == 31 && record.ColourPrimaries == 1)
+
+	other := VPCodecConfigurationRecord{}
+	if err = other.Unmarshal(record.Marshal()); err != nil {
+		panic(err)
+	}
+
+	utils.Assert(bytes.Equal(other.Marshal(), record.Marshal()))
+	utils.Assert(other.Profile == 0 && other.BitDepth == 8 && other.ChromaSubsampling == ChromaSubsampling420Vertical)
+}
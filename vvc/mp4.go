@@ -0,0 +1,391 @@
+package vvc
+
+import (
+	"encoding/binary"
+	"fmt"
+	"github.com/lkmio/avformat/avc"
+	"github.com/lkmio/avformat/bufio"
+)
+
+/*
+ISO/IEC 14496-15:2022
+11.2.4.2 Syntax
+aligned(8) class VvcPTLRecord(num_sublayers) {
+	bit(2) reserved = 0;
+	unsigned int(6) num_bytes_constraint_info;
+	unsigned int(7) general_profile_idc;
+	unsigned int(1) general_tier_flag;
+	unsigned int(8) general_level_idc;
+	unsigned int(1) ptl_frame_only_constraint_flag;
+	unsigned int(1) ptl_multilayer_enabled_flag;
+	unsigned int(8*num_bytes_constraint_info - 2) general_constraint_info;
+	for (i=num_sublayers - 2; i >= 0; i--)
+		unsigned int(1) ptl_sublayer_level_present_flag[i];
+	for (j=num_sublayers; j<=8 && num_sublayers > 1; j++)
+		bit(1) ptl_reserved_zero_bit = 0;
+	for (i=num_sublayers-2; i >= 0; i--)
+		if (ptl_sublayer_level_present_flag[i])
+			unsigned int(8) sublayer_level_idc[i];
+	unsigned int(8) ptl_num_sub_profiles;
+	for (j=0; j < ptl_num_sub_profiles; j++)
+		unsigned int(32) general_sub_profile_idc[j];
+}
+
+aligned(8) class VvcDecoderConfigurationRecord {
+	bit(5) reserved = '11111'b;
+	unsigned int(2) LengthSizeMinusOne;
+	unsigned int(1) ptl_present_flag;
+	if (ptl_present_flag) {
+		unsigned int(9) ols_idx;
+		unsigned int(3) num_sublayers;
+		unsigned int(2) constant_frame_rate;
+		unsigned int(2) chroma_format_idc;
+		unsigned int(3) bit_depth_minus8;
+		bit(5) reserved = '11111'b;
+		VvcPTLRecord(num_sublayers) native_ptl;
+		unsigned int(16) max_picture_width;
+		unsigned int(16) max_picture_height;
+		unsigned int(16) avg_frame_rate;
+	}
+	unsigned int(8) num_of_arrays;
+	for (j=0; j < num_of_arrays; j++) {
+		unsigned int(1) array_completeness;
+		bit(2) reserved = 0;
+		unsigned int(5) NAL_unit_type;
+		if (NAL_unit_type != DCI_NUT && NAL_unit_type != OPI_NUT)
+			unsigned int(16) num_nalus;
+		for (i=0; i< num_nalus; i++) {
+			unsigned int(16) nal_unit_length;
+			bit(8*nal_unit_length) nal_unit;
+		}
+	}
+}
+
+vvcC是FullBox, Marshal/Unmarshal的数据不包含version和flags.
+*/
+
+type VvcPTLRecord struct {
+	GeneralProfileIdc            byte
+	GeneralTierFlag              byte
+	GeneralLevelIdc              byte
+	PtlFrameOnlyConstraintFlag   byte
+	PtlMultilayerEnabledFlag     byte
+	GeneralConstraintInfo        []byte // 包含ptl_frame_only_constraint_flag和ptl_multilayer_enabled_flag, 长度即num_bytes_constraint_info
+	PtlSublayerLevelPresentFlags []bool // 下标为子层id, 长度为num_sublayers-1
+	SublayerLevelIdc             []byte
+	GeneralSubProfileIdc         []uint32
+}
+
+type VvcDecoderConfigurationRecord struct {
+	LengthSizeMinusOne byte
+	PtlPresentFlag     byte
+	OlsIdx             uint16
+	NumSublayers       byte
+	ConstantFrameRate  byte
+	ChromaFormatIdc    byte
+	BitDepthMinus8     byte
+	NativePTL          VvcPTLRecord
+	MaxPictureWidth    uint16
+	MaxPictureHeight   uint16
+	AvgFrameRate       uint16
+
+	VPSList [][]byte // AnnexB格式
+	SPSList [][]byte
+	PPSList [][]byte
+}
+
+// NewVvcPTLRecord 使用sps中的profile_tier_level创建
+func NewVvcPTLRecord(ptl *ProfileTierLevel) VvcPTLRecord {
+	record := VvcPTLRecord{
+		GeneralProfileIdc:          byte(ptl.GeneralProfileIdc),
+		GeneralTierFlag:            byte(ptl.GeneralTierFlag),
+		GeneralLevelIdc:            byte(ptl.GeneralLevelIdc),
+		PtlFrameOnlyConstraintFlag: byte(ptl.PtlFrameOnlyConstraintFlag),
+		PtlMultilayerEnabledFlag:   byte(ptl.PtlMultilayerEnabledFlag),
+		GeneralConstraintInfo:      ptl.GeneralConstraintInfo,
+		GeneralSubProfileIdc:       ptl.GeneralSubProfileIdc,
+	}
+
+	record.PtlSublayerLevelPresentFlags = ptl.PtlSublayerLevelPresentFlags
+	for i, present := range ptl.PtlSublayerLevelPresentFlags {
+		if present {
+			record.SublayerLevelIdc = append(record.SublayerLevelIdc, byte(ptl.SublayerLevelIdc[i]))
+		} else {
+			record.SublayerLevelIdc = append(record.SublayerLevelIdc, 0)
+		}
+	}
+
+	return record
+}
+
+func (r *VvcPTLRecord) marshal(dst []byte, numSublayers int) []byte {
+	constraintInfo := r.GeneralConstraintInfo
+	if len(constraintInfo) == 0 {
+		// gci_present_flag = 0
+		constraintInfo = []byte{0}
+	}
+
+	dst = append(dst, byte(len(constraintInfo)&0x3F), r.GeneralProfileIdc<<1|r.GeneralTierFlag&0x1, r.GeneralLevelIdc)
+	offset := len(dst)
+	dst = append(dst, constraintInfo...)
+	dst[offset] = dst[offset]&0x3F | r.PtlFrameOnlyConstraintFlag<<7 | (r.PtlMultilayerEnabledFlag&0x1)<<6
+
+	if numSublayers > 1 {
+		var flags byte
+		for i := numSublayers - 2; i >= 0; i-- {
+			if i < len(r.PtlSublayerLevelPresentFlags) && r.PtlSublayerLevelPresentFlags[i] {
+				flags |= 1 << (7 - (numSublayers - 2 - i))
+			}
+		}
+
+		dst = append(dst, flags)
+		for i := numSublayers - 2; i >= 0; i-- {
+			if i < len(r.PtlSublayerLevelPresentFlags) && r.PtlSublayerLevelPresentFlags[i] {
+				dst = append(dst, r.SublayerLevelIdc[i])
+			}
+		}
+	}
+
+	dst = append(dst, byte(len(r.GeneralSubProfileIdc)))
+	for _, idc := range r.GeneralSubProfileIdc {
+		dst = binary.BigEndian.AppendUint32(dst, idc)
+	}
+
+	return dst
+}
+
+func (r *VvcPTLRecord) unmarshal(reader bufio.BytesReader, numSublayers int) error {
+	size, err := reader.ReadUint8()
+	if err != nil {
+		return err
+	}
+
+	profile, err := reader.ReadUint8()
+	if err != nil {
+		return err
+	}
+
+	if r.GeneralLevelIdc, err = reader.ReadUint8(); err != nil {
+		return err
+	} else if size&0x3F == 0 {
+		return fmt.Errorf("invalid num_bytes_constraint_info 0")
+	}
+
+	r.GeneralProfileIdc = profile >> 1
+	r.GeneralTierFlag = profile & 0x1
+	if r.GeneralConstraintInfo, err = reader.ReadBytes(int(size & 0x3F)); err != nil {
+		return err
+	}
+
+	r.PtlFrameOnlyConstraintFlag = r.GeneralConstraintInfo[0] >> 7
+	r.PtlMultilayerEnabledFlag = r.GeneralConstraintInfo[0] >> 6 & 0x1
+
+	r.PtlSublayerLevelPresentFlags = nil
+	r.SublayerLevelIdc = nil
+	if numSublayers > 1 {
+		flags, err := reader.ReadUint8()
+		if err != nil {
+			return err
+		}
+
+		r.PtlSublayerLevelPresentFlags = make([]bool, numSublayers-1)
+		r.SublayerLevelIdc = make([]byte, numSublayers-1)
+		for i := numSublayers - 2; i >= 0; i-- {
+			r.PtlSublayerLevelPresentFlags[i] = flags>>(7-(numSublayers-2-i))&0x1 == 1
+		}
+
+		for i := numSublayers - 2; i >= 0; i-- {
+			if !r.PtlSublayerLevelPresentFlags[i] {
+				continue
+			} else if r.SublayerLevelIdc[i], err = reader.ReadUint8(); err != nil {
+				return err
+			}
+		}
+	}
+
+	count, err := reader.ReadUint8()
+	if err != nil {
+		return err
+	}
+
+	r.GeneralSubProfileIdc = nil
+	for i := 0; i < int(count); i++ {
+		idc, err := reader.ReadUint32()
+		if err != nil {
+			return err
+		}
+
+		r.GeneralSubProfileIdc = append(r.GeneralSubProfileIdc, idc)
+	}
+
+	return nil
+}
+
+func (r *VvcDecoderConfigurationRecord) Marshal(vpsList, spsList, ppsList [][]byte) ([]byte, error) {
+	if len(spsList) == 0 {
+		return nil, fmt.Errorf("sps cannot be null")
+	}
+	if len(ppsList) == 0 {
+		return nil, fmt.Errorf("pps cannot be null")
+	}
+
+	lengthSizeMinusOne := r.LengthSizeMinusOne
+	if lengthSizeMinusOne == 0 {
+		lengthSizeMinusOne = 3
+	}
+
+	data := []byte{0xF8 | (lengthSizeMinusOne&0x3)<<1 | r.PtlPresentFlag&0x1}
+	if r.PtlPresentFlag == 1 {
+		data = binary.BigEndian.AppendUint16(data, r.OlsIdx<<7|uint16(r.NumSublayers&0x7)<<4|uint16(r.ConstantFrameRate&0x3)<<2|uint16(r.ChromaFormatIdc&0x3))
+		data = append(data, r.BitDepthMinus8<<5|0x1F)
+		data = r.NativePTL.marshal(data, int(r.NumSublayers))
+		data = binary.BigEndian.AppendUint16(data, r.MaxPictureWidth)
+		data = binary.BigEndian.AppendUint16(data, r.MaxPictureHeight)
+		data = binary.BigEndian.AppendUint16(data, r.AvgFrameRate)
+	}
+
+	var arrays [][][]byte
+	for _, list := range [][][]byte{vpsList, spsList, ppsList} {
+		if len(list) > 0 {
+			arrays = append(arrays, list)
+		}
+	}
+
+	data = append(data, byte(len(arrays)))
+	for _, list := range arrays {
+		naluType := NalUnitType(avc.RemoveStartCode(list[0]))
+		// array_completeness = 1, 所有参数集都保存在vvcC中
+		data = append(data, 0x80|byte(naluType))
+		data = binary.BigEndian.AppendUint16(data, uint16(len(list)))
+		for _, nalu := range list {
+			noStartCodeNALU := avc.RemoveStartCode(nalu)
+			data = binary.BigEndian.AppendUint16(data, uint16(len(noStartCodeNALU)))
+			data = append(data, noStartCodeNALU...)
+		}
+	}
+
+	return data, nil
+}
+
+func (r *VvcDecoderConfigurationRecord) Unmarshal(data []byte) error {
+	reader := bufio.NewBytesReader(data)
+	flags, err := reader.ReadUint8()
+	if err != nil {
+		return err
+	}
+
+	r.LengthSizeMinusOne = flags >> 1 & 0x3
+	r.PtlPresentFlag = flags & 0x1
+	if r.PtlPresentFlag == 1 {
+		value, err := reader.ReadUint16()
+		if err != nil {
+			return err
+		}
+
+		r.OlsIdx = value >> 7
+		r.NumSublayers = byte(value >> 4 & 0x7)
+		r.ConstantFrameRate = byte(value >> 2 & 0x3)
+		r.ChromaFormatIdc = byte(value & 0x3)
+
+		bitDepth, err := reader.ReadUint8()
+		if err != nil {
+			return err
+		}
+
+		r.BitDepthMinus8 = bitDepth >> 5
+		if err = r.NativePTL.unmarshal(reader, int(r.NumSublayers)); err != nil {
+			return err
+		} else if r.MaxPictureWidth, err = reader.ReadUint16(); err != nil {
+			return err
+		} else if r.MaxPictureHeight, err = reader.ReadUint16(); err != nil {
+			return err
+		} else if r.AvgFrameRate, err = reader.ReadUint16(); err != nil {
+			return err
+		}
+	}
+
+	numOfArrays, err := reader.ReadUint8()
+	if err != nil {
+		return err
+	}
+
+	r.VPSList, r.SPSList, r.PPSList = nil, nil, nil
+	for i := 0; i < int(numOfArrays); i++ {
+		value, err := reader.ReadUint8()
+		if err != nil {
+			return err
+		}
+
+		naluType := VVCNALUnitType(value & 0x1F)
+		naluCount := uint16(1)
+		if naluType != VvcNalDCI && naluType != VvcNalOPI {
+			if naluCount, err = reader.ReadUint16(); err != nil {
+				return err
+			}
+		}
+
+		for j := 0; j < int(naluCount); j++ {
+			naluLength, err := reader.ReadUint16()
+			if err != nil {
+				return err
+			}
+
+			bytes, err := reader.ReadBytes(int(naluLength))
+			if err != nil {
+				return err
+			}
+
+			//添加start code
+			nalu := make([]byte, len(bytes)+4)
+			binary.BigEndian.PutUint32(nalu, 0x1)
+			copy(nalu[4:], bytes)
+
+			switch naluType {
+			case VvcNalVPS:
+				r.VPSList = append(r.VPSList, nalu)
+			case VvcNalSPS:
+				r.SPSList = append(r.SPSList, nalu)
+			case VvcNalPPS:
+				r.PPSList = append(r.PPSList, nalu)
+			}
+		}
+	}
+
+	if len(r.SPSList) == 0 {
+		return fmt.Errorf("h266parser: no SPS found in VvcDecoderConfigurationRecord")
+	} else if len(r.PPSList) == 0 {
+		return fmt.Errorf("h266parser: no PPS found in VvcDecoderConfigurationRecord")
+	}
+
+	return nil
+}
+
+// NewVvcDecoderConfigurationRecord 使用参数集创建vvcC, vps可以为空
+func NewVvcDecoderConfigurationRecord(vps, sps, pps []byte) (*VvcDecoderConfigurationRecord, *SPS, error) {
+	spsInfo, err := ParseSPS(sps)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	record := &VvcDecoderConfigurationRecord{
+		LengthSizeMinusOne: 3,
+		NumSublayers:       byte(spsInfo.MaxSublayersMinus1 + 1),
+		ChromaFormatIdc:    byte(spsInfo.ChromaFormatIdc),
+		BitDepthMinus8:     byte(spsInfo.BitDepth - 8),
+		MaxPictureWidth:    uint16(spsInfo.PicWidthMaxInLuma),
+		MaxPictureHeight:   uint16(spsInfo.PicHeightMaxInLuma),
+		SPSList:            [][]byte{sps},
+		PPSList:            [][]byte{pps},
+	}
+
+	if vps != nil {
+		record.VPSList = [][]byte{vps}
+	}
+
+	if spsInfo.PtlDpbHrdParamsPresent {
+		record.PtlPresentFlag = 1
+		record.NativePTL = NewVvcPTLRecord(&spsInfo.PTL)
+	}
+
+	return record, &spsInfo, nil
+}
@@ -0,0 +1,325 @@
+package vvc
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/lkmio/avformat/avc"
+	"github.com/lkmio/avformat/bufio"
+)
+
+type ProfileTierLevel struct {
+	GeneralProfileIdc            int
+	GeneralTierFlag              int
+	GeneralLevelIdc              int
+	PtlFrameOnlyConstraintFlag   int
+	PtlMultilayerEnabledFlag     int
+	GeneralConstraintInfo        []byte // 从ptl_frame_only_constraint_flag开始到general_constraints_info()字节对齐结束, 与vvcC的格式一致
+	PtlSublayerLevelPresentFlags []bool // 下标为子层id
+	SublayerLevelIdc             []int
+	GeneralSubProfileIdc         []uint32
+}
+
+/*
+profile_tier_level( profileTierPresentFlag, MaxNumSubLayersMinus1 ) {
+	if( profileTierPresentFlag ) {
+		general_profile_idc	u(7)
+		general_tier_flag	u(1)
+	}
+	general_level_idc	u(8)
+	ptl_frame_only_constraint_flag	u(1)
+	ptl_multilayer_enabled_flag	u(1)
+	if( profileTierPresentFlag )
+		general_constraints_info( )
+	for( i = MaxNumSubLayersMinus1 − 1; i >= 0; i− − )
+		ptl_sublayer_level_present_flag[ i ]	u(1)
+	while( !byte_aligned( ) )
+		ptl_reserved_zero_bit	u(1)
+	for( i = MaxNumSubLayersMinus1 − 1; i >= 0; i− − )
+		if( ptl_sublayer_level_present_flag[ i ] )
+			sublayer_level_idc[ i ]	u(8)
+	if( profileTierPresentFlag ) {
+		ptl_num_sub_profiles	u(8)
+		for( i = 0; i < ptl_num_sub_profiles; i++ )
+			general_sub_profile_idc[ i ]	u(32)
+	}
+}
+*/
+
+func parsePTL(r *bufio.GolombBitReader, rbsp []byte, profileTierPresent bool, maxNumSubLayersMinus1 int) ProfileTierLevel {
+	ptl := ProfileTierLevel{}
+	if profileTierPresent {
+		ptl.GeneralProfileIdc = r.Bits(7)
+		ptl.GeneralTierFlag = r.Bits(1)
+	}
+
+	ptl.GeneralLevelIdc = r.Bits(8)
+	start := r.Offset()
+	ptl.PtlFrameOnlyConstraintFlag = r.Bits(1)
+	ptl.PtlMultilayerEnabledFlag = r.Bits(1)
+	if profileTierPresent {
+		// general_constraints_info(), 只保存原始数据
+		if gciPresent := r.Flag(); gciPresent {
+			r.Skip(71)
+			r.Skip(r.Bits(8))
+		}
+
+		r.ByteAlign()
+		// profile_tier_level()开始时字节对齐, 截取的数据是完整的字节
+		if start%8 == 0 && r.Err() == nil {
+			ptl.GeneralConstraintInfo = rbsp[start/8 : r.Offset()/8]
+		}
+	}
+
+	ptl.PtlSublayerLevelPresentFlags = make([]bool, maxNumSubLayersMinus1)
+	ptl.SublayerLevelIdc = make([]int, maxNumSubLayersMinus1+1)
+	for i := maxNumSubLayersMinus1 - 1; i >= 0; i-- {
+		ptl.PtlSublayerLevelPresentFlags[i] = r.Flag()
+	}
+
+	r.ByteAlign()
+	// 未携带的子层level等于上一层
+	ptl.SublayerLevelIdc[maxNumSubLayersMinus1] = ptl.GeneralLevelIdc
+	for i := maxNumSubLayersMinus1 - 1; i >= 0; i-- {
+		if ptl.PtlSublayerLevelPresentFlags[i] {
+			ptl.SublayerLevelIdc[i] = r.Bits(8)
+		} else {
+			ptl.SublayerLevelIdc[i] = ptl.SublayerLevelIdc[i+1]
+		}
+	}
+
+	if profileTierPresent {
+		count := r.Bits(8)
+		for i := 0; i < count && r.Err() == nil; i++ {
+			ptl.GeneralSubProfileIdc = append(ptl.GeneralSubProfileIdc, uint32(r.Bits(32)))
+		}
+	}
+
+	return ptl
+}
+
+type SPS struct {
+	SeqParameterSetID      int
+	VideoParameterSetID    int
+	MaxSublayersMinus1     int
+	ChromaFormatIdc        int
+	Log2CtuSize            int
+	PtlDpbHrdParamsPresent bool
+	PTL                    ProfileTierLevel
+	GdrEnabled             bool
+	RefPicResampling       bool
+	PicWidthMaxInLuma      int
+	PicHeightMaxInLuma     int
+	ConfWinLeftOffset      int
+	ConfWinRightOffset     int
+	ConfWinTopOffset       int
+	ConfWinBottomOffset    int
+	SubpicInfoPresent      bool
+	NumSubpics             int
+	BitDepth               int
+
+	// 裁剪后的宽高
+	Width  int
+	Height int
+}
+
+func ceilLog2(v int) int {
+	var n int
+	for 1<<n < v {
+		n++
+	}
+
+	return n
+}
+
+// ParseSPS 解析sps, 读取到sps_bitdepth_minus8为止
+func ParseSPS(sps []byte) (SPS, error) {
+	s := SPS{}
+	sps = avc.RemoveStartCode(sps)
+	if len(sps) < 3 {
+		return s, fmt.Errorf("incorrect Unit Size")
+	} else if NalUnitType(sps) != VvcNalSPS {
+		return s, fmt.Errorf("invalid sps nal unit type %d", NalUnitType(sps))
+	}
+
+	rbsp := avc.EBSP2RBSP(sps[2:])
+	r := &bufio.GolombBitReader{R: bytes.NewReader(rbsp)}
+	s.SeqParameterSetID = r.Bits(4)
+	s.VideoParameterSetID = r.Bits(4)
+	s.MaxSublayersMinus1 = r.Bits(3)
+	s.ChromaFormatIdc = r.Bits(2)
+	s.Log2CtuSize = r.Bits(2) + 5
+	if s.PtlDpbHrdParamsPresent = r.Flag(); s.PtlDpbHrdParamsPresent {
+		s.PTL = parsePTL(r, rbsp, true, s.MaxSublayersMinus1)
+	}
+
+	s.GdrEnabled = r.Flag()
+	if s.RefPicResampling = r.Flag(); s.RefPicResampling {
+		// sps_res_change_in_clvs_allowed_flag
+		r.Skip(1)
+	}
+
+	s.PicWidthMaxInLuma = r.UE()
+	s.PicHeightMaxInLuma = r.UE()
+	if conformanceWindow := r.Flag(); conformanceWindow {
+		s.ConfWinLeftOffset = r.UE()
+		s.ConfWinRightOffset = r.UE()
+		s.ConfWinTopOffset = r.UE()
+		s.ConfWinBottomOffset = r.UE()
+	}
+
+	s.NumSubpics = 1
+	if s.SubpicInfoPresent = r.Flag(); s.SubpicInfoPresent {
+		parseSubpicInfo(r, &s)
+	}
+
+	s.BitDepth = r.UE() + 8
+	if r.Err() != nil {
+		return s, fmt.Errorf("need more data")
+	}
+
+	// 裁剪窗口以色度采样为单位
+	subWidthC, subHeightC := 1, 1
+	if s.ChromaFormatIdc == 1 {
+		subWidthC, subHeightC = 2, 2
+	} else if s.ChromaFormatIdc == 2 {
+		subWidthC = 2
+	}
+
+	s.Width = s.PicWidthMaxInLuma - subWidthC*(s.ConfWinLeftOffset+s.ConfWinRightOffset)
+	s.Height = s.PicHeightMaxInLuma - subHeightC*(s.ConfWinTopOffset+s.ConfWinBottomOffset)
+	return s, nil
+}
+
+func parseSubpicInfo(r *bufio.GolombBitReader, s *SPS) {
+	numSubpicsMinus1 := r.UE()
+	s.NumSubpics = numSubpicsMinus1 + 1
+
+	var independentSubpics, sameSize bool
+	if numSubpicsMinus1 > 0 {
+		independentSubpics = r.Flag()
+		sameSize = r.Flag()
+	}
+
+	ctbSize := 1 << s.Log2CtuSize
+	widthBits := ceilLog2((s.PicWidthMaxInLuma + ctbSize - 1) / ctbSize)
+	heightBits := ceilLog2((s.PicHeightMaxInLuma + ctbSize - 1) / ctbSize)
+	for i := 0; numSubpicsMinus1 > 0 && i <= numSubpicsMinus1; i++ {
+		if !sameSize || i == 0 {
+			if i > 0 && s.PicWidthMaxInLuma > ctbSize {
+				r.Skip(widthBits)
+			}
+			if i > 0 && s.PicHeightMaxInLuma > ctbSize {
+				r.Skip(heightBits)
+			}
+			if i < numSubpicsMinus1 && s.PicWidthMaxInLuma > ctbSize {
+				r.Skip(widthBits)
+			}
+			if i < numSubpicsMinus1 && s.PicHeightMaxInLuma > ctbSize {
+				r.Skip(heightBits)
+			}
+		}
+
+		if !independentSubpics {
+			// sps_subpic_treated_as_pic_flag, sps_loop_filter_across_subpic_enabled_flag
+			r.Skip(2)
+		}
+	}
+
+	idLen := r.UE() + 1
+	if explicitlySignalled := r.Flag(); explicitlySignalled {
+		if present := r.Flag(); present {
+			r.Skip(idLen * s.NumSubpics)
+		}
+	}
+}
+
+type VPS struct {
+	VideoParameterSetID int
+	MaxLayers           int
+	MaxSublayersMinus1  int
+	LayerIDs            []int
+	PTLs                []ProfileTierLevel
+}
+
+// ParseVPS 解析vps, 读取到profile_tier_level()为止
+func ParseVPS(vps []byte) (VPS, error) {
+	v := VPS{}
+	vps = avc.RemoveStartCode(vps)
+	if len(vps) < 3 {
+		return v, fmt.Errorf("incorrect Unit Size")
+	} else if NalUnitType(vps) != VvcNalVPS {
+		return v, fmt.Errorf("invalid vps nal unit type %d", NalUnitType(vps))
+	}
+
+	rbsp := avc.EBSP2RBSP(vps[2:])
+	r := &bufio.GolombBitReader{R: bytes.NewReader(rbsp)}
+	v.VideoParameterSetID = r.Bits(4)
+	maxLayersMinus1 := r.Bits(6)
+	v.MaxLayers = maxLayersMinus1 + 1
+	v.MaxSublayersMinus1 = r.Bits(3)
+
+	defaultPtlMaxTid, allIndependentLayers := true, true
+	if maxLayersMinus1 > 0 && v.MaxSublayersMinus1 > 0 {
+		defaultPtlMaxTid = r.Flag()
+	}
+	if maxLayersMinus1 > 0 {
+		allIndependentLayers = r.Flag()
+	}
+
+	for i := 0; i <= maxLayersMinus1; i++ {
+		v.LayerIDs = append(v.LayerIDs, r.Bits(6))
+		if i > 0 && !allIndependentLayers {
+			if independent := r.Flag(); !independent {
+				maxTidRefPresent := r.Flag()
+				for j := 0; j < i; j++ {
+					if directRef := r.Flag(); maxTidRefPresent && directRef {
+						r.Skip(3)
+					}
+				}
+			}
+		}
+	}
+
+	numPtls := 1
+	if maxLayersMinus1 > 0 {
+		eachLayerIsAnOls := allIndependentLayers
+		if allIndependentLayers {
+			eachLayerIsAnOls = r.Flag()
+		}
+
+		if !eachLayerIsAnOls {
+			olsModeIdc := 2
+			if !allIndependentLayers {
+				olsModeIdc = r.Bits(2)
+			}
+
+			if olsModeIdc == 2 {
+				numOutputLayerSetsMinus2 := r.Bits(8)
+				r.Skip((numOutputLayerSetsMinus2 + 1) * v.MaxLayers)
+			}
+		}
+
+		numPtls = r.Bits(8) + 1
+	}
+
+	ptPresent := make([]bool, numPtls)
+	ptlMaxTid := make([]int, numPtls)
+	for i := 0; i < numPtls; i++ {
+		ptPresent[i] = i == 0 || r.Flag()
+		ptlMaxTid[i] = v.MaxSublayersMinus1
+		if !defaultPtlMaxTid {
+			ptlMaxTid[i] = r.Bits(3)
+		}
+	}
+
+	r.ByteAlign()
+	for i := 0; i < numPtls; i++ {
+		v.PTLs = append(v.PTLs, parsePTL(r, rbsp, ptPresent[i], ptlMaxTid[i]))
+	}
+
+	if r.Err() != nil {
+		return v, fmt.Errorf("need more data")
+	}
+
+	return v, nil
+}
@@ -0,0 +1,123 @@
+package vvc
+
+import (
+	"encoding/binary"
+	"fmt"
+	"github.com/lkmio/avformat/avc"
+)
+
+type VVCNALUnitType int
+
+// H.266 Table 5 NAL unit type codes and NAL unit type classes
+const (
+	VvcNalTrail     = VVCNALUnitType(0)
+	VvcNalSTSA      = VVCNALUnitType(1)
+	VvcNalRADL      = VVCNALUnitType(2)
+	VvcNalRASL      = VVCNALUnitType(3)
+	VvcNalRsvVCL4   = VVCNALUnitType(4)
+	VvcNalRsvVCL5   = VVCNALUnitType(5)
+	VvcNalRsvVCL6   = VVCNALUnitType(6)
+	VvcNalIdrWRADL  = VVCNALUnitType(7)
+	VvcNalIdrNLP    = VVCNALUnitType(8)
+	VvcNalCraNUT    = VVCNALUnitType(9)
+	VvcNalGdrNUT    = VVCNALUnitType(10)
+	VvcNalRsvIRAP11 = VVCNALUnitType(11)
+	VvcNalOPI       = VVCNALUnitType(12)
+	VvcNalDCI       = VVCNALUnitType(13)
+	VvcNalVPS       = VVCNALUnitType(14)
+	VvcNalSPS       = VVCNALUnitType(15)
+	VvcNalPPS       = VVCNALUnitType(16)
+	VvcNalPrefixAPS = VVCNALUnitType(17)
+	VvcNalSuffixAPS = VVCNALUnitType(18)
+	VvcNalPH        = VVCNALUnitType(19)
+	VvcNalAUD       = VVCNALUnitType(20)
+	VvcNalEOS       = VVCNALUnitType(21)
+	VvcNalEOB       = VVCNALUnitType(22)
+	VvcNalPrefixSEI = VVCNALUnitType(23)
+	VvcNalSuffixSEI = VVCNALUnitType(24)
+	VvcNalFD        = VVCNALUnitType(25)
+	VvcNalRsvNVCL26 = VVCNALUnitType(26)
+	VvcNalRsvNVCL27 = VVCNALUnitType(27)
+	VvcNalUnspec28  = VVCNALUnitType(28)
+	VvcNalUnspec29  = VVCNALUnitType(29)
+	VvcNalUnspec30  = VVCNALUnitType(30)
+	VvcNalUnspec31  = VVCNALUnitType(31)
+)
+
+/*
+nal_unit_header( ) {
+	forbidden_zero_bit	f(1)
+	nuh_reserved_zero_bit	u(1)
+	nuh_layer_id	u(6)
+	nal_unit_type	u(5)
+	nuh_temporal_id_plus1	u(3)
+}
+*/
+
+// NalUnitType 从不包含start code的nalu中读取nal_unit_type
+func NalUnitType(nalu []byte) VVCNALUnitType {
+	return VVCNALUnitType(nalu[1] >> 3)
+}
+
+// IsIRAP IDR/CRA/GDR帧可以作为随机访问点
+func (t VVCNALUnitType) IsIRAP() bool {
+	return t >= VvcNalIdrWRADL && t <= VvcNalGdrNUT
+}
+
+// ParseExtraDataFromKeyNALU 从关键帧中解析出vps/sps/pps, vps是可选的
+func ParseExtraDataFromKeyNALU(data []byte) ([]byte, []byte, []byte, error) {
+	var vps []byte
+	var sps []byte
+	var pps []byte
+
+	avc.SplitNalU(data, func(nalu []byte) {
+		noStartCodeNALU := avc.RemoveStartCode(nalu)
+		if len(noStartCodeNALU) < 2 {
+			return
+		}
+
+		var dst *[]byte
+		switch NalUnitType(noStartCodeNALU) {
+		case VvcNalVPS:
+			dst = &vps
+		case VvcNalSPS:
+			dst = &sps
+		case VvcNalPPS:
+			dst = &pps
+		default:
+			return
+		}
+
+		*dst = make([]byte, 4+len(noStartCodeNALU))
+		binary.BigEndian.PutUint32(*dst, 0x1)
+		copy((*dst)[4:], noStartCodeNALU)
+	})
+
+	if sps == nil || pps == nil {
+		return nil, nil, nil, fmt.Errorf("not find extra data for H266")
+	}
+	return vps, sps, pps, nil
+}
+
+func IsKeyFrame(p []byte) bool {
+	index := 0
+	for {
+		n, _ := avc.FindStartCode(p[index:])
+		if n < 0 || index+n+1 >= len(p) {
+			return false
+		}
+
+		index += n
+		type_ := NalUnitType(p[index:])
+		if type_.IsIRAP() {
+			return true
+		}
+
+		switch type_ {
+		case VvcNalOPI, VvcNalDCI, VvcNalVPS, VvcNalSPS, VvcNalPPS, VvcNalPrefixAPS, VvcNalPH, VvcNalAUD, VvcNalPrefixSEI:
+			break
+		default:
+			return false
+		}
+	}
+}
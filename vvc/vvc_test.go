@@ -0,0 +1,102 @@
+package vvc
+
+import (
+	"bytes"
+	"github.com/lkmio/avformat/avc"
+	"github.com/lkmio/avformat/bufio"
+	"github.com/lkmio/avformat/utils"
+	"testing"
+)
+
+// 1920x1080, main10, level 5.1, 2个子层
+func newSPS() []byte {
+	writer := &bufio.BitsWriter{Data: make([]byte, 32)}
+	writer.Write(4, 0) // sps_seq_parameter_set_id
+	writer.Write(4, 0) // sps_video_parameter_set_id
+	writer.Write(3, 1) // sps_max_sublayers_minus1
+	writer.Write(2, 1) // sps_chroma_format_idc
+	writer.Write(2, 2) // sps_log2_ctu_size_minus5
+	writer.Write(1, 1) // sps_ptl_dpb_hrd_params_present_flag
+	// profile_tier_level
+	writer.Write(7, 1)  // general_profile_idc
+	writer.Write(1, 0)  // general_tier_flag
+	writer.Write(8, 83) // general_level_idc
+	writer.Write(1, 1)  // ptl_frame_only_constraint_flag
+	writer.Write(1, 0)  // ptl_multilayer_enabled_flag
+	writer.Write(1, 1)  // gci_present_flag
+	writer.Write(71, 0)
+	writer.Write(8, 0) // gci_num_additional_bits
+	writer.Seek(8 - writer.Offset%8)
+	writer.Write(1, 1) // ptl_sublayer_level_present_flag[0]
+	writer.Seek(8 - writer.Offset%8)
+	writer.Write(8, 80) // sublayer_level_idc[0]
+	writer.Write(8, 1)  // ptl_num_sub_profiles
+	writer.Write(32, 0x12345678)
+	writer.Write(1, 0) // sps_gdr_enabled_flag
+	writer.Write(1, 0) // sps_ref_pic_resampling_enabled_flag
+	writer.WriteUE(1920)
+	writer.WriteUE(1088)
+	writer.Write(1, 1) // sps_conformance_window_flag
+	writer.WriteUE(0)
+	writer.WriteUE(0)
+	writer.WriteUE(0)
+	writer.WriteUE(4)
+	writer.Write(1, 0) // sps_subpic_info_present_flag
+	writer.WriteUE(2)  // sps_bitdepth_minus8
+	return append([]byte{0, 0, 0, 1, 0x00, byte(VvcNalSPS)<<3 | 1}, avc.RBSP2EBSP(writer.WriteTrailingBits())...)
+}
+
+func TestVVC(t *testing.T) {
+	sps := newSPS()
+	pps := []byte{0, 0, 0, 1, 0x00, byte(VvcNalPPS)<<3 | 1, 0x80}
+	idr := []byte{0, 0, 0, 1, 0x00, byte(VvcNalIdrNLP)<<3 | 1, 0x84, 0x10}
+	trail := []byte{0, 0, 0, 1, 0x00, byte(VvcNalTrail)<<3 | 1, 0x84, 0x10}
+
+	keyFrame := append(append(append([]byte{}, sps...), pps...), idr...)
+	utils.Assert(IsKeyFrame(keyFrame))
+	utils.Assert(!IsKeyFrame(trail))
+
+	vps, sps2, pps2, err := ParseExtraDataFromKeyNALU(keyFrame)
+	if err != nil {
+		panic(err)
+	}
+
+	utils.Assert(vps == nil && bytes.Equal(sps2, sps) && bytes.Equal(pps2, pps))
+
+	info, err := ParseSPS(sps)
+	if err != nil {
+		panic(err)
+	}
+
+	utils.Assert(info.Width == 1920 && info.Height == 1080 && info.BitDepth == 10 && info.Log2CtuSize == 7)
+	utils.Assert(info.PTL.GeneralProfileIdc == 1 && info.PTL.GeneralLevelIdc == 83 && info.PTL.PtlFrameOnlyConstraintFlag == 1)
+	utils.Assert(len(info.PTL.GeneralConstraintInfo) == 11 && info.PTL.SublayerLevelIdc[0] == 80)
+	utils.Assert(len(info.PTL.GeneralSubProfileIdc) == 1 && info.PTL.GeneralSubProfileIdc[0] == 0x12345678)
+
+	record, _, err := NewVvcDecoderConfigurationRecord(nil, sps, pps)
+	if err != nil {
+		panic(err)
+	}
+
+	data, err := record.Marshal(record.VPSList, record.SPSList, record.PPSList)
+	if err != nil {
+		panic(err)
+	}
+
+	other := VvcDecoderConfigurationRecord{}
+	if err = other.Unmarshal(data); err != nil {
+		panic(err)
+	}
+
+	utils.Assert(other.PtlPresentFlag == 1 && other.NumSublayers == 2 && other.BitDepthMinus8 == 2 && other.ChromaFormatIdc == 1)
+	utils.Assert(other.MaxPictureWidth == 1920 && other.MaxPictureHeight == 1088 && other.LengthSizeMinusOne == 3)
+	utils.Assert(other.NativePTL.GeneralLevelIdc == 83 && other.NativePTL.PtlSublayerLevelPresentFlags[0] && other.NativePTL.SublayerLevelIdc[0] == 80)
+	utils.Assert(bytes.Equal(avc.RemoveStartCode(other.SPSList[0]), avc.RemoveStartCode(sps)))
+
+	data2, err := other.Marshal(other.VPSList, other.SPSList, other.PPSList)
+	if err != nil {
+		panic(err)
+	}
+
+	utils.Assert(bytes.Equal(data, data2))
+}